@@ -0,0 +1,100 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Snapshot is one GBFS document as fetched from the operator
+type Snapshot struct {
+	Feed      string
+	URL       string
+	FetchedAt time.Time
+	Hash      string // hex SHA-256 of Body, which identifies the content
+	Body      []byte // uncompressed, only set by Put and Load
+}
+
+// Store is a raw archive of fetched GBFS documents. Contents are compressed
+// and addressed by hash, so identical documents are only stored once.
+type Store interface {
+	// Put archives snapshot, filling in its Hash
+	Put(ctx context.Context, snapshot *Snapshot) error
+
+	// List returns the snapshots of feed fetched within [from, to), oldest
+	// first, without their Body
+	List(ctx context.Context, feed string, from time.Time, to time.Time) ([]Snapshot, error)
+
+	// Load returns the content of the document with the given hash
+	Load(ctx context.Context, hash string) ([]byte, error)
+}
+
+// Hash returns the content address of body
+func Hash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func compress(body []byte) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	writer := gzip.NewWriter(&buffer)
+
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func decompress(content []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// verify checks that body matches the hash it was loaded by
+func verify(hash string, body []byte) error {
+	if Hash(body) != hash {
+		return fmt.Errorf("archived document %s is corrupt", hash)
+	}
+
+	return nil
+}
+
+// Merge lists the snapshots of several feeds as a single timeline, oldest
+// first. Snapshots fetched at the same time keep the order of feeds.
+func Merge(ctx context.Context, store Store, feeds []string, from time.Time, to time.Time) ([]Snapshot, error) {
+	snapshots := []Snapshot{}
+
+	for _, feed := range feeds {
+		listed, err := store.List(ctx, feed, from, to)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s snapshots: %w", feed, err)
+		}
+
+		snapshots = append(snapshots, listed...)
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].FetchedAt.Before(snapshots[j].FetchedAt)
+	})
+
+	return snapshots, nil
+}
@@ -0,0 +1,116 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DatabaseStore archives documents in the raw_document and raw_snapshot
+// tables
+type DatabaseStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewDatabaseStore(pool *pgxpool.Pool) *DatabaseStore {
+	return &DatabaseStore{pool: pool}
+}
+
+func (s *DatabaseStore) Put(ctx context.Context, snapshot *Snapshot) error {
+	snapshot.Hash = Hash(snapshot.Body)
+	content, err := compress(snapshot.Body)
+
+	if err != nil {
+		return fmt.Errorf("failed to compress document: %w", err)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO "public"."raw_document" ("hash", "content") VALUES ($1, $2) ON CONFLICT ("hash") DO NOTHING`,
+		snapshot.Hash,
+		content,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to archive document: %w", err)
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO "public"."raw_snapshot" ("feed", "fetched_at", "url", "hash") VALUES ($1, $2, $3, $4)
+		ON CONFLICT ("feed", "fetched_at") DO NOTHING`,
+		snapshot.Feed,
+		snapshot.FetchedAt,
+		snapshot.URL,
+		snapshot.Hash,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to archive snapshot: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *DatabaseStore) List(ctx context.Context, feed string, from time.Time, to time.Time) ([]Snapshot, error) {
+	rows, err := s.pool.Query(
+		ctx,
+		`SELECT "fetched_at", "url", "hash" FROM "public"."raw_snapshot"
+		WHERE "feed" = $1 AND "fetched_at" >= $2 AND "fetched_at" < $3
+		ORDER BY "fetched_at"`,
+		feed,
+		from,
+		to,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	defer rows.Close()
+
+	snapshots := []Snapshot{}
+
+	for rows.Next() {
+		snapshot := Snapshot{Feed: feed}
+
+		if err := rows.Scan(&snapshot.FetchedAt, &snapshot.URL, &snapshot.Hash); err != nil {
+			return nil, fmt.Errorf("failed to list snapshots: %w", err)
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+func (s *DatabaseStore) Load(ctx context.Context, hash string) ([]byte, error) {
+	var content []byte
+	err := s.pool.QueryRow(ctx, `SELECT "content" FROM "public"."raw_document" WHERE "hash" = $1`, hash).Scan(&content)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archived document: %w", err)
+	}
+
+	body, err := decompress(content)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress archived document: %w", err)
+	}
+
+	return body, verify(hash, body)
+}
@@ -0,0 +1,168 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskStore archives documents in a directory:
+//
+//	objects/ab/abcdef….json.gz  gzipped document, named by hash
+//	index/<feed>/2006-01-02.log  one "<unix nanoseconds> <hash> <url>" line per fetch, by UTC day
+type DiskStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewDiskStore(dir string) (*DiskStore, error) {
+	for _, sub := range []string{"objects", "index"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create archive directory: %w", err)
+		}
+	}
+
+	return &DiskStore{dir: dir}, nil
+}
+
+func (s *DiskStore) objectPath(hash string) string {
+	return filepath.Join(s.dir, "objects", hash[:2], hash+".json.gz")
+}
+
+func (s *DiskStore) indexPath(feed string, day time.Time) string {
+	return filepath.Join(s.dir, "index", feed, day.UTC().Format(time.DateOnly)+".log")
+}
+
+func (s *DiskStore) Put(ctx context.Context, snapshot *Snapshot) error {
+	snapshot.Hash = Hash(snapshot.Body)
+	path := s.objectPath(snapshot.Hash)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		content, err := compress(snapshot.Body)
+
+		if err != nil {
+			return fmt.Errorf("failed to compress document: %w", err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create archive directory: %w", err)
+		}
+
+		// Write then rename, so a crash never leaves a truncated object
+		// behind a valid name
+		if err := os.WriteFile(path+".tmp", content, 0o644); err != nil {
+			return fmt.Errorf("failed to write document: %w", err)
+		}
+
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return fmt.Errorf("failed to write document: %w", err)
+		}
+	}
+
+	index := s.indexPath(snapshot.Feed, snapshot.FetchedAt)
+
+	if err := os.MkdirAll(filepath.Dir(index), 0o755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	file, err := os.OpenFile(index, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+
+	if err != nil {
+		return fmt.Errorf("failed to open archive index: %w", err)
+	}
+
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%d %s %s\n", snapshot.FetchedAt.UnixNano(), snapshot.Hash, snapshot.URL)
+
+	if err != nil {
+		return fmt.Errorf("failed to write archive index: %w", err)
+	}
+
+	return file.Close()
+}
+
+func (s *DiskStore) List(ctx context.Context, feed string, from time.Time, to time.Time) ([]Snapshot, error) {
+	snapshots := []Snapshot{}
+	start := from.UTC().Truncate(24 * time.Hour)
+
+	for day := start; day.Before(to); day = day.Add(24 * time.Hour) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		file, err := os.Open(s.indexPath(feed, day))
+
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive index: %w", err)
+		}
+
+		scanner := bufio.NewScanner(file)
+
+		for scanner.Scan() {
+			fields := strings.SplitN(scanner.Text(), " ", 3)
+
+			if len(fields) != 3 {
+				continue
+			}
+
+			nanoseconds, err := strconv.ParseInt(fields[0], 10, 64)
+
+			if err != nil {
+				continue
+			}
+
+			fetchedAt := time.Unix(0, nanoseconds)
+
+			if fetchedAt.Before(from) || !fetchedAt.Before(to) {
+				continue
+			}
+
+			snapshots = append(snapshots, Snapshot{Feed: feed, URL: fields[2], FetchedAt: fetchedAt, Hash: fields[1]})
+		}
+
+		err = scanner.Err()
+		file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive index: %w", err)
+		}
+	}
+
+	return snapshots, nil
+}
+
+func (s *DiskStore) Load(ctx context.Context, hash string) ([]byte, error) {
+	if len(hash) < 2 || strings.ContainsAny(hash, `/\.`) {
+		return nil, fmt.Errorf("invalid document hash %q", hash)
+	}
+
+	content, err := os.ReadFile(s.objectPath(hash))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archived document: %w", err)
+	}
+
+	body, err := decompress(content)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress archived document: %w", err)
+	}
+
+	return body, verify(hash, body)
+}
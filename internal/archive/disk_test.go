@@ -0,0 +1,67 @@
+package archive
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDiskStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewDiskStore(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 6, 1, 23, 59, 50, 0, time.UTC)
+	bodies := []string{`{"ttl":10}`, `{"ttl":10}`, `{"ttl":20}`}
+
+	for i, body := range bodies {
+		snapshot := Snapshot{
+			Feed:      "station_status",
+			URL:       "https://example.com/station_status.json",
+			FetchedAt: start.Add(time.Duration(i) * 10 * time.Second),
+			Body:      []byte(body),
+		}
+
+		if err := store.Put(ctx, &snapshot); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	snapshots, err := store.List(ctx, "station_status", start, start.Add(time.Hour))
+
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(snapshots) != 3 {
+		t.Fatalf("List() returned %d snapshots across midnight, want 3", len(snapshots))
+	}
+
+	if snapshots[0].Hash != snapshots[1].Hash || snapshots[1].Hash == snapshots[2].Hash {
+		t.Errorf("identical documents should share a hash, others should not: %+v", snapshots)
+	}
+
+	if !snapshots[2].FetchedAt.Equal(start.Add(20 * time.Second)) {
+		t.Errorf("FetchedAt = %s, want %s", snapshots[2].FetchedAt, start.Add(20*time.Second))
+	}
+
+	body, err := store.Load(ctx, snapshots[2].Hash)
+
+	if err != nil || string(body) != bodies[2] {
+		t.Errorf("Load() = %q, %v, want %q", body, err, bodies[2])
+	}
+
+	// The upper bound is exclusive
+	snapshots, err = store.List(ctx, "station_status", start, start.Add(10*time.Second))
+
+	if err != nil || len(snapshots) != 1 {
+		t.Errorf("List() = %d snapshots, %v, want 1", len(snapshots), err)
+	}
+
+	if _, err := store.Load(ctx, "../../etc/passwd"); err == nil {
+		t.Error("Load() accepted a path as hash")
+	}
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/backfill"
+	"github.com/ngc7293/hixi/internal/sync"
+)
+
+func backfillCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("backfill", "status|trips <path>", `Import historical data into a new deployment.
+
+  status  station_status JSON documents (a directory, possibly a raw archive
+          directory, or a .tar/.tar.gz tarball), stored as availability
+          history. Only snapshots older than the existing history are imported.
+  trips   BIXI trip history CSV files (a file, directory or tarball), stored
+          as departures and arrivals per station. Run after station_information
+          has been synced, so that stations can be matched.
+
+Imported files are recorded, so an interrupted import can be run again.`)
+	batch := flags.Int("batch", 100, "status documents imported per transaction")
+	migrate := flags.Bool("migrate", true, "apply pending database migrations first")
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if err := parseFlags(flags, args); errors.Is(err, errHelp) {
+			return err
+		}
+
+		flags.Usage()
+		return errUsage
+	}
+
+	kind := args[0]
+
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 || (kind != "status" && kind != "trips") || *batch < 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	err = cfg.ValidateDatabase()
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	normalizer, err := sync.NormalizerFor(cfg.GBFS.System)
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: gbfs.system: %w", err)
+	}
+
+	pool, err := connect(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer pool.Close()
+
+	if *migrate {
+		err = runDatabaseMigrations(ctx, pool, cfg.Paths.SchemaFS())
+
+		if err != nil {
+			return fmt.Errorf("failed to run database migrations: %w", err)
+		}
+	}
+
+	importer := backfill.New(pool, normalizer, *batch)
+
+	var summary *backfill.Summary
+
+	if kind == "status" {
+		summary, err = importer.ImportStatus(ctx, flags.Arg(0))
+	} else {
+		summary, err = importer.ImportTrips(ctx, flags.Arg(0))
+	}
+
+	if summary != nil {
+		fmt.Fprintf(
+			os.Stderr,
+			"imported %d files (%d rows) in %s, skipped %d already imported, %d invalid, %d overlapping existing history, %d unmatched trip ends\n",
+			summary.Files, summary.Rows, summary.Duration.Round(time.Second), summary.Skipped, summary.Invalid, summary.Outside, summary.Unknown,
+		)
+	}
+
+	return err
+}
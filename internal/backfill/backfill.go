@@ -0,0 +1,153 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ngc7293/hixi/internal/sync"
+)
+
+// bucketWidth is the width of the historical_station_availability buckets
+const bucketWidth = 5 * time.Minute
+
+// Summary reports what an import did
+type Summary struct {
+	Files    int // files imported
+	Skipped  int // files imported by a previous run
+	Rows     int64
+	Invalid  int // files which could not be parsed
+	Outside  int // snapshots not imported because they overlap existing history
+	Unknown  int // trip ends whose station could not be matched
+	Duration time.Duration
+}
+
+// Importer loads historical data into the database. Files are imported in
+// batches, each in its own transaction, and recorded by content hash so that
+// an interrupted import can simply be run again.
+type Importer struct {
+	pool       *pgxpool.Pool
+	normalizer sync.Normalizer
+	batchSize  int
+
+	stations map[string]int64 // by GBFS station_id
+}
+
+func New(pool *pgxpool.Pool, normalizer sync.Normalizer, batchSize int) *Importer {
+	return &Importer{
+		pool:       pool,
+		normalizer: normalizer,
+		batchSize:  max(batchSize, 1),
+		stations:   map[string]int64{},
+	}
+}
+
+// imported reports whether a file with the given content hash was imported
+// by a previous run
+func (i *Importer) imported(ctx context.Context, hash string) (bool, error) {
+	var found bool
+	err := i.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM "public"."backfill_file" WHERE "hash" = $1)`, hash).Scan(&found)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to check imported files: %w", err)
+	}
+
+	return found, nil
+}
+
+func markImported(ctx context.Context, tx pgx.Tx, hash string, kind string, name string, rows int64) error {
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO "public"."backfill_file" ("hash", "kind", "name", "rows", "imported_at") VALUES ($1, $2, $3, $4, NOW())`,
+		hash,
+		kind,
+		name,
+		rows,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to record imported file: %w", err)
+	}
+
+	return nil
+}
+
+// stationID returns the id of the station with the given GBFS station_id,
+// creating it if it was never seen. Stations looked up within tx are added to
+// pending, to be cached once tx is committed.
+func (i *Importer) stationID(ctx context.Context, tx pgx.Tx, externalID string, pending map[string]int64) (int64, error) {
+	if id, ok := i.stations[externalID]; ok {
+		return id, nil
+	}
+
+	if id, ok := pending[externalID]; ok {
+		return id, nil
+	}
+
+	var id int64
+	err := tx.QueryRow(
+		ctx,
+		`INSERT INTO "public"."station" ("external_id") VALUES ($1)
+		ON CONFLICT ("external_id") DO UPDATE SET "external_id" = excluded."external_id"
+		RETURNING "id"`,
+		externalID,
+	).Scan(&id)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to getsert station: %w", err)
+	}
+
+	pending[externalID] = id
+	return id, nil
+}
+
+// historyStart returns the time from which the database already holds
+// availability history. Backfilled snapshots must be older: refreshing the
+// continuous aggregate over a range whose raw data was dropped by the
+// retention policy would erase the history of that range.
+func (i *Importer) historyStart(ctx context.Context) (time.Time, error) {
+	var start *time.Time
+	err := i.pool.QueryRow(ctx, `
+		SELECT LEAST(
+			(SELECT MIN("time_bucket") FROM "public"."historical_station_availability"),
+			(SELECT MIN("time") FROM "public"."live_station_availability")
+		)`,
+	).Scan(&start)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to find start of history: %w", err)
+	}
+
+	if start == nil {
+		return time.Now(), nil
+	}
+
+	return start.Truncate(bucketWidth), nil
+}
+
+// refreshAggregate materializes [from, to] into historical_station_availability.
+// This must happen right after inserting backfilled rows: the aggregate
+// policy only refreshes recent buckets, and the retention policy drops raw
+// rows older than a day on its next run.
+func (i *Importer) refreshAggregate(ctx context.Context, from time.Time, to time.Time) error {
+	_, err := i.pool.Exec(
+		ctx,
+		`CALL REFRESH_CONTINUOUS_AGGREGATE('public.historical_station_availability'::REGCLASS, $1::TIMESTAMPTZ, $2::TIMESTAMPTZ)`,
+		from.Truncate(bucketWidth),
+		to.Truncate(bucketWidth).Add(bucketWidth),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to refresh historical availability: %w", err)
+	}
+
+	return nil
+}
+
+func progress(kind string, summary *Summary, start time.Time) {
+	slog.Info("backfill progress", "kind", kind, "files", summary.Files, "skipped", summary.Skipped, "rows", summary.Rows, "elapsed", time.Since(start).Round(time.Second))
+}
@@ -0,0 +1,134 @@
+package backfill
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// readSources calls fn with the name and content of every file with one of
+// the given extensions at path, which is either a single file, a directory
+// (searched recursively) or a tarball (.tar, .tar.gz or .tgz). Files are
+// visited in lexical order, except within tarballs which are read in archive
+// order.
+func readSources(path string, extensions []string, fn func(name string, content []byte) error) error {
+	matches := func(name string) bool {
+		for _, extension := range extensions {
+			if strings.HasSuffix(strings.ToLower(name), extension) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		names := []string{}
+
+		err := filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !entry.IsDir() && matches(name) {
+				names = append(names, name)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			content, err := os.ReadFile(name)
+
+			if err != nil {
+				return err
+			}
+
+			if err := fn(name, content); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	lower := strings.ToLower(path)
+
+	if !strings.HasSuffix(lower, ".tar") && !strings.HasSuffix(lower, ".tar.gz") && !strings.HasSuffix(lower, ".tgz") {
+		content, err := os.ReadFile(path)
+
+		if err != nil {
+			return err
+		}
+
+		return fn(path, content)
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	if !strings.HasSuffix(lower, ".tar") {
+		gz, err := gzip.NewReader(file)
+
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+
+		defer gz.Close()
+		reader = gz
+	}
+
+	archive := tar.NewReader(reader)
+
+	for {
+		header, err := archive.Next()
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !matches(header.Name) {
+			continue
+		}
+
+		content, err := io.ReadAll(archive)
+
+		if err != nil {
+			return fmt.Errorf("failed to read %s from %s: %w", header.Name, path, err)
+		}
+
+		if err := fn(path+":"+header.Name, content); err != nil {
+			return err
+		}
+	}
+}
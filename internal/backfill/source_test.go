@@ -0,0 +1,102 @@
+package backfill
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ngc7293/hixi/internal/sync"
+)
+
+func TestReadSources(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"b/2.json":   `{"n": 2}`,
+		"a/1.json":   `{"n": 1}`,
+		"notes.txt":  "ignored",
+		"c/3.JSON":   `{"n": 3}`,
+		"d/4.json.x": "ignored",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, "dump", name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tarball := filepath.Join(dir, "dump.tar.gz")
+	file, err := os.Create(tarball)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+
+	for _, name := range []string{"a/1.json", "notes.txt", "b/2.json"} {
+		content := files[name]
+		archive.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		archive.Write([]byte(content))
+	}
+
+	archive.Close()
+	gz.Close()
+	file.Close()
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"directory", filepath.Join(dir, "dump"), []string{`{"n": 1}`, `{"n": 2}`, `{"n": 3}`}},
+		{"tarball", tarball, []string{`{"n": 1}`, `{"n": 2}`}},
+		{"single file", filepath.Join(dir, "dump", "b", "2.json"), []string{`{"n": 2}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+
+			err := readSources(tt.path, []string{".json"}, func(name string, content []byte) error {
+				if !strings.HasSuffix(strings.ToLower(name), ".json") {
+					t.Errorf("unexpected file %s", name)
+				}
+
+				got = append(got, string(content))
+				return nil
+			})
+
+			if err != nil {
+				t.Fatalf("readSources() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readSources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStatusDocument(t *testing.T) {
+	normalizer, _ := sync.NormalizerFor("bixi")
+	document, err := parseStatusDocument("status.json", []byte(`{"last_updated": 1500000000, "ttl": 10, "data": {"stations": [{"station_id": "1"}]}}`), normalizer)
+
+	if err != nil || document.LastUpdated != 1500000000 || len(document.Data) != 1 {
+		t.Errorf("parseStatusDocument() = %+v, %v", document, err)
+	}
+
+	if _, err := parseStatusDocument("status.json", []byte(`{"data": {"stations": []}}`), normalizer); err == nil {
+		t.Error("parseStatusDocument() accepted a document without last_updated")
+	}
+}
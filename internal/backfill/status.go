@@ -0,0 +1,252 @@
+package backfill
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ngc7293/hixi/internal/archive"
+	"github.com/ngc7293/hixi/internal/sync"
+	"github.com/ngc7293/hixi/pkg/gbfs"
+)
+
+// statusFile is a station_status document waiting to be imported
+type statusFile struct {
+	name     string
+	hash     string
+	time     time.Time
+	stations []sync.StationStatus
+}
+
+// parseStatusDocument decodes a station_status document through normalizer,
+// which may be gzipped (as in a raw archive directory)
+func parseStatusDocument(name string, content []byte, normalizer sync.Normalizer) (*gbfs.GBFSDocument[[]sync.StationStatus], error) {
+	if strings.HasSuffix(strings.ToLower(name), ".gz") {
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer reader.Close()
+
+		content, err = io.ReadAll(reader)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	document := gbfs.GBFSDocument[json.RawMessage]{}
+
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	if document.LastUpdated == 0 {
+		return nil, fmt.Errorf("missing last_updated")
+	}
+
+	stations, err := normalizer.StationStatus(document.Data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &gbfs.GBFSDocument[[]sync.StationStatus]{LastUpdated: document.LastUpdated, TTL: document.TTL, Data: stations}, nil
+}
+
+// ImportStatus imports station_status documents into
+// live_station_availability, timestamped with their last_updated, then
+// materializes them into historical_station_availability. Only snapshots
+// older than the existing history are imported.
+func (i *Importer) ImportStatus(ctx context.Context, path string) (*Summary, error) {
+	start := time.Now()
+	summary := &Summary{}
+
+	cutoff, err := i.historyStart(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("importing station_status snapshots", "path", path, "before", cutoff)
+
+	batch := []statusFile{}
+	batched := map[string]bool{}
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		rows, err := i.insertStatus(ctx, batch)
+
+		if err != nil {
+			return err
+		}
+
+		summary.Files += len(batch)
+		summary.Rows += rows
+		progress("status", summary, start)
+
+		batch = batch[:0]
+		clear(batched)
+		return nil
+	}
+
+	err = readSources(path, []string{".json", ".json.gz"}, func(name string, content []byte) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		hash := archive.Hash(content)
+		done, err := i.imported(ctx, hash)
+
+		if err != nil {
+			return err
+		}
+
+		if done || batched[hash] {
+			summary.Skipped++
+			return nil
+		}
+
+		document, err := parseStatusDocument(name, content, i.normalizer)
+
+		if err != nil {
+			slog.Warn("skipping invalid station_status document", "file", name, "error", err)
+			summary.Invalid++
+			return nil
+		}
+
+		updated := time.Unix(document.LastUpdated, 0)
+
+		if !updated.Before(cutoff) {
+			summary.Outside++
+			return nil
+		}
+
+		batch = append(batch, statusFile{name: name, hash: hash, time: updated, stations: document.Data})
+		batched[hash] = true
+
+		if len(batch) >= i.batchSize {
+			return flush()
+		}
+
+		return nil
+	})
+
+	if err == nil {
+		err = flush()
+	}
+
+	summary.Duration = time.Since(start)
+	return summary, err
+}
+
+// insertStatus imports one batch of documents in a single transaction
+func (i *Importer) insertStatus(ctx context.Context, batch []statusFile) (int64, error) {
+	// Let an interrupted batch complete rather than leave the aggregate
+	// unrefreshed
+	ctx = context.WithoutCancel(ctx)
+
+	tx, err := i.pool.Begin(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	pending := map[string]int64{}
+	rows := [][]any{}
+	from, to := batch[0].time, batch[0].time
+
+	for _, file := range batch {
+		before := len(rows)
+
+		for _, station := range file.stations {
+			id, err := i.stationID(ctx, tx, station.StationID, pending)
+
+			if err != nil {
+				return 0, err
+			}
+
+			rows = append(rows, []any{
+				file.time,
+				id,
+				station.NumBikesAvailable,
+				station.NumBikesDisabled,
+				station.EbikesAvailable,
+				station.EbikesDisabled,
+				station.NumDocksAvailable,
+				station.NumDocksDisabled,
+			})
+		}
+
+		err = markImported(ctx, tx, file.hash, "status", file.name, int64(len(rows)-before))
+
+		if err != nil {
+			return 0, err
+		}
+
+		from, to = minTime(from, file.time), maxTime(to, file.time)
+	}
+
+	count, err := tx.CopyFrom(
+		ctx,
+		pgx.Identifier{"public", "live_station_availability"},
+		[]string{
+			"time",
+			"station_id",
+			"bikes_available",
+			"bikes_disabled",
+			"ebikes_available",
+			"ebikes_disabled",
+			"docks_available",
+			"docks_disabled",
+		},
+		pgx.CopyFromRows(rows),
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert station availability: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for externalID, id := range pending {
+		i.stations[externalID] = id
+	}
+
+	return count, i.refreshAggregate(ctx, from, to)
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+
+	return a
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+
+	return a
+}
@@ -0,0 +1,371 @@
+package backfill
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // trip histories are in Montréal local time
+
+	"github.com/ngc7293/hixi/internal/archive"
+)
+
+// flowBatchSize is the number of station_flow rows upserted per statement
+const flowBatchSize = 5000
+
+type flowKey struct {
+	station int64
+	time    time.Time
+}
+
+type flow struct {
+	departures int32
+	arrivals   int32
+}
+
+// stationIndex matches the station references found in trip histories:
+// station codes (the GBFS short_name) until 2021, names since 2022
+type stationIndex struct {
+	byCode map[string]int64
+	byName map[string]int64
+}
+
+func normalizeStationName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func (i *Importer) loadStationIndex(ctx context.Context) (*stationIndex, error) {
+	index := &stationIndex{byCode: map[string]int64{}, byName: map[string]int64{}}
+	rows, err := i.pool.Query(ctx, `SELECT "id", "short_name", "name" FROM "public"."station"`)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stations: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var code, name *string
+
+		if err := rows.Scan(&id, &code, &name); err != nil {
+			return nil, fmt.Errorf("failed to query stations: %w", err)
+		}
+
+		if code != nil {
+			index.byCode[*code] = id
+		}
+
+		if name != nil {
+			index.byName[normalizeStationName(*name)] = id
+		}
+	}
+
+	return index, rows.Err()
+}
+
+// tripColumns locates the columns of one of BIXI's trip history formats
+type tripColumns struct {
+	start, startStation, end, endStation int
+	byName                               bool // stations are referenced by name rather than code
+	milliseconds                         bool // times are unix milliseconds rather than local time
+}
+
+func detectTripColumns(header []string) (*tripColumns, error) {
+	position := map[string]int{}
+
+	for i, name := range header {
+		position[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	find := func(names ...string) (*tripColumns, bool) {
+		indices := []int{}
+
+		for _, name := range names {
+			index, ok := position[name]
+
+			if !ok {
+				return nil, false
+			}
+
+			indices = append(indices, index)
+		}
+
+		return &tripColumns{start: indices[0], startStation: indices[1], end: indices[2], endStation: indices[3]}, true
+	}
+
+	// 2014 to 2021
+	if columns, ok := find("start_date", "start_station_code", "end_date", "end_station_code"); ok {
+		return columns, nil
+	}
+
+	// 2022 onwards
+	if columns, ok := find("starttimems", "startstationname", "endtimems", "endstationname"); ok {
+		columns.byName, columns.milliseconds = true, true
+		return columns, nil
+	}
+
+	return nil, errors.New("unrecognized trip history header")
+}
+
+func (c *tripColumns) parseTime(value string, location *time.Location) (time.Time, error) {
+	if c.milliseconds {
+		milliseconds, err := strconv.ParseFloat(value, 64)
+
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return time.UnixMilli(int64(milliseconds)), nil
+	}
+
+	for _, layout := range []string{time.DateTime, "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+func (c *tripColumns) station(value string, stations *stationIndex) (int64, bool) {
+	if c.byName {
+		id, ok := stations.byName[normalizeStationName(value)]
+		return id, ok
+	}
+
+	id, ok := stations.byCode[strings.TrimSpace(value)]
+	return id, ok
+}
+
+// parseTrips aggregates a trip history CSV into departures and arrivals per
+// station and 5 minute bucket. Trip ends at unknown stations are counted but
+// otherwise ignored.
+func parseTrips(reader io.Reader, stations *stationIndex, location *time.Location) (map[flowKey]*flow, int, error) {
+	records := csv.NewReader(reader)
+	records.FieldsPerRecord = -1
+	records.ReuseRecord = true
+
+	header, err := records.Read()
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns, err := detectTripColumns(header)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	flows := map[flowKey]*flow{}
+	unknown := 0
+
+	add := func(stationValue string, timeValue string, departure bool) error {
+		if strings.TrimSpace(stationValue) == "" || strings.TrimSpace(timeValue) == "" {
+			return nil
+		}
+
+		id, ok := columns.station(stationValue, stations)
+
+		if !ok {
+			unknown++
+			return nil
+		}
+
+		t, err := columns.parseTime(timeValue, location)
+
+		if err != nil {
+			return err
+		}
+
+		key := flowKey{station: id, time: t.UTC().Truncate(bucketWidth)}
+
+		if flows[key] == nil {
+			flows[key] = &flow{}
+		}
+
+		if departure {
+			flows[key].departures++
+		} else {
+			flows[key].arrivals++
+		}
+
+		return nil
+	}
+
+	for line := 2; ; line++ {
+		record, err := records.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		width := max(columns.start, columns.startStation, columns.end, columns.endStation)
+
+		if len(record) <= width {
+			return nil, 0, fmt.Errorf("line %d: expected at least %d fields", line, width+1)
+		}
+
+		if err := add(record[columns.startStation], record[columns.start], true); err != nil {
+			return nil, 0, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if err := add(record[columns.endStation], record[columns.end], false); err != nil {
+			return nil, 0, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	return flows, unknown, nil
+}
+
+// ImportTrips imports BIXI trip history CSVs into station_flow, one file per
+// transaction. Stations are matched against station_information, so the
+// stations must have been synced first.
+func (i *Importer) ImportTrips(ctx context.Context, path string) (*Summary, error) {
+	start := time.Now()
+	summary := &Summary{}
+
+	location, err := time.LoadLocation("America/Montreal")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load time zone: %w", err)
+	}
+
+	stations, err := i.loadStationIndex(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stations.byCode) == 0 && len(stations.byName) == 0 {
+		return nil, errors.New("no stations known yet: sync station_information before importing trips")
+	}
+
+	err = readSources(path, []string{".csv"}, func(name string, content []byte) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		hash := archive.Hash(content)
+		done, err := i.imported(ctx, hash)
+
+		if err != nil {
+			return err
+		}
+
+		if done {
+			summary.Skipped++
+			return nil
+		}
+
+		flows, unknown, err := parseTrips(bytes.NewReader(content), stations, location)
+
+		if err != nil {
+			slog.Warn("skipping invalid trip history", "file", name, "error", err)
+			summary.Invalid++
+			return nil
+		}
+
+		rows, err := i.insertFlows(ctx, name, hash, flows)
+
+		if err != nil {
+			return err
+		}
+
+		summary.Files++
+		summary.Rows += rows
+		summary.Unknown += unknown
+		progress("trips", summary, start)
+		return nil
+	})
+
+	summary.Duration = time.Since(start)
+	return summary, err
+}
+
+// insertFlows adds flows to station_flow and records the file, in a single
+// transaction so that a file is never counted twice
+func (i *Importer) insertFlows(ctx context.Context, name string, hash string, flows map[flowKey]*flow) (int64, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	tx, err := i.pool.Begin(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	times := make([]time.Time, 0, flowBatchSize)
+	ids := make([]int64, 0, flowBatchSize)
+	departures := make([]int32, 0, flowBatchSize)
+	arrivals := make([]int32, 0, flowBatchSize)
+
+	upsert := func() error {
+		if len(times) == 0 {
+			return nil
+		}
+
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO "public"."station_flow" ("time", "station_id", "departures", "arrivals")
+			SELECT * FROM UNNEST($1::TIMESTAMPTZ[], $2::BIGINT[], $3::INTEGER[], $4::INTEGER[])
+			ON CONFLICT ("station_id", "time") DO UPDATE SET
+				"departures" = "station_flow"."departures" + excluded."departures",
+				"arrivals" = "station_flow"."arrivals" + excluded."arrivals"`,
+			times,
+			ids,
+			departures,
+			arrivals,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to insert station flow: %w", err)
+		}
+
+		times, ids, departures, arrivals = times[:0], ids[:0], departures[:0], arrivals[:0]
+		return nil
+	}
+
+	for key, value := range flows {
+		times = append(times, key.time)
+		ids = append(ids, key.station)
+		departures = append(departures, value.departures)
+		arrivals = append(arrivals, value.arrivals)
+
+		if len(times) >= flowBatchSize {
+			if err := upsert(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := upsert(); err != nil {
+		return 0, err
+	}
+
+	err = markImported(ctx, tx, hash, "trips", name, int64(len(flows)))
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int64(len(flows)), nil
+}
@@ -0,0 +1,81 @@
+package backfill
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTrips(t *testing.T) {
+	location, err := time.LoadLocation("America/Montreal")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stations := &stationIndex{
+		byCode: map[string]int64{"6001": 1, "6002": 2},
+		byName: map[string]int64{"metcalfe / square dorchester": 1, "de la commune / place jacques-cartier": 2},
+	}
+
+	tests := []struct {
+		name        string
+		csv         string
+		want        map[flowKey]flow
+		wantUnknown int
+	}{
+		{
+			name: "codes and local time",
+			csv: "start_date,start_station_code,end_date,end_station_code,duration_sec,is_member\n" +
+				"2019-06-01 08:01:00,6001,2019-06-01 08:14:59,6002,839,1\n" +
+				"2019-06-01 08:03:30,6001,2019-06-01 08:20:00,9999,990,0\n",
+			want: map[flowKey]flow{
+				{1, time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)}:  {departures: 2},
+				{2, time.Date(2019, 6, 1, 12, 10, 0, 0, time.UTC)}: {arrivals: 1},
+			},
+			wantUnknown: 1,
+		},
+		{
+			name: "names and milliseconds",
+			csv: "\ufeffSTARTSTATIONNAME,STARTSTATIONARRONDISSEMENT,STARTSTATIONLATITUDE,STARTSTATIONLONGITUDE,ENDSTATIONNAME,ENDSTATIONARRONDISSEMENT,ENDSTATIONLATITUDE,ENDSTATIONLONGITUDE,STARTTIMEMS,ENDTIMEMS\n" +
+				"Metcalfe / Square Dorchester,Ville-Marie,45.5,-73.57,de la Commune /  Place Jacques-Cartier,Ville-Marie,45.5,-73.55,1717243260000,1717244100000\n" +
+				"Metcalfe / Square Dorchester,Ville-Marie,45.5,-73.57,,,,,1717243320000,\n",
+			want: map[flowKey]flow{
+				{1, time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}:  {departures: 2},
+				{2, time.Date(2024, 6, 1, 12, 15, 0, 0, time.UTC)}: {arrivals: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flows, unknown, err := parseTrips(strings.NewReader(tt.csv), stations, location)
+
+			if err != nil {
+				t.Fatalf("parseTrips() error = %v", err)
+			}
+
+			if unknown != tt.wantUnknown {
+				t.Errorf("unknown = %d, want %d", unknown, tt.wantUnknown)
+			}
+
+			if len(flows) != len(tt.want) {
+				t.Errorf("parseTrips() returned %d buckets, want %d", len(flows), len(tt.want))
+			}
+
+			for key, want := range tt.want {
+				if got := flows[key]; got == nil || *got != want {
+					t.Errorf("flow at %d %s = %+v, want %+v", key.station, key.time, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTripsUnknownFormat(t *testing.T) {
+	_, _, err := parseTrips(strings.NewReader("a,b,c\n1,2,3\n"), &stationIndex{}, time.UTC)
+
+	if err == nil {
+		t.Error("parseTrips() accepted an unknown header")
+	}
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/sync"
+)
+
+// errInvalidConfig is returned by `hixi config check` once the validation
+// errors have been printed
+var errInvalidConfig = errors.New("invalid configuration")
+
+// checkConfig validates cfg, including the settings which are only known to
+// other packages
+func checkConfig(cfg *config.Config) error {
+	errs := []error{cfg.Validate()}
+
+	if !cfg.APIOnly && cfg.GBFS.System != "" {
+		if _, err := sync.NormalizerFor(cfg.GBFS.System); err != nil {
+			errs = append(errs, fmt.Errorf("gbfs.system: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// configCommand implements `hixi config check`: it validates the
+// configuration and prints the effective values
+func configCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("config", "check", "Validate the configuration file and environment, then print the effective configuration.")
+	apiOnly := flags.Bool("api-only", false, "only check the settings used by hixi serve")
+	syncOnly := flags.Bool("sync-only", false, "only check the settings used by hixi sync")
+
+	if len(args) == 0 || args[0] != "check" {
+		if err := parseFlags(flags, args); errors.Is(err, errHelp) {
+			return err
+		}
+
+		flags.Usage()
+		return errUsage
+	}
+
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	if *apiOnly {
+		cfg.APIOnly = true
+	}
+
+	if *syncOnly {
+		cfg.SyncOnly = true
+	}
+
+	err = checkConfig(cfg)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		return errInvalidConfig
+	}
+
+	err = yaml.NewEncoder(os.Stdout).Encode(cfg.Redacted())
+
+	if err != nil {
+		return fmt.Errorf("failed to print configuration: %w", err)
+	}
+
+	fmt.Fprintln(os.Stderr, "configuration OK")
+	return nil
+}
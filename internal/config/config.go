@@ -0,0 +1,728 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ngc7293/hixi/internal/ui"
+	"github.com/ngc7293/hixi/schema"
+)
+
+type Config struct {
+	DatabaseURL string `yaml:"database_url"`
+	APIOnly     bool   `yaml:"api_only"`
+	SyncOnly    bool   `yaml:"sync_only"`
+
+	Migrations Migrations `yaml:"migrations"`
+	Retention  Retention  `yaml:"retention"`
+	GBFS       GBFS       `yaml:"gbfs"`
+	Feeds      Feeds      `yaml:"feeds"`
+	Validation Validation `yaml:"validation"`
+	Archive    Archive    `yaml:"archive"`
+	Server     Server     `yaml:"server"`
+	Map        Map        `yaml:"map"`
+	Paths      Paths      `yaml:"paths"`
+}
+
+// How a process brings the PostgreSQL schema up to date on startup
+const (
+	MigrationsApply = "apply" // apply pending migrations, one process at a time
+	MigrationsWait  = "wait"  // wait for another process to apply them, e.g. for API replicas
+	MigrationsOff   = "off"   // start regardless, only warning about pending migrations
+)
+
+// Migrations controls the migration step on startup. Every mode refuses to
+// start against a schema newer than the migrations this binary knows.
+type Migrations struct {
+	Mode        string        `yaml:"mode"`
+	WaitTimeout time.Duration `yaml:"wait_timeout"` // wait mode only, 0 waits forever
+}
+
+// aggregateRefreshWindow is how far back the continuous aggregate policy
+// refreshes (its start_offset), which raw data must outlive
+const aggregateRefreshWindow = 90 * time.Minute
+
+// Retention sets how long PostgreSQL keeps each hypertable or continuous
+// aggregate, and after how long their chunks are compressed. Policies are
+// applied on startup if Apply is set, or with `hixi retention apply`. The
+// per vehicle type relations follow the policies of the live and historical
+// availability.
+type Retention struct {
+	Apply                  bool   `yaml:"apply"`
+	LiveAvailability       Policy `yaml:"live_station_availability"`
+	HistoricalAvailability Policy `yaml:"historical_station_availability"`
+	StationFlow            Policy `yaml:"station_flow"`
+}
+
+// Policy is the retention of one relation. A zero duration keeps data
+// forever, or never compresses it.
+type Policy struct {
+	DropAfter     time.Duration `yaml:"drop_after"`
+	CompressAfter time.Duration `yaml:"compress_after"`
+}
+
+type GBFS struct {
+	DiscoveryURL   string        `yaml:"discovery_url"`
+	PreferLanguage string        `yaml:"prefer_language"`
+	Timeout        time.Duration `yaml:"timeout"`
+	System         string        `yaml:"system"` // selects how operator quirks are normalized: bixi or generic
+}
+
+type Feeds struct {
+	StationStatus      Feed `yaml:"station_status"`
+	StationInformation Feed `yaml:"station_information"`
+	SystemRegions      Feed `yaml:"system_regions"`       // optional, not checked by /ready
+	SystemAlerts       Feed `yaml:"system_alerts"`        // optional, not checked by /ready
+	VehicleTypes       Feed `yaml:"vehicle_types"`        // optional, not checked by /ready
+	SystemPricingPlans Feed `yaml:"system_pricing_plans"` // optional, not checked by /ready
+	GeofencingZones    Feed `yaml:"geofencing_zones"`     // optional, not checked by /ready
+}
+
+// Feed controls how often a GBFS feed is polled. A zero Interval follows the
+// ttl advertised by the feed itself. /ready fails once the last successful
+// sync is older than MaxStaleness.
+type Feed struct {
+	Interval     time.Duration `yaml:"interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxStaleness time.Duration `yaml:"max_staleness"`
+}
+
+// Actions taken on station_status records which break a validation rule
+const (
+	ValidationOff        = "off"        // accept the record silently
+	ValidationRecord     = "record"     // record a data quality issue, but store the record
+	ValidationQuarantine = "quarantine" // record a data quality issue and drop the record
+)
+
+// Validation sets the action taken for each data quality rule
+type Validation struct {
+	NegativeCount  string `yaml:"negative_count"`  // a count is negative, usually after subtracting ebikes from bikes
+	OverCapacity   string `yaml:"over_capacity"`   // bikes and docks add up to more than the station capacity
+	UnknownStation string `yaml:"unknown_station"` // the station is missing from station_information
+	FutureReport   string `yaml:"future_report"`   // last_reported is more than 5 minutes in the future
+}
+
+// Archive keeps a raw copy of every fetched GBFS document, so that ingestion
+// can be replayed with `hixi replay`
+type Archive struct {
+	Mode        string `yaml:"mode"`         // off, disk or database
+	Dir         string `yaml:"dir"`          // disk mode only
+	DatabaseURL string `yaml:"database_url"` // database mode only, defaults to database_url
+}
+
+type Server struct {
+	Listen            string        `yaml:"listen"`
+	TrustProxyHeaders bool          `yaml:"trust_proxy_headers"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`  // how long in-flight requests may take to complete on shutdown
+	MetricsListen     string        `yaml:"metrics_listen"`    // optional private listener for /metrics and /admin, e.g. for sync-only replicas
+	MaxAggregateLag   time.Duration `yaml:"max_aggregate_lag"` // /ready fails once the continuous aggregate lags further behind
+}
+
+type Map struct {
+	URL         string            `yaml:"url"`
+	Layers      map[string]string `yaml:"layers"`
+	Bounds      []float64         `yaml:"bounds"` // minLon, minLat, maxLon, maxLat
+	CacheDir    string            `yaml:"cache_dir"`
+	CacheSizeMB int64             `yaml:"cache_size_mb"`
+	RateLimit   float64           `yaml:"rate_limit"` // requests per second, per client
+}
+
+// Paths override the migrations and UI embedded in the binary, for
+// development
+type Paths struct {
+	Schema string `yaml:"schema"` // embedded if empty
+	Static string `yaml:"static"` // embedded if empty
+}
+
+// SchemaFS returns the database migrations
+func (p Paths) SchemaFS() fs.FS {
+	if p.Schema == "" {
+		return schema.Migrations
+	}
+
+	return os.DirFS(p.Schema)
+}
+
+// StaticFS returns the UI, and whether it exists: the embedded UI is missing
+// if it was not built before compiling
+func (p Paths) StaticFS() (fs.FS, bool) {
+	if p.Static == "" {
+		return ui.FS()
+	}
+
+	return os.DirFS(p.Static), true
+}
+
+func Default() *Config {
+	return &Config{
+		Migrations: Migrations{
+			Mode:        MigrationsApply,
+			WaitTimeout: 10 * time.Minute,
+		},
+		Retention: Retention{
+			Apply:            true,
+			LiveAvailability: Policy{DropAfter: 24 * time.Hour},
+		},
+		GBFS: GBFS{
+			PreferLanguage: "en",
+			Timeout:        30 * time.Second,
+			System:         "bixi",
+		},
+		Feeds: Feeds{
+			StationStatus:      Feed{Timeout: 30 * time.Second, MaxStaleness: 15 * time.Minute},
+			StationInformation: Feed{Timeout: 30 * time.Second, MaxStaleness: time.Hour},
+			SystemRegions:      Feed{Timeout: 30 * time.Second},
+			SystemAlerts:       Feed{Timeout: 30 * time.Second},
+			VehicleTypes:       Feed{Timeout: 30 * time.Second},
+			SystemPricingPlans: Feed{Timeout: 30 * time.Second},
+			GeofencingZones:    Feed{Timeout: 30 * time.Second},
+		},
+		Validation: Validation{
+			NegativeCount:  ValidationRecord,
+			OverCapacity:   ValidationRecord,
+			UnknownStation: ValidationRecord,
+			FutureReport:   ValidationRecord,
+		},
+		Archive: Archive{
+			Mode: "off",
+		},
+		Server: Server{
+			Listen:          ":8080",
+			ShutdownTimeout: 15 * time.Second,
+			MaxAggregateLag: 30 * time.Minute,
+		},
+		Map: Map{
+			CacheSizeMB: 512,
+			RateLimit:   20,
+		},
+	}
+}
+
+// Load reads the configuration file at path (if any) on top of the defaults,
+// then applies environment variable overrides. The result is not validated.
+func Load(path string) (*Config, error) {
+	config := Default()
+
+	if path != "" {
+		file, err := os.Open(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		defer file.Close()
+
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+
+		// An empty file is a valid (if pointless) configuration
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	err := config.applyEnvironment(os.LookupEnv, os.Environ())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+type override struct {
+	name  string
+	apply func(value string) error
+}
+
+func stringOverride(name string, target *string) override {
+	return override{name, func(value string) error { *target = value; return nil }}
+}
+
+func boolOverride(name string, target *bool) override {
+	return override{name, func(value string) (err error) { *target, err = strconv.ParseBool(value); return }}
+}
+
+func int64Override(name string, target *int64) override {
+	return override{name, func(value string) (err error) { *target, err = strconv.ParseInt(value, 10, 64); return }}
+}
+
+func floatOverride(name string, target *float64) override {
+	return override{name, func(value string) (err error) { *target, err = strconv.ParseFloat(value, 64); return }}
+}
+
+func durationOverride(name string, target *time.Duration) override {
+	return override{name, func(value string) (err error) { *target, err = time.ParseDuration(value); return }}
+}
+
+func boundsOverride(name string, target *[]float64) override {
+	return override{name, func(value string) error {
+		bounds := []float64{}
+
+		for _, part := range strings.Split(value, ",") {
+			coordinate, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+
+			if err != nil {
+				return err
+			}
+
+			bounds = append(bounds, coordinate)
+		}
+
+		*target = bounds
+		return nil
+	}}
+}
+
+func (c *Config) applyEnvironment(lookup func(string) (string, bool), environ []string) error {
+	overrides := []override{
+		stringOverride("DATABASE_URL", &c.DatabaseURL),
+		boolOverride("API_ONLY", &c.APIOnly),
+		boolOverride("SYNC_ONLY", &c.SyncOnly),
+		stringOverride("MIGRATIONS_MODE", &c.Migrations.Mode),
+		durationOverride("MIGRATIONS_WAIT_TIMEOUT", &c.Migrations.WaitTimeout),
+		boolOverride("RETENTION_APPLY", &c.Retention.Apply),
+		durationOverride("RETENTION_LIVE_DROP_AFTER", &c.Retention.LiveAvailability.DropAfter),
+		durationOverride("RETENTION_LIVE_COMPRESS_AFTER", &c.Retention.LiveAvailability.CompressAfter),
+		durationOverride("RETENTION_HISTORICAL_DROP_AFTER", &c.Retention.HistoricalAvailability.DropAfter),
+		durationOverride("RETENTION_HISTORICAL_COMPRESS_AFTER", &c.Retention.HistoricalAvailability.CompressAfter),
+		durationOverride("RETENTION_FLOW_DROP_AFTER", &c.Retention.StationFlow.DropAfter),
+		durationOverride("RETENTION_FLOW_COMPRESS_AFTER", &c.Retention.StationFlow.CompressAfter),
+		stringOverride("GBFS_DISCOVERY_URL", &c.GBFS.DiscoveryURL),
+		stringOverride("PREFER_LANGUAGE", &c.GBFS.PreferLanguage),
+		durationOverride("GBFS_TIMEOUT", &c.GBFS.Timeout),
+		stringOverride("GBFS_SYSTEM", &c.GBFS.System),
+		durationOverride("STATION_STATUS_INTERVAL", &c.Feeds.StationStatus.Interval),
+		durationOverride("STATION_STATUS_TIMEOUT", &c.Feeds.StationStatus.Timeout),
+		durationOverride("STATION_INFORMATION_INTERVAL", &c.Feeds.StationInformation.Interval),
+		durationOverride("STATION_INFORMATION_TIMEOUT", &c.Feeds.StationInformation.Timeout),
+		durationOverride("SYSTEM_REGIONS_INTERVAL", &c.Feeds.SystemRegions.Interval),
+		durationOverride("SYSTEM_REGIONS_TIMEOUT", &c.Feeds.SystemRegions.Timeout),
+		durationOverride("SYSTEM_ALERTS_INTERVAL", &c.Feeds.SystemAlerts.Interval),
+		durationOverride("SYSTEM_ALERTS_TIMEOUT", &c.Feeds.SystemAlerts.Timeout),
+		durationOverride("VEHICLE_TYPES_INTERVAL", &c.Feeds.VehicleTypes.Interval),
+		durationOverride("VEHICLE_TYPES_TIMEOUT", &c.Feeds.VehicleTypes.Timeout),
+		durationOverride("SYSTEM_PRICING_PLANS_INTERVAL", &c.Feeds.SystemPricingPlans.Interval),
+		durationOverride("SYSTEM_PRICING_PLANS_TIMEOUT", &c.Feeds.SystemPricingPlans.Timeout),
+		durationOverride("GEOFENCING_ZONES_INTERVAL", &c.Feeds.GeofencingZones.Interval),
+		durationOverride("GEOFENCING_ZONES_TIMEOUT", &c.Feeds.GeofencingZones.Timeout),
+		stringOverride("VALIDATION_NEGATIVE_COUNT", &c.Validation.NegativeCount),
+		stringOverride("VALIDATION_OVER_CAPACITY", &c.Validation.OverCapacity),
+		stringOverride("VALIDATION_UNKNOWN_STATION", &c.Validation.UnknownStation),
+		stringOverride("VALIDATION_FUTURE_REPORT", &c.Validation.FutureReport),
+		stringOverride("ARCHIVE_MODE", &c.Archive.Mode),
+		stringOverride("ARCHIVE_DIR", &c.Archive.Dir),
+		stringOverride("ARCHIVE_DATABASE_URL", &c.Archive.DatabaseURL),
+		stringOverride("LISTEN_ADDRESS", &c.Server.Listen),
+		boolOverride("TRUST_PROXY_HEADERS", &c.Server.TrustProxyHeaders),
+		durationOverride("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout),
+		stringOverride("METRICS_LISTEN", &c.Server.MetricsListen),
+		durationOverride("MAX_AGGREGATE_LAG", &c.Server.MaxAggregateLag),
+		durationOverride("STATION_STATUS_MAX_STALENESS", &c.Feeds.StationStatus.MaxStaleness),
+		durationOverride("STATION_INFORMATION_MAX_STALENESS", &c.Feeds.StationInformation.MaxStaleness),
+		stringOverride("MAP_URL", &c.Map.URL),
+		boundsOverride("MAP_BOUNDS", &c.Map.Bounds),
+		stringOverride("MAP_CACHE_DIR", &c.Map.CacheDir),
+		int64Override("MAP_CACHE_SIZE_MB", &c.Map.CacheSizeMB),
+		floatOverride("MAP_RATE_LIMIT", &c.Map.RateLimit),
+		stringOverride("SCHEMA_PATH", &c.Paths.Schema),
+		stringOverride("STATIC_PATH", &c.Paths.Static),
+	}
+
+	errs := []error{}
+
+	for _, o := range overrides {
+		value, ok := lookup(o.name)
+
+		if !ok {
+			continue
+		}
+
+		if err := o.apply(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s environment variable %q: %w", o.name, value, err))
+		}
+	}
+
+	// Additional map layers are declared as MAP_URL_<NAME>
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+
+		if layer, found := strings.CutPrefix(name, "MAP_URL_"); found && layer != "" {
+			if c.Map.Layers == nil {
+				c.Map.Layers = map[string]string{}
+			}
+
+			c.Map.Layers[strings.ToLower(layer)] = value
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type problems []error
+
+func (p *problems) fail(field string, format string, args ...any) {
+	*p = append(*p, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+// Validate checks the configuration, reporting every problem found rather
+// than only the first. Sync settings are skipped for api_only and server
+// settings for sync_only.
+func (c *Config) Validate() error {
+	p := problems{}
+	c.validateDatabase(&p)
+
+	if c.APIOnly && c.SyncOnly {
+		p.fail("api_only", "cannot be combined with sync_only")
+	}
+
+	if !c.APIOnly {
+		c.validateSync(&p)
+	}
+
+	if !c.SyncOnly {
+		c.validateServer(&p)
+	}
+
+	return errors.Join(p...)
+}
+
+// ValidateDatabase only checks the settings needed to connect to and migrate
+// the database, for commands which neither sync nor serve
+func (c *Config) ValidateDatabase() error {
+	p := problems{}
+	c.validateDatabase(&p)
+	return errors.Join(p...)
+}
+
+// SQLitePath returns the path of the database file when database_url is a
+// sqlite: URL (sqlite:hixi.db or sqlite:///var/lib/hixi/hixi.db)
+func (c *Config) SQLitePath() (string, bool) {
+	path, ok := strings.CutPrefix(c.DatabaseURL, "sqlite:")
+
+	if !ok {
+		return "", false
+	}
+
+	return strings.TrimPrefix(path, "//"), true
+}
+
+func (c *Config) validateDatabase(p *problems) {
+	if path, ok := c.SQLitePath(); ok {
+		// Tables are created by the SQLite store itself
+		if path == "" {
+			p.fail("database_url", "must name a file after sqlite:")
+		}
+
+		c.validateSQLiteRetention(p)
+		return
+	}
+
+	if c.DatabaseURL == "" {
+		p.fail("database_url", "is required (or set DATABASE_URL)")
+	} else if strings.Contains(c.DatabaseURL, "://") {
+		// Anything else is assumed to be a key=value connection string
+		if u, err := url.Parse(c.DatabaseURL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			p.fail("database_url", "must be a postgres:// or sqlite: URL, or a key=value connection string")
+		}
+	}
+
+	switch c.Migrations.Mode {
+	case MigrationsApply, MigrationsWait, MigrationsOff:
+	default:
+		p.fail("migrations.mode", "must be one of apply, wait or off, got %q", c.Migrations.Mode)
+	}
+
+	if c.Migrations.WaitTimeout < 0 {
+		p.fail("migrations.wait_timeout", "must not be negative, got %s", c.Migrations.WaitTimeout)
+	}
+
+	if c.Paths.Schema != "" && !isDir(c.Paths.Schema) {
+		p.fail("paths.schema", "%q is not a directory", c.Paths.Schema)
+	}
+
+	c.validateRetention(p)
+}
+
+func (c *Config) validateRetention(p *problems) {
+	policies := []struct {
+		field string
+		value Policy
+	}{
+		{"retention.live_station_availability", c.Retention.LiveAvailability},
+		{"retention.historical_station_availability", c.Retention.HistoricalAvailability},
+		{"retention.station_flow", c.Retention.StationFlow},
+	}
+
+	for _, policy := range policies {
+		if policy.value.DropAfter < 0 {
+			p.fail(policy.field+".drop_after", "must not be negative, got %s", policy.value.DropAfter)
+		}
+
+		if policy.value.CompressAfter < 0 {
+			p.fail(policy.field+".compress_after", "must not be negative, got %s", policy.value.CompressAfter)
+		}
+
+		if policy.value.DropAfter > 0 && policy.value.CompressAfter >= policy.value.DropAfter {
+			p.fail(policy.field+".compress_after", "must be shorter than drop_after, got %s", policy.value.CompressAfter)
+		}
+	}
+
+	// Raw data must be aggregated before being dropped, and TimescaleDB only
+	// compresses aggregate chunks which are no longer refreshed
+	if after := c.Retention.LiveAvailability.DropAfter; after > 0 && after <= aggregateRefreshWindow {
+		p.fail("retention.live_station_availability.drop_after", "must be longer than the %s aggregate refresh window, got %s", aggregateRefreshWindow, after)
+	}
+
+	if after := c.Retention.HistoricalAvailability.CompressAfter; after > 0 && after <= aggregateRefreshWindow {
+		p.fail("retention.historical_station_availability.compress_after", "must be longer than the %s aggregate refresh window, got %s", aggregateRefreshWindow, after)
+	}
+}
+
+// validateSQLiteRetention refuses the policies SQLite cannot apply: it only
+// prunes raw availability, and neither compresses nor drops its aggregate
+func (c *Config) validateSQLiteRetention(p *problems) {
+	if after := c.Retention.LiveAvailability.DropAfter; after < 0 {
+		p.fail("retention.live_station_availability.drop_after", "must not be negative, got %s", after)
+	}
+
+	unsupported := []struct {
+		field string
+		value time.Duration
+	}{
+		{"retention.live_station_availability.compress_after", c.Retention.LiveAvailability.CompressAfter},
+		{"retention.historical_station_availability.drop_after", c.Retention.HistoricalAvailability.DropAfter},
+		{"retention.historical_station_availability.compress_after", c.Retention.HistoricalAvailability.CompressAfter},
+		{"retention.station_flow.drop_after", c.Retention.StationFlow.DropAfter},
+		{"retention.station_flow.compress_after", c.Retention.StationFlow.CompressAfter},
+	}
+
+	for _, setting := range unsupported {
+		if setting.value != 0 {
+			p.fail(setting.field, "is not supported when database_url is sqlite:")
+		}
+	}
+}
+
+func (c *Config) validateSync(p *problems) {
+	if c.GBFS.DiscoveryURL == "" {
+		p.fail("gbfs.discovery_url", "is required unless running api_only")
+	} else if !isHTTPURL(c.GBFS.DiscoveryURL) {
+		p.fail("gbfs.discovery_url", "must be an http(s) URL")
+	}
+
+	if c.GBFS.PreferLanguage == "" {
+		p.fail("gbfs.prefer_language", "must not be empty")
+	}
+
+	if c.GBFS.System == "" {
+		p.fail("gbfs.system", "must not be empty")
+	}
+
+	durations := []struct {
+		field    string
+		value    time.Duration
+		positive bool
+	}{
+		{"gbfs.timeout", c.GBFS.Timeout, true},
+		{"feeds.station_status.interval", c.Feeds.StationStatus.Interval, false},
+		{"feeds.station_status.timeout", c.Feeds.StationStatus.Timeout, true},
+		{"feeds.station_information.interval", c.Feeds.StationInformation.Interval, false},
+		{"feeds.station_information.timeout", c.Feeds.StationInformation.Timeout, true},
+		{"feeds.system_regions.interval", c.Feeds.SystemRegions.Interval, false},
+		{"feeds.system_regions.timeout", c.Feeds.SystemRegions.Timeout, true},
+		{"feeds.system_alerts.interval", c.Feeds.SystemAlerts.Interval, false},
+		{"feeds.system_alerts.timeout", c.Feeds.SystemAlerts.Timeout, true},
+		{"feeds.vehicle_types.interval", c.Feeds.VehicleTypes.Interval, false},
+		{"feeds.vehicle_types.timeout", c.Feeds.VehicleTypes.Timeout, true},
+		{"feeds.system_pricing_plans.interval", c.Feeds.SystemPricingPlans.Interval, false},
+		{"feeds.system_pricing_plans.timeout", c.Feeds.SystemPricingPlans.Timeout, true},
+		{"feeds.geofencing_zones.interval", c.Feeds.GeofencingZones.Interval, false},
+		{"feeds.geofencing_zones.timeout", c.Feeds.GeofencingZones.Timeout, true},
+	}
+
+	for _, d := range durations {
+		if d.value < 0 || (d.positive && d.value == 0) {
+			p.fail(d.field, "must be a positive duration, got %s", d.value)
+		}
+	}
+
+	rules := []struct {
+		field  string
+		action string
+	}{
+		{"validation.negative_count", c.Validation.NegativeCount},
+		{"validation.over_capacity", c.Validation.OverCapacity},
+		{"validation.unknown_station", c.Validation.UnknownStation},
+		{"validation.future_report", c.Validation.FutureReport},
+	}
+
+	for _, r := range rules {
+		switch r.action {
+		case ValidationOff, ValidationRecord, ValidationQuarantine:
+		default:
+			p.fail(r.field, "must be one of off, record or quarantine, got %q", r.action)
+		}
+	}
+
+	c.validateArchive(p)
+}
+
+// ValidateArchive checks the settings needed to read the raw archive, for
+// `hixi replay`
+func (c *Config) ValidateArchive() error {
+	p := problems{}
+	c.validateDatabase(&p)
+	c.validateArchive(&p)
+
+	if c.Archive.Mode == "off" {
+		p.fail("archive.mode", "must be disk or database to replay")
+	}
+
+	return errors.Join(p...)
+}
+
+func (c *Config) validateArchive(p *problems) {
+	switch c.Archive.Mode {
+	case "off":
+	case "database":
+		if _, ok := c.SQLitePath(); ok && c.Archive.DatabaseURL == "" {
+			p.fail("archive.database_url", "is required in database mode when database_url is sqlite:")
+		}
+	case "disk":
+		if c.Archive.Dir == "" {
+			p.fail("archive.dir", "is required in disk mode")
+		}
+	default:
+		p.fail("archive.mode", "must be one of off, disk or database, got %q", c.Archive.Mode)
+	}
+}
+
+func (c *Config) validateServer(p *problems) {
+	if c.Server.Listen == "" {
+		p.fail("server.listen", "must not be empty")
+	}
+
+	durations := []struct {
+		field string
+		value time.Duration
+	}{
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"server.max_aggregate_lag", c.Server.MaxAggregateLag},
+		{"feeds.station_status.max_staleness", c.Feeds.StationStatus.MaxStaleness},
+		{"feeds.station_information.max_staleness", c.Feeds.StationInformation.MaxStaleness},
+	}
+
+	for _, d := range durations {
+		if d.value <= 0 {
+			p.fail(d.field, "must be a positive duration, got %s", d.value)
+		}
+	}
+
+	if c.Map.URL == "" {
+		p.fail("map.url", "is required unless running sync_only (or set MAP_URL)")
+	} else if !isTileTemplate(c.Map.URL) {
+		p.fail("map.url", "must contain {z}, {x} and {y} placeholders")
+	}
+
+	for name, template := range c.Map.Layers {
+		if name == "" || strings.Contains(name, "/") {
+			p.fail("map.layers", "invalid layer name %q", name)
+		}
+
+		if !isTileTemplate(template) {
+			p.fail("map.layers."+name, "must contain {z}, {x} and {y} placeholders")
+		}
+	}
+
+	if len(c.Map.Bounds) != 0 {
+		if len(c.Map.Bounds) != 4 {
+			p.fail("map.bounds", "expected [minLon, minLat, maxLon, maxLat]")
+		} else if c.Map.Bounds[0] >= c.Map.Bounds[2] || c.Map.Bounds[1] >= c.Map.Bounds[3] {
+			p.fail("map.bounds", "minimum coordinates must be lower than maximum coordinates")
+		}
+	}
+
+	if c.Map.CacheSizeMB < 0 {
+		p.fail("map.cache_size_mb", "must not be negative")
+	}
+
+	if c.Map.RateLimit <= 0 {
+		p.fail("map.rate_limit", "must be a positive number of requests per second")
+	}
+
+	if c.Paths.Static != "" && !isDir(c.Paths.Static) {
+		p.fail("paths.static", "%q is not a directory", c.Paths.Static)
+	}
+}
+
+// Redacted returns a copy of the configuration safe to print, with the
+// database password and map URL query parameters (usually access tokens)
+// removed
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.DatabaseURL = redactPassword(c.DatabaseURL)
+	redacted.Archive.DatabaseURL = redactPassword(c.Archive.DatabaseURL)
+
+	redacted.Map.URL = redactQuery(c.Map.URL)
+	redacted.Map.Layers = map[string]string{}
+
+	for name, template := range c.Map.Layers {
+		redacted.Map.Layers[name] = redactQuery(template)
+	}
+
+	return &redacted
+}
+
+func redactPassword(value string) string {
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			return u.String()
+		}
+	}
+
+	return value
+}
+
+func redactQuery(value string) string {
+	before, query, found := strings.Cut(value, "?")
+
+	if !found {
+		return value
+	}
+
+	params := strings.Split(query, "&")
+
+	for i, param := range params {
+		if key, _, ok := strings.Cut(param, "="); ok {
+			params[i] = key + "=xxxxx"
+		}
+	}
+
+	return before + "?" + strings.Join(params, "&")
+}
+
+func isTileTemplate(value string) bool {
+	return strings.Contains(value, "{z}") && strings.Contains(value, "{x}") && strings.Contains(value, "{y}")
+}
+
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
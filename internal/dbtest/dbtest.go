@@ -0,0 +1,215 @@
+// Package dbtest provides ephemeral, migrated TimescaleDB/PostGIS databases
+// for integration tests.
+//
+// Databases are created on the server at HIXI_TEST_DATABASE_URL if set, which
+// must allow creating databases. Otherwise a TimescaleDB container is started
+// with docker, once per test binary. Tests are skipped when neither is
+// available, or when running with -short.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/tern/v2/migrate"
+
+	"github.com/ngc7293/hixi/schema"
+)
+
+const image = "timescale/timescaledb-ha:pg16"
+
+var (
+	once      sync.Once
+	serverURL string
+	container string
+	startErr  error
+	databases atomic.Int64
+)
+
+// Main runs the tests of a package, then stops the database container if
+// one was started. Call it from TestMain.
+func Main(m *testing.M) {
+	code := m.Run()
+
+	if container != "" {
+		_ = exec.Command("docker", "rm", "--force", container).Run()
+	}
+
+	os.Exit(code)
+}
+
+func start() (string, error) {
+	if url := os.Getenv("HIXI_TEST_DATABASE_URL"); url != "" {
+		return url, nil
+	}
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		return "", fmt.Errorf("set HIXI_TEST_DATABASE_URL or install docker")
+	}
+
+	output, err := exec.Command(
+		"docker", "run", "--detach", "--rm",
+		"--env", "POSTGRES_PASSWORD=hixi",
+		"--publish", "127.0.0.1::5432",
+		image,
+	).Output()
+
+	if err != nil {
+		return "", fmt.Errorf("failed to start %s: %w", image, err)
+	}
+
+	container = strings.TrimSpace(string(output))
+
+	output, err = exec.Command("docker", "port", container, "5432/tcp").Output()
+
+	if err != nil {
+		return "", fmt.Errorf("failed to find database port: %w", err)
+	}
+
+	address := strings.TrimSpace(strings.Split(string(output), "\n")[0])
+	url := fmt.Sprintf("postgres://postgres:hixi@%s/postgres?sslmode=disable", address)
+
+	// The image restarts the server once initialized, so wait for it to
+	// accept queries for a little while
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	for ready := 0; ready < 3; {
+		conn, err := pgx.Connect(ctx, url)
+
+		if err == nil {
+			err = conn.Ping(ctx)
+			conn.Close(ctx)
+		}
+
+		if err == nil {
+			ready++
+		} else {
+			ready = 0
+		}
+
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("database did not start: %w", err)
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	return url, nil
+}
+
+// withDatabase returns server with its path replaced by database
+func withDatabase(server string, database string) (string, error) {
+	u, err := url.Parse(server)
+
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = "/" + database
+	return u.String(), nil
+}
+
+// New creates a fresh database with every migration applied, dropped when
+// the test completes
+func New(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	pool := Empty(t)
+	ctx := context.Background()
+	conn, err := pool.Acquire(ctx)
+
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+
+	defer conn.Release()
+
+	migrator, err := migrate.NewMigrator(ctx, conn.Conn(), "schema_migrations")
+
+	if err == nil {
+		err = migrator.LoadMigrations(schema.Migrations)
+	}
+
+	if err == nil {
+		err = migrator.Migrate(ctx)
+	}
+
+	if err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	return pool
+}
+
+// Empty creates a fresh database without any migration applied, dropped
+// when the test completes
+func Empty(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("skipping database test in short mode")
+	}
+
+	once.Do(func() { serverURL, startErr = start() })
+
+	if startErr != nil {
+		t.Skipf("no test database available: %v", startErr)
+	}
+
+	ctx := context.Background()
+	admin, err := pgx.Connect(ctx, serverURL)
+
+	if err != nil {
+		t.Skipf("no test database available: %v", err)
+	}
+
+	defer admin.Close(ctx)
+
+	name := fmt.Sprintf("hixi_test_%d_%d", os.Getpid(), databases.Add(1))
+
+	if _, err := admin.Exec(ctx, `CREATE DATABASE `+name); err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+
+	databaseURL, err := withDatabase(serverURL, name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err := pgxpool.New(ctx, databaseURL)
+
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		pool.Close()
+
+		admin, err := pgx.Connect(ctx, serverURL)
+
+		if err != nil {
+			t.Logf("failed to drop test database: %v", err)
+			return
+		}
+
+		defer admin.Close(ctx)
+
+		if _, err := admin.Exec(ctx, `DROP DATABASE `+name+` WITH (FORCE)`); err != nil {
+			t.Logf("failed to drop test database: %v", err)
+		}
+	})
+
+	return pool
+}
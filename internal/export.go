@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+type exportRow struct {
+	Time            time.Time `json:"time"`
+	StationID       string    `json:"station_id"`
+	BikesAvailable  *float64  `json:"bikes_available"`
+	EbikesAvailable *float64  `json:"ebikes_available"`
+	DocksAvailable  *float64  `json:"docks_available"`
+}
+
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*value, 'f', 2, 64)
+}
+
+// parseExportTime accepts either an RFC 3339 timestamp or a plain date
+func parseExportTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.ParseInLocation(time.DateOnly, value, time.Local)
+}
+
+func exportCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("export", "", "Export historical station availability, averaged over time buckets.")
+	station := flags.String("station", "", "only export this station (GBFS station_id)")
+	from := flags.String("from", "", "start of the export window, as RFC 3339 or YYYY-MM-DD (default: 24 hours ago)")
+	to := flags.String("to", "", "end of the export window, as RFC 3339 or YYYY-MM-DD (default: now)")
+	bucket := flags.Duration("bucket", 15*time.Minute, "time bucket width, at least 5m")
+	format := flags.String("format", "csv", "output format: csv or json (one object per line)")
+	output := flags.String("out", "-", "output file, - for stdout")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 || (*format != "csv" && *format != "json") || *bucket < 5*time.Minute {
+		flags.Usage()
+		return errUsage
+	}
+
+	end := time.Now()
+	start := end.Add(-24 * time.Hour)
+
+	if *to != "" {
+		t, err := parseExportTime(*to)
+
+		if err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+
+		end = t
+	}
+
+	if *from != "" {
+		t, err := parseExportTime(*from)
+
+		if err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+
+		start = t
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	err = cfg.ValidateDatabase()
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	pool, err := connect(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer pool.Close()
+
+	var out io.Writer = os.Stdout
+
+	if *output != "-" {
+		file, err := os.Create(*output)
+
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+
+		defer file.Close()
+		out = file
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT
+			TIME_BUCKET($1::INTERVAL, "time_bucket") AS "bucket",
+			"station"."external_id",
+			AVG("bikes_available")::DOUBLE PRECISION,
+			AVG("ebikes_available")::DOUBLE PRECISION,
+			AVG("docks_available")::DOUBLE PRECISION
+		FROM "public"."historical_station_availability"
+		JOIN "public"."station" ON "station"."id" = "historical_station_availability"."station_id"
+		WHERE
+			"time_bucket" >= $2
+			AND "time_bucket" < $3
+			AND ($4 = '' OR "station"."external_id" = $4)
+		GROUP BY "bucket", "station"."external_id"
+		ORDER BY "bucket", "station"."external_id"`,
+		*bucket,
+		start,
+		end,
+		*station,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to query availability: %w", err)
+	}
+
+	defer rows.Close()
+
+	csvWriter := csv.NewWriter(out)
+	jsonEncoder := json.NewEncoder(out)
+
+	if *format == "csv" {
+		err = csvWriter.Write([]string{"time", "station_id", "bikes_available", "ebikes_available", "docks_available"})
+
+		if err != nil {
+			return fmt.Errorf("failed to write export: %w", err)
+		}
+	}
+
+	count := 0
+
+	for rows.Next() {
+		row := exportRow{}
+		err := rows.Scan(&row.Time, &row.StationID, &row.BikesAvailable, &row.EbikesAvailable, &row.DocksAvailable)
+
+		if err != nil {
+			return fmt.Errorf("failed to read availability: %w", err)
+		}
+
+		if *format == "csv" {
+			err = csvWriter.Write([]string{
+				row.Time.Format(time.RFC3339),
+				row.StationID,
+				formatOptionalFloat(row.BikesAvailable),
+				formatOptionalFloat(row.EbikesAvailable),
+				formatOptionalFloat(row.DocksAvailable),
+			})
+		} else {
+			err = jsonEncoder.Encode(row)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to write export: %w", err)
+		}
+
+		count++
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to read availability: %w", err)
+	}
+
+	csvWriter.Flush()
+
+	if err = csvWriter.Error(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d rows\n", count)
+	return nil
+}
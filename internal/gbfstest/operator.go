@@ -0,0 +1,193 @@
+// Package gbfstest provides a fake GBFS operator for tests, serving scripted
+// feed snapshots and failures over HTTP
+package gbfstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/pkg/gbfs"
+)
+
+type feed struct {
+	snapshots [][]byte // served in order, the last one is repeated
+	failures  []int    // status codes returned before any snapshot
+	delay     time.Duration
+	requests  int
+}
+
+// Operator is a fake GBFS operator. The discovery document lists every feed
+// which was given a snapshot, in English.
+type Operator struct {
+	server *httptest.Server
+
+	mu    sync.Mutex
+	feeds map[string]*feed
+}
+
+// NewOperator starts a fake operator, stopped when the test completes
+func NewOperator(t testing.TB) *Operator {
+	operator := &Operator{feeds: map[string]*feed{}}
+	operator.server = httptest.NewServer(http.HandlerFunc(operator.serve))
+	t.Cleanup(operator.server.Close)
+	return operator
+}
+
+// DiscoveryURL is the URL of the gbfs.json discovery document
+func (o *Operator) DiscoveryURL() string {
+	return o.server.URL + "/gbfs.json"
+}
+
+// FeedURL is the URL of the named feed
+func (o *Operator) FeedURL(name string) string {
+	return o.server.URL + "/en/" + name + ".json"
+}
+
+func (o *Operator) feed(name string) *feed {
+	if o.feeds[name] == nil {
+		o.feeds[name] = &feed{}
+	}
+
+	return o.feeds[name]
+}
+
+// Push queues a snapshot of the named feed, wrapping data in a GBFS document
+func (o *Operator) Push(name string, lastUpdated time.Time, ttl int64, data any) {
+	content, err := json.Marshal(gbfs.GBFSDocument[any]{LastUpdated: lastUpdated.Unix(), TTL: ttl, Data: data})
+
+	if err != nil {
+		panic(err)
+	}
+
+	o.PushRaw(name, string(content))
+}
+
+// PushRaw queues a snapshot of the named feed with the exact body given, to
+// test malformed documents
+func (o *Operator) PushRaw(name string, body string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	feed := o.feed(name)
+	feed.snapshots = append(feed.snapshots, []byte(body))
+}
+
+// Fail makes the next requests for the named feed fail with the given status
+// codes, one per request
+func (o *Operator) Fail(name string, statuses ...int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	feed := o.feed(name)
+	feed.failures = append(feed.failures, statuses...)
+}
+
+// Delay makes every response for the named feed wait for d, to test timeouts
+func (o *Operator) Delay(name string, d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.feed(name).delay = d
+}
+
+// Requests returns how many times the named feed was requested
+func (o *Operator) Requests(name string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if feed, ok := o.feeds[name]; ok {
+		return feed.requests
+	}
+
+	return 0
+}
+
+func (o *Operator) discovery() []byte {
+	names := []string{}
+
+	for name, feed := range o.feeds {
+		if len(feed.snapshots) > 0 {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	language := gbfs.GBFSDiscoveryLanguage{}
+
+	for _, name := range names {
+		language.Feeds = append(language.Feeds, gbfs.GBFSFeed{Name: name, URL: o.FeedURL(name)})
+	}
+
+	content, _ := json.Marshal(gbfs.GBFSDocument[gbfs.GBFSDiscoveryData]{
+		LastUpdated: time.Now().Unix(),
+		Data:        gbfs.GBFSDiscoveryData{"en": language},
+	})
+
+	return content
+}
+
+func (o *Operator) serve(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+
+	if r.URL.Path == "/gbfs.json" {
+		content := o.discovery()
+		o.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(content)
+		return
+	}
+
+	name, found := strings.CutPrefix(r.URL.Path, "/en/")
+	name, _ = strings.CutSuffix(name, ".json")
+	feed, ok := o.feeds[name]
+
+	if !found || !ok {
+		o.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+
+	feed.requests++
+	delay := feed.delay
+	status := http.StatusOK
+	var content []byte
+
+	switch {
+	case len(feed.failures) > 0:
+		status, feed.failures = feed.failures[0], feed.failures[1:]
+	case len(feed.snapshots) == 0:
+		status = http.StatusNotFound
+	default:
+		content = feed.snapshots[0]
+
+		if len(feed.snapshots) > 1 {
+			feed.snapshots = feed.snapshots[1:]
+		}
+	}
+
+	o.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
@@ -2,142 +2,186 @@ package internal
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"log/slog"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/tern/v2/migrate"
 
-	"github.com/ngc7293/hixi/internal/server"
-	"github.com/ngc7293/hixi/internal/sync"
-	"github.com/ngc7293/hixi/pkg/gbfs"
+	"github.com/ngc7293/hixi/internal/config"
 )
 
-func runDatabaseMigrations(pool *pgxpool.Pool) error {
-	conn, err := pool.Acquire(context.Background())
+// errUsage is returned by commands when their arguments are invalid, after
+// having printed their usage
+var errUsage = errors.New("invalid usage")
 
-	if err != nil {
-		return fmt.Errorf("failed to acquire connection: %w", err)
-	}
+// errHelp is returned by commands invoked with -h, after having printed their
+// usage
+var errHelp = errors.New("help requested")
 
-	defer conn.Release()
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{"run", "sync GBFS feeds and serve the API (default)", runCommand},
+		{"serve", "serve the API and map only", serveCommand},
+		{"sync", "sync GBFS feeds only", syncCommand},
+		{"migrate", "manage database migrations (up, down, status, to N)", migrateCommand},
+		{"retention", "manage retention and compression policies (apply, status)", retentionCommand},
+		{"regions", "manage custom regions (list, import, remove)", regionsCommand},
+		{"export", "export historical availability as CSV or JSON", exportCommand},
+		{"replay", "re-ingest archived GBFS documents", replayCommand},
+		{"backfill", "import historical status dumps or trip histories", backfillCommand},
+		{"config", "validate and print the configuration (check)", configCommand},
+	}
+}
 
-	migrator, err := migrate.NewMigrator(context.Background(), conn.Conn(), "schema_migrations")
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: hixi <command> [flags] [arguments]\n\ncommands:\n")
 
-	if err != nil {
-		return fmt.Errorf("failed to create migrator: %w", err)
+	for _, c := range commands() {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", c.name, c.summary)
 	}
 
-	err = migrator.LoadMigrations(os.DirFS("schema"))
+	fmt.Fprintf(os.Stderr, "\nRun `hixi <command> -h` for the flags of each command.\n")
+}
+
+// commonFlags are the flags shared by every command
+type commonFlags struct {
+	config string
+	schema string
+	static string
+}
+
+// load loads the configuration, overriding its paths with -schema and
+// -static if given
+func (f *commonFlags) load() (*config.Config, error) {
+	cfg, err := config.Load(f.config)
 
 	if err != nil {
-		return fmt.Errorf("failed to load migrations %w", err)
+		return nil, err
 	}
 
-	err = migrator.Migrate(context.Background())
+	if f.schema != "" {
+		cfg.Paths.Schema = f.schema
+	}
 
-	if err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	if f.static != "" {
+		cfg.Paths.Static = f.static
 	}
 
-	return nil
+	return cfg, nil
 }
 
-func Run() {
-	options := slog.HandlerOptions{Level: slog.LevelDebug}
-	logger := slog.New(slog.NewTextHandler(os.Stderr, &options))
-	slog.SetDefault(logger)
+// newFlagSet creates the flag set of a command, with the flags shared by
+// every command
+func newFlagSet(name string, arguments string, description string) (*flag.FlagSet, *commonFlags) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	common := &commonFlags{}
 
-	dbUrl := os.Getenv("DATABASE_URL")
-	preferLanguage := os.Getenv("PREFER_LANGUAGE")
+	flags.StringVar(&common.config, "config", os.Getenv("HIXI_CONFIG"), "path to a YAML configuration file (defaults to $HIXI_CONFIG)")
+	flags.StringVar(&common.schema, "schema", "", "read migrations from this directory instead of the embedded ones, for development")
+	flags.StringVar(&common.static, "static", "", "serve the UI from this directory instead of the embedded one, for development")
 
-	if preferLanguage == "" {
-		slog.Info("defaulting to preferring english (en) feeds")
-		preferLanguage = "en"
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: hixi %s [flags] %s\n\n%s\n\nflags:\n", name, arguments, description)
+		flags.PrintDefaults()
 	}
 
-	if dbUrl == "" {
-		slog.Error("DATABASE_URL environment variable is not set")
-		os.Exit(1)
-	}
+	return flags, common
+}
 
-	if len(os.Args) != 2 {
-		slog.Error("usage: hixi <gbfs-discovery-url>")
-		os.Exit(1)
-	}
+// parseFlags parses a command's arguments, translating -h into errHelp
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
 
-	pool, err := pgxpool.New(context.Background(), dbUrl)
+	if errors.Is(err, flag.ErrHelp) {
+		return errHelp
+	}
 
 	if err != nil {
-		slog.Error("failed to connect to database", "error", err)
-		os.Exit(1)
+		return errUsage
 	}
 
-	defer pool.Close()
+	return nil
+}
+
+func connect(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	if _, ok := cfg.SQLitePath(); ok {
+		return nil, errors.New("this command requires PostgreSQL, database_url is sqlite:")
+	}
 
-	err = runDatabaseMigrations(pool)
+	pool, err := pgxpool.New(ctx, cfg.DatabaseURL)
 
 	if err != nil {
-		slog.Error("failed to run database migrations", "error", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	c := make(chan error, 1)
+	return pool, nil
+}
 
-	apiOnly, apiErr := strconv.ParseBool(os.Getenv("API_ONLY"))
-	syncOnly, syncErr := strconv.ParseBool(os.Getenv("SYNC_ONLY"))
+// Run runs the command named by args, and returns the exit code of the
+// process
+func Run(args []string) int {
+	options := slog.HandlerOptions{Level: slog.LevelDebug}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &options))
+	slog.SetDefault(logger)
 
-	if apiErr != nil {
-		apiOnly = false
-	}
+	name := "run"
 
-	if syncErr != nil {
-		syncOnly = false
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		usage()
+		return 0
 	}
 
-	if syncOnly && apiOnly {
-		slog.Error("cannot run both sync and api only")
-		os.Exit(1)
+	// Without a command, or when invoked the historical way as
+	// `hixi <gbfs-discovery-url>`, both sync and serve
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") && !strings.Contains(args[0], "://") {
+		name, args = args[0], args[1:]
 	}
 
-	if apiOnly == false {
-		discovery, err := sync.FetchDocument[gbfs.GBFSDiscoveryData](os.Args[1])
-
-		if err != nil {
-			slog.Error("failed to fetch gbfs discovery", "error", err)
-			os.Exit(1)
-		}
+	// Commands wind down gracefully on SIGINT or SIGTERM, a second signal
+	// kills the process immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		stationStatusUrl, lang, err := sync.FindFeedURLWithLanguage(discovery.Data, "station_status", preferLanguage)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-		if err != nil {
-			slog.Error("failed to find station_status", "error", err)
-			os.Exit(1)
+	for _, c := range commands() {
+		if c.name != name {
+			continue
 		}
 
-		slog.Info("found station_status", "url", stationStatusUrl, "language", lang)
-
-		stationInformationUrl, lang, err := sync.FindFeedURLWithLanguage(discovery.Data, "station_information", preferLanguage)
-
-		if err != nil {
-			slog.Error("failed to find station_information", "error", err)
-			os.Exit(1)
+		err := c.run(ctx, args)
+		stop()
+
+		switch {
+		case errors.Is(err, errHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		case err != nil:
+			slog.Error("command failed", "command", name, "error", err)
+			return 1
 		}
 
-		slog.Info("found station_information", "url", stationStatusUrl, "language", lang)
-
-		go func() { c <- sync.FetchStationStatusLoop(pool, stationStatusUrl) }()
-		go func() { c <- sync.FetchStationInformationLoop(pool, stationInformationUrl) }()
-	}
-
-	if syncOnly == false {
-		go func() { c <- server.Serve(pool) }()
+		return 0
 	}
 
-	if err := <-c; err != nil {
-		panic(err)
-	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	return 2
 }
@@ -0,0 +1,23 @@
+package internal
+
+import "testing"
+
+func TestRunExitCode(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{args: []string{"help"}, want: 0},
+		{args: []string{"migrate", "-h"}, want: 0},
+		{args: []string{"config", "check", "-h"}, want: 0},
+		{args: []string{"config", "check", "-bogus"}, want: 2},
+		{args: []string{"migrate"}, want: 2},
+		{args: []string{"bogus"}, want: 2},
+	}
+
+	for _, tt := range tests {
+		if got := Run(tt.args); got != tt.want {
+			t.Errorf("Run(%q) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
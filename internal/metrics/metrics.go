@@ -0,0 +1,208 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "hixi"
+
+var (
+	Registry = prometheus.NewRegistry()
+
+	SyncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_duration_seconds",
+		Help:      "Time taken to fetch and store one snapshot of a GBFS feed.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"feed"})
+
+	SyncStations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_stations_total",
+		Help:      "Stations seen in GBFS snapshots, by outcome (processed, skipped, inserted).",
+	}, []string{"feed", "result"})
+
+	SyncSnapshotStations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sync_snapshot_stations",
+		Help:      "Stations in the most recent GBFS snapshot, by outcome (processed, skipped, inserted).",
+	}, []string{"feed", "result"})
+
+	FetchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_fetch_errors_total",
+		Help:      "Failed GBFS feed syncs, by error type.",
+	}, []string{"feed", "type"})
+
+	DataQualityIssues = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_data_quality_issues_total",
+		Help:      "Feed records breaking a validation rule, by rule.",
+	}, []string{"feed", "rule"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of API requests, by route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	MapUpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "map_upstream_duration_seconds",
+		Help:      "Latency of map tile requests to the upstream tile server, by layer and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"layer", "outcome"})
+
+	feedStaleness = newStalenessCollector()
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		SyncDuration,
+		SyncStations,
+		SyncSnapshotStations,
+		FetchErrors,
+		DataQualityIssues,
+		HTTPRequestDuration,
+		MapUpstreamDuration,
+		feedStaleness,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// FeedUpdated records the last_updated timestamp of a GBFS feed, from which
+// its staleness is computed at scrape time
+func FeedUpdated(feed string, lastUpdated int64) {
+	feedStaleness.set(feed, time.Unix(lastUpdated, 0))
+}
+
+// RecordSnapshot records the outcome of storing one station feed snapshot
+func RecordSnapshot(feed string, processed, skipped, inserted int) {
+	for result, count := range map[string]int{"processed": processed, "skipped": skipped, "inserted": inserted} {
+		SyncStations.WithLabelValues(feed, result).Add(float64(count))
+		SyncSnapshotStations.WithLabelValues(feed, result).Set(float64(count))
+	}
+}
+
+type stalenessCollector struct {
+	desc *prometheus.Desc
+
+	mu          sync.Mutex
+	lastUpdated map[string]time.Time
+	now         func() time.Time
+}
+
+func newStalenessCollector() *stalenessCollector {
+	return &stalenessCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feed", "staleness_seconds"),
+			"Seconds since the last_updated timestamp of the most recently fetched GBFS feed document.",
+			[]string{"feed"}, nil,
+		),
+		lastUpdated: map[string]time.Time{},
+		now:         time.Now,
+	}
+}
+
+func (c *stalenessCollector) set(feed string, lastUpdated time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastUpdated[feed] = lastUpdated
+}
+
+func (c *stalenessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *stalenessCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for feed, lastUpdated := range c.lastUpdated {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, c.now().Sub(lastUpdated).Seconds(), feed)
+	}
+}
+
+// RegisterPool exposes the connection pool statistics of pool
+func RegisterPool(pool *pgxpool.Pool) error {
+	return Registry.Register(&poolCollector{pool: pool})
+}
+
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+var (
+	poolAcquiredConns        = poolDesc("acquired_connections", "Connections currently acquired from the pool.")
+	poolIdleConns            = poolDesc("idle_connections", "Idle connections in the pool.")
+	poolTotalConns           = poolDesc("total_connections", "Total connections in the pool.")
+	poolMaxConns             = poolDesc("max_connections", "Maximum size of the pool.")
+	poolAcquireCount         = poolDesc("acquires_total", "Successful connection acquisitions from the pool.")
+	poolAcquireDuration      = poolDesc("acquire_duration_seconds_total", "Total time spent waiting to acquire connections.")
+	poolEmptyAcquireCount    = poolDesc("empty_acquires_total", "Acquisitions which had to wait because the pool was empty.")
+	poolCanceledAcquireCount = poolDesc("canceled_acquires_total", "Acquisitions cancelled by their context.")
+)
+
+func poolDesc(name string, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		poolAcquiredConns, poolIdleConns, poolTotalConns, poolMaxConns,
+		poolAcquireCount, poolAcquireDuration, poolEmptyAcquireCount, poolCanceledAcquireCount,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// InstrumentRoute wraps handler to record its latency under the given route
+// pattern, rather than the raw path, to keep label cardinality bounded
+func InstrumentRoute(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		handler(recorder, r)
+
+		HTTPRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Observe(time.Since(start).Seconds())
+	}
+}
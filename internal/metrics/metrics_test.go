@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricNames(t *testing.T) {
+	// Vectors are only exported once they have at least one child
+	SyncDuration.WithLabelValues("station_status").Observe(1)
+	FetchErrors.WithLabelValues("station_status", "timeout").Inc()
+	DataQualityIssues.WithLabelValues("station_status", "negative_count").Inc()
+	MapUpstreamDuration.WithLabelValues("default", "ok").Observe(0.1)
+	RecordSnapshot("station_status", 3, 1, 2)
+	FeedUpdated("station_status", time.Now().Add(-time.Minute).Unix())
+
+	handler := InstrumentRoute("/stations/{stationId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stations/42", nil))
+
+	families, err := Registry.Gather()
+
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	found := map[string]bool{}
+
+	for _, family := range families {
+		found[family.GetName()] = true
+	}
+
+	for _, name := range []string{
+		"hixi_sync_duration_seconds",
+		"hixi_sync_stations_total",
+		"hixi_sync_snapshot_stations",
+		"hixi_sync_fetch_errors_total",
+		"hixi_sync_data_quality_issues_total",
+		"hixi_feed_staleness_seconds",
+		"hixi_http_request_duration_seconds",
+		"hixi_map_upstream_duration_seconds",
+		"go_goroutines",
+	} {
+		if !found[name] {
+			t.Errorf("metric %s is not exported", name)
+		}
+	}
+}
+
+func TestInstrumentRouteLabels(t *testing.T) {
+	handler := InstrumentRoute("/heatmap", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/heatmap?shape=triangle", nil))
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	want := `hixi_http_request_duration_seconds_count{method="GET",route="/heatmap",status="400"} 1`
+
+	if !strings.Contains(recorder.Body.String(), want) {
+		t.Errorf("metrics output does not contain %q", want)
+	}
+}
@@ -0,0 +1,302 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/tern/v2/migrate"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/schema"
+)
+
+// migrationLockKey is the advisory lock held while migrating, so that
+// processes starting together apply each migration once
+const migrationLockKey = int64(0x68697869) // "hixi"
+
+// schemaPollInterval is how often the wait mode checks the schema version
+const schemaPollInterval = 2 * time.Second
+
+// checkSchemaVersion refuses a schema migrated by a newer binary, whose
+// tables this one may misuse
+func checkSchemaVersion(current int32, known int32) error {
+	if current > known {
+		return fmt.Errorf("database schema is at version %d, newer than the %d migrations of this binary: upgrade hixi", current, known)
+	}
+
+	return nil
+}
+
+// withMigrator runs fn with a migrator loaded with migrations, on a
+// connection held for the duration of the call. The migration lock is held
+// throughout, and fn is not run against a newer schema.
+func withMigrator(ctx context.Context, pool *pgxpool.Pool, migrations fs.FS, fn func(migrator *migrate.Migrator) error) error {
+	conn, err := pool.Acquire(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	defer conn.Release()
+
+	locked := false
+	err = conn.QueryRow(ctx, `SELECT PG_TRY_ADVISORY_LOCK($1)`, migrationLockKey).Scan(&locked)
+
+	if err == nil && !locked {
+		slog.Info("waiting for another process to finish migrating")
+		_, err = conn.Exec(ctx, `SELECT PG_ADVISORY_LOCK($1)`, migrationLockKey)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		ctx := context.WithoutCancel(ctx)
+		_, err := conn.Exec(ctx, `SELECT PG_ADVISORY_UNLOCK($1)`, migrationLockKey)
+
+		// Session locks outlive the call, never return a connection still
+		// holding it to the pool
+		if err != nil {
+			slog.Warn("failed to release migration lock", "error", err)
+			conn.Conn().Close(ctx)
+		}
+	}()
+
+	migrator, err := migrate.NewMigrator(ctx, conn.Conn(), "schema_migrations")
+
+	if err != nil {
+		return fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	err = migrator.LoadMigrations(migrations)
+
+	if err != nil {
+		return fmt.Errorf("failed to load migrations %w", err)
+	}
+
+	current, err := migrator.GetCurrentVersion(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to get current schema version: %w", err)
+	}
+
+	err = checkSchemaVersion(current, int32(len(migrator.Migrations)))
+
+	if err != nil {
+		return err
+	}
+
+	return fn(migrator)
+}
+
+func runDatabaseMigrations(ctx context.Context, pool *pgxpool.Pool, migrations fs.FS) error {
+	return withMigrator(ctx, pool, migrations, func(migrator *migrate.Migrator) error {
+		err := migrator.Migrate(ctx)
+
+		if err != nil {
+			return fmt.Errorf("failed to run migrations: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// prepareSchema runs the startup migration step selected by cfg.Mode
+func prepareSchema(ctx context.Context, pool *pgxpool.Pool, st store.Store, migrations fs.FS, cfg config.Migrations) error {
+	if cfg.Mode == config.MigrationsApply {
+		return runDatabaseMigrations(ctx, pool, migrations)
+	}
+
+	known, err := schema.Version(migrations)
+
+	if err != nil {
+		return err
+	}
+
+	if cfg.Mode == config.MigrationsWait {
+		return waitForSchema(ctx, st, known, cfg.WaitTimeout)
+	}
+
+	current, err := st.SchemaVersion(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if current < known {
+		slog.Warn("database schema migrations are pending", "version", current, "expected", known)
+	}
+
+	return checkSchemaVersion(current, known)
+}
+
+// waitForSchema waits for another process to migrate the schema to known,
+// giving up after timeout unless it is 0
+func waitForSchema(ctx context.Context, st store.Store, known int32, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(schemaPollInterval)
+	defer ticker.Stop()
+
+	for logged := false; ; logged = true {
+		current, err := st.SchemaVersion(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		if current == known {
+			return nil
+		}
+
+		err = checkSchemaVersion(current, known)
+
+		if err != nil {
+			return err
+		}
+
+		if !logged {
+			slog.Info("waiting for database schema migrations", "version", current, "expected", known)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database schema still at version %d, expected %d: %w", current, known, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func migrateCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("migrate", "up|down|status|to N", `Manage database migrations, embedded in the binary unless -schema is given.
+
+  up      apply all pending migrations
+  down    roll back the most recently applied migration
+  status  list migrations and whether they have been applied
+  to N    migrate up or down to version N, 0 rolling back every migration`)
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if err := parseFlags(flags, args); errors.Is(err, errHelp) {
+			return err
+		}
+
+		flags.Usage()
+		return errUsage
+	}
+
+	action := args[0]
+
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	arguments := 0
+
+	if action == "to" {
+		arguments = 1
+	}
+
+	if flags.NArg() != arguments {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	err = cfg.ValidateDatabase()
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	pool, err := connect(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer pool.Close()
+
+	return withMigrator(ctx, pool, cfg.Paths.SchemaFS(), func(migrator *migrate.Migrator) error {
+		switch action {
+		case "up":
+			migrator.OnStart = func(sequence int32, name, direction, sql string) {
+				fmt.Printf("%s %03d %s\n", direction, sequence, name)
+			}
+
+			return migrator.Migrate(ctx)
+
+		case "down":
+			current, err := migrator.GetCurrentVersion(ctx)
+
+			if err != nil {
+				return fmt.Errorf("failed to get current schema version: %w", err)
+			}
+
+			if current == 0 {
+				return fmt.Errorf("no migration to roll back")
+			}
+
+			migrator.OnStart = func(sequence int32, name, direction, sql string) {
+				fmt.Printf("%s %03d %s\n", direction, sequence, name)
+			}
+
+			return migrator.MigrateTo(ctx, current-1)
+
+		case "to":
+			target, err := strconv.ParseInt(flags.Arg(0), 10, 32)
+
+			if err != nil || target < 0 || target > int64(len(migrator.Migrations)) {
+				return fmt.Errorf("invalid target version %q, expected 0 to %d", flags.Arg(0), len(migrator.Migrations))
+			}
+
+			migrator.OnStart = func(sequence int32, name, direction, sql string) {
+				fmt.Printf("%s %03d %s\n", direction, sequence, name)
+			}
+
+			return migrator.MigrateTo(ctx, int32(target))
+
+		case "status":
+			current, err := migrator.GetCurrentVersion(ctx)
+
+			if err != nil {
+				return fmt.Errorf("failed to get current schema version: %w", err)
+			}
+
+			fmt.Printf("current version: %d of %d\n\n", current, len(migrator.Migrations))
+
+			for _, migration := range migrator.Migrations {
+				state := "pending"
+
+				if migration.Sequence <= current {
+					state = "applied"
+				}
+
+				fmt.Printf("  %03d  %-8s %s\n", migration.Sequence, state, migration.Name)
+			}
+
+			return nil
+
+		default:
+			flags.Usage()
+			return errUsage
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/tern/v2/migrate"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/dbtest"
+	"github.com/ngc7293/hixi/internal/store/postgres"
+	"github.com/ngc7293/hixi/schema"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	tests := []struct {
+		current int32
+		wantErr bool
+	}{
+		{current: 0},
+		{current: 5},
+		{current: 6, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := checkSchemaVersion(tt.current, 5); (err != nil) != tt.wantErr {
+			t.Errorf("checkSchemaVersion(%d, 5) error = %v, wantErr %v", tt.current, err, tt.wantErr)
+		}
+	}
+}
+
+func TestConcurrentMigrations(t *testing.T) {
+	pool := dbtest.Empty(t)
+	ctx := context.Background()
+	group := errgroup.Group{}
+
+	for range 4 {
+		group.Go(func() error { return runDatabaseMigrations(ctx, pool, schema.Migrations) })
+	}
+
+	if err := group.Wait(); err != nil {
+		t.Fatalf("runDatabaseMigrations() error = %v", err)
+	}
+
+	known, _ := schema.Version(schema.Migrations)
+	version, err := postgres.New(pool).SchemaVersion(ctx)
+
+	if err != nil || version != known {
+		t.Errorf("SchemaVersion() = %d, %v, want %d", version, err, known)
+	}
+}
+
+func TestWaitForSchema(t *testing.T) {
+	pool := dbtest.Empty(t)
+	ctx := context.Background()
+	st := postgres.New(pool)
+	mode := config.Migrations{Mode: config.MigrationsWait, WaitTimeout: time.Second}
+
+	if err := prepareSchema(ctx, pool, st, schema.Migrations, mode); err == nil {
+		t.Fatal("prepareSchema() succeeded on an empty database, want timeout")
+	}
+
+	waiting := make(chan error)
+	mode.WaitTimeout = time.Minute
+
+	go func() { waiting <- prepareSchema(ctx, pool, st, schema.Migrations, mode) }()
+
+	if err := runDatabaseMigrations(ctx, pool, schema.Migrations); err != nil {
+		t.Fatalf("runDatabaseMigrations() error = %v", err)
+	}
+
+	if err := <-waiting; err != nil {
+		t.Errorf("prepareSchema() error = %v", err)
+	}
+}
+
+func TestNewerSchema(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	st := postgres.New(pool)
+
+	if _, err := pool.Exec(ctx, `UPDATE "schema_migrations" SET "version" = "version" + 1`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []string{config.MigrationsApply, config.MigrationsWait, config.MigrationsOff} {
+		err := prepareSchema(ctx, pool, st, schema.Migrations, config.Migrations{Mode: mode, WaitTimeout: time.Second})
+
+		if err == nil || !strings.Contains(err.Error(), "newer") {
+			t.Errorf("prepareSchema(%s) error = %v, want newer schema", mode, err)
+		}
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+
+	err := withMigrator(ctx, pool, schema.Migrations, func(migrator *migrate.Migrator) error {
+		if err := migrator.MigrateTo(ctx, 0); err != nil {
+			return fmt.Errorf("down: %w", err)
+		}
+
+		if err := migrator.Migrate(ctx); err != nil {
+			return fmt.Errorf("up: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("migrating to 0 and back: %v", err)
+	}
+
+	known, _ := schema.Version(schema.Migrations)
+	version, err := postgres.New(pool).SchemaVersion(ctx)
+
+	if err != nil || version != known {
+		t.Errorf("SchemaVersion() = %d, %v, want %d", version, err, known)
+	}
+}
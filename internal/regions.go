@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/store/postgres"
+)
+
+// customRegion is a polygon to import, with its GeoJSON geometry
+type customRegion struct {
+	name     string
+	geometry json.RawMessage
+}
+
+type geoJSON struct {
+	Type       string          `json:"type"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+	Features   []geoJSON       `json:"features"`
+}
+
+// parseRegions reads the regions of a GeoJSON Polygon or MultiPolygon
+// geometry, Feature or FeatureCollection. Regions are named after the
+// nameProperty of each feature, unless name is given.
+func parseRegions(data []byte, name string, nameProperty string) ([]customRegion, error) {
+	document := geoJSON{}
+
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode GeoJSON: %w", err)
+	}
+
+	switch document.Type {
+	case "Polygon", "MultiPolygon":
+		if name == "" {
+			return nil, errors.New("a bare geometry needs -name")
+		}
+
+		return []customRegion{{name: name, geometry: data}}, nil
+
+	case "Feature":
+		region, err := parseFeature(document, name, nameProperty)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []customRegion{region}, nil
+
+	case "FeatureCollection":
+		if name != "" && len(document.Features) != 1 {
+			return nil, errors.New("-name only applies to a single feature")
+		}
+
+		regions := []customRegion{}
+
+		for i, feature := range document.Features {
+			region, err := parseFeature(feature, name, nameProperty)
+
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+
+			regions = append(regions, region)
+		}
+
+		return regions, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type %q: expected Polygon, MultiPolygon, Feature or FeatureCollection", document.Type)
+	}
+}
+
+func parseFeature(feature geoJSON, name string, nameProperty string) (customRegion, error) {
+	geometry := geoJSON{}
+
+	if err := json.Unmarshal(feature.Geometry, &geometry); err != nil {
+		return customRegion{}, fmt.Errorf("failed to decode geometry: %w", err)
+	}
+
+	if geometry.Type != "Polygon" && geometry.Type != "MultiPolygon" {
+		return customRegion{}, fmt.Errorf("unsupported geometry %q: expected Polygon or MultiPolygon", geometry.Type)
+	}
+
+	if name == "" {
+		name, _ = feature.Properties[nameProperty].(string)
+	}
+
+	if name == "" {
+		return customRegion{}, fmt.Errorf("missing %q property, or -name", nameProperty)
+	}
+
+	return customRegion{name: name, geometry: feature.Geometry}, nil
+}
+
+func regionsCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("regions", "list | import FILE | remove ID", `Manage the regions stations are grouped by. Regions of the system_regions feed
+are synced automatically; custom regions are imported from GeoJSON polygons
+and contain the stations located within them.
+
+  list    list every region and its number of stations
+  import  import the Polygon or MultiPolygon features of a GeoJSON file
+  remove  remove a custom region`)
+	name := flags.String("name", "", "name of the imported region (overrides -name-property)")
+	nameProperty := flags.String("name-property", "name", "feature property naming each imported region")
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if err := parseFlags(flags, args); errors.Is(err, errHelp) {
+			return err
+		}
+
+		flags.Usage()
+		return errUsage
+	}
+
+	action := args[0]
+
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	valid := (action == "list" && flags.NArg() == 0) ||
+		(action == "import" && flags.NArg() == 1) ||
+		(action == "remove" && flags.NArg() == 1)
+
+	if !valid {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	err = cfg.ValidateDatabase()
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	pool, err := connect(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer pool.Close()
+
+	st := postgres.New(pool)
+
+	switch action {
+	case "import":
+		data, err := os.ReadFile(flags.Arg(0))
+
+		if err != nil {
+			return fmt.Errorf("failed to read regions: %w", err)
+		}
+
+		regions, err := parseRegions(data, *name, *nameProperty)
+
+		if err != nil {
+			return err
+		}
+
+		return st.InTx(ctx, func(tx store.Store) error {
+			for _, region := range regions {
+				id, err := tx.Regions().Create(ctx, region.name, region.geometry)
+
+				if err != nil {
+					return fmt.Errorf("failed to import region %q: %w", region.name, err)
+				}
+
+				fmt.Printf("imported region %d: %s\n", id, region.name)
+			}
+
+			return nil
+		})
+
+	case "remove":
+		id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid region id %q", flags.Arg(0))
+		}
+
+		err = st.Regions().Delete(ctx, id)
+
+		if errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("no custom region with id %d", id)
+		}
+
+		return err
+	}
+
+	regions, err := st.Regions().List(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-6s %-16s %-8s %s\n", "id", "region_id", "stations", "name")
+
+	for _, region := range regions {
+		source := "(custom)"
+
+		if region.ExternalID != nil {
+			source = *region.ExternalID
+		}
+
+		fmt.Printf("%-6d %-16s %-8d %s\n", region.ID, source, region.Stations, region.Name)
+	}
+
+	return nil
+}
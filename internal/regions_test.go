@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRegions(t *testing.T) {
+	const polygon = `{"type": "Polygon", "coordinates": [[[-73.58, 45.49], [-73.54, 45.49], [-73.54, 45.51], [-73.58, 45.49]]]}`
+
+	tests := []struct {
+		name      string
+		data      string
+		flagName  string
+		wantNames []string
+		wantErr   string
+	}{
+		{name: "geometry", data: polygon, flagName: "Centre", wantNames: []string{"Centre"}},
+		{name: "unnamed geometry", data: polygon, wantErr: "-name"},
+		{name: "feature", data: `{"type": "Feature", "properties": {"name": "Plateau"}, "geometry": ` + polygon + `}`, wantNames: []string{"Plateau"}},
+		{name: "renamed feature", data: `{"type": "Feature", "properties": {"name": "Plateau"}, "geometry": ` + polygon + `}`, flagName: "Mile End", wantNames: []string{"Mile End"}},
+		{
+			name:      "collection",
+			data:      `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "A"}, "geometry": ` + polygon + `}, {"type": "Feature", "properties": {"name": "B"}, "geometry": ` + polygon + `}]}`,
+			wantNames: []string{"A", "B"},
+		},
+		{
+			name:     "collection with -name",
+			data:     `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "A"}, "geometry": ` + polygon + `}, {"type": "Feature", "properties": {"name": "B"}, "geometry": ` + polygon + `}]}`,
+			flagName: "C",
+			wantErr:  "single feature",
+		},
+		{name: "unnamed feature", data: `{"type": "Feature", "properties": {}, "geometry": ` + polygon + `}`, wantErr: `"name"`},
+		{name: "point", data: `{"type": "Feature", "properties": {"name": "A"}, "geometry": {"type": "Point", "coordinates": [-73.57, 45.5]}}`, wantErr: "Point"},
+		{name: "invalid", data: `{`, wantErr: "decode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regions, err := parseRegions([]byte(tt.data), tt.flagName, "name")
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseRegions() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil || len(regions) != len(tt.wantNames) {
+				t.Fatalf("parseRegions() = %+v, %v, want %v", regions, err, tt.wantNames)
+			}
+
+			for i, region := range regions {
+				if region.name != tt.wantNames[i] || !strings.Contains(string(region.geometry), "Polygon") {
+					t.Errorf("parseRegions()[%d] = %s %s, want %s", i, region.name, region.geometry, tt.wantNames[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ngc7293/hixi/internal/archive"
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/store/postgres"
+	"github.com/ngc7293/hixi/internal/sync"
+)
+
+// openArchive opens the raw archive configured in cfg, if any. The returned
+// function releases it.
+func openArchive(ctx context.Context, cfg *config.Config, pool *pgxpool.Pool) (archive.Store, func(), error) {
+	switch cfg.Archive.Mode {
+	case "disk":
+		store, err := archive.NewDiskStore(cfg.Archive.Dir)
+		return store, func() {}, err
+
+	case "database":
+		if cfg.Archive.DatabaseURL == "" || cfg.Archive.DatabaseURL == cfg.DatabaseURL {
+			return archive.NewDatabaseStore(pool), func() {}, nil
+		}
+
+		archivePool, err := pgxpool.New(ctx, cfg.Archive.DatabaseURL)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to archive database: %w", err)
+		}
+
+		return archive.NewDatabaseStore(archivePool), archivePool.Close, nil
+
+	default:
+		return nil, func() {}, nil
+	}
+}
+
+func replayCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("replay", "", `Re-ingest archived GBFS documents into the database, in the order and with
+the timestamps they were originally fetched at. The archive is read according
+to the archive settings; replay into a fresh database (database_url) to
+reproduce an ingestion bug.`)
+	from := flags.String("from", "", "start of the replay window, as RFC 3339 or YYYY-MM-DD (required)")
+	to := flags.String("to", "", "end of the replay window, as RFC 3339 or YYYY-MM-DD (default: now)")
+	migrate := flags.Bool("migrate", true, "apply pending database migrations first")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 || *from == "" {
+		flags.Usage()
+		return errUsage
+	}
+
+	start, err := parseExportTime(*from)
+
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+
+	end := time.Now()
+
+	if *to != "" {
+		end, err = parseExportTime(*to)
+
+		if err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	err = cfg.ValidateArchive()
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	normalizer, err := sync.NormalizerFor(cfg.GBFS.System)
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: gbfs.system: %w", err)
+	}
+
+	pool, err := connect(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer pool.Close()
+
+	if *migrate {
+		err = runDatabaseMigrations(ctx, pool, cfg.Paths.SchemaFS())
+
+		if err != nil {
+			return fmt.Errorf("failed to run database migrations: %w", err)
+		}
+	}
+
+	st := postgres.New(pool)
+	store, release, err := openArchive(ctx, cfg, pool)
+
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	// Station information and vehicle types come first at equal timestamps,
+	// so that stations are known before their status is validated
+	snapshots, err := archive.Merge(ctx, store, []string{"station_information", "vehicle_types", "station_status", "system_regions", "system_alerts", "system_pricing_plans", "geofencing_zones"}, start, end)
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("replaying snapshots", "count", len(snapshots), "from", start, "to", end)
+
+	for i, snapshot := range snapshots {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		body, err := store.Load(ctx, snapshot.Hash)
+
+		if err != nil {
+			return err
+		}
+
+		fetcher := sync.ArchivedFetcher{Body: body, FetchedAt: snapshot.FetchedAt}
+
+		switch snapshot.Feed {
+		case "station_information":
+			_, err = sync.FetchStationInformationOnce(ctx, st, fetcher, snapshot.URL, 0, normalizer)
+		case "vehicle_types":
+			_, err = sync.FetchVehicleTypesOnce(ctx, st, fetcher, snapshot.URL, 0)
+		case "station_status":
+			_, err = sync.FetchStationStatusOnce(ctx, st, fetcher, snapshot.URL, 0, normalizer, cfg.Validation)
+		case "system_regions":
+			_, err = sync.FetchSystemRegionsOnce(ctx, st, fetcher, snapshot.URL, 0)
+		case "system_alerts":
+			_, err = sync.FetchSystemAlertsOnce(ctx, st, fetcher, snapshot.URL, 0)
+		case "system_pricing_plans":
+			_, err = sync.FetchSystemPricingPlansOnce(ctx, st, fetcher, snapshot.URL, 0)
+		case "geofencing_zones":
+			_, err = sync.FetchGeofencingZonesOnce(ctx, st, fetcher, snapshot.URL, 0)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to replay %s snapshot fetched at %s: %w", snapshot.Feed, snapshot.FetchedAt.Format(time.RFC3339), err)
+		}
+
+		if (i+1)%1000 == 0 {
+			slog.Info("replay progress", "replayed", i+1, "of", len(snapshots), "at", snapshot.FetchedAt)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "replayed %d snapshots\n", len(snapshots))
+	return nil
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/store/postgres"
+)
+
+// retentionPolicies returns the configured policy of each relation. Vehicle
+// type availability is kept like the rest of the availability.
+func retentionPolicies(cfg config.Retention) []store.RetentionPolicy {
+	return []store.RetentionPolicy{
+		{Relation: "live_station_availability", DropAfter: cfg.LiveAvailability.DropAfter, CompressAfter: cfg.LiveAvailability.CompressAfter},
+		{Relation: "historical_station_availability", DropAfter: cfg.HistoricalAvailability.DropAfter, CompressAfter: cfg.HistoricalAvailability.CompressAfter},
+		{Relation: "live_station_vehicle_availability", DropAfter: cfg.LiveAvailability.DropAfter, CompressAfter: cfg.LiveAvailability.CompressAfter},
+		{Relation: "historical_station_vehicle_availability", DropAfter: cfg.HistoricalAvailability.DropAfter, CompressAfter: cfg.HistoricalAvailability.CompressAfter},
+		{Relation: "station_flow", DropAfter: cfg.StationFlow.DropAfter, CompressAfter: cfg.StationFlow.CompressAfter},
+	}
+}
+
+// applyRetention applies the configured retention policies
+func applyRetention(ctx context.Context, st store.Store, cfg config.Retention) error {
+	policies := retentionPolicies(cfg)
+	err := st.Retention().Apply(ctx, policies)
+
+	if err != nil {
+		return fmt.Errorf("failed to apply retention policies: %w", err)
+	}
+
+	for _, policy := range policies {
+		slog.Info("applied retention policy", "relation", policy.Relation, "drop_after", policy.DropAfter, "compress_after", policy.CompressAfter)
+	}
+
+	return nil
+}
+
+// formatAfter prints a policy duration, in days when round
+func formatAfter(after time.Duration) string {
+	switch {
+	case after == 0:
+		return "never"
+	case after%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", after/(24*time.Hour))
+	default:
+		return after.String()
+	}
+}
+
+func retentionCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("retention", "apply|status", `Manage the retention and compression policies of PostgreSQL.
+
+  apply   apply the policies of the retention configuration
+  status  list the policies in effect and the chunks of each relation`)
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if err := parseFlags(flags, args); errors.Is(err, errHelp) {
+			return err
+		}
+
+		flags.Usage()
+		return errUsage
+	}
+
+	action := args[0]
+
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 || (action != "apply" && action != "status") {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	err = cfg.ValidateDatabase()
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	pool, err := connect(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer pool.Close()
+
+	st := postgres.New(pool)
+
+	if action == "apply" {
+		return applyRetention(ctx, st, cfg.Retention)
+	}
+
+	statuses, err := st.Retention().List(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-32s %-10s %-10s %s\n", "relation", "drop", "compress", "chunks")
+
+	for _, status := range statuses {
+		fmt.Printf("%-32s %-10s %-10s %d (%d compressed)\n", status.Relation, formatAfter(status.DropAfter), formatAfter(status.CompressAfter), status.Chunks, status.CompressedChunks)
+	}
+
+	return nil
+}
@@ -0,0 +1,371 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/metrics"
+	"github.com/ngc7293/hixi/internal/server"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/store/postgres"
+	"github.com/ngc7293/hixi/internal/store/sqlite"
+	"github.com/ngc7293/hixi/internal/sync"
+	"github.com/ngc7293/hixi/pkg/gbfs"
+	"github.com/ngc7293/hixi/schema"
+)
+
+type feedURLs struct {
+	stationStatus      string
+	stationInformation string
+	systemRegions      string // optional feeds are empty if the operator does not publish them
+	systemAlerts       string
+	vehicleTypes       string
+	systemPricingPlans string
+	geofencingZones    string
+}
+
+// findOptionalFeed finds a feed operators may not publish, returning an empty
+// URL if it is missing
+func findOptionalFeed(discovery gbfs.GBFSDiscoveryData, name string, preferLanguage string) (string, error) {
+	url, lang, err := sync.FindFeedURLWithLanguage(discovery, name, preferLanguage)
+
+	if errors.Is(err, sync.ErrFeedNotFound) {
+		slog.Info("optional feed not published", "feed", name)
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s: %w", name, err)
+	}
+
+	slog.Info("found "+name, "url", url, "language", lang)
+	return url, nil
+}
+
+// discoverFeeds finds the station feeds in the GBFS discovery document
+func discoverFeeds(ctx context.Context, cfg *config.Config) (*feedURLs, error) {
+	discovery, err := sync.FetchDocument[gbfs.GBFSDiscoveryData](ctx, cfg.GBFS.DiscoveryURL, cfg.GBFS.Timeout)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gbfs discovery: %w", err)
+	}
+
+	stationStatusUrl, lang, err := sync.FindFeedURLWithLanguage(discovery.Data, "station_status", cfg.GBFS.PreferLanguage)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find station_status: %w", err)
+	}
+
+	slog.Info("found station_status", "url", stationStatusUrl, "language", lang)
+
+	stationInformationUrl, lang, err := sync.FindFeedURLWithLanguage(discovery.Data, "station_information", cfg.GBFS.PreferLanguage)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find station_information: %w", err)
+	}
+
+	slog.Info("found station_information", "url", stationInformationUrl, "language", lang)
+
+	feeds := &feedURLs{stationStatus: stationStatusUrl, stationInformation: stationInformationUrl}
+
+	optional := []struct {
+		name string
+		url  *string
+	}{
+		{"system_regions", &feeds.systemRegions},
+		{"system_alerts", &feeds.systemAlerts},
+		{"vehicle_types", &feeds.vehicleTypes},
+		{"system_pricing_plans", &feeds.systemPricingPlans},
+		{"geofencing_zones", &feeds.geofencingZones},
+	}
+
+	for _, feed := range optional {
+		*feed.url, err = findOptionalFeed(discovery.Data, feed.name, cfg.GBFS.PreferLanguage)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return feeds, nil
+}
+
+// openStore opens the database configured in cfg, running the migration step
+// of migrations.mode and applying retention policies on PostgreSQL. pool is
+// nil for SQLite. The returned
+// function closes the database.
+func openStore(ctx context.Context, cfg *config.Config) (store.Store, *pgxpool.Pool, func(), error) {
+	if path, ok := cfg.SQLitePath(); ok {
+		st, err := sqlite.Open(ctx, path, cfg.Retention.LiveAvailability.DropAfter)
+
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		slog.Info("using embedded database", "path", path)
+		return st, nil, func() { st.Close() }, nil
+	}
+
+	pool, err := connect(ctx, cfg)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	st := postgres.New(pool)
+	err = prepareSchema(ctx, pool, st, cfg.Paths.SchemaFS(), cfg.Migrations)
+
+	if err != nil {
+		pool.Close()
+		return nil, nil, nil, fmt.Errorf("failed to prepare database schema: %w", err)
+	}
+
+	if cfg.Retention.Apply {
+		err = applyRetention(ctx, st, cfg.Retention)
+
+		if err != nil {
+			pool.Close()
+			return nil, nil, nil, err
+		}
+	}
+
+	err = metrics.RegisterPool(pool)
+
+	if err != nil {
+		pool.Close()
+		return nil, nil, nil, fmt.Errorf("failed to register pool metrics: %w", err)
+	}
+
+	return st, pool, pool.Close, nil
+}
+
+// knownSchemaVersion is the schema version this binary migrates the
+// configured database to
+func knownSchemaVersion(cfg *config.Config) (int32, error) {
+	// The embedded database has its own schema steps
+	if _, ok := cfg.SQLitePath(); ok {
+		return sqlite.Version()
+	}
+
+	return schema.Version(cfg.Paths.SchemaFS())
+}
+
+// start runs the sync loops and/or the API server according to the api_only
+// and sync_only settings. The first failure, or ctx being cancelled, stops
+// every component; start returns once they have all wound down.
+func start(ctx context.Context, cfg *config.Config) error {
+	err := checkConfig(cfg)
+
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	st, pool, closeStore, err := openStore(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	schemaVersion, err := knownSchemaVersion(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	// Set up the sync before starting any component, so that a failure does
+	// not leave them running
+	var feeds *feedURLs
+	var normalizer sync.Normalizer
+	var fetcher sync.HTTPFetcher
+
+	if !cfg.APIOnly {
+		feeds, err = discoverFeeds(ctx, cfg)
+
+		if err != nil {
+			return err
+		}
+
+		normalizer, err = sync.NormalizerFor(cfg.GBFS.System)
+
+		if err != nil {
+			return err
+		}
+
+		archive, release, err := openArchive(ctx, cfg, pool)
+
+		if err != nil {
+			return err
+		}
+
+		defer release()
+
+		fetcher = sync.HTTPFetcher{Archive: archive}
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+
+	if cfg.Server.MetricsListen != "" {
+		group.Go(func() error { return server.ServeAdmin(ctx, st, cfg.Server.MetricsListen) })
+	}
+
+	if !cfg.APIOnly {
+		group.Go(func() error {
+			return sync.FetchStationStatusLoop(ctx, st, fetcher, feeds.stationStatus, cfg.Feeds.StationStatus, normalizer, cfg.Validation)
+		})
+
+		group.Go(func() error {
+			return sync.FetchStationInformationLoop(ctx, st, fetcher, feeds.stationInformation, cfg.Feeds.StationInformation, normalizer)
+		})
+
+		if feeds.systemRegions != "" {
+			group.Go(func() error {
+				return sync.FetchSystemRegionsLoop(ctx, st, fetcher, feeds.systemRegions, cfg.Feeds.SystemRegions)
+			})
+		}
+
+		if feeds.systemAlerts != "" {
+			group.Go(func() error {
+				return sync.FetchSystemAlertsLoop(ctx, st, fetcher, feeds.systemAlerts, cfg.Feeds.SystemAlerts)
+			})
+		}
+
+		if feeds.vehicleTypes != "" {
+			group.Go(func() error {
+				return sync.FetchVehicleTypesLoop(ctx, st, fetcher, feeds.vehicleTypes, cfg.Feeds.VehicleTypes)
+			})
+		}
+
+		if feeds.systemPricingPlans != "" {
+			group.Go(func() error {
+				return sync.FetchSystemPricingPlansLoop(ctx, st, fetcher, feeds.systemPricingPlans, cfg.Feeds.SystemPricingPlans)
+			})
+		}
+
+		if feeds.geofencingZones != "" {
+			group.Go(func() error {
+				return sync.FetchGeofencingZonesLoop(ctx, st, fetcher, feeds.geofencingZones, cfg.Feeds.GeofencingZones)
+			})
+		}
+	}
+
+	if !cfg.SyncOnly {
+		group.Go(func() error { return server.Serve(ctx, st, cfg, schemaVersion) })
+	}
+
+	err = group.Wait()
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("shut down cleanly")
+	return nil
+}
+
+func runCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("run", "[gbfs-discovery-url]", "Sync GBFS feeds and serve the API from a single process.")
+	migrations := flags.String("migrations", "", "apply, wait or off: how to migrate the database on startup (overrides migrations.mode)")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		cfg.GBFS.DiscoveryURL = flags.Arg(0)
+	default:
+		flags.Usage()
+		return errUsage
+	}
+
+	if *migrations != "" {
+		cfg.Migrations.Mode = *migrations
+	}
+
+	return start(ctx, cfg)
+}
+
+func serveCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("serve", "", "Serve the API, map tiles and UI without syncing GBFS feeds.")
+	listen := flags.String("listen", "", "address to listen on (overrides server.listen)")
+	migrations := flags.String("migrations", "", "apply, wait or off: how to migrate the database on startup (overrides migrations.mode)")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	cfg.APIOnly, cfg.SyncOnly = true, false
+
+	if *listen != "" {
+		cfg.Server.Listen = *listen
+	}
+
+	if *migrations != "" {
+		cfg.Migrations.Mode = *migrations
+	}
+
+	return start(ctx, cfg)
+}
+
+func syncCommand(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("sync", "[gbfs-discovery-url]", "Sync GBFS feeds into the database without serving the API.")
+	language := flags.String("language", "", "preferred feed language (overrides gbfs.prefer_language)")
+	migrations := flags.String("migrations", "", "apply, wait or off: how to migrate the database on startup (overrides migrations.mode)")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cfg, err := common.load()
+
+	if err != nil {
+		return err
+	}
+
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		cfg.GBFS.DiscoveryURL = flags.Arg(0)
+	default:
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg.APIOnly, cfg.SyncOnly = false, true
+
+	if *language != "" {
+		cfg.GBFS.PreferLanguage = *language
+	}
+
+	if *migrations != "" {
+		cfg.Migrations.Mode = *migrations
+	}
+
+	return start(ctx, cfg)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+func alertResponse(alert store.Alert, now time.Time) v1.Alert {
+	response := v1.Alert{
+		ID:          alert.ExternalID,
+		Type:        alert.Type,
+		Times:       []v1.AlertWindow{},
+		StationIDs:  alert.StationIDs,
+		RegionIDs:   alert.RegionIDs,
+		URL:         alert.URL,
+		Summary:     alert.Summary,
+		Description: alert.Description,
+		LastUpdated: unixPtr(alert.LastUpdated),
+		Withdrawn:   unixPtr(alert.Withdrawn),
+		Active:      alert.ActiveAt(now),
+	}
+
+	if response.StationIDs == nil {
+		response.StationIDs = []string{}
+	}
+
+	if response.RegionIDs == nil {
+		response.RegionIDs = []string{}
+	}
+
+	for _, window := range alert.Times {
+		response.Times = append(response.Times, v1.AlertWindow{
+			Start: window.Start.Unix(),
+			End:   unixPtr(window.End),
+		})
+	}
+
+	return response
+}
+
+// activeAlerts returns the alerts in effect at now
+func (api *Handler) activeAlerts(ctx context.Context, now time.Time) ([]store.Alert, error) {
+	alerts, err := api.store.Alerts().List(ctx, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	active := []store.Alert{}
+
+	for _, alert := range alerts {
+		if alert.ActiveAt(now) {
+			active = append(active, alert)
+		}
+	}
+
+	return active, nil
+}
+
+// ListAlerts lists the alerts of system_alerts still published at since (a
+// unix timestamp, now by default), including those withdrawn after it
+func (api *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	since, err := parseTimeParam(r, "since", now)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	alerts, err := api.store.Alerts().List(r.Context(), since)
+
+	if err != nil {
+		slog.Error("failed to query alerts", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := v1.ListAlertsResponse{Alerts: []v1.Alert{}}
+
+	for _, alert := range alerts {
+		response.Alerts = append(response.Alerts, alertResponse(alert, now))
+	}
+
+	writeJSON(w, r, response, "max-age=60, public")
+}
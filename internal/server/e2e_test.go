@@ -0,0 +1,157 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/dbtest"
+	"github.com/ngc7293/hixi/internal/gbfstest"
+	"github.com/ngc7293/hixi/internal/server"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/store/postgres"
+	"github.com/ngc7293/hixi/internal/store/sqlite"
+	"github.com/ngc7293/hixi/internal/sync"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+	"github.com/ngc7293/hixi/schema"
+)
+
+func get[T any](t *testing.T, handler http.Handler, path string, wantStatus int) T {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+	if recorder.Code != wantStatus {
+		t.Fatalf("GET %s = %d %s, want %d", path, recorder.Code, recorder.Body.String(), wantStatus)
+	}
+
+	var response T
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("GET %s: invalid response: %v", path, err)
+	}
+
+	return response
+}
+
+// TestSyncToAPI syncs a fake operator's feeds and reads them back through
+// the API
+func TestSyncToAPI(t *testing.T) {
+	known, _ := schema.Version(schema.Migrations)
+	testSyncToAPI(t, postgres.New(dbtest.New(t)), "", known, false)
+}
+
+func TestSyncToAPISQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hixi.db")
+	st, err := sqlite.Open(context.Background(), path, config.Default().Retention.LiveAvailability.DropAfter)
+
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	defer st.Close()
+
+	known, err := sqlite.Version()
+
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+
+	testSyncToAPI(t, st, "sqlite:"+path, known, true)
+}
+
+// testSyncToAPI runs against the database at databaseURL. Unless the store
+// aggregates as it goes, nothing has been aggregated when the API is queried.
+func testSyncToAPI(t *testing.T, st store.Store, databaseURL string, schemaVersion int32, aggregated bool) {
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+	now := time.Now().Truncate(time.Second)
+	capacity := int64(20)
+	ebikes := int64(2)
+
+	operator.Push("station_information", now, 3600, v1_0.StationInformationData{Stations: []v1_0.StationInformationStation{
+		{StationID: "42", Name: "Metcalfe / Square Dorchester", Lat: 45.5, Lon: -73.57, Capacity: &capacity},
+	}})
+
+	// BIXI counts its ebikes in num_bikes_available
+	type bixiStationStatus struct {
+		v1_0.StationStatus
+		NumEbikesAvailable *int64 `json:"num_ebikes_available"`
+	}
+
+	operator.Push("station_status", now, 10, map[string][]bixiStationStatus{"stations": {
+		{StationStatus: v1_0.StationStatus{StationID: "42", NumBikesAvailable: 7, NumDocksAvailable: 13, LastReported: now.Unix()}, NumEbikesAvailable: &ebikes},
+	}})
+
+	cfg := config.Default()
+	cfg.DatabaseURL = databaseURL
+	cfg.Paths.Static = t.TempDir()
+	cfg.Map.URL = "https://tiles.example.com/{z}/{x}/{y}.png"
+
+	normalizer, _ := sync.NormalizerFor(cfg.GBFS.System)
+
+	if _, err := sync.FetchStationInformationOnce(ctx, st, sync.HTTPFetcher{}, operator.FeedURL("station_information"), time.Second, normalizer); err != nil {
+		t.Fatalf("FetchStationInformationOnce() error = %v", err)
+	}
+
+	if _, err := sync.FetchStationStatusOnce(ctx, st, sync.HTTPFetcher{}, operator.FeedURL("station_status"), time.Second, normalizer, cfg.Validation); err != nil {
+		t.Fatalf("FetchStationStatusOnce() error = %v", err)
+	}
+
+	handler, err := server.NewHandler(st, cfg, schemaVersion)
+
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+
+	stations := get[v1.ListStationResponse](t, handler, "/stations", http.StatusOK)
+
+	if len(stations.Features) != 1 || !stations.Features[0].Properties.Active || stations.Features[0].Properties.Name != "Metcalfe / Square Dorchester" {
+		t.Fatalf("/stations = %+v, want one active station", stations)
+	}
+
+	id := stations.Features[0].Properties.ID
+	station := get[v1.GetStationResponse](t, handler, fmt.Sprintf("/stations/%d", id), http.StatusOK)
+
+	if station.CurrentAvailability.BikesAvailable != 5 || station.CurrentAvailability.EbikesAvailable != 2 {
+		t.Errorf("current availability = %+v, want 5 bikes and 2 ebikes", station.CurrentAvailability)
+	}
+
+	if station.Capacity == nil || *station.Capacity != capacity {
+		t.Errorf("capacity = %v, want %d", station.Capacity, capacity)
+	}
+
+	health := get[v1.HealthResponse](t, handler, "/health?detail=true", http.StatusOK)
+
+	if health.Status != "ok" {
+		t.Errorf("/health = %+v, want ok", health)
+	}
+
+	wantReady := http.StatusServiceUnavailable
+
+	if aggregated {
+		wantReady = http.StatusOK
+	}
+
+	ready := get[v1.HealthResponse](t, handler, "/ready", wantReady)
+
+	for _, check := range ready.Checks {
+		if check.OK != (aggregated || check.Name != "aggregate") {
+			t.Errorf("/ready check %s = %+v", check.Name, check)
+		}
+	}
+
+	issues := get[v1.ListDataQualityIssuesResponse](t, handler, "/quality/issues", http.StatusOK)
+
+	if len(issues.Issues) != 0 {
+		t.Errorf("/quality/issues = %+v, want none", issues)
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+func geofencingRuleResponse(rule store.GeofencingRule) v1.GeofencingRule {
+	response := v1.GeofencingRule{
+		VehicleTypeIDs:     rule.VehicleTypeIDs,
+		RideAllowed:        rule.RideAllowed,
+		RideThroughAllowed: rule.RideThroughAllowed,
+		MaximumSpeedKph:    rule.MaximumSpeedKph,
+		StationParking:     rule.StationParking,
+	}
+
+	if response.VehicleTypeIDs == nil {
+		response.VehicleTypeIDs = []string{}
+	}
+
+	return response
+}
+
+func geofencingZoneResponse(zone store.GeofencingZone, now time.Time) v1.GeofencingZone {
+	response := v1.GeofencingZone{
+		Name:   zone.Name,
+		Start:  unixPtr(zone.Start),
+		End:    unixPtr(zone.End),
+		Active: zone.ActiveAt(now),
+		Rules:  []v1.GeofencingRule{},
+	}
+
+	for _, rule := range zone.Rules {
+		response.Rules = append(response.Rules, geofencingRuleResponse(rule))
+	}
+
+	return response
+}
+
+// parseCoordinateParam parses a longitude or latitude query parameter, within
+// [-limit, limit]
+func parseCoordinateParam(r *http.Request, name string, limit float64) (float64, error) {
+	value, err := strconv.ParseFloat(r.URL.Query().Get(name), 64)
+
+	if err != nil || value < -limit || value > limit {
+		return 0, fmt.Errorf("invalid %s: expected degrees within ±%g", name, limit)
+	}
+
+	return value, nil
+}
+
+// ListGeofencingZones lists the zones of geofencing_zones as GeoJSON, by
+// precedence
+func (api *Handler) ListGeofencingZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := api.store.GeofencingZones().List(r.Context())
+
+	if err != nil {
+		slog.Error("failed to query geofencing zones", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	response := v1.GeofencingZonesResponse{
+		Type:     "FeatureCollection",
+		Features: []v1.GeofencingZoneFeature{},
+	}
+
+	for _, zone := range zones {
+		feature := v1.GeofencingZoneFeature{
+			Type:       "Feature",
+			Properties: geofencingZoneResponse(zone, now),
+		}
+
+		err = json.Unmarshal(zone.Geometry, &feature.Geometry)
+
+		if err != nil {
+			slog.Error("failed to decode geofencing zone", "path", r.URL.Path, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		response.Features = append(response.Features, feature)
+	}
+
+	content, err := json.Marshal(response)
+
+	if err != nil {
+		slog.Error("failed to marshal response", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "max-age=300, public")
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(content)
+
+	if err != nil {
+		slog.Error("failed to write response", "path", r.URL.Path, "error", err)
+		return
+	}
+}
+
+// GetGeofencingRules returns the zones containing a point (lon and lat) in
+// effect at a unix timestamp (now by default), and the rule applying there to
+// vehicle_type_id, or to every vehicle if omitted
+func (api *Handler) GetGeofencingRules(w http.ResponseWriter, r *http.Request) {
+	lon, err := parseCoordinateParam(r, "lon", 180)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lat, err := parseCoordinateParam(r, "lat", 90)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	at, err := parseTimeParam(r, "at", time.Now())
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	zones, err := api.store.GeofencingZones().At(r.Context(), lon, lat)
+
+	if err != nil {
+		slog.Error("failed to query geofencing zones", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := v1.GeofencingRulesResponse{
+		Coordinates: [2]float64{lon, lat},
+		Zones:       []v1.GeofencingZone{},
+	}
+
+	vehicleTypeID := r.URL.Query().Get("vehicle_type_id")
+
+	if vehicleTypeID != "" {
+		response.VehicleTypeID = &vehicleTypeID
+	}
+
+	for _, zone := range zones {
+		if !zone.ActiveAt(at) {
+			continue
+		}
+
+		response.Zones = append(response.Zones, geofencingZoneResponse(zone, at))
+
+		if rule := zone.RuleFor(vehicleTypeID); rule != nil && response.Rule == nil {
+			applied := geofencingRuleResponse(*rule)
+			response.Rule = &applied
+		}
+	}
+
+	writeJSON(w, r, response, "max-age=60, public")
+}
+
+// GeofencingZoneTile renders the zones within a web mercator tile as a Mapbox
+// Vector Tile, for the map to overlay
+func (api *Handler) GeofencingZoneTile(w http.ResponseWriter, r *http.Request) {
+	tile, err := parseMapTile(r.PathValue("z"), r.PathValue("x"), r.PathValue("y"))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	content, err := api.store.GeofencingZones().Tile(r.Context(), tile.Z, tile.X, tile.Y)
+
+	if errors.Is(err, store.ErrUnsupported) {
+		http.Error(w, "vector tiles not supported by this storage backend", http.StatusNotImplemented)
+		return
+	}
+
+	if err != nil {
+		slog.Error("failed to render geofencing zone tile", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "max-age=300, public")
+	w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(content)
+
+	if err != nil {
+		slog.Error("failed to write geofencing zone tile", "path", r.URL.Path, "error", err)
+		return
+	}
+}
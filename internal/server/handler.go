@@ -1,23 +1,71 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"strings"
+	"strconv"
 	"time"
 
 	"log/slog"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/metrics"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/tilecache"
 	v1 "github.com/ngc7293/hixi/pkg/api/v1"
 )
 
 type Handler struct {
-	pool   *pgxpool.Pool
-	mapUrl string
+	store      store.Store
+	mapLayers  map[string]string
+	mapBounds  *bounds
+	mapClient  *http.Client
+	mapLimiter *rateLimiter
+	trustProxy bool
+	tiles      *tilecache.Cache
+
+	schemaVersion   int32
+	feeds           config.Feeds
+	maxAggregateLag time.Duration
+}
+
+// stationActiveWindow is how recently a station must have reported to be
+// listed as active
+const stationActiveWindow = 2 * time.Hour
+
+// unixPtr converts an optional time to unix seconds
+func unixPtr(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+
+	seconds := t.Unix()
+	return &seconds
+}
+
+// stationMetadata converts the metadata of a station, listing no rental
+// methods as an empty array
+func stationMetadata(shortName *string, metadata store.StationMetadata) v1.StationMetadata {
+	rentalMethods := metadata.RentalMethods
+
+	if rentalMethods == nil {
+		rentalMethods = []string{}
+	}
+
+	return v1.StationMetadata{
+		ShortName:                   shortName,
+		Address:                     metadata.Address,
+		CrossStreet:                 metadata.CrossStreet,
+		RegionID:                    metadata.RegionID,
+		PostCode:                    metadata.PostCode,
+		RentalMethods:               rentalMethods,
+		HasKiosk:                    metadata.HasKiosk,
+		ElectricBikeSurchargeWaiver: metadata.ElectricBikeSurchargeWaiver,
+		IsCharging:                  metadata.IsCharging,
+	}
 }
 
 func (api *Handler) ListStation(w http.ResponseWriter, r *http.Request) {
@@ -25,62 +73,64 @@ func (api *Handler) ListStation(w http.ResponseWriter, r *http.Request) {
 		Type: "FeatureCollection",
 	}
 
-	{
-		rows, err := api.pool.Query(r.Context(), `
-			WITH "station_status" AS (
-				SELECT
-					"station_id" AS "id",
-					MAX("time") > (NOW() - '2 hours'::INTERVAL) AS "active"
-				FROM "public"."live_station_availability"
-				GROUP BY "station_id"
-			)
-			SELECT
-				"id",
-				"name",
-				ST_X("location"),
-				ST_Y("location"),
-				COALESCE("active", false)
-			FROM "public"."station"
-			LEFT JOIN "station_status" USING ("id")
-			WHERE "location" IS NOT NULL`,
-		)
+	query := r.URL.Query()
+	filter := store.StationFilter{
+		ActiveSince:  time.Now().Add(-stationActiveWindow),
+		RentalMethod: query.Get("rental_method"),
+	}
+
+	if value := query.Get("kiosk"); value != "" {
+		kiosk, err := strconv.ParseBool(value)
 
 		if err != nil {
-			slog.Error("failed to query stations", "path", r.URL.Path, "error", err)
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			http.Error(w, "invalid kiosk: expected true or false", http.StatusBadRequest)
 			return
 		}
 
-		defer rows.Close()
+		filter.HasKiosk = &kiosk
+	}
 
-		for rows.Next() {
-			var id int64
-			var name string
-			var lon, lat float64
-			var active bool
+	stations, err := api.store.Stations().List(r.Context(), filter)
 
-			err := rows.Scan(&id, &name, &lon, &lat, &active)
+	if err != nil {
+		slog.Error("failed to query stations", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
 
-			if err != nil {
-				slog.Error("failed to query stations", "path", r.URL.Path, "error", err)
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
+	alerts, err := api.activeAlerts(r.Context(), time.Now())
 
-			response.Features = append(response.Features, v1.StationFeature{
-				Type:       "Feature",
-				Properties: v1.StationFeatureProperties{ID: id, Name: name, Active: active},
-				Geometry:   v1.GeoJSONPoint{Type: "Point", Coordinates: [2]float64{lon, lat}},
-			})
-		}
+	if err != nil {
+		slog.Error("failed to query alerts", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
 
-		err = rows.Err()
+	for _, station := range stations {
+		properties := v1.StationFeatureProperties{
+			ID:              station.ID,
+			Name:            station.Name,
+			Active:          station.Active,
+			Decommissioned:  station.Decommissioned,
+			StationMetadata: stationMetadata(station.ShortName, station.Metadata),
+			Alerts:          []v1.StationAlert{},
+		}
 
-		if err != nil {
-			slog.Error("failed to query stations", "path", r.URL.Path, "error", err)
-			http.Error(w, "internal error", http.StatusInternalServerError)
-			return
+		for _, alert := range alerts {
+			if alert.Affects(station.ExternalID, station.Metadata.RegionID) {
+				properties.Alerts = append(properties.Alerts, v1.StationAlert{
+					ID:      alert.ExternalID,
+					Type:    alert.Type,
+					Summary: alert.Summary,
+				})
+			}
 		}
+
+		response.Features = append(response.Features, v1.StationFeature{
+			Type:       "Feature",
+			Properties: properties,
+			Geometry:   v1.GeoJSONPoint{Type: "Point", Coordinates: [2]float64{station.Lon, station.Lat}},
+		})
 	}
 
 	content, err := json.Marshal(response)
@@ -104,95 +154,114 @@ func (api *Handler) ListStation(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *Handler) GetStation(w http.ResponseWriter, r *http.Request) {
-	stationID := r.PathValue("stationId")
+	stationID, err := strconv.ParseInt(r.PathValue("stationId"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "invalid station id", http.StatusBadRequest)
+		return
+	}
+
 	response := v1.GetStationResponse{}
 
 	{
-		rows, err := api.pool.Query(r.Context(), `
-			SELECT
-				TIME_BUCKET('15 minutes'::INTERVAL, "time_bucket") AS "time_bucket",
-				"bikes_available",
-				"ebikes_available"
-			FROM "public"."historical_station_availability"
-			WHERE
-				"station_id" = $1
-				AND "time_bucket" BETWEEN NOW() - '1 DAY'::INTERVAL AND NOW()
-			ORDER BY "time_bucket"
-			`,
-			stationID,
-		)
+		station, err := api.store.Stations().Get(r.Context(), stationID)
+
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "station not found", http.StatusNotFound)
+			return
+		}
 
 		if err != nil {
-			slog.Error("failed to write response", "path", r.URL.Path, "error", err)
+			slog.Error("failed to query station", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
-		defer rows.Close()
+		response.Name = station.Name
+		response.StationMetadata = stationMetadata(station.ShortName, station.Metadata)
+		response.Capacity = station.Capacity
 
-		for rows.Next() {
-			var timeBucket time.Time
-			var bikesAvailable, ebikesAvailable float64
+		now := time.Now()
+		alerts, err := api.activeAlerts(r.Context(), now)
 
-			err := rows.Scan(&timeBucket, &bikesAvailable, &ebikesAvailable)
+		if err != nil {
+			slog.Error("failed to query alerts", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
 
-			if err != nil {
-				slog.Error("failed to query stations", "error", err)
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
+		response.Alerts = []v1.Alert{}
 
-			response.HistoricalAvailability = append(response.HistoricalAvailability, v1.Availability{
-				Time:            timeBucket.Unix(),
-				BikesAvailable:  bikesAvailable,
-				EbikesAvailable: ebikesAvailable,
-			})
+		for _, alert := range alerts {
+			if alert.Affects(station.ExternalID, station.Metadata.RegionID) {
+				response.Alerts = append(response.Alerts, alertResponse(alert, now))
+			}
 		}
+	}
 
-		err = rows.Err()
+	vehicleTypeIDs, err := api.vehicleTypeIDs(r.Context())
 
-		if err != nil {
-			slog.Error("failed to query stations", "error", err)
-			http.Error(w, "internal error", http.StatusInternalServerError)
-			return
-		}
+	if err != nil {
+		slog.Error("failed to query vehicle types", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
 	}
 
-	{
-		var capacity *int64
+	versions, err := api.store.Stations().History(r.Context(), stationID)
+
+	if err != nil {
+		slog.Error("failed to query station versions", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
 
-		err := api.pool.QueryRow(r.Context(), `
-			SELECT
-				"capacity"
-			FROM "public"."station"
-			WHERE "id" = $1
-			LIMIT 1`,
-			stationID,
-		).Scan(&capacity)
+	response.Versions = []v1.StationVersion{}
+
+	for _, version := range versions {
+		response.Versions = append(response.Versions, v1.StationVersion{
+			ValidFrom:   version.ValidFrom.Unix(),
+			ValidTo:     unixPtr(version.ValidTo),
+			Name:        version.Name,
+			ShortName:   version.ShortName,
+			Coordinates: [2]float64{version.Lon, version.Lat},
+			Capacity:    version.Capacity,
+
+			ElectricBikeSurchargeWaiver: version.ElectricBikeSurchargeWaiver,
+		})
+	}
+
+	if len(versions) > 0 {
+		response.Decommissioned = unixPtr(versions[len(versions)-1].ValidTo)
+	}
+
+	{
+		now := time.Now()
+		history, err := api.store.Availability().History(r.Context(), stationID, now.Add(-24*time.Hour), now, 15*time.Minute)
 
 		if err != nil {
-			slog.Error("failed to query station capacity", "error", err)
+			slog.Error("failed to query station history", "error", err)
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
-		response.Capacity = capacity
+		for _, bucket := range history {
+			response.HistoricalAvailability = append(response.HistoricalAvailability, v1.Availability{
+				Time:            bucket.Time.Unix(),
+				BikesAvailable:  bucket.BikesAvailable,
+				EbikesAvailable: bucket.EbikesAvailable,
+				Capacity:        store.CapacityAt(versions, bucket.Time),
+				Vehicles:        averageVehicles(vehicleTypeIDs, bucket.Vehicles),
+			})
+		}
 	}
 
 	{
-		var time_ time.Time
-		var bikesAvailable, ebikesAvailable float64
-
-		err := api.pool.QueryRow(r.Context(), `
-			SELECT
-				"time",
-				"bikes_available",
-				"ebikes_available"
-			FROM "public"."live_station_availability"
-			WHERE "station_id" = $1
-			ORDER BY "time" DESC
-			LIMIT 1`,
-			stationID,
-		).Scan(&time_, &bikesAvailable, &ebikesAvailable)
+		current, err := api.store.Availability().Latest(r.Context(), stationID)
+
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "no availability for station", http.StatusNotFound)
+			return
+		}
 
 		if err != nil {
 			slog.Error("failed to query current station availability", "error", err)
@@ -200,10 +269,18 @@ func (api *Handler) GetStation(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		ebikes := int64(0)
+
+		if current.EbikesAvailable != nil {
+			ebikes = *current.EbikesAvailable
+		}
+
 		response.CurrentAvailability = v1.Availability{
-			Time:            time_.Unix(),
-			BikesAvailable:  bikesAvailable,
-			EbikesAvailable: ebikesAvailable,
+			Time:            current.Time.Unix(),
+			BikesAvailable:  float64(current.BikesAvailable),
+			EbikesAvailable: float64(ebikes),
+			Capacity:        store.CapacityAt(versions, current.Time),
+			Vehicles:        currentVehicles(vehicleTypeIDs, current.Vehicles),
 		}
 	}
 
@@ -227,73 +304,135 @@ func (api *Handler) GetStation(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (api *Handler) MapProxy(w http.ResponseWriter, r *http.Request) {
-	finalUrl := strings.Replace(api.mapUrl, "{z}", r.PathValue("z"), 1)
-	finalUrl = strings.Replace(finalUrl, "{x}", r.PathValue("x"), 1)
-	finalUrl = strings.Replace(finalUrl, "{y}", r.PathValue("y"), 1)
-
-	response, err := http.Get(finalUrl)
+// NewHandler builds the API, map proxy and UI routes. The health check
+// expects the database of st to be at schemaVersion.
+func NewHandler(st store.Store, cfg *config.Config, schemaVersion int32) (http.Handler, error) {
+	tiles, err := tilecache.New(cfg.Map.CacheDir, cfg.Map.CacheSizeMB*1024*1024, 1024, 12*time.Hour)
 
 	if err != nil {
-		slog.Error("failed to fetch map tile", "path", r.URL.Path, "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to create map tile cache: %w", err)
 	}
 
-	defer response.Body.Close()
+	mux := http.NewServeMux()
+	api := &Handler{
+		store:      st,
+		mapLayers:  newMapLayers(cfg.Map),
+		mapBounds:  newMapBounds(cfg.Map),
+		mapClient:  &http.Client{Timeout: 10 * time.Second},
+		mapLimiter: newRateLimiter(cfg.Map.RateLimit, 2*cfg.Map.RateLimit),
+		trustProxy: cfg.Server.TrustProxyHeaders,
+		tiles:      tiles,
+
+		schemaVersion:   schemaVersion,
+		feeds:           cfg.Feeds,
+		maxAggregateLag: cfg.Server.MaxAggregateLag,
+	}
 
-	if response.StatusCode != http.StatusOK {
-		slog.Error("failed to fetch map tile", "path", r.URL.Path, "status", response.Status)
-		http.Error(w, "map tile not found", http.StatusNotFound)
-		return
+	routes := map[string]http.HandlerFunc{
+		"/stations":                        api.ListStation,
+		"/stations/{stationId}":            api.GetStation,
+		"/heatmap":                         api.Heatmap,
+		"/map/{z}/{x}/{y}":                 api.MapProxy,
+		"/map/{layer}/{z}/{x}/{y}":         api.MapProxy,
+		"/health":                          api.Health,
+		"/ready":                           api.Ready,
+		"/quality/issues":                  api.ListDataQualityIssues,
+		"/regions":                         api.ListRegions,
+		"/regions/{regionId}/availability": api.GetRegionAvailability,
+		"/alerts":                          api.ListAlerts,
+		"/vehicle_types":                   api.ListVehicleTypes,
+		"/pricing_plans":                   api.ListPricingPlans,
+		"/ebike_depletion":                 api.GetEbikeDepletion,
+		"/geofencing_zones":                api.ListGeofencingZones,
+		"/geofencing_zones/rules":          api.GetGeofencingRules,
+		"/geofencing_zones/{z}/{x}/{y}":    api.GeofencingZoneTile,
 	}
 
-	w.Header().Set("Content-Type", response.Header.Get("Content-Type"))
-	w.Header().Set("Cache-Control", "max-age=43200, public")
-	w.WriteHeader(response.StatusCode)
+	for pattern, handler := range routes {
+		mux.HandleFunc(pattern, metrics.InstrumentRoute(pattern, handler))
+	}
 
-	_, err = io.Copy(w, response.Body)
+	mux.Handle("/metrics", metrics.Handler())
 
-	if err != nil {
-		slog.Error("failed to write map tile response", "path", r.URL.Path, "error", err)
-		return
+	static, ok := cfg.Paths.StaticFS()
+
+	if !ok {
+		slog.Warn("UI was not built into this binary, serving the API only; run `npm run build` before `go build`")
 	}
+
+	mux.Handle("/", http.FileServerFS(static))
+
+	return mux, nil
+}
+
+// NewAdminHandler builds the routes of the server.metrics_listen listener,
+// which are not meant to be exposed publicly
+func NewAdminHandler(st store.Store) http.Handler {
+	mux := http.NewServeMux()
+	api := &Handler{store: st}
+
+	mux.HandleFunc("/admin/retention", metrics.InstrumentRoute("/admin/retention", api.Retention))
+	mux.Handle("/metrics", metrics.Handler())
+
+	return mux
 }
 
-func (api *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	err := api.pool.Ping(r.Context())
+// ServeAdmin runs the admin server on addr until ctx is cancelled
+func ServeAdmin(ctx context.Context, st store.Store, addr string) error {
+	server := &http.Server{Addr: addr, Handler: NewAdminHandler(st), ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
 
-	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
 	}
 
-	w.Header().Set("Content-Type", "text/plain")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write([]byte("OK"))
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	return server.Shutdown(shutdownCtx)
+}
+
+// Serve runs the API server until ctx is cancelled, then waits up to
+// server.shutdown_timeout for in-flight requests to complete
+func Serve(ctx context.Context, st store.Store, cfg *config.Config, schemaVersion int32) error {
+	handler, err := NewHandler(st, cfg, schemaVersion)
 
 	if err != nil {
-		slog.Error("failed to write health response", "path", r.URL.Path, "error", err)
-		return
+		return err
 	}
-}
 
-func Serve(pool *pgxpool.Pool) error {
-	mapUrl, ok := os.LookupEnv("MAP_URL")
+	server := &http.Server{
+		Addr:              cfg.Server.Listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-	if !ok {
-		return fmt.Errorf("MAP_URL environment variable is not set")
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
 	}
 
-	mux := http.NewServeMux()
-	api := &Handler{pool: pool, mapUrl: mapUrl}
+	slog.Info("shutting down server", "timeout", cfg.Server.ShutdownTimeout)
 
-	mux.HandleFunc("/stations", api.ListStation)
-	mux.HandleFunc("/stations/{stationId}", api.GetStation)
-	mux.HandleFunc("/map/{z}/{x}/{y}", api.MapProxy)
-	mux.HandleFunc("/health", api.Health)
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.Server.ShutdownTimeout)
+	defer cancel()
 
-	fs := http.FileServerFS(os.DirFS("dist/"))
-	mux.Handle("/", fs)
+	err = server.Shutdown(shutdownCtx)
+
+	if err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 
-	return http.ListenAndServe(":8080", mux)
+	return nil
 }
@@ -0,0 +1,673 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/server"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/store/memory"
+	"github.com/ngc7293/hixi/internal/store/sqlite"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+func newMemoryHandler(t *testing.T) (*memory.Store, http.Handler) {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Paths.Static = t.TempDir()
+	cfg.Map.URL = "https://tiles.example.com/{z}/{x}/{y}.png"
+
+	// The memory store has no schema
+	st := memory.New()
+	handler, err := server.NewHandler(st, cfg, 0)
+
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+
+	return st, handler
+}
+
+func TestStationHandlers(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	capacity := int64(20)
+
+	for _, id := range []string{"1", "2"} {
+		err := st.Stations().Upsert(ctx, store.StationInformation{ExternalID: id, Name: "Station " + id, Lat: 45.5, Lon: -73.57, Capacity: &capacity}, now)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	active, _ := st.Stations().FindOrCreate(ctx, "1")
+	inactive, _ := st.Stations().FindOrCreate(ctx, "2")
+
+	insert := func(stationID int64, at time.Time, bikes int64, ebikes int64) {
+		t.Helper()
+		err := st.Availability().Insert(ctx, store.Availability{Time: at, StationID: stationID, BikesAvailable: bikes, EbikesAvailable: &ebikes, DocksAvailable: 20 - bikes - ebikes})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insert(active.ID, now.Add(-time.Hour), 4, 0)
+	insert(active.ID, now, 6, 2)
+	insert(inactive.ID, now.Add(-3*time.Hour), 1, 1)
+
+	stations := get[v1.ListStationResponse](t, handler, "/stations", http.StatusOK)
+
+	if len(stations.Features) != 2 {
+		t.Fatalf("/stations = %+v, want 2 stations", stations)
+	}
+
+	for _, feature := range stations.Features {
+		if feature.Properties.Active != (feature.Properties.ID == active.ID) {
+			t.Errorf("station %d active = %v", feature.Properties.ID, feature.Properties.Active)
+		}
+	}
+
+	station := get[v1.GetStationResponse](t, handler, fmt.Sprintf("/stations/%d", active.ID), http.StatusOK)
+
+	if station.CurrentAvailability.BikesAvailable != 6 || station.CurrentAvailability.EbikesAvailable != 2 || station.CurrentAvailability.Time != now.Unix() {
+		t.Errorf("current availability = %+v, want 6 bikes and 2 ebikes", station.CurrentAvailability)
+	}
+
+	if len(station.HistoricalAvailability) != 2 {
+		t.Errorf("historical availability = %+v, want 2 buckets", station.HistoricalAvailability)
+	}
+
+	if station.Capacity == nil || *station.Capacity != capacity {
+		t.Errorf("capacity = %v, want %d", station.Capacity, capacity)
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/stations/999", http.StatusNotFound},
+		{"/stations?kiosk=maybe", http.StatusBadRequest},
+		{"/stations/metcalfe", http.StatusBadRequest},
+		{"/heatmap", http.StatusNotImplemented},
+		{"/heatmap?shape=circle", http.StatusBadRequest},
+		{"/heatmap?metric=arrivals", http.StatusBadRequest},
+		{"/heatmap?size=10", http.StatusBadRequest},
+		{"/heatmap?from=0&to=86400000", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("GET %s = %d, want %d", tt.path, recorder.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestStationMetadataFilters(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	kiosk := true
+	address := "Metcalfe / du Square-Dorchester"
+
+	stations := []store.StationInformation{
+		{ExternalID: "1", Name: "Metcalfe", Metadata: store.StationMetadata{Address: &address, RentalMethods: []string{"KEY", "CREDITCARD"}, HasKiosk: &kiosk}},
+		{ExternalID: "2", Name: "Peel", Metadata: store.StationMetadata{RentalMethods: []string{"KEY"}}},
+	}
+
+	for _, station := range stations {
+		if err := st.Stations().Upsert(ctx, station, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/stations", []string{"Metcalfe", "Peel"}},
+		{"/stations?rental_method=creditcard", []string{"Metcalfe"}},
+		{"/stations?rental_method=KEY&kiosk=true", []string{"Metcalfe"}},
+		{"/stations?kiosk=false", []string{}},
+	}
+
+	for _, tt := range tests {
+		response := get[v1.ListStationResponse](t, handler, tt.path, http.StatusOK)
+		names := []string{}
+
+		for _, feature := range response.Features {
+			names = append(names, feature.Properties.Name)
+		}
+
+		if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+			t.Errorf("GET %s = %v, want %v", tt.path, names, tt.want)
+		}
+	}
+
+	metcalfe, _ := st.Stations().FindOrCreate(ctx, "1")
+
+	if err := st.Availability().Insert(ctx, store.Availability{Time: now, StationID: metcalfe.ID, BikesAvailable: 1, DocksAvailable: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	station := get[v1.GetStationResponse](t, handler, fmt.Sprintf("/stations/%d", metcalfe.ID), http.StatusOK)
+
+	if station.Name != "Metcalfe" || station.Address == nil || len(station.RentalMethods) != 2 || station.HasKiosk == nil || !*station.HasKiosk {
+		t.Errorf("/stations/%d = %+v, want the station metadata", metcalfe.ID, station)
+	}
+}
+
+func TestReady(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	ready := get[v1.HealthResponse](t, handler, "/ready", http.StatusServiceUnavailable)
+
+	if len(ready.Checks) != 5 {
+		t.Fatalf("/ready = %+v, want 5 checks", ready)
+	}
+
+	station, _ := st.Stations().FindOrCreate(ctx, "1")
+
+	if err := st.Availability().Insert(ctx, store.Availability{Time: now, StationID: station.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, feed := range []string{"station_status", "station_information"} {
+		if err := st.SyncState().Record(ctx, feed, now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ready = get[v1.HealthResponse](t, handler, "/ready", http.StatusOK)
+
+	if ready.Status != "ok" {
+		t.Errorf("/ready = %+v, want ok", ready)
+	}
+}
+
+func TestListDataQualityIssues(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	issues := []store.QualityIssue{
+		{Time: now.Add(-48 * time.Hour), Feed: "station_status", StationExternalID: "1", Rule: "over_capacity", Record: []byte(`{}`)},
+		{Time: now.Add(-time.Hour), Feed: "station_status", StationExternalID: "1", Rule: "negative_count", Quarantined: true, Record: []byte(`{}`)},
+		{Time: now, Feed: "station_status", StationExternalID: "2", Rule: "unknown_station", Record: []byte(`{"station_id":"2"}`)},
+	}
+
+	for _, issue := range issues {
+		if err := st.Quality().Record(ctx, issue); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query     string
+		wantRules []string
+	}{
+		{"", []string{"unknown_station", "negative_count"}},
+		{"?station=1", []string{"negative_count"}},
+		{"?rule=unknown_station", []string{"unknown_station"}},
+		{"?limit=1", []string{"unknown_station"}},
+		{fmt.Sprintf("?since=%d", now.Add(-72*time.Hour).Unix()), []string{"unknown_station", "negative_count", "over_capacity"}},
+	}
+
+	for _, tt := range tests {
+		response := get[v1.ListDataQualityIssuesResponse](t, handler, "/quality/issues"+tt.query, http.StatusOK)
+		rules := []string{}
+
+		for _, issue := range response.Issues {
+			rules = append(rules, issue.Rule)
+		}
+
+		if fmt.Sprint(rules) != fmt.Sprint(tt.wantRules) {
+			t.Errorf("/quality/issues%s rules = %v, want %v", tt.query, rules, tt.wantRules)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/retention", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("GET /admin/retention = %d on the public listener, want %d", recorder.Code, http.StatusNotFound)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.NewAdminHandler(st).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/retention", nil))
+
+	if recorder.Code != http.StatusNotImplemented {
+		t.Errorf("GET /admin/retention = %d, want %d from the memory store", recorder.Code, http.StatusNotImplemented)
+	}
+
+	embedded, err := sqlite.Open(context.Background(), filepath.Join(t.TempDir(), "hixi.db"), 48*time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer embedded.Close()
+
+	response := get[v1.RetentionResponse](t, server.NewAdminHandler(embedded), "/admin/retention", http.StatusOK)
+	dropAfter := map[string]*int64{}
+
+	for _, relation := range response.Relations {
+		dropAfter[relation.Relation] = relation.DropAfter
+	}
+
+	if live := dropAfter["live_station_availability"]; live == nil || *live != 172800 {
+		t.Errorf("live_station_availability drop_after = %v, want the configured 2 days", live)
+	}
+
+	if historical, ok := dropAfter["historical_station_availability"]; !ok || historical != nil {
+		t.Errorf("historical_station_availability drop_after = %v, want kept forever", historical)
+	}
+}
+
+func TestStationCapacityHistory(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	for _, version := range []struct {
+		at       time.Time
+		capacity int64
+	}{
+		{now.Add(-4 * time.Hour), 10},
+		{now.Add(-90 * time.Minute), 20},
+	} {
+		err := st.Stations().Upsert(ctx, store.StationInformation{ExternalID: "1", Name: "Station 1", Lat: 45.5, Lon: -73.57, Capacity: &version.capacity}, version.at)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	station, _ := st.Stations().FindOrCreate(ctx, "1")
+
+	for _, at := range []time.Time{now.Add(-3 * time.Hour), now} {
+		if err := st.Availability().Insert(ctx, store.Availability{Time: at, StationID: station.ID, BikesAvailable: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	response := get[v1.GetStationResponse](t, handler, fmt.Sprintf("/stations/%d", station.ID), http.StatusOK)
+
+	if len(response.Versions) != 2 || response.Decommissioned != nil || *response.Capacity != 20 {
+		t.Fatalf("/stations/%d = %+v, want 2 versions of a station in service", station.ID, response)
+	}
+
+	capacities := []int64{}
+
+	for _, bucket := range response.HistoricalAvailability {
+		capacities = append(capacities, *bucket.Capacity)
+	}
+
+	if fmt.Sprint(capacities) != "[10 20]" || *response.CurrentAvailability.Capacity != 20 {
+		t.Errorf("capacities = %v then %d, want the capacity valid in each bucket", capacities, *response.CurrentAvailability.Capacity)
+	}
+}
+
+func TestRegions(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Hour)
+	region := "31"
+
+	if err := st.Regions().Upsert(ctx, region, "Ville-Marie"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		err := st.Stations().Upsert(ctx, store.StationInformation{ExternalID: id, Name: "Station " + id, Lat: 45.5, Lon: -73.57, Metadata: store.StationMetadata{RegionID: &region}}, now.Add(-time.Hour))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		known, _ := st.Stations().FindOrCreate(ctx, id)
+
+		if err := st.Availability().Insert(ctx, store.Availability{Time: now.Add(-30 * time.Minute), StationID: known.ID, BikesAvailable: 3, DocksAvailable: 7}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	regions := get[v1.ListRegionsResponse](t, handler, "/regions", http.StatusOK)
+
+	if len(regions.Regions) != 1 || regions.Regions[0].Stations != 2 || regions.Regions[0].Custom || regions.Regions[0].RegionID == nil || *regions.Regions[0].RegionID != region {
+		t.Fatalf("/regions = %+v, want region %s with 2 stations", regions, region)
+	}
+
+	id := regions.Regions[0].ID
+	availability := get[v1.RegionAvailabilityResponse](t, handler, fmt.Sprintf("/regions/%d/availability?bucket=3600", id), http.StatusOK)
+
+	if availability.Region.Name != "Ville-Marie" || len(availability.Availability) != 1 || availability.Availability[0].Stations != 2 || availability.Availability[0].BikesAvailable != 6 || availability.Availability[0].DocksAvailable != 14 {
+		t.Errorf("/regions/%d/availability = %+v, want 6 bikes and 14 docks over 2 stations", id, availability)
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{fmt.Sprintf("/regions/%d/availability", id+1), http.StatusNotFound},
+		{"/regions/plateau/availability", http.StatusBadRequest},
+		{fmt.Sprintf("/regions/%d/availability?bucket=60", id), http.StatusBadRequest},
+		{fmt.Sprintf("/regions/%d/availability?from=%d&to=%d", id, now.Unix(), now.Add(-time.Hour).Unix()), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("GET %s = %d, want %d", tt.path, recorder.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	region := "31"
+	stations := map[string]int64{}
+
+	for _, id := range []string{"1", "2", "3"} {
+		metadata := store.StationMetadata{}
+
+		if id == "3" {
+			metadata.RegionID = &region
+		}
+
+		err := st.Stations().Upsert(ctx, store.StationInformation{ExternalID: id, Name: "Station " + id, Lat: 45.5, Lon: -73.57, Metadata: metadata}, now.Add(-time.Hour))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		known, _ := st.Stations().FindOrCreate(ctx, id)
+		stations[id] = known.ID
+
+		if err := st.Availability().Insert(ctx, store.Availability{Time: now.Add(-time.Minute), StationID: known.ID, BikesAvailable: 3, DocksAvailable: 7}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ended := now.Add(-time.Minute)
+	alerts := []store.Alert{
+		{ExternalID: "closure", Type: "STATION_CLOSURE", StationIDs: []string{"1"}, Summary: "Station 1 closed", Times: []store.AlertWindow{{Start: now.Add(-time.Hour)}}},
+		{ExternalID: "ended", Type: "STATION_CLOSURE", StationIDs: []string{"1"}, Summary: "Roadwork", Times: []store.AlertWindow{{Start: now.Add(-time.Hour), End: &ended}}},
+		{ExternalID: "planned", Type: "STATION_MOVE", StationIDs: []string{"2"}, Summary: "Station 2 moving", Times: []store.AlertWindow{{Start: now.Add(time.Hour)}}},
+		{ExternalID: "region", Type: "OTHER", RegionIDs: []string{region}, Summary: "Festival"},
+		{ExternalID: "withdrawn", Type: "SYSTEM_CLOSURE", Summary: "Outage"},
+	}
+
+	for _, alert := range alerts {
+		if err := st.Alerts().Upsert(ctx, alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := st.Alerts().Withdraw(ctx, []string{"closure", "ended", "planned", "region"}, now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	list := get[v1.ListAlertsResponse](t, handler, "/alerts", http.StatusOK)
+	active := map[string]bool{}
+
+	for _, alert := range list.Alerts {
+		active[alert.ID] = alert.Active
+	}
+
+	if len(list.Alerts) != 4 || !active["closure"] || active["ended"] || active["planned"] || !active["region"] {
+		t.Errorf("/alerts = %+v, want closure and region active, ended and planned inactive", list)
+	}
+
+	list = get[v1.ListAlertsResponse](t, handler, fmt.Sprintf("/alerts?since=%d", now.Add(-time.Hour).Unix()), http.StatusOK)
+
+	if len(list.Alerts) != 5 || list.Alerts[4].ID != "withdrawn" || list.Alerts[4].Withdrawn == nil || list.Alerts[4].Active {
+		t.Errorf("/alerts?since = %+v, want the withdrawn alert included", list)
+	}
+
+	features := get[v1.ListStationResponse](t, handler, "/stations", http.StatusOK)
+	want := map[int64][]string{stations["1"]: {"closure"}, stations["2"]: {}, stations["3"]: {"region"}}
+
+	if len(features.Features) != 3 {
+		t.Fatalf("/stations = %+v, want 3 stations", features)
+	}
+
+	for _, feature := range features.Features {
+		got := []string{}
+
+		for _, alert := range feature.Properties.Alerts {
+			got = append(got, alert.ID)
+		}
+
+		if !slices.Equal(got, want[feature.Properties.ID]) {
+			t.Errorf("/stations alerts of station %d = %v, want %v", feature.Properties.ID, got, want[feature.Properties.ID])
+		}
+	}
+
+	station := get[v1.GetStationResponse](t, handler, fmt.Sprintf("/stations/%d", stations["1"]), http.StatusOK)
+
+	if len(station.Alerts) != 1 || station.Alerts[0].ID != "closure" || station.Alerts[0].Summary != "Station 1 closed" || len(station.Alerts[0].Times) != 1 {
+		t.Errorf("/stations/%d alerts = %+v, want closure", stations["1"], station.Alerts)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/alerts?since=yesterday", nil))
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("GET /alerts?since=yesterday = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func TestVehicleTypes(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	if err := st.Stations().Upsert(ctx, store.StationInformation{ExternalID: "1", Name: "Station 1", Lat: 45.5, Lon: -73.57}, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	station, _ := st.Stations().FindOrCreate(ctx, "1")
+	scooter, _ := st.VehicleTypes().FindOrCreate(ctx, "scooter")
+
+	formFactor, propulsion := "scooter", "electric"
+
+	if err := st.VehicleTypes().Upsert(ctx, store.VehicleType{ExternalID: "scooter", FormFactor: &formFactor, PropulsionType: &propulsion}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, available := range []int64{2, 4} {
+		err := st.Availability().Insert(ctx, store.Availability{
+			Time:           now.Add(time.Duration(i-2) * time.Minute),
+			StationID:      station.ID,
+			BikesAvailable: 1,
+			DocksAvailable: 5,
+			Vehicles:       []store.VehicleAvailability{{VehicleTypeID: scooter, Available: available}},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := get[v1.ListVehicleTypesResponse](t, handler, "/vehicle_types", http.StatusOK)
+
+	if len(list.VehicleTypes) != 1 || list.VehicleTypes[0].ID != "scooter" || list.VehicleTypes[0].FormFactor == nil || *list.VehicleTypes[0].FormFactor != "scooter" {
+		t.Errorf("/vehicle_types = %+v, want the scooter", list)
+	}
+
+	response := get[v1.GetStationResponse](t, handler, fmt.Sprintf("/stations/%d", station.ID), http.StatusOK)
+
+	if response.CurrentAvailability.Vehicles["scooter"] != 4 {
+		t.Errorf("/stations/%d current = %+v, want 4 scooters", station.ID, response.CurrentAvailability)
+	}
+
+	if len(response.HistoricalAvailability) == 0 || response.HistoricalAvailability[len(response.HistoricalAvailability)-1].Vehicles["scooter"] == 0 {
+		t.Errorf("/stations/%d historical = %+v, want scooters averaged", station.ID, response.HistoricalAvailability)
+	}
+}
+
+func TestEbikeDepletion(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	for _, id := range []string{"1", "2"} {
+		information := store.StationInformation{ExternalID: id, Name: "Station " + id, Lat: 45.5, Lon: -73.57}
+		information.Metadata.ElectricBikeSurchargeWaiver = id == "1"
+
+		if err := st.Stations().Upsert(ctx, information, start.Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		station, _ := st.Stations().FindOrCreate(ctx, id)
+
+		for i, available := range []int64{4, 2} {
+			ebikes := available
+
+			if id == "2" {
+				ebikes = 3
+			}
+
+			err := st.Availability().Insert(ctx, store.Availability{Time: start.Add(time.Duration(i) * 5 * time.Minute), StationID: station.ID, EbikesAvailable: &ebikes})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := st.PricingPlans().Upsert(ctx, store.PricingPlan{PlanID: "single", Name: "Single trip", Currency: "CAD", Price: 1.25}, start.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	path := fmt.Sprintf("/ebike_depletion?from=%d&to=%d", start.Unix(), start.Add(time.Hour).Unix())
+	response := get[v1.EbikeDepletionResponse](t, handler, path, http.StatusOK)
+
+	if response.Waiver.Stations != 1 || response.Waiver.EbikesAvailable != 3 || response.Waiver.Departures != 24 {
+		t.Errorf("%s waiver = %+v, want 24 departures per hour", path, response.Waiver)
+	}
+
+	if response.NoWaiver.Stations != 1 || response.NoWaiver.EbikesAvailable != 3 || response.NoWaiver.Departures != 0 {
+		t.Errorf("%s no_waiver = %+v, want no departure", path, response.NoWaiver)
+	}
+
+	if len(response.PricingPlans) != 1 || response.PricingPlans[0].ID != "single" || response.PricingPlans[0].PerMinPricing == nil {
+		t.Errorf("%s pricing_plans = %+v, want the single trip", path, response.PricingPlans)
+	}
+
+	plans := get[v1.ListPricingPlansResponse](t, handler, "/pricing_plans", http.StatusOK)
+
+	if len(plans.PricingPlans) != 1 || plans.PricingPlans[0].Price != 1.25 || plans.PricingPlans[0].ValidTo != nil {
+		t.Errorf("/pricing_plans = %+v, want the current single trip", plans)
+	}
+
+	for _, path := range []string{
+		"/ebike_depletion?from=abc",
+		fmt.Sprintf("/ebike_depletion?from=%d&to=%d", start.Unix(), start.Unix()),
+		fmt.Sprintf("/ebike_depletion?from=%d&to=%d", start.Add(-40*24*time.Hour).Unix(), start.Unix()),
+		fmt.Sprintf("/pricing_plans?from=%d&to=%d", start.Unix(), start.Add(-time.Hour).Unix()),
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want %d", path, recorder.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGeofencingZones(t *testing.T) {
+	st, handler := newMemoryHandler(t)
+	ctx := context.Background()
+	later := time.Now().Add(time.Hour).Truncate(time.Second)
+	speed := int64(15)
+
+	zones := []store.GeofencingZone{
+		{
+			Start:    &later,
+			Geometry: []byte(`{"type": "MultiPolygon", "coordinates": [[[[-73.58, 45.5], [-73.56, 45.5], [-73.56, 45.52], [-73.58, 45.52], [-73.58, 45.5]]]]}`),
+			Rules:    []store.GeofencingRule{{}},
+		},
+		{
+			Geometry: []byte(`{"type": "MultiPolygon", "coordinates": [[[[-73.6, 45.49], [-73.5, 45.49], [-73.5, 45.55], [-73.6, 45.55], [-73.6, 45.49]]]]}`),
+			Rules: []store.GeofencingRule{
+				{VehicleTypeIDs: []string{"ebike"}, RideAllowed: true, RideThroughAllowed: true, MaximumSpeedKph: &speed},
+				{RideAllowed: true, RideThroughAllowed: true},
+			},
+		},
+	}
+
+	if err := st.GeofencingZones().Replace(ctx, zones); err != nil {
+		t.Fatal(err)
+	}
+
+	list := get[v1.GeofencingZonesResponse](t, handler, "/geofencing_zones", http.StatusOK)
+
+	if list.Type != "FeatureCollection" || len(list.Features) != 2 || list.Features[0].Properties.Active || !list.Features[1].Properties.Active ||
+		list.Features[0].Geometry.Type != "MultiPolygon" || len(list.Features[0].Geometry.Coordinates[0][0]) != 5 {
+		t.Errorf("/geofencing_zones = %+v, want 2 zones, the first not yet in effect", list)
+	}
+
+	tests := []struct {
+		path      string
+		wantZones int
+		wantSpeed *int64
+	}{
+		{"/geofencing_zones/rules?lon=-73.57&lat=45.51&vehicle_type_id=ebike", 1, &speed},
+		{"/geofencing_zones/rules?lon=-73.57&lat=45.51", 1, nil},
+		{fmt.Sprintf("/geofencing_zones/rules?lon=-73.57&lat=45.51&at=%d", later.Unix()), 2, nil},
+		{"/geofencing_zones/rules?lon=-73.4&lat=45.51", 0, nil},
+	}
+
+	for _, tt := range tests {
+		rules := get[v1.GeofencingRulesResponse](t, handler, tt.path, http.StatusOK)
+
+		if len(rules.Zones) != tt.wantZones || (tt.wantZones > 0) != (rules.Rule != nil) ||
+			(rules.Rule != nil && (rules.Rule.MaximumSpeedKph == nil) != (tt.wantSpeed == nil)) {
+			t.Errorf("%s = %+v, want %d zones", tt.path, rules, tt.wantZones)
+		}
+	}
+
+	// The zone not yet in effect forbids riding once it starts
+	if rules := get[v1.GeofencingRulesResponse](t, handler, fmt.Sprintf("/geofencing_zones/rules?lon=-73.57&lat=45.51&at=%d", later.Unix()), http.StatusOK); rules.Rule == nil || rules.Rule.RideAllowed {
+		t.Errorf("rules at %d = %+v, want riding forbidden", later.Unix(), rules)
+	}
+
+	for path, wantStatus := range map[string]int{
+		"/geofencing_zones/rules?lon=-73.57":           http.StatusBadRequest,
+		"/geofencing_zones/rules?lon=-200&lat=45.51":   http.StatusBadRequest,
+		"/geofencing_zones/rules?lon=-73.57&lat=north": http.StatusBadRequest,
+		"/geofencing_zones/12/1211/1463":               http.StatusNotImplemented,
+		"/geofencing_zones/30/0/0":                     http.StatusBadRequest,
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if recorder.Code != wantStatus {
+			t.Errorf("GET %s = %d, want %d", path, recorder.Code, wantStatus)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+func check(name string, err error) v1.HealthCheck {
+	if err != nil {
+		return v1.HealthCheck{Name: name, OK: false, Message: err.Error()}
+	}
+
+	return v1.HealthCheck{Name: name, OK: true}
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, checks []v1.HealthCheck) {
+	response := v1.HealthResponse{Status: "ok", Checks: checks}
+	status := http.StatusOK
+
+	for _, check := range checks {
+		if !check.OK {
+			response.Status = "fail"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	content, err := json.Marshal(response)
+
+	if err != nil {
+		slog.Error("failed to marshal response", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err = w.Write(content)
+
+	if err != nil {
+		slog.Error("failed to write response", "path", r.URL.Path, "error", err)
+		return
+	}
+}
+
+func (api *Handler) checkDatabase(ctx context.Context) v1.HealthCheck {
+	start := time.Now()
+	result := check("database", api.store.Ping(ctx))
+
+	if result.OK {
+		result.Message = fmt.Sprintf("ping %s", time.Since(start).Round(time.Millisecond))
+	}
+
+	return result
+}
+
+func (api *Handler) checkSchema(ctx context.Context) v1.HealthCheck {
+	version, err := api.store.SchemaVersion(ctx)
+
+	if errors.Is(err, store.ErrUnsupported) {
+		return v1.HealthCheck{Name: "schema", OK: true, Message: "not versioned"}
+	}
+
+	if err != nil {
+		return check("schema", err)
+	}
+
+	switch {
+	case version < api.schemaVersion:
+		return check("schema", fmt.Errorf("schema version %d, expected %d: migrations pending", version, api.schemaVersion))
+	case version > api.schemaVersion:
+		return check("schema", fmt.Errorf("schema version %d is newer than expected %d", version, api.schemaVersion))
+	}
+
+	return v1.HealthCheck{Name: "schema", OK: true, Message: fmt.Sprintf("version %d", version)}
+}
+
+func (api *Handler) checkFeed(ctx context.Context, feed string, maxStaleness time.Duration) v1.HealthCheck {
+	name := "feed:" + feed
+
+	state, err := api.store.SyncState().Get(ctx, feed)
+
+	if errors.Is(err, store.ErrNotFound) {
+		return check(name, errors.New("never synced"))
+	}
+
+	if err != nil {
+		return check(name, err)
+	}
+
+	lastSuccess := state.LastSuccess
+
+	age := time.Since(lastSuccess).Round(time.Second)
+	result := check(name, nil)
+	result.Time = new(int64)
+	*result.Time = lastSuccess.Unix()
+	result.Message = fmt.Sprintf("last synced %s ago", age)
+
+	if maxStaleness > 0 && age > maxStaleness {
+		result.OK = false
+		result.Message = fmt.Sprintf("last synced %s ago, more than %s", age, maxStaleness)
+	}
+
+	return result
+}
+
+func (api *Handler) checkAggregate(ctx context.Context) v1.HealthCheck {
+	latest, lag, err := api.store.Availability().AggregateLag(ctx)
+
+	if errors.Is(err, store.ErrNotFound) {
+		return check("aggregate", errors.New("no aggregated data"))
+	}
+
+	if err != nil {
+		return check("aggregate", err)
+	}
+
+	result := check("aggregate", nil)
+	result.Time = new(int64)
+	*result.Time = latest.Unix()
+	result.Message = fmt.Sprintf("%s behind live data", lag.Round(time.Second))
+
+	if api.maxAggregateLag > 0 && lag > api.maxAggregateLag {
+		result.OK = false
+		result.Message = fmt.Sprintf("%s behind live data, more than %s", lag.Round(time.Second), api.maxAggregateLag)
+	}
+
+	return result
+}
+
+// Health reports whether the process is up and can reach the database. With
+// ?detail=true, the result of each check is returned as JSON.
+func (api *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	database := api.checkDatabase(ctx)
+
+	if r.URL.Query().Get("detail") == "true" {
+		writeHealth(w, r, []v1.HealthCheck{database})
+		return
+	}
+
+	if !database.OK {
+		slog.Error("health check failed", "path", r.URL.Path, "error", database.Message)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write([]byte("OK"))
+
+	if err != nil {
+		slog.Error("failed to write health response", "path", r.URL.Path, "error", err)
+		return
+	}
+}
+
+// Ready reports whether this replica can serve fresh data: the database is
+// reachable and at the expected schema version, every feed has synced
+// recently and the continuous aggregate is keeping up. Responds 503 if any
+// check fails.
+func (api *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	database := api.checkDatabase(ctx)
+	checks := []v1.HealthCheck{database}
+
+	if database.OK {
+		checks = append(checks,
+			api.checkSchema(ctx),
+			api.checkFeed(ctx, "station_status", api.feeds.StationStatus.MaxStaleness),
+			api.checkFeed(ctx, "station_information", api.feeds.StationInformation.MaxStaleness),
+			api.checkAggregate(ctx),
+		)
+	}
+
+	writeHealth(w, r, checks)
+}
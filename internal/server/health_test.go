@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+func TestWriteHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     []v1.HealthCheck
+		wantStatus int
+		wantBody   string
+	}{
+		{"all ok", []v1.HealthCheck{check("database", nil), check("schema", nil)}, http.StatusOK, "ok"},
+		{"one failing", []v1.HealthCheck{check("database", nil), check("feed:station_status", errors.New("never synced"))}, http.StatusServiceUnavailable, "fail"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			writeHealth(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil), tt.checks)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+
+			if got := recorder.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+
+			response := v1.HealthResponse{}
+
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid response body: %v", err)
+			}
+
+			if response.Status != tt.wantBody || len(response.Checks) != len(tt.checks) {
+				t.Errorf("response = %+v", response)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+const (
+	heatmapDefaultCellSize = 500.0
+	heatmapMinCellSize     = 50.0
+	heatmapMaxCellSize     = 10000.0
+	heatmapMaxWindow       = 31 * 24 * time.Hour
+)
+
+// parseTimeParam reads a unix timestamp (in seconds) from the query string,
+// falling back to def when the parameter is absent
+func parseTimeParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+
+	if value == "" {
+		return def, nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: expected unix timestamp", name)
+	}
+
+	return time.Unix(seconds, 0), nil
+}
+
+func (api *Handler) Heatmap(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	shape := query.Get("shape")
+	metric := query.Get("metric")
+
+	if shape == "" {
+		shape = "hex"
+	}
+
+	if metric == "" {
+		metric = "bikes"
+	}
+
+	if !slices.Contains(store.HeatmapShapes, shape) {
+		http.Error(w, "invalid shape: expected hex or square", http.StatusBadRequest)
+		return
+	}
+
+	if !slices.Contains(store.HeatmapMetrics, metric) {
+		http.Error(w, "invalid metric: expected bikes, ebikes, docks or departures", http.StatusBadRequest)
+		return
+	}
+
+	cellSize := heatmapDefaultCellSize
+
+	if value := query.Get("size"); value != "" {
+		size, err := strconv.ParseFloat(value, 64)
+
+		if err != nil || size < heatmapMinCellSize || size > heatmapMaxCellSize {
+			http.Error(w, fmt.Sprintf("invalid size: expected meters between %.0f and %.0f", heatmapMinCellSize, heatmapMaxCellSize), http.StatusBadRequest)
+			return
+		}
+
+		cellSize = size
+	}
+
+	now := time.Now()
+	to, err := parseTimeParam(r, "to", now)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTimeParam(r, "from", to.Add(-24*time.Hour))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !from.Before(to) || to.Sub(from) > heatmapMaxWindow {
+		http.Error(w, "invalid window: from must precede to by at most 31 days", http.StatusBadRequest)
+		return
+	}
+
+	response := v1.HeatmapResponse{
+		Type:     "FeatureCollection",
+		Features: []v1.HeatmapFeature{},
+	}
+
+	cells, err := api.store.Availability().Heatmap(r.Context(), store.HeatmapQuery{
+		Shape:    shape,
+		Metric:   metric,
+		CellSize: cellSize,
+		From:     from,
+		To:       to,
+	})
+
+	if errors.Is(err, store.ErrUnsupported) {
+		http.Error(w, fmt.Sprintf("%s heatmap not supported by this storage backend", shape), http.StatusNotImplemented)
+		return
+	}
+
+	if err != nil {
+		slog.Error("failed to query heatmap", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, cell := range cells {
+		feature := v1.HeatmapFeature{
+			Type:       "Feature",
+			Properties: v1.HeatmapFeatureProperties{Value: cell.Value, Stations: cell.Stations},
+		}
+
+		err = json.Unmarshal(cell.Geometry, &feature.Geometry)
+
+		if err != nil {
+			slog.Error("failed to decode heatmap cell", "path", r.URL.Path, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		response.Features = append(response.Features, feature)
+	}
+
+	content, err := json.Marshal(response)
+
+	if err != nil {
+		slog.Error("failed to marshal response", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "max-age=300, public")
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(content)
+
+	if err != nil {
+		slog.Error("failed to write response", "path", r.URL.Path, "error", err)
+		return
+	}
+}
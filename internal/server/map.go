@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/metrics"
+	"github.com/ngc7293/hixi/internal/tilecache"
+)
+
+const (
+	defaultMapLayer = "default"
+	maxMapZoom      = 22
+	maxMapTileBytes = 4 * 1024 * 1024
+)
+
+// bounds is a WGS84 bounding box, used to restrict proxied tiles to the
+// system's service area
+type bounds struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+func (b *bounds) intersects(other bounds) bool {
+	return b.MinLon <= other.MaxLon && other.MinLon <= b.MaxLon &&
+		b.MinLat <= other.MaxLat && other.MinLat <= b.MaxLat
+}
+
+type mapTile struct {
+	Z, X, Y int
+}
+
+// parseMapTile validates the {z}/{x}/{y} path values of a tile request
+func parseMapTile(z, x, y string) (mapTile, error) {
+	zoom, err := strconv.Atoi(z)
+
+	if err != nil || zoom < 0 || zoom > maxMapZoom {
+		return mapTile{}, fmt.Errorf("invalid zoom level %q", z)
+	}
+
+	// Retina tiles are commonly requested as {y}@2x
+	y = strings.TrimSuffix(y, "@2x")
+
+	tx, errX := strconv.Atoi(x)
+	ty, errY := strconv.Atoi(y)
+	limit := 1 << zoom
+
+	if errX != nil || errY != nil || tx < 0 || ty < 0 || tx >= limit || ty >= limit {
+		return mapTile{}, fmt.Errorf("invalid tile coordinates %q/%q at zoom %d", x, y, zoom)
+	}
+
+	return mapTile{Z: zoom, X: tx, Y: ty}, nil
+}
+
+// bounds returns the WGS84 extent of a web mercator tile
+func (t mapTile) bounds() bounds {
+	n := float64(int(1) << t.Z)
+
+	lon := func(x int) float64 { return float64(x)/n*360 - 180 }
+	lat := func(y int) float64 { return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180 / math.Pi }
+
+	return bounds{MinLon: lon(t.X), MaxLon: lon(t.X + 1), MinLat: lat(t.Y + 1), MaxLat: lat(t.Y)}
+}
+
+func (t mapTile) url(template string, retina bool) string {
+	y := strconv.Itoa(t.Y)
+
+	if retina {
+		y += "@2x"
+	}
+
+	finalUrl := strings.Replace(template, "{z}", strconv.Itoa(t.Z), 1)
+	finalUrl = strings.Replace(finalUrl, "{x}", strconv.Itoa(t.X), 1)
+	return strings.Replace(finalUrl, "{y}", y, 1)
+}
+
+// newMapLayers maps layer names to upstream URL templates. The map.url
+// template is served as the default layer.
+func newMapLayers(cfg config.Map) map[string]string {
+	layers := map[string]string{defaultMapLayer: cfg.URL}
+
+	for name, template := range cfg.Layers {
+		layers[name] = template
+	}
+
+	return layers
+}
+
+// newMapBounds converts the optional map.bounds setting, which has already
+// been validated
+func newMapBounds(cfg config.Map) *bounds {
+	if len(cfg.Bounds) != 4 {
+		return nil
+	}
+
+	return &bounds{MinLon: cfg.Bounds[0], MinLat: cfg.Bounds[1], MaxLon: cfg.Bounds[2], MaxLat: cfg.Bounds[3]}
+}
+
+func (api *Handler) fetchMapTile(ctx context.Context, finalUrl string) (*tilecache.Tile, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, finalUrl, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := api.mapClient.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected upstream status: %s", response.Status)
+	}
+
+	contentType := response.Header.Get("Content-Type")
+
+	if !strings.HasPrefix(contentType, "image/") && !strings.HasPrefix(contentType, "application/") {
+		return nil, fmt.Errorf("unexpected upstream content type: %q", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxMapTileBytes+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxMapTileBytes {
+		return nil, fmt.Errorf("upstream tile exceeds %d bytes", maxMapTileBytes)
+	}
+
+	return &tilecache.Tile{
+		ContentType: contentType,
+		Data:        data,
+		FetchedAt:   time.Now(),
+	}, nil
+}
+
+func (api *Handler) MapProxy(w http.ResponseWriter, r *http.Request) {
+	if !api.mapLimiter.Allow(clientAddress(r, api.trustProxy)) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	layer := r.PathValue("layer")
+
+	if layer == "" {
+		layer = defaultMapLayer
+	}
+
+	template, ok := api.mapLayers[layer]
+
+	if !ok {
+		http.Error(w, "unknown map layer", http.StatusNotFound)
+		return
+	}
+
+	tile, err := parseMapTile(r.PathValue("z"), r.PathValue("x"), r.PathValue("y"))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if api.mapBounds != nil && !api.mapBounds.intersects(tile.bounds()) {
+		http.Error(w, "map tile outside of service area", http.StatusNotFound)
+		return
+	}
+
+	retina := strings.HasSuffix(r.PathValue("y"), "@2x")
+	finalUrl := tile.url(template, retina)
+
+	// The upstream URL may embed an access token, so tiles are keyed by their
+	// layer and coordinates instead
+	key := fmt.Sprintf("%s/%d/%d/%d", layer, tile.Z, tile.X, tile.Y)
+
+	if retina {
+		key += "@2x"
+	}
+
+	cached, err := api.tiles.Get(r.Context(), key, func(ctx context.Context, _ string) (*tilecache.Tile, error) {
+		start := time.Now()
+		tile, err := api.fetchMapTile(ctx, finalUrl)
+
+		outcome := "ok"
+
+		if err != nil {
+			outcome = "error"
+		}
+
+		metrics.MapUpstreamDuration.WithLabelValues(layer, outcome).Observe(time.Since(start).Seconds())
+		return tile, err
+	})
+
+	if err != nil {
+		slog.Error("failed to fetch map tile", "path", r.URL.Path, "error", err)
+		http.Error(w, "map tile not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", cached.ContentType)
+	w.Header().Set("Cache-Control", "max-age=43200, public")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(cached.Data)
+
+	if err != nil {
+		slog.Error("failed to write map tile response", "path", r.URL.Path, "error", err)
+		return
+	}
+}
+
+// clientAddress identifies the client for rate limiting purposes. The
+// X-Forwarded-For header is only honoured behind a trusted reverse proxy,
+// since it is otherwise trivially spoofed.
+func clientAddress(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			client, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(client)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
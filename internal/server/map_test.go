@@ -0,0 +1,87 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMapTile(t *testing.T) {
+	tests := []struct {
+		name    string
+		z, x, y string
+		want    mapTile
+		wantErr bool
+	}{
+		{name: "valid tile", z: "12", x: "1211", y: "1465", want: mapTile{Z: 12, X: 1211, Y: 1465}},
+		{name: "retina tile", z: "12", x: "1211", y: "1465@2x", want: mapTile{Z: 12, X: 1211, Y: 1465}},
+		{name: "world tile", z: "0", x: "0", y: "0", want: mapTile{Z: 0, X: 0, Y: 0}},
+		{name: "negative zoom", z: "-1", x: "0", y: "0", wantErr: true},
+		{name: "zoom too deep", z: "23", x: "0", y: "0", wantErr: true},
+		{name: "x out of range", z: "2", x: "4", y: "0", wantErr: true},
+		{name: "y out of range", z: "2", x: "0", y: "4", wantErr: true},
+		{name: "not a number", z: "2", x: "0", y: "..%2F", wantErr: true},
+		{name: "template injection", z: "{x}", x: "0", y: "0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tile, err := parseMapTile(tt.z, tt.x, tt.y)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMapTile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && tile != tt.want {
+				t.Errorf("parseMapTile() = %v, want %v", tile, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapTileWithinBounds(t *testing.T) {
+	// Roughly the island of Montréal
+	montreal := bounds{MinLon: -73.98, MinLat: 45.40, MaxLon: -73.47, MaxLat: 45.71}
+
+	tests := []struct {
+		name string
+		tile mapTile
+		want bool
+	}{
+		{name: "world tile", tile: mapTile{Z: 0, X: 0, Y: 0}, want: true},
+		{name: "downtown Montréal", tile: mapTile{Z: 12, X: 1206, Y: 1465}, want: true},
+		{name: "Toronto", tile: mapTile{Z: 12, X: 1143, Y: 1494}, want: false},
+		{name: "southern hemisphere", tile: mapTile{Z: 2, X: 1, Y: 3}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := montreal.intersects(tt.tile.bounds()); got != tt.want {
+				t.Errorf("intersects(%v) = %v, want %v", tt.tile.bounds(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+
+	if !limiter.Allow("a") || !limiter.Allow("a") {
+		t.Fatalf("burst requests were rejected")
+	}
+
+	if limiter.Allow("a") {
+		t.Errorf("request beyond burst was allowed")
+	}
+
+	if !limiter.Allow("b") {
+		t.Errorf("other client was rate limited")
+	}
+
+	now = now.Add(time.Second)
+
+	if !limiter.Allow("a") {
+		t.Errorf("request after refill was rejected")
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+const depletionMaxWindow = 31 * 24 * time.Hour
+
+func pricingSegmentsResponse(segments []store.PricingSegment) []v1.PricingSegment {
+	response := []v1.PricingSegment{}
+
+	for _, segment := range segments {
+		response = append(response, v1.PricingSegment{
+			Start:    segment.Start,
+			Rate:     segment.Rate,
+			Interval: segment.Interval,
+			End:      segment.End,
+		})
+	}
+
+	return response
+}
+
+func pricingPlanResponse(plan store.PricingPlan) v1.PricingPlan {
+	return v1.PricingPlan{
+		ID:            plan.PlanID,
+		ValidFrom:     plan.ValidFrom.Unix(),
+		ValidTo:       unixPtr(plan.ValidTo),
+		Name:          plan.Name,
+		Currency:      plan.Currency,
+		Price:         plan.Price,
+		IsTaxable:     plan.IsTaxable,
+		Description:   plan.Description,
+		URL:           plan.URL,
+		PerKmPricing:  pricingSegmentsResponse(plan.PerKmPricing),
+		PerMinPricing: pricingSegmentsResponse(plan.PerMinPricing),
+	}
+}
+
+// ListPricingPlans lists the versions of the plans of system_pricing_plans in
+// effect between from and to (unix timestamps, both now by default)
+func (api *Handler) ListPricingPlans(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from, err := parseTimeParam(r, "from", now)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := parseTimeParam(r, "to", now)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if to.Before(from) {
+		http.Error(w, "invalid window: from must not follow to", http.StatusBadRequest)
+		return
+	}
+
+	plans, err := api.store.PricingPlans().List(r.Context(), from, to)
+
+	if err != nil {
+		slog.Error("failed to query pricing plans", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := v1.ListPricingPlansResponse{PricingPlans: []v1.PricingPlan{}}
+
+	for _, plan := range plans {
+		response.PricingPlans = append(response.PricingPlans, pricingPlanResponse(plan))
+	}
+
+	writeJSON(w, r, response, "max-age=300, public")
+}
+
+// GetEbikeDepletion compares how fast ebikes leave stations waiving the ebike
+// surcharge with the others, between from and to (the last 24 hours by
+// default). Stations are grouped by the waiver flag they had at the time.
+func (api *Handler) GetEbikeDepletion(w http.ResponseWriter, r *http.Request) {
+	to, err := parseTimeParam(r, "to", time.Now())
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTimeParam(r, "from", to.Add(-24*time.Hour))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !from.Before(to) || to.Sub(from) > depletionMaxWindow {
+		http.Error(w, "invalid window: from must precede to by at most 31 days", http.StatusBadRequest)
+		return
+	}
+
+	depletion, err := api.store.Availability().EbikeDepletion(r.Context(), from, to)
+
+	if err != nil {
+		slog.Error("failed to query ebike depletion", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	plans, err := api.store.PricingPlans().List(r.Context(), from, to)
+
+	if err != nil {
+		slog.Error("failed to query pricing plans", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := v1.EbikeDepletionResponse{From: from.Unix(), To: to.Unix(), PricingPlans: []v1.PricingPlan{}}
+
+	for _, group := range depletion {
+		stats := v1.EbikeDepletion{
+			Stations:        group.Stations,
+			EbikesAvailable: group.EbikesAvailable,
+			Departures:      group.Departures,
+		}
+
+		if group.Waiver {
+			response.Waiver = stats
+		} else {
+			response.NoWaiver = stats
+		}
+	}
+
+	for _, plan := range plans {
+		response.PricingPlans = append(response.PricingPlans, pricingPlanResponse(plan))
+	}
+
+	writeJSON(w, r, response, "max-age=300, public")
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+const maxDataQualityIssues = 1000
+
+// ListDataQualityIssues lists the most recent data quality issues found while
+// syncing, optionally filtered by station (GBFS station_id) and rule
+func (api *Handler) ListDataQualityIssues(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	since, err := parseTimeParam(r, "since", time.Now().Add(-24*time.Hour))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 100
+
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+
+		if err != nil || limit < 1 || limit > maxDataQualityIssues {
+			http.Error(w, "invalid limit: expected 1 to 1000", http.StatusBadRequest)
+			return
+		}
+	}
+
+	response := v1.ListDataQualityIssuesResponse{Issues: []v1.DataQualityIssue{}}
+
+	issues, err := api.store.Quality().List(r.Context(), store.QualityFilter{
+		Since:     since,
+		StationID: query.Get("station"),
+		Rule:      query.Get("rule"),
+		Limit:     limit,
+	})
+
+	if err != nil {
+		slog.Error("failed to query data quality issues", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, issue := range issues {
+		response.Issues = append(response.Issues, v1.DataQualityIssue{
+			Time:            issue.Time.Unix(),
+			Feed:            issue.Feed,
+			SnapshotUpdated: issue.SnapshotUpdated.Unix(),
+			StationID:       issue.StationExternalID,
+			Rule:            issue.Rule,
+			Message:         issue.Message,
+			Quarantined:     issue.Quarantined,
+			Record:          issue.Record,
+		})
+	}
+
+	content, err := json.Marshal(response)
+
+	if err != nil {
+		slog.Error("failed to marshal response", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "max-age=60, public")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(content)
+
+	if err != nil {
+		slog.Error("failed to write response", "path", r.URL.Path, "error", err)
+		return
+	}
+}
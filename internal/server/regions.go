@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+const (
+	regionDefaultBucket = 15 * time.Minute
+	regionMinBucket     = 5 * time.Minute // width of the historical aggregate
+	regionMaxBucket     = 24 * time.Hour
+	regionMaxWindow     = 31 * 24 * time.Hour
+)
+
+func regionResponse(region store.Region) v1.Region {
+	return v1.Region{
+		ID:       region.ID,
+		RegionID: region.ExternalID,
+		Name:     region.Name,
+		Custom:   region.ExternalID == nil,
+		Stations: region.Stations,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, response any, cacheControl string) {
+	content, err := json.Marshal(response)
+
+	if err != nil {
+		slog.Error("failed to marshal response", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(content)
+
+	if err != nil {
+		slog.Error("failed to write response", "path", r.URL.Path, "error", err)
+		return
+	}
+}
+
+// ListRegions lists the regions of system_regions and the custom regions
+func (api *Handler) ListRegions(w http.ResponseWriter, r *http.Request) {
+	regions, err := api.store.Regions().List(r.Context())
+
+	if err != nil {
+		slog.Error("failed to query regions", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := v1.ListRegionsResponse{Regions: []v1.Region{}}
+
+	for _, region := range regions {
+		response.Regions = append(response.Regions, regionResponse(region))
+	}
+
+	writeJSON(w, r, response, "max-age=300, public")
+}
+
+// GetRegionAvailability sums the availability of the stations currently
+// within a region, between from and to (unix timestamps, the last 24 hours by
+// default) in buckets of bucket seconds
+func (api *Handler) GetRegionAvailability(w http.ResponseWriter, r *http.Request) {
+	regionID, err := strconv.ParseInt(r.PathValue("regionId"), 10, 64)
+
+	if err != nil {
+		http.Error(w, "invalid region id", http.StatusBadRequest)
+		return
+	}
+
+	bucket := regionDefaultBucket
+
+	if value := r.URL.Query().Get("bucket"); value != "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		bucket = time.Duration(seconds) * time.Second
+
+		if err != nil || bucket < regionMinBucket || bucket > regionMaxBucket || bucket%regionMinBucket != 0 {
+			http.Error(w, fmt.Sprintf("invalid bucket: expected a multiple of %.0f seconds up to %.0f", regionMinBucket.Seconds(), regionMaxBucket.Seconds()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	to, err := parseTimeParam(r, "to", time.Now())
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTimeParam(r, "from", to.Add(-24*time.Hour))
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !from.Before(to) || to.Sub(from) > regionMaxWindow {
+		http.Error(w, "invalid window: from must precede to by at most 31 days", http.StatusBadRequest)
+		return
+	}
+
+	availability, err := api.store.Regions().Availability(r.Context(), regionID, from, to, bucket)
+
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "region not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		slog.Error("failed to query region availability", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	regions, err := api.store.Regions().List(r.Context())
+
+	if err != nil {
+		slog.Error("failed to query regions", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := v1.RegionAvailabilityResponse{Availability: []v1.RegionAvailability{}}
+
+	for _, region := range regions {
+		if region.ID == regionID {
+			response.Region = regionResponse(region)
+		}
+	}
+
+	for _, a := range availability {
+		response.Availability = append(response.Availability, v1.RegionAvailability{
+			Time:            a.Time.Unix(),
+			Stations:        a.Stations,
+			BikesAvailable:  a.BikesAvailable,
+			EbikesAvailable: a.EbikesAvailable,
+			DocksAvailable:  a.DocksAvailable,
+		})
+	}
+
+	writeJSON(w, r, response, "max-age=60, public")
+}
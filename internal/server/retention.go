@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+// optionalSeconds converts a policy duration, nil if the policy is disabled
+func optionalSeconds(after time.Duration) *int64 {
+	if after == 0 {
+		return nil
+	}
+
+	seconds := int64(after.Seconds())
+	return &seconds
+}
+
+// Retention reports the retention and compression policies in effect
+func (api *Handler) Retention(w http.ResponseWriter, r *http.Request) {
+	statuses, err := api.store.Retention().List(r.Context())
+
+	if errors.Is(err, store.ErrUnsupported) {
+		http.Error(w, "retention policies are not supported by this database", http.StatusNotImplemented)
+		return
+	}
+
+	if err != nil {
+		slog.Error("failed to query retention policies", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := v1.RetentionResponse{Relations: []v1.RetentionStatus{}}
+
+	for _, status := range statuses {
+		response.Relations = append(response.Relations, v1.RetentionStatus{
+			Relation:           status.Relation,
+			DropAfter:          optionalSeconds(status.DropAfter),
+			CompressAfter:      optionalSeconds(status.CompressAfter),
+			CompressionEnabled: status.CompressionEnabled,
+			Chunks:             status.Chunks,
+			CompressedChunks:   status.CompressedChunks,
+			Oldest:             unixPtr(status.Oldest),
+		})
+	}
+
+	content, err := json.Marshal(response)
+
+	if err != nil {
+		slog.Error("failed to marshal response", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(content)
+
+	if err != nil {
+		slog.Error("failed to write response", "path", r.URL.Path, "error", err)
+		return
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/ngc7293/hixi/internal/store"
+	v1 "github.com/ngc7293/hixi/pkg/api/v1"
+)
+
+// vehicleTypeIDs returns the GBFS vehicle_type_id of each vehicle type, by id
+func (api *Handler) vehicleTypeIDs(ctx context.Context) (map[int64]string, error) {
+	vehicleTypes, err := api.store.VehicleTypes().List(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[int64]string{}
+
+	for _, vehicleType := range vehicleTypes {
+		ids[vehicleType.ID] = vehicleType.ExternalID
+	}
+
+	return ids, nil
+}
+
+// currentVehicles keys the vehicles of Availability by GBFS vehicle_type_id,
+// nil without counts
+func currentVehicles(ids map[int64]string, vehicles []store.VehicleAvailability) map[string]float64 {
+	if len(vehicles) == 0 {
+		return nil
+	}
+
+	result := map[string]float64{}
+
+	for _, count := range vehicles {
+		result[ids[count.VehicleTypeID]] = float64(count.Available)
+	}
+
+	return result
+}
+
+// averageVehicles keys the vehicles of AverageAvailability by GBFS
+// vehicle_type_id, nil without counts
+func averageVehicles(ids map[int64]string, vehicles []store.AverageVehicleAvailability) map[string]float64 {
+	if len(vehicles) == 0 {
+		return nil
+	}
+
+	result := map[string]float64{}
+
+	for _, average := range vehicles {
+		result[ids[average.VehicleTypeID]] = average.Available
+	}
+
+	return result
+}
+
+func vehicleTypeResponse(vehicleType store.VehicleType) v1.VehicleType {
+	return v1.VehicleType{
+		ID:             vehicleType.ExternalID,
+		FormFactor:     vehicleType.FormFactor,
+		PropulsionType: vehicleType.PropulsionType,
+		Name:           vehicleType.Name,
+		MaxRangeMeters: vehicleType.MaxRangeMeters,
+	}
+}
+
+// ListVehicleTypes lists the vehicle types counted by the availability of
+// stations
+func (api *Handler) ListVehicleTypes(w http.ResponseWriter, r *http.Request) {
+	vehicleTypes, err := api.store.VehicleTypes().List(r.Context())
+
+	if err != nil {
+		slog.Error("failed to query vehicle types", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := v1.ListVehicleTypesResponse{VehicleTypes: []v1.VehicleType{}}
+
+	for _, vehicleType := range vehicleTypes {
+		response.VehicleTypes = append(response.VehicleTypes, vehicleTypeResponse(vehicleType))
+	}
+
+	writeJSON(w, r, response, "max-age=300, public")
+}
@@ -0,0 +1,1035 @@
+// Package memory implements store.Store in memory, for tests. Transactions
+// are serialized and rolled back by restoring a copy of the whole store.
+package memory
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+// aggregateBucket is the width of the historical aggregate's buckets
+const aggregateBucket = 5 * time.Minute
+
+type station struct {
+	id                 int64
+	externalID         string
+	lastStatusReported *time.Time
+	info               *store.StationInformation // nil until listed in station_information
+	versions           []store.StationVersion
+}
+
+type data struct {
+	nextID       int64
+	stations     map[int64]*station
+	byExternalID map[string]int64
+	availability map[int64][]store.Availability // by station, in insertion order
+	issues       []store.QualityIssue
+	syncState    map[string]store.SyncState
+	regions      []store.Region // Stations is counted when listing
+	alerts       map[string]store.Alert
+	vehicleTypes []store.VehicleType // by id, starting at 1
+	pricingPlans []store.PricingPlan // versions, by plan_id then valid_from
+	zones        []store.GeofencingZone
+}
+
+func (d *data) clone() *data {
+	c := &data{
+		nextID:       d.nextID,
+		stations:     map[int64]*station{},
+		byExternalID: maps.Clone(d.byExternalID),
+		availability: map[int64][]store.Availability{},
+		issues:       slices.Clone(d.issues),
+		syncState:    maps.Clone(d.syncState),
+		regions:      slices.Clone(d.regions),
+		alerts:       maps.Clone(d.alerts),
+		vehicleTypes: slices.Clone(d.vehicleTypes),
+		pricingPlans: slices.Clone(d.pricingPlans),
+		zones:        d.zones, // replaced, never modified
+	}
+
+	for id, s := range d.stations {
+		copied := *s
+		copied.versions = slices.Clone(s.versions)
+		c.stations[id] = &copied
+	}
+
+	for id, rows := range d.availability {
+		c.availability[id] = slices.Clone(rows)
+	}
+
+	return c
+}
+
+type Store struct {
+	mu   *sync.Mutex // held for the whole of a transaction
+	data **data
+	inTx bool
+}
+
+func New() *Store {
+	d := &data{
+		nextID:       1,
+		stations:     map[int64]*station{},
+		byExternalID: map[string]int64{},
+		availability: map[int64][]store.Availability{},
+		syncState:    map[string]store.SyncState{},
+		alerts:       map[string]store.Alert{},
+	}
+
+	return &Store{mu: &sync.Mutex{}, data: &d}
+}
+
+// lock gives fn exclusive access to the data. Within a transaction, the
+// transaction already holds it.
+func (s *Store) lock(fn func(d *data) error) error {
+	if !s.inTx {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	return fn(*s.data)
+}
+
+func (s *Store) Stations() store.StationRepository {
+	return stations{s}
+}
+
+func (s *Store) Availability() store.AvailabilityRepository {
+	return availability{s}
+}
+
+func (s *Store) Quality() store.QualityRepository {
+	return quality{s}
+}
+
+func (s *Store) SyncState() store.SyncStateRepository {
+	return syncState{s}
+}
+
+func (s *Store) Retention() store.RetentionRepository {
+	return retention{}
+}
+
+func (s *Store) Regions() store.RegionRepository {
+	return regions{s}
+}
+
+func (s *Store) Alerts() store.AlertRepository {
+	return alerts{s}
+}
+
+func (s *Store) VehicleTypes() store.VehicleTypeRepository {
+	return vehicleTypes{s}
+}
+
+func (s *Store) PricingPlans() store.PricingPlanRepository {
+	return pricingPlans{s}
+}
+
+func (s *Store) GeofencingZones() store.GeofencingZoneRepository {
+	return geofencingZones{s}
+}
+
+func (s *Store) InTx(ctx context.Context, fn func(tx store.Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := (*s.data).clone()
+	err := fn(&Store{mu: s.mu, data: s.data, inTx: true})
+
+	if err != nil {
+		*s.data = saved
+	}
+
+	return err
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (s *Store) SchemaVersion(ctx context.Context) (int32, error) {
+	return 0, store.ErrUnsupported
+}
+
+type stations struct {
+	s *Store
+}
+
+func (r stations) FindOrCreate(ctx context.Context, externalID string) (*store.KnownStation, error) {
+	known := &store.KnownStation{}
+
+	err := r.s.lock(func(d *data) error {
+		id, ok := d.byExternalID[externalID]
+
+		if !ok {
+			id = d.nextID
+			d.nextID++
+			d.stations[id] = &station{id: id, externalID: externalID}
+			d.byExternalID[externalID] = id
+		}
+
+		s := d.stations[id]
+		known.ID = id
+		known.LastStatusReported = s.lastStatusReported
+		known.Listed = s.info != nil
+
+		if s.info != nil {
+			known.Capacity = s.info.Capacity
+		}
+
+		return nil
+	})
+
+	return known, err
+}
+
+func (r stations) Upsert(ctx context.Context, info store.StationInformation, at time.Time) error {
+	return r.s.lock(func(d *data) error {
+		id, ok := d.byExternalID[info.ExternalID]
+
+		if !ok {
+			id = d.nextID
+			d.nextID++
+			d.stations[id] = &station{id: id, externalID: info.ExternalID}
+			d.byExternalID[info.ExternalID] = id
+		}
+
+		s := d.stations[id]
+		info.Metadata.RentalMethods = slices.Clone(info.Metadata.RentalMethods)
+
+		if len(s.versions) > 0 {
+			latest := &s.versions[len(s.versions)-1]
+
+			if at.Before(latest.ValidFrom) || (latest.ValidTo != nil && at.Before(*latest.ValidTo)) {
+				return nil
+			}
+
+			if latest.ValidTo == nil && latest.Describes(info) {
+				s.info = &info
+				return nil
+			}
+
+			if latest.ValidTo == nil {
+				latest.ValidTo = &at
+			}
+
+			// A version replaced as soon as it started is overwritten
+			if latest.ValidFrom.Equal(at) {
+				s.versions = s.versions[:len(s.versions)-1]
+			}
+		}
+
+		s.info = &info
+		s.versions = append(s.versions, store.StationVersion{
+			ValidFrom: at,
+			Name:      info.Name,
+			ShortName: info.ShortName,
+			Lon:       info.Lon,
+			Lat:       info.Lat,
+			Capacity:  info.Capacity,
+
+			ElectricBikeSurchargeWaiver: info.Metadata.ElectricBikeSurchargeWaiver,
+		})
+
+		return nil
+	})
+}
+
+func (r stations) Decommission(ctx context.Context, listed []string, at time.Time) (int64, error) {
+	decommissioned := int64(0)
+
+	err := r.s.lock(func(d *data) error {
+		for _, s := range d.stations {
+			if len(s.versions) == 0 || slices.Contains(listed, s.externalID) {
+				continue
+			}
+
+			latest := &s.versions[len(s.versions)-1]
+
+			if latest.ValidTo == nil && !latest.ValidFrom.After(at) {
+				latest.ValidTo = &at
+				decommissioned++
+			}
+		}
+
+		return nil
+	})
+
+	return decommissioned, err
+}
+
+func (r stations) History(ctx context.Context, id int64) ([]store.StationVersion, error) {
+	result := []store.StationVersion{}
+
+	err := r.s.lock(func(d *data) error {
+		if s, ok := d.stations[id]; ok {
+			result = append(result, s.versions...)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (r stations) SetLastReported(ctx context.Context, id int64, reported time.Time) error {
+	return r.s.lock(func(d *data) error {
+		s, ok := d.stations[id]
+
+		if !ok {
+			return store.ErrNotFound
+		}
+
+		s.lastStatusReported = &reported
+		return nil
+	})
+}
+
+func (r stations) List(ctx context.Context, filter store.StationFilter) ([]store.StationSummary, error) {
+	result := []store.StationSummary{}
+
+	err := r.s.lock(func(d *data) error {
+		for _, s := range d.stations {
+			if s.info == nil || !filter.Matches(s.info.Metadata) {
+				continue
+			}
+
+			active := false
+
+			for _, a := range d.availability[s.id] {
+				active = active || a.Time.After(filter.ActiveSince)
+			}
+
+			result = append(result, store.StationSummary{
+				ID:             s.id,
+				ExternalID:     s.externalID,
+				Name:           s.info.Name,
+				ShortName:      s.info.ShortName,
+				Lon:            s.info.Lon,
+				Lat:            s.info.Lat,
+				Active:         active,
+				Decommissioned: s.versions[len(s.versions)-1].ValidTo != nil,
+				Metadata:       s.info.Metadata,
+			})
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, err
+}
+
+func (r stations) Get(ctx context.Context, id int64) (*store.StationInformation, error) {
+	var info store.StationInformation
+
+	err := r.s.lock(func(d *data) error {
+		s, ok := d.stations[id]
+
+		if !ok {
+			return store.ErrNotFound
+		}
+
+		info = store.StationInformation{ExternalID: s.externalID}
+
+		if s.info != nil {
+			info = *s.info
+			info.Metadata.RentalMethods = slices.Clone(info.Metadata.RentalMethods)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+type availability struct {
+	s *Store
+}
+
+func (r availability) Insert(ctx context.Context, a store.Availability) error {
+	return r.s.lock(func(d *data) error {
+		if _, ok := d.stations[a.StationID]; !ok {
+			return store.ErrNotFound
+		}
+
+		a.Vehicles = slices.SortedFunc(slices.Values(a.Vehicles), func(a, b store.VehicleAvailability) int {
+			return cmp.Compare(a.VehicleTypeID, b.VehicleTypeID)
+		})
+		d.availability[a.StationID] = append(d.availability[a.StationID], a)
+		return nil
+	})
+}
+
+func (r availability) Latest(ctx context.Context, stationID int64) (*store.Availability, error) {
+	var latest *store.Availability
+
+	err := r.s.lock(func(d *data) error {
+		for _, a := range d.availability[stationID] {
+			if latest == nil || a.Time.After(latest.Time) {
+				latest = &a
+			}
+		}
+
+		if latest == nil {
+			return store.ErrNotFound
+		}
+
+		return nil
+	})
+
+	return latest, err
+}
+
+// History averages the raw availability directly, since there is no
+// aggregate to lag behind
+func (r availability) History(ctx context.Context, stationID int64, from time.Time, to time.Time, bucket time.Duration) ([]store.AverageAvailability, error) {
+	type sum struct {
+		bikes, ebikes     float64
+		count, ebikeCount int
+		vehicles          map[int64]float64 // by vehicle type id
+		vehicleCounts     map[int64]int
+	}
+
+	sums := map[time.Time]*sum{}
+
+	err := r.s.lock(func(d *data) error {
+		for _, a := range d.availability[stationID] {
+			if a.Time.Before(from) || a.Time.After(to) {
+				continue
+			}
+
+			key := a.Time.Truncate(bucket)
+
+			if sums[key] == nil {
+				sums[key] = &sum{vehicles: map[int64]float64{}, vehicleCounts: map[int64]int{}}
+			}
+
+			for _, vehicles := range a.Vehicles {
+				sums[key].vehicles[vehicles.VehicleTypeID] += float64(vehicles.Available)
+				sums[key].vehicleCounts[vehicles.VehicleTypeID]++
+			}
+
+			sums[key].bikes += float64(a.BikesAvailable)
+			sums[key].count++
+
+			if a.EbikesAvailable != nil {
+				sums[key].ebikes += float64(*a.EbikesAvailable)
+				sums[key].ebikeCount++
+			}
+		}
+
+		return nil
+	})
+
+	result := []store.AverageAvailability{}
+
+	for _, key := range slices.SortedFunc(maps.Keys(sums), time.Time.Compare) {
+		s := sums[key]
+		average := store.AverageAvailability{Time: key, BikesAvailable: s.bikes / float64(s.count)}
+
+		// Like AVG, ignore missing ebike counts
+		if s.ebikeCount > 0 {
+			average.EbikesAvailable = s.ebikes / float64(s.ebikeCount)
+		}
+
+		for _, id := range slices.Sorted(maps.Keys(s.vehicles)) {
+			average.Vehicles = append(average.Vehicles, store.AverageVehicleAvailability{
+				VehicleTypeID: id,
+				Available:     s.vehicles[id] / float64(s.vehicleCounts[id]),
+			})
+		}
+
+		result = append(result, average)
+	}
+
+	return result, err
+}
+
+// AggregateLag treats everything as aggregated, so the lag is only within the
+// latest bucket
+func (r availability) AggregateLag(ctx context.Context) (time.Time, time.Duration, error) {
+	var latest time.Time
+
+	err := r.s.lock(func(d *data) error {
+		for _, rows := range d.availability {
+			for _, a := range rows {
+				if a.Time.After(latest) {
+					latest = a.Time
+				}
+			}
+		}
+
+		if latest.IsZero() {
+			return store.ErrNotFound
+		}
+
+		return nil
+	})
+
+	bucket := latest.Truncate(aggregateBucket)
+	return bucket, latest.Sub(bucket), err
+}
+
+func (r availability) Heatmap(ctx context.Context, query store.HeatmapQuery) ([]store.HeatmapCell, error) {
+	return nil, store.ErrUnsupported
+}
+
+// EbikeDepletion averages each station's ebike counts within 5 minute
+// buckets, like the aggregate, then compares consecutive buckets. Buckets
+// separated by a gap are not compared.
+func (r availability) EbikeDepletion(ctx context.Context, from time.Time, to time.Time) ([]store.EbikeDepletion, error) {
+	type sum struct {
+		stations            map[int64]bool
+		ebikes, departures  float64
+		buckets, comparable int
+	}
+
+	groups := map[bool]*sum{}
+
+	err := r.s.lock(func(d *data) error {
+		for id, rows := range d.availability {
+			totals, counts := map[time.Time]float64{}, map[time.Time]int{}
+
+			for _, a := range rows {
+				key := a.Time.Truncate(aggregateBucket)
+
+				if a.EbikesAvailable == nil || key.Before(from) || key.After(to) {
+					continue
+				}
+
+				totals[key] += float64(*a.EbikesAvailable)
+				counts[key]++
+			}
+
+			previous, previousKey := -1.0, time.Time{}
+
+			for _, key := range slices.SortedFunc(maps.Keys(totals), time.Time.Compare) {
+				ebikes := totals[key] / float64(counts[key])
+				version := versionAt(d.stations[id].versions, key)
+
+				if version != nil {
+					group := groups[version.ElectricBikeSurchargeWaiver]
+
+					if group == nil {
+						group = &sum{stations: map[int64]bool{}}
+						groups[version.ElectricBikeSurchargeWaiver] = group
+					}
+
+					group.stations[id] = true
+					group.ebikes += ebikes
+					group.buckets++
+
+					if previous >= 0 && key.Sub(previousKey) == aggregateBucket {
+						group.departures += max(previous-ebikes, 0)
+						group.comparable++
+					}
+				}
+
+				previous, previousKey = ebikes, key
+			}
+		}
+
+		return nil
+	})
+
+	result := []store.EbikeDepletion{}
+
+	for _, waiver := range []bool{false, true} {
+		group := groups[waiver]
+
+		if group == nil {
+			continue
+		}
+
+		depletion := store.EbikeDepletion{
+			Waiver:          waiver,
+			Stations:        int64(len(group.stations)),
+			EbikesAvailable: group.ebikes / float64(group.buckets),
+		}
+
+		// 12 buckets of 5 minutes make an hour
+		if group.comparable > 0 {
+			depletion.Departures = group.departures * 12 / float64(group.comparable)
+		}
+
+		result = append(result, depletion)
+	}
+
+	return result, err
+}
+
+// versionAt returns the version of versions valid at t, nil if none
+func versionAt(versions []store.StationVersion, t time.Time) *store.StationVersion {
+	for i := range versions {
+		if !t.Before(versions[i].ValidFrom) && (versions[i].ValidTo == nil || t.Before(*versions[i].ValidTo)) {
+			return &versions[i]
+		}
+	}
+
+	return nil
+}
+
+type quality struct {
+	s *Store
+}
+
+func (r quality) Record(ctx context.Context, issue store.QualityIssue) error {
+	return r.s.lock(func(d *data) error {
+		issue.Record = slices.Clone(issue.Record)
+		d.issues = append(d.issues, issue)
+		return nil
+	})
+}
+
+func (r quality) List(ctx context.Context, filter store.QualityFilter) ([]store.QualityIssue, error) {
+	result := []store.QualityIssue{}
+
+	err := r.s.lock(func(d *data) error {
+		for _, issue := range d.issues {
+			if issue.Time.Before(filter.Since) {
+				continue
+			}
+
+			if filter.StationID != "" && issue.StationExternalID != filter.StationID {
+				continue
+			}
+
+			if filter.Rule != "" && issue.Rule != filter.Rule {
+				continue
+			}
+
+			result = append(result, issue)
+		}
+
+		return nil
+	})
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.After(result[j].Time) })
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result, err
+}
+
+type syncState struct {
+	s *Store
+}
+
+func (r syncState) Record(ctx context.Context, feed string, lastUpdated time.Time, syncedAt time.Time) error {
+	return r.s.lock(func(d *data) error {
+		d.syncState[feed] = store.SyncState{Feed: feed, LastSuccess: syncedAt, LastUpdated: &lastUpdated}
+		return nil
+	})
+}
+
+func (r syncState) Get(ctx context.Context, feed string) (*store.SyncState, error) {
+	var state *store.SyncState
+
+	err := r.s.lock(func(d *data) error {
+		if s, ok := d.syncState[feed]; ok {
+			state = &s
+			return nil
+		}
+
+		return store.ErrNotFound
+	})
+
+	return state, err
+}
+
+// retention is not supported: the memory store only lives as long as a test
+type retention struct{}
+
+func (retention) Apply(ctx context.Context, policies []store.RetentionPolicy) error {
+	return store.ErrUnsupported
+}
+
+func (retention) List(ctx context.Context) ([]store.RetentionStatus, error) {
+	return nil, store.ErrUnsupported
+}
+
+type regions struct {
+	s *Store
+}
+
+func (r regions) Upsert(ctx context.Context, externalID string, name string) error {
+	return r.s.lock(func(d *data) error {
+		for i := range d.regions {
+			if *d.regions[i].ExternalID == externalID {
+				d.regions[i].Name = name
+				return nil
+			}
+		}
+
+		d.regions = append(d.regions, store.Region{ID: int64(len(d.regions) + 1), ExternalID: &externalID, Name: name})
+		return nil
+	})
+}
+
+func (r regions) Create(ctx context.Context, name string, geometry []byte) (int64, error) {
+	return 0, store.ErrUnsupported
+}
+
+func (r regions) Delete(ctx context.Context, id int64) error {
+	// Every region comes from system_regions
+	return store.ErrNotFound
+}
+
+// within reports whether s currently references the region
+func within(s *station, region store.Region) bool {
+	return s.info != nil && s.info.Metadata.RegionID != nil && *s.info.Metadata.RegionID == *region.ExternalID
+}
+
+func (r regions) List(ctx context.Context) ([]store.Region, error) {
+	result := []store.Region{}
+
+	err := r.s.lock(func(d *data) error {
+		for _, region := range d.regions {
+			region.Stations = 0
+
+			for _, s := range d.stations {
+				if within(s, region) && s.versions[len(s.versions)-1].ValidTo == nil {
+					region.Stations++
+				}
+			}
+
+			result = append(result, region)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// Availability averages each station's samples within the bucket, then sums
+// the averages
+func (r regions) Availability(ctx context.Context, id int64, from time.Time, to time.Time, bucket time.Duration) ([]store.RegionAvailability, error) {
+	type sum struct {
+		bikes, ebikes, docks float64
+		count, ebikeCount    int
+	}
+
+	buckets := map[time.Time]*store.RegionAvailability{}
+
+	err := r.s.lock(func(d *data) error {
+		index := slices.IndexFunc(d.regions, func(region store.Region) bool { return region.ID == id })
+
+		if index < 0 {
+			return store.ErrNotFound
+		}
+
+		for _, s := range d.stations {
+			if !within(s, d.regions[index]) {
+				continue
+			}
+
+			sums := map[time.Time]*sum{}
+
+			for _, a := range d.availability[s.id] {
+				if a.Time.Before(from) || a.Time.After(to) {
+					continue
+				}
+
+				key := a.Time.Truncate(bucket)
+
+				if sums[key] == nil {
+					sums[key] = &sum{}
+				}
+
+				sums[key].bikes += float64(a.BikesAvailable)
+				sums[key].docks += float64(a.DocksAvailable)
+				sums[key].count++
+
+				if a.EbikesAvailable != nil {
+					sums[key].ebikes += float64(*a.EbikesAvailable)
+					sums[key].ebikeCount++
+				}
+			}
+
+			for key, station := range sums {
+				if buckets[key] == nil {
+					buckets[key] = &store.RegionAvailability{Time: key}
+				}
+
+				total := buckets[key]
+				total.Stations++
+				total.BikesAvailable += station.bikes / float64(station.count)
+				total.DocksAvailable += station.docks / float64(station.count)
+
+				if station.ebikeCount > 0 {
+					total.EbikesAvailable += station.ebikes / float64(station.ebikeCount)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := []store.RegionAvailability{}
+
+	for _, key := range slices.SortedFunc(maps.Keys(buckets), time.Time.Compare) {
+		result = append(result, *buckets[key])
+	}
+
+	return result, nil
+}
+
+type alerts struct {
+	s *Store
+}
+
+func (r alerts) Upsert(ctx context.Context, alert store.Alert) error {
+	alert.Times = slices.Clone(alert.Times)
+	alert.StationIDs = slices.Clone(alert.StationIDs)
+	alert.RegionIDs = slices.Clone(alert.RegionIDs)
+	alert.Withdrawn = nil
+
+	return r.s.lock(func(d *data) error {
+		d.alerts[alert.ExternalID] = alert
+		return nil
+	})
+}
+
+func (r alerts) Withdraw(ctx context.Context, listed []string, at time.Time) (int64, error) {
+	withdrawn := int64(0)
+
+	err := r.s.lock(func(d *data) error {
+		for id, alert := range d.alerts {
+			if alert.Withdrawn == nil && !slices.Contains(listed, id) {
+				alert.Withdrawn = &at
+				d.alerts[id] = alert
+				withdrawn++
+			}
+		}
+
+		return nil
+	})
+
+	return withdrawn, err
+}
+
+func (r alerts) List(ctx context.Context, since time.Time) ([]store.Alert, error) {
+	result := []store.Alert{}
+
+	err := r.s.lock(func(d *data) error {
+		for _, id := range slices.Sorted(maps.Keys(d.alerts)) {
+			alert := d.alerts[id]
+
+			if alert.Withdrawn == nil || alert.Withdrawn.After(since) {
+				result = append(result, alert)
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+type vehicleTypes struct {
+	s *Store
+}
+
+func (r vehicleTypes) FindOrCreate(ctx context.Context, externalID string) (int64, error) {
+	var id int64
+
+	err := r.s.lock(func(d *data) error {
+		for _, vehicleType := range d.vehicleTypes {
+			if vehicleType.ExternalID == externalID {
+				id = vehicleType.ID
+				return nil
+			}
+		}
+
+		id = int64(len(d.vehicleTypes) + 1)
+		d.vehicleTypes = append(d.vehicleTypes, store.VehicleType{ID: id, ExternalID: externalID})
+		return nil
+	})
+
+	return id, err
+}
+
+func (r vehicleTypes) Upsert(ctx context.Context, vehicleType store.VehicleType) error {
+	id, err := r.FindOrCreate(ctx, vehicleType.ExternalID)
+
+	if err != nil {
+		return err
+	}
+
+	return r.s.lock(func(d *data) error {
+		vehicleType.ID = id
+		d.vehicleTypes[id-1] = vehicleType
+		return nil
+	})
+}
+
+func (r vehicleTypes) List(ctx context.Context) ([]store.VehicleType, error) {
+	var result []store.VehicleType
+
+	err := r.s.lock(func(d *data) error {
+		result = slices.Clone(d.vehicleTypes)
+		return nil
+	})
+
+	if result == nil {
+		result = []store.VehicleType{}
+	}
+
+	return result, err
+}
+
+type pricingPlans struct {
+	s *Store
+}
+
+func (r pricingPlans) Upsert(ctx context.Context, plan store.PricingPlan, at time.Time) error {
+	return r.s.lock(func(d *data) error {
+		latest := -1
+
+		for i := range d.pricingPlans {
+			if d.pricingPlans[i].PlanID == plan.PlanID {
+				latest = i
+			}
+		}
+
+		if latest >= 0 {
+			current := &d.pricingPlans[latest]
+
+			if at.Before(current.ValidFrom) || (current.ValidTo != nil && at.Before(*current.ValidTo)) {
+				return nil
+			}
+
+			if current.ValidTo == nil && current.Describes(plan) {
+				return nil
+			}
+
+			if current.ValidTo == nil {
+				current.ValidTo = &at
+			}
+
+			// A version replaced as soon as it started is overwritten
+			if current.ValidFrom.Equal(at) {
+				d.pricingPlans = slices.Delete(d.pricingPlans, latest, latest+1)
+			}
+		}
+
+		plan.ValidFrom = at
+		plan.ValidTo = nil
+		plan.PerKmPricing = slices.Clone(plan.PerKmPricing)
+		plan.PerMinPricing = slices.Clone(plan.PerMinPricing)
+		d.pricingPlans = append(d.pricingPlans, plan)
+
+		slices.SortStableFunc(d.pricingPlans, func(a, b store.PricingPlan) int {
+			return cmp.Or(cmp.Compare(a.PlanID, b.PlanID), a.ValidFrom.Compare(b.ValidFrom))
+		})
+
+		return nil
+	})
+}
+
+func (r pricingPlans) Withdraw(ctx context.Context, listed []string, at time.Time) (int64, error) {
+	withdrawn := int64(0)
+
+	err := r.s.lock(func(d *data) error {
+		for i := range d.pricingPlans {
+			plan := &d.pricingPlans[i]
+
+			if plan.ValidTo == nil && !plan.ValidFrom.After(at) && !slices.Contains(listed, plan.PlanID) {
+				plan.ValidTo = &at
+				withdrawn++
+			}
+		}
+
+		return nil
+	})
+
+	return withdrawn, err
+}
+
+func (r pricingPlans) List(ctx context.Context, from time.Time, to time.Time) ([]store.PricingPlan, error) {
+	result := []store.PricingPlan{}
+
+	err := r.s.lock(func(d *data) error {
+		for _, plan := range d.pricingPlans {
+			if !plan.ValidFrom.After(to) && (plan.ValidTo == nil || plan.ValidTo.After(from)) {
+				result = append(result, plan)
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+type geofencingZones struct {
+	s *Store
+}
+
+func (r geofencingZones) Replace(ctx context.Context, zones []store.GeofencingZone) error {
+	return r.s.lock(func(d *data) error {
+		d.zones = slices.Clone(zones)
+		return nil
+	})
+}
+
+func (r geofencingZones) List(ctx context.Context) ([]store.GeofencingZone, error) {
+	result := []store.GeofencingZone{}
+
+	err := r.s.lock(func(d *data) error {
+		result = append(result, d.zones...)
+		return nil
+	})
+
+	return result, err
+}
+
+func (r geofencingZones) At(ctx context.Context, lon float64, lat float64) ([]store.GeofencingZone, error) {
+	result := []store.GeofencingZone{}
+
+	err := r.s.lock(func(d *data) error {
+		for _, zone := range d.zones {
+			inside, err := store.Contains(zone.Geometry, lon, lat)
+
+			if err != nil {
+				return err
+			}
+
+			if inside {
+				result = append(result, zone)
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (r geofencingZones) Tile(ctx context.Context, z int, x int, y int) ([]byte, error) {
+	return nil, store.ErrUnsupported
+}
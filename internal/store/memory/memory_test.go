@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/storetest"
+)
+
+func TestInTxRollback(t *testing.T) {
+	st := New()
+	ctx := context.Background()
+	failure := errors.New("failure")
+
+	err := st.InTx(ctx, func(tx store.Store) error {
+		if _, err := tx.Stations().FindOrCreate(ctx, "1"); err != nil {
+			return err
+		}
+
+		return failure
+	})
+
+	if !errors.Is(err, failure) {
+		t.Fatalf("InTx() error = %v, want %v", err, failure)
+	}
+
+	if stations, _ := st.Stations().List(ctx, store.StationFilter{}); len(stations) != 0 {
+		t.Errorf("List() = %+v, want rolled back", stations)
+	}
+
+	err = st.InTx(ctx, func(tx store.Store) error {
+		return tx.Stations().Upsert(ctx, store.StationInformation{ExternalID: "1", Name: "Station 1"}, time.Now())
+	})
+
+	if err != nil {
+		t.Fatalf("InTx() error = %v", err)
+	}
+
+	if stations, _ := st.Stations().List(ctx, store.StationFilter{}); len(stations) != 1 {
+		t.Errorf("List() = %+v, want committed", stations)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	st := New()
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	station, _ := st.Stations().FindOrCreate(ctx, "1")
+
+	for i, bikes := range []int64{2, 4, 9} {
+		err := st.Availability().Insert(ctx, store.Availability{Time: start.Add(time.Duration(i) * 10 * time.Minute), StationID: station.ID, BikesAvailable: bikes})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := st.Availability().History(ctx, station.ID, start, start.Add(time.Hour), 15*time.Minute)
+
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+
+	if len(history) != 2 || history[0].BikesAvailable != 3 || history[1].BikesAvailable != 9 || !history[1].Time.Equal(start.Add(15*time.Minute)) {
+		t.Errorf("History() = %+v, want 2 buckets averaging 3 and 9 bikes", history)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) (store.Store, func(ctx context.Context) error) {
+		return New(), nil
+	})
+}
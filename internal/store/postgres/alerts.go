@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type alerts struct {
+	db querier
+}
+
+func (r alerts) Upsert(ctx context.Context, alert store.Alert) error {
+	windows := alert.Times
+
+	if windows == nil {
+		windows = []store.AlertWindow{}
+	}
+
+	times, err := json.Marshal(windows)
+
+	if err != nil {
+		return err
+	}
+
+	stationIDs, regionIDs := alert.StationIDs, alert.RegionIDs
+
+	if stationIDs == nil {
+		stationIDs = []string{}
+	}
+
+	if regionIDs == nil {
+		regionIDs = []string{}
+	}
+
+	_, err = r.db.Exec(
+		ctx,
+		`INSERT INTO "public"."system_alert" (
+			"external_id",
+			"type",
+			"times",
+			"station_ids",
+			"region_ids",
+			"url",
+			"summary",
+			"description",
+			"last_updated"
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT ("external_id") DO UPDATE SET
+			"type" = excluded."type",
+			"times" = excluded."times",
+			"station_ids" = excluded."station_ids",
+			"region_ids" = excluded."region_ids",
+			"url" = excluded."url",
+			"summary" = excluded."summary",
+			"description" = excluded."description",
+			"last_updated" = excluded."last_updated",
+			"withdrawn" = NULL`,
+		alert.ExternalID,
+		alert.Type,
+		string(times),
+		stationIDs,
+		regionIDs,
+		alert.URL,
+		alert.Summary,
+		alert.Description,
+		alert.LastUpdated,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert alert: %w", err)
+	}
+
+	return nil
+}
+
+func (r alerts) Withdraw(ctx context.Context, listed []string, at time.Time) (int64, error) {
+	tag, err := r.db.Exec(
+		ctx,
+		`UPDATE "public"."system_alert" SET "withdrawn" = $2
+		WHERE "withdrawn" IS NULL AND NOT ("external_id" = ANY($1))`,
+		listed,
+		at,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to withdraw alerts: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (r alerts) List(ctx context.Context, since time.Time) ([]store.Alert, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			"external_id",
+			"type",
+			"times"::TEXT,
+			"station_ids",
+			"region_ids",
+			"url",
+			"summary",
+			"description",
+			"last_updated",
+			"withdrawn"
+		FROM "public"."system_alert"
+		WHERE "withdrawn" IS NULL OR "withdrawn" > $1
+		ORDER BY "external_id"`,
+		since,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.Alert{}
+
+	for rows.Next() {
+		alert := store.Alert{}
+		var times string
+
+		err := rows.Scan(
+			&alert.ExternalID,
+			&alert.Type,
+			&times,
+			&alert.StationIDs,
+			&alert.RegionIDs,
+			&alert.URL,
+			&alert.Summary,
+			&alert.Description,
+			&alert.LastUpdated,
+			&alert.Withdrawn,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query alerts: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(times), &alert.Times); err != nil {
+			return nil, fmt.Errorf("failed to decode times of alert %s: %w", alert.ExternalID, err)
+		}
+
+		result = append(result, alert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+
+	return result, nil
+}
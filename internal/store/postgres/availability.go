@@ -0,0 +1,422 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+// PostGIS grid generators, keyed by store.HeatmapShapes
+var heatmapShapes = map[string]string{
+	"hex":    "ST_HexagonGrid",
+	"square": "ST_SquareGrid",
+}
+
+// Per-station aggregate over the requested window, keyed by
+// store.HeatmapMetrics. Departures are inferred from drops in the number of
+// vehicles docked between two consecutive time buckets, buckets separated by
+// a gap are not compared.
+var heatmapMetrics = map[string]string{
+	"bikes":      `AVG("bikes_available")`,
+	"ebikes":     `AVG("ebikes_available")`,
+	"docks":      `AVG("docks_available")`,
+	"departures": `SUM(GREATEST("previous_vehicles" - "vehicles", 0))`,
+}
+
+type availability struct {
+	db querier
+}
+
+func (r availability) Insert(ctx context.Context, a store.Availability) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO "public"."live_station_availability" (
+				"time",
+				"station_id",
+				"bikes_available",
+				"bikes_disabled",
+				"ebikes_available",
+				"ebikes_disabled",
+				"docks_available",
+				"docks_disabled"
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		a.Time,
+		a.StationID,
+		a.BikesAvailable,
+		a.BikesDisabled,
+		a.EbikesAvailable,
+		a.EbikesDisabled,
+		a.DocksAvailable,
+		a.DocksDisabled,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert station availability: %w", err)
+	}
+
+	if len(a.Vehicles) == 0 {
+		return nil
+	}
+
+	vehicleTypeIDs, available := []int64{}, []int64{}
+
+	for _, vehicles := range a.Vehicles {
+		vehicleTypeIDs = append(vehicleTypeIDs, vehicles.VehicleTypeID)
+		available = append(available, vehicles.Available)
+	}
+
+	_, err = r.db.Exec(
+		ctx,
+		`INSERT INTO "public"."live_station_vehicle_availability" ("time", "station_id", "vehicle_type_id", "available")
+		SELECT $1, $2, "vehicle_type_id", "available"
+		FROM UNNEST($3::BIGINT[], $4::INTEGER[]) AS "vehicles" ("vehicle_type_id", "available")`,
+		a.Time,
+		a.StationID,
+		vehicleTypeIDs,
+		available,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert station vehicle availability: %w", err)
+	}
+
+	return nil
+}
+
+func (r availability) Latest(ctx context.Context, stationID int64) (*store.Availability, error) {
+	a := store.Availability{StationID: stationID}
+	err := r.db.QueryRow(ctx, `
+		SELECT
+			"time",
+			"bikes_available",
+			"bikes_disabled",
+			"ebikes_available",
+			"ebikes_disabled",
+			"docks_available",
+			"docks_disabled"
+		FROM "public"."live_station_availability"
+		WHERE "station_id" = $1
+		ORDER BY "time" DESC
+		LIMIT 1`,
+		stationID,
+	).Scan(&a.Time, &a.BikesAvailable, &a.BikesDisabled, &a.EbikesAvailable, &a.EbikesDisabled, &a.DocksAvailable, &a.DocksDisabled)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query current station availability: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT "vehicle_type_id", "available"
+		FROM "public"."live_station_vehicle_availability"
+		WHERE "station_id" = $1 AND "time" = $2
+		ORDER BY "vehicle_type_id"`,
+		stationID,
+		a.Time,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query current station vehicle availability: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		vehicles := store.VehicleAvailability{}
+
+		if err := rows.Scan(&vehicles.VehicleTypeID, &vehicles.Available); err != nil {
+			return nil, fmt.Errorf("failed to query current station vehicle availability: %w", err)
+		}
+
+		a.Vehicles = append(a.Vehicles, vehicles)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query current station vehicle availability: %w", err)
+	}
+
+	return &a, nil
+}
+
+func (r availability) History(ctx context.Context, stationID int64, from time.Time, to time.Time, bucket time.Duration) ([]store.AverageAvailability, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			TIME_BUCKET(MAKE_INTERVAL(secs => $4), "time_bucket") AS "bucket",
+			AVG("bikes_available"),
+			COALESCE(AVG("ebikes_available"), 0)
+		FROM "public"."historical_station_availability"
+		WHERE
+			"station_id" = $1
+			AND "time_bucket" BETWEEN $2 AND $3
+		GROUP BY "bucket"
+		ORDER BY "bucket"`,
+		stationID,
+		from,
+		to,
+		bucket.Seconds(),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station history: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.AverageAvailability{}
+
+	for rows.Next() {
+		average := store.AverageAvailability{}
+		err := rows.Scan(&average.Time, &average.BikesAvailable, &average.EbikesAvailable)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query station history: %w", err)
+		}
+
+		result = append(result, average)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query station history: %w", err)
+	}
+
+	return result, r.vehicleHistory(ctx, result, stationID, from, to, bucket)
+}
+
+// vehicleHistory adds the availability of each vehicle type to the buckets
+// of History
+func (r availability) vehicleHistory(ctx context.Context, buckets []store.AverageAvailability, stationID int64, from time.Time, to time.Time, bucket time.Duration) error {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			TIME_BUCKET(MAKE_INTERVAL(secs => $4), "time_bucket") AS "bucket",
+			"vehicle_type_id",
+			AVG("available")
+		FROM "public"."historical_station_vehicle_availability"
+		WHERE
+			"station_id" = $1
+			AND "time_bucket" BETWEEN $2 AND $3
+		GROUP BY "bucket", "vehicle_type_id"
+		ORDER BY "bucket", "vehicle_type_id"`,
+		stationID,
+		from,
+		to,
+		bucket.Seconds(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to query station vehicle history: %w", err)
+	}
+
+	defer rows.Close()
+
+	byTime := map[int64]*store.AverageAvailability{}
+
+	for i := range buckets {
+		byTime[buckets[i].Time.Unix()] = &buckets[i]
+	}
+
+	for rows.Next() {
+		var at time.Time
+		vehicles := store.AverageVehicleAvailability{}
+
+		if err := rows.Scan(&at, &vehicles.VehicleTypeID, &vehicles.Available); err != nil {
+			return fmt.Errorf("failed to query station vehicle history: %w", err)
+		}
+
+		// The aggregates are refreshed separately: skip buckets the
+		// station aggregate does not have yet
+		if average, ok := byTime[at.Unix()]; ok {
+			average.Vehicles = append(average.Vehicles, vehicles)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query station vehicle history: %w", err)
+	}
+
+	return nil
+}
+
+func (r availability) AggregateLag(ctx context.Context) (time.Time, time.Duration, error) {
+	var latest *time.Time
+	var lag *time.Duration
+	err := r.db.QueryRow(ctx, `
+		SELECT
+			"latest",
+			(SELECT MAX("time") FROM "public"."live_station_availability") - "latest"
+		FROM (SELECT MAX("time_bucket") AS "latest" FROM "public"."historical_station_availability") AS "aggregate"`,
+	).Scan(&latest, &lag)
+
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("failed to read aggregate state: %w", err)
+	}
+
+	if latest == nil || lag == nil {
+		return time.Time{}, 0, store.ErrNotFound
+	}
+
+	return *latest, *lag, nil
+}
+
+func (r availability) Heatmap(ctx context.Context, query store.HeatmapQuery) ([]store.HeatmapCell, error) {
+	gridFunction, ok := heatmapShapes[query.Shape]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown heatmap shape %q", query.Shape)
+	}
+
+	metricExpression, ok := heatmapMetrics[query.Metric]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown heatmap metric %q", query.Metric)
+	}
+
+	// Both the grid function and the metric expression come from the
+	// whitelists above, never from user input directly.
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		WITH "bounds" AS (
+			SELECT
+				ST_Transform(ST_SetSRID(ST_Extent("location")::GEOMETRY, 4326), 3857) AS "geom",
+				-- Web mercator stretches distances by 1/cos(latitude), cells
+				-- are sized in meters at the center of the stations
+				$1 / COS(RADIANS((ST_YMin(ST_Extent("location")) + ST_YMax(ST_Extent("location"))) / 2)) AS "size"
+			FROM "public"."station"
+			WHERE "location" IS NOT NULL
+		), "grid" AS (
+			SELECT "cell"."geom", "cell"."i", "cell"."j"
+			FROM "bounds", %s("bounds"."size", "bounds"."geom") AS "cell"
+		), "bucket" AS (
+			SELECT
+				"station_id",
+				"bikes_available",
+				"ebikes_available",
+				"docks_available",
+				COALESCE("bikes_available", 0) + COALESCE("ebikes_available", 0) AS "vehicles",
+				CASE WHEN LAG("time_bucket") OVER "w" = "time_bucket" - '5 minutes'::INTERVAL
+					THEN LAG(COALESCE("bikes_available", 0) + COALESCE("ebikes_available", 0)) OVER "w"
+				END AS "previous_vehicles"
+			FROM "public"."historical_station_availability"
+			WHERE "time_bucket" BETWEEN $2 AND $3
+			WINDOW "w" AS (PARTITION BY "station_id" ORDER BY "time_bucket")
+		), "station_value" AS (
+			SELECT
+				"station_id",
+				%s AS "value"
+			FROM "bucket"
+			GROUP BY "station_id"
+		), "station_cell" AS (
+			-- A station on the edge between cells intersects each of them,
+			-- it is only counted in the first
+			SELECT DISTINCT ON ("station"."id")
+				"station"."id" AS "station_id",
+				"grid"."geom"
+			FROM "grid"
+			JOIN "public"."station" ON ST_Intersects("grid"."geom", ST_Transform("station"."location", 3857))
+			ORDER BY "station"."id", "grid"."i", "grid"."j"
+		)
+		SELECT
+			ST_AsGeoJSON(ST_Transform("station_cell"."geom", 4326)),
+			COALESCE(SUM("station_value"."value"), 0),
+			COUNT(*)
+		FROM "station_cell"
+		JOIN "station_value" ON "station_value"."station_id" = "station_cell"."station_id"
+		GROUP BY "station_cell"."geom"`,
+		gridFunction,
+		metricExpression,
+	),
+		query.CellSize,
+		query.From,
+		query.To,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query heatmap: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.HeatmapCell{}
+
+	for rows.Next() {
+		var geometry string
+		cell := store.HeatmapCell{}
+
+		if err := rows.Scan(&geometry, &cell.Value, &cell.Stations); err != nil {
+			return nil, fmt.Errorf("failed to query heatmap: %w", err)
+		}
+
+		cell.Geometry = []byte(geometry)
+		result = append(result, cell)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query heatmap: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r availability) EbikeDepletion(ctx context.Context, from time.Time, to time.Time) ([]store.EbikeDepletion, error) {
+	// Only consecutive buckets are compared, a gap in syncing would otherwise
+	// count every ebike which left meanwhile. 12 buckets of 5 minutes make an
+	// hour.
+	rows, err := r.db.Query(ctx, `
+		WITH "bucket" AS (
+			SELECT
+				"station_id",
+				"time_bucket",
+				"ebikes_available",
+				CASE WHEN LAG("time_bucket") OVER "w" = "time_bucket" - '5 minutes'::INTERVAL
+					THEN LAG("ebikes_available") OVER "w"
+				END AS "previous"
+			FROM "public"."historical_station_availability"
+			WHERE "time_bucket" BETWEEN $1 AND $2 AND "ebikes_available" IS NOT NULL
+			WINDOW "w" AS (PARTITION BY "station_id" ORDER BY "time_bucket")
+		)
+		SELECT
+			h."electric_bike_surcharge_waiver",
+			COUNT(DISTINCT b."station_id"),
+			AVG(b."ebikes_available"),
+			COALESCE(SUM(GREATEST(b."previous" - b."ebikes_available", 0)) * 12 / NULLIF(COUNT(b."previous"), 0), 0)
+		FROM "bucket" b
+		JOIN "public"."station_history" h ON
+			h."station_id" = b."station_id"
+			AND h."valid_from" <= b."time_bucket"
+			AND (h."valid_to" IS NULL OR b."time_bucket" < h."valid_to")
+		GROUP BY h."electric_bike_surcharge_waiver"
+		ORDER BY h."electric_bike_surcharge_waiver"`,
+		from,
+		to,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ebike depletion: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.EbikeDepletion{}
+
+	for rows.Next() {
+		depletion := store.EbikeDepletion{}
+
+		if err := rows.Scan(&depletion.Waiver, &depletion.Stations, &depletion.EbikesAvailable, &depletion.Departures); err != nil {
+			return nil, fmt.Errorf("failed to query ebike depletion: %w", err)
+		}
+
+		result = append(result, depletion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query ebike depletion: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+const geofencingZoneColumns = `
+	"name",
+	"start",
+	"end",
+	"rules"::TEXT,
+	ST_AsGeoJSON("geometry")`
+
+type geofencingZones struct {
+	db querier
+}
+
+// scanGeofencingZone reads the geofencingZoneColumns of row
+func scanGeofencingZone(row pgx.Row) (store.GeofencingZone, error) {
+	zone := store.GeofencingZone{}
+	var rules, geometry string
+
+	if err := row.Scan(&zone.Name, &zone.Start, &zone.End, &rules, &geometry); err != nil {
+		return zone, err
+	}
+
+	if err := json.Unmarshal([]byte(rules), &zone.Rules); err != nil {
+		return zone, fmt.Errorf("failed to decode rules of geofencing zone: %w", err)
+	}
+
+	zone.Geometry = json.RawMessage(geometry)
+	return zone, nil
+}
+
+func (r geofencingZones) Replace(ctx context.Context, zones []store.GeofencingZone) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM "public"."geofencing_zone"`)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete geofencing zones: %w", err)
+	}
+
+	for position, zone := range zones {
+		rules, err := json.Marshal(nonNil(zone.Rules))
+
+		if err != nil {
+			return err
+		}
+
+		_, err = r.db.Exec(
+			ctx,
+			`INSERT INTO "public"."geofencing_zone" ("position", "name", "start", "end", "rules", "geometry")
+			VALUES ($1, $2, $3, $4, $5, ST_Multi(ST_SetSRID(ST_GeomFromGeoJSON($6), 4326)))`,
+			position,
+			zone.Name,
+			zone.Start,
+			zone.End,
+			string(rules),
+			string(zone.Geometry),
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to insert geofencing zone: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// query lists the zones matching a condition on the zone "z"
+func (r geofencingZones) query(ctx context.Context, condition string, args ...any) ([]store.GeofencingZone, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+geofencingZoneColumns+`
+		FROM "public"."geofencing_zone" z
+		WHERE `+condition+`
+		ORDER BY "position"`,
+		args...,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query geofencing zones: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.GeofencingZone{}
+
+	for rows.Next() {
+		zone, err := scanGeofencingZone(rows)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query geofencing zones: %w", err)
+		}
+
+		result = append(result, zone)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query geofencing zones: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r geofencingZones) List(ctx context.Context) ([]store.GeofencingZone, error) {
+	return r.query(ctx, `TRUE`)
+}
+
+func (r geofencingZones) At(ctx context.Context, lon float64, lat float64) ([]store.GeofencingZone, error) {
+	return r.query(ctx, `ST_Intersects(z."geometry", ST_SetSRID(ST_MakePoint($1, $2), 4326))`, lon, lat)
+}
+
+func (r geofencingZones) Tile(ctx context.Context, z int, x int, y int) ([]byte, error) {
+	var tile []byte
+
+	// Rules are nested, so they are kept as JSON text in the tile
+	err := r.db.QueryRow(ctx, `
+		WITH "feature" AS (
+			SELECT
+				"position",
+				"name",
+				EXTRACT(EPOCH FROM "start")::BIGINT AS "start",
+				EXTRACT(EPOCH FROM "end")::BIGINT AS "end",
+				"rules"::TEXT AS "rules",
+				ST_AsMVTGeom(ST_Transform("geometry", 3857), ST_TileEnvelope($1, $2, $3)) AS "geom"
+			FROM "public"."geofencing_zone"
+			WHERE ST_Intersects("geometry", ST_Transform(ST_TileEnvelope($1, $2, $3), 4326))
+		)
+		SELECT COALESCE(ST_AsMVT("feature".*, 'geofencing_zones', 4096, 'geom'), '')
+		FROM "feature"`,
+		z,
+		x,
+		y,
+	).Scan(&tile)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to render geofencing zone tile: %w", err)
+	}
+
+	return tile, nil
+}
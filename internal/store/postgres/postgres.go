@@ -0,0 +1,121 @@
+// Package postgres implements store.Store on TimescaleDB and PostGIS
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+// querier is implemented by both the pool and transactions
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type Store struct {
+	pool *pgxpool.Pool
+	db   querier
+	inTx bool
+}
+
+func New(pool *pgxpool.Pool) *Store {
+	return &Store{pool: pool, db: pool}
+}
+
+func (s *Store) Stations() store.StationRepository {
+	return stations{s.db}
+}
+
+func (s *Store) Availability() store.AvailabilityRepository {
+	return availability{s.db}
+}
+
+func (s *Store) Quality() store.QualityRepository {
+	return quality{s.db}
+}
+
+func (s *Store) SyncState() store.SyncStateRepository {
+	return syncState{s.db}
+}
+
+func (s *Store) Retention() store.RetentionRepository {
+	return retention{s}
+}
+
+func (s *Store) Regions() store.RegionRepository {
+	return regions{s.db}
+}
+
+func (s *Store) Alerts() store.AlertRepository {
+	return alerts{s.db}
+}
+
+func (s *Store) VehicleTypes() store.VehicleTypeRepository {
+	return vehicleTypes{s.db}
+}
+
+func (s *Store) PricingPlans() store.PricingPlanRepository {
+	return pricingPlans{s.db}
+}
+
+func (s *Store) GeofencingZones() store.GeofencingZoneRepository {
+	return geofencingZones{s.db}
+}
+
+func (s *Store) InTx(ctx context.Context, fn func(tx store.Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = fn(&Store{pool: s.pool, db: tx, inTx: true})
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+func (s *Store) SchemaVersion(ctx context.Context) (int32, error) {
+	var version int32
+	err := s.db.QueryRow(ctx, `SELECT "version" FROM "public"."schema_migrations"`).Scan(&version)
+
+	// The table is only created by the first migration run
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/dbtest"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/storetest"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) (store.Store, func(ctx context.Context) error) {
+		pool := dbtest.New(t)
+
+		materialize := func(ctx context.Context) error {
+			_, err := pool.Exec(ctx, `CALL REFRESH_CONTINUOUS_AGGREGATE('public.historical_station_availability', NULL, NULL)`)
+
+			if err != nil {
+				return err
+			}
+
+			_, err = pool.Exec(ctx, `CALL REFRESH_CONTINUOUS_AGGREGATE('public.historical_station_vehicle_availability', NULL, NULL)`)
+			return err
+		}
+
+		return New(pool), materialize
+	})
+}
+
+func TestRetention(t *testing.T) {
+	st := New(dbtest.New(t))
+	ctx := context.Background()
+
+	policies := []store.RetentionPolicy{
+		{Relation: "live_station_availability", DropAfter: 7 * 24 * time.Hour, CompressAfter: 24 * time.Hour},
+		{Relation: "historical_station_availability", CompressAfter: 7 * 24 * time.Hour},
+		{Relation: "station_flow"},
+	}
+
+	// Applying twice must leave the policies untouched
+	for range 2 {
+		if err := st.Retention().Apply(ctx, policies); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+
+	statuses, err := st.Retention().List(ctx)
+
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	got := map[string]store.RetentionPolicy{}
+
+	for _, status := range statuses {
+		got[status.Relation] = status.RetentionPolicy
+	}
+
+	for _, policy := range policies {
+		if got[policy.Relation] != policy {
+			t.Errorf("List() %s = %+v, want %+v", policy.Relation, got[policy.Relation], policy)
+		}
+	}
+
+	if err := st.Retention().Apply(ctx, []store.RetentionPolicy{{Relation: "station"}}); err == nil {
+		t.Error("Apply() on a plain table succeeded, want error")
+	}
+}
@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+const pricingPlanColumns = `
+	"plan_id",
+	"valid_from",
+	"valid_to",
+	"name",
+	"currency",
+	"price",
+	"is_taxable",
+	"description",
+	"url",
+	"per_km_pricing"::TEXT,
+	"per_min_pricing"::TEXT`
+
+type pricingPlans struct {
+	db querier
+}
+
+// scanPricingPlan reads the pricingPlanColumns of row
+func scanPricingPlan(row pgx.Row) (store.PricingPlan, error) {
+	plan := store.PricingPlan{}
+	var perKm, perMin string
+
+	err := row.Scan(
+		&plan.PlanID,
+		&plan.ValidFrom,
+		&plan.ValidTo,
+		&plan.Name,
+		&plan.Currency,
+		&plan.Price,
+		&plan.IsTaxable,
+		&plan.Description,
+		&plan.URL,
+		&perKm,
+		&perMin,
+	)
+
+	if err != nil {
+		return plan, err
+	}
+
+	if err := json.Unmarshal([]byte(perKm), &plan.PerKmPricing); err != nil {
+		return plan, fmt.Errorf("failed to decode per_km_pricing of plan %s: %w", plan.PlanID, err)
+	}
+
+	if err := json.Unmarshal([]byte(perMin), &plan.PerMinPricing); err != nil {
+		return plan, fmt.Errorf("failed to decode per_min_pricing of plan %s: %w", plan.PlanID, err)
+	}
+
+	return plan, nil
+}
+
+func (r pricingPlans) Upsert(ctx context.Context, plan store.PricingPlan, at time.Time) error {
+	latest, err := scanPricingPlan(r.db.QueryRow(
+		ctx,
+		`SELECT `+pricingPlanColumns+`
+		FROM "public"."pricing_plan"
+		WHERE "plan_id" = $1
+		ORDER BY "valid_from" DESC
+		LIMIT 1`,
+		plan.PlanID,
+	))
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to query pricing plan: %w", err)
+	}
+
+	if err == nil {
+		if at.Before(latest.ValidFrom) || (latest.ValidTo != nil && at.Before(*latest.ValidTo)) {
+			return nil
+		}
+
+		if latest.ValidTo == nil && latest.Describes(plan) {
+			return nil
+		}
+	}
+
+	perKm, err := json.Marshal(nonNil(plan.PerKmPricing))
+
+	if err != nil {
+		return err
+	}
+
+	perMin, err := json.Marshal(nonNil(plan.PerMinPricing))
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		ctx,
+		`UPDATE "public"."pricing_plan" SET "valid_to" = $2 WHERE "plan_id" = $1 AND "valid_to" IS NULL`,
+		plan.PlanID,
+		at,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to end pricing plan version: %w", err)
+	}
+
+	// A version replaced as soon as it started is overwritten
+	_, err = r.db.Exec(
+		ctx,
+		`INSERT INTO "public"."pricing_plan" (
+			"plan_id",
+			"valid_from",
+			"name",
+			"currency",
+			"price",
+			"is_taxable",
+			"description",
+			"url",
+			"per_km_pricing",
+			"per_min_pricing"
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT ("plan_id", "valid_from") DO UPDATE SET
+			"valid_to" = NULL,
+			"name" = excluded."name",
+			"currency" = excluded."currency",
+			"price" = excluded."price",
+			"is_taxable" = excluded."is_taxable",
+			"description" = excluded."description",
+			"url" = excluded."url",
+			"per_km_pricing" = excluded."per_km_pricing",
+			"per_min_pricing" = excluded."per_min_pricing"`,
+		plan.PlanID,
+		at,
+		plan.Name,
+		plan.Currency,
+		plan.Price,
+		plan.IsTaxable,
+		plan.Description,
+		plan.URL,
+		string(perKm),
+		string(perMin),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert pricing plan version: %w", err)
+	}
+
+	return nil
+}
+
+func (r pricingPlans) Withdraw(ctx context.Context, listed []string, at time.Time) (int64, error) {
+	tag, err := r.db.Exec(
+		ctx,
+		`UPDATE "public"."pricing_plan" SET "valid_to" = $2
+		WHERE "valid_to" IS NULL AND "valid_from" <= $2 AND NOT ("plan_id" = ANY($1))`,
+		listed,
+		at,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to withdraw pricing plans: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (r pricingPlans) List(ctx context.Context, from time.Time, to time.Time) ([]store.PricingPlan, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+pricingPlanColumns+`
+		FROM "public"."pricing_plan"
+		WHERE "valid_from" <= $2 AND ("valid_to" IS NULL OR "valid_to" > $1)
+		ORDER BY "plan_id", "valid_from"`,
+		from,
+		to,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pricing plans: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.PricingPlan{}
+
+	for rows.Next() {
+		plan, err := scanPricingPlan(rows)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pricing plans: %w", err)
+		}
+
+		result = append(result, plan)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query pricing plans: %w", err)
+	}
+
+	return result, nil
+}
+
+// nonNil returns values, or an empty slice if nil
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+
+	return values
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type quality struct {
+	db querier
+}
+
+func (r quality) Record(ctx context.Context, issue store.QualityIssue) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO "public"."data_quality_issue" (
+			"time",
+			"feed",
+			"snapshot_updated",
+			"station_external_id",
+			"rule",
+			"message",
+			"quarantined",
+			"record"
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		issue.Time,
+		issue.Feed,
+		issue.SnapshotUpdated,
+		issue.StationExternalID,
+		issue.Rule,
+		issue.Message,
+		issue.Quarantined,
+		string(issue.Record),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to record data quality issue: %w", err)
+	}
+
+	return nil
+}
+
+func (r quality) List(ctx context.Context, filter store.QualityFilter) ([]store.QualityIssue, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			"time",
+			"feed",
+			"snapshot_updated",
+			"station_external_id",
+			"rule",
+			"message",
+			"quarantined",
+			"record"
+		FROM "public"."data_quality_issue"
+		WHERE
+			"time" >= $1
+			AND ($2 = '' OR "station_external_id" = $2)
+			AND ($3 = '' OR "rule" = $3)
+		ORDER BY "time" DESC
+		LIMIT $4`,
+		filter.Since,
+		filter.StationID,
+		filter.Rule,
+		filter.Limit,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query data quality issues: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.QualityIssue{}
+
+	for rows.Next() {
+		issue := store.QualityIssue{}
+		err := rows.Scan(
+			&issue.Time,
+			&issue.Feed,
+			&issue.SnapshotUpdated,
+			&issue.StationExternalID,
+			&issue.Rule,
+			&issue.Message,
+			&issue.Quarantined,
+			&issue.Record,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query data quality issues: %w", err)
+		}
+
+		result = append(result, issue)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query data quality issues: %w", err)
+	}
+
+	return result, nil
+}
+
+type syncState struct {
+	db querier
+}
+
+func (r syncState) Record(ctx context.Context, feed string, lastUpdated time.Time, syncedAt time.Time) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO "public"."sync_state" ("feed", "last_success", "last_updated")
+		VALUES ($1, $3, $2)
+		ON CONFLICT ("feed") DO UPDATE SET
+			"last_success" = excluded."last_success",
+			"last_updated" = excluded."last_updated"`,
+		feed,
+		lastUpdated,
+		syncedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to record sync state: %w", err)
+	}
+
+	return nil
+}
+
+func (r syncState) Get(ctx context.Context, feed string) (*store.SyncState, error) {
+	state := store.SyncState{Feed: feed}
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT "last_success", "last_updated" FROM "public"."sync_state" WHERE "feed" = $1`,
+		feed,
+	).Scan(&state.LastSuccess, &state.LastUpdated)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+// regionStations joins the stations within region "r": those referencing it
+// by region_id, or located within its geometry
+const regionStations = `JOIN "public"."station" s ON s."region_id" = r."external_id" OR ST_Contains(r."geometry", s."location")`
+
+type regions struct {
+	db querier
+}
+
+func (r regions) Upsert(ctx context.Context, externalID string, name string) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO "public"."region" ("external_id", "name") VALUES ($1, $2)
+		ON CONFLICT ("external_id") DO UPDATE SET "name" = excluded."name"`,
+		externalID,
+		name,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert region: %w", err)
+	}
+
+	return nil
+}
+
+func (r regions) Create(ctx context.Context, name string, geometry []byte) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(
+		ctx,
+		`INSERT INTO "public"."region" ("name", "geometry")
+		VALUES ($1, ST_Multi(ST_SetSRID(ST_GeomFromGeoJSON($2), 4326)))
+		RETURNING "id"`,
+		name,
+		string(geometry),
+	).Scan(&id)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert region: %w", err)
+	}
+
+	return id, nil
+}
+
+func (r regions) Delete(ctx context.Context, id int64) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM "public"."region" WHERE "id" = $1 AND "external_id" IS NULL`, id)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete region: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r regions) List(ctx context.Context) ([]store.Region, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			r."id",
+			r."external_id",
+			r."name",
+			COUNT(s."id") FILTER (
+				WHERE EXISTS (SELECT 1 FROM "public"."station_history" h WHERE h."station_id" = s."id" AND h."valid_to" IS NULL)
+			)
+		FROM "public"."region" r
+		LEFT `+regionStations+`
+		GROUP BY r."id"
+		ORDER BY r."id"`,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query regions: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.Region{}
+
+	for rows.Next() {
+		region := store.Region{}
+
+		if err := rows.Scan(&region.ID, &region.ExternalID, &region.Name, &region.Stations); err != nil {
+			return nil, fmt.Errorf("failed to query regions: %w", err)
+		}
+
+		result = append(result, region)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query regions: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r regions) Availability(ctx context.Context, id int64, from time.Time, to time.Time, bucket time.Duration) ([]store.RegionAvailability, error) {
+	err := r.db.QueryRow(ctx, `SELECT "id" FROM "public"."region" WHERE "id" = $1`, id).Scan(&id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query region: %w", err)
+	}
+
+	// Each station's availability is averaged within the bucket, then summed
+	rows, err := r.db.Query(ctx, `
+		WITH "station_bucket" AS (
+			SELECT
+				TIME_BUCKET(MAKE_INTERVAL(secs => $4), a."time_bucket") AS "bucket",
+				AVG(a."bikes_available") AS "bikes_available",
+				COALESCE(AVG(a."ebikes_available"), 0) AS "ebikes_available",
+				AVG(a."docks_available") AS "docks_available"
+			FROM "public"."region" r
+			`+regionStations+`
+			JOIN "public"."historical_station_availability" a ON a."station_id" = s."id"
+			WHERE
+				r."id" = $1
+				AND a."time_bucket" BETWEEN $2 AND $3
+			GROUP BY "bucket", s."id"
+		)
+		SELECT "bucket", COUNT(*), SUM("bikes_available"), SUM("ebikes_available"), SUM("docks_available")
+		FROM "station_bucket"
+		GROUP BY "bucket"
+		ORDER BY "bucket"`,
+		id,
+		from,
+		to,
+		bucket.Seconds(),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query region availability: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.RegionAvailability{}
+
+	for rows.Next() {
+		a := store.RegionAvailability{}
+
+		if err := rows.Scan(&a.Time, &a.Stations, &a.BikesAvailable, &a.EbikesAvailable, &a.DocksAvailable); err != nil {
+			return nil, fmt.Errorf("failed to query region availability: %w", err)
+		}
+
+		result = append(result, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query region availability: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+// retentionLockKey serializes processes applying retention policies on
+// startup, which would otherwise add the same policy twice
+const retentionLockKey = int64(0x72657465) // "rete"
+
+// enableCompression enables compression on the relations which accept
+// retention policies, segmented by station so that each station's history
+// stays cheap to read
+var enableCompression = map[string]string{
+	"live_station_availability": `ALTER TABLE "public"."live_station_availability" SET (
+		timescaledb.compress,
+		timescaledb.compress_segmentby = 'station_id',
+		timescaledb.compress_orderby = 'time DESC'
+	)`,
+	"historical_station_availability": `ALTER MATERIALIZED VIEW "public"."historical_station_availability" SET (
+		timescaledb.compress = true
+	)`,
+	"live_station_vehicle_availability": `ALTER TABLE "public"."live_station_vehicle_availability" SET (
+		timescaledb.compress,
+		timescaledb.compress_segmentby = 'station_id',
+		timescaledb.compress_orderby = 'time DESC'
+	)`,
+	"historical_station_vehicle_availability": `ALTER MATERIALIZED VIEW "public"."historical_station_vehicle_availability" SET (
+		timescaledb.compress = true
+	)`,
+	"station_flow": `ALTER TABLE "public"."station_flow" SET (
+		timescaledb.compress,
+		timescaledb.compress_segmentby = 'station_id',
+		timescaledb.compress_orderby = 'time DESC'
+	)`,
+}
+
+type retention struct {
+	s *Store
+}
+
+func (r retention) Apply(ctx context.Context, policies []store.RetentionPolicy) error {
+	return r.s.InTx(ctx, func(tx store.Store) error {
+		db := tx.(*Store).db
+		_, err := db.Exec(ctx, `SELECT PG_ADVISORY_XACT_LOCK($1)`, retentionLockKey)
+
+		if err != nil {
+			return fmt.Errorf("failed to acquire retention lock: %w", err)
+		}
+
+		statuses, err := retention{tx.(*Store)}.List(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		current := map[string]store.RetentionStatus{}
+
+		for _, status := range statuses {
+			current[status.Relation] = status
+		}
+
+		for _, policy := range policies {
+			enable, ok := enableCompression[policy.Relation]
+			status, found := current[policy.Relation]
+
+			if !ok || !found {
+				return fmt.Errorf("no retention policy applies to %q", policy.Relation)
+			}
+
+			relation := "public." + policy.Relation
+
+			if policy.CompressAfter != status.CompressAfter {
+				if policy.CompressAfter > 0 && !status.CompressionEnabled {
+					if _, err := db.Exec(ctx, enable); err != nil {
+						return fmt.Errorf("failed to enable compression on %s: %w", policy.Relation, err)
+					}
+				}
+
+				err := replacePolicy(ctx, db, "COMPRESSION", "COMPRESS_AFTER", relation, policy.CompressAfter)
+
+				if err != nil {
+					return fmt.Errorf("failed to set compression policy of %s: %w", policy.Relation, err)
+				}
+			}
+
+			if policy.DropAfter != status.DropAfter {
+				err := replacePolicy(ctx, db, "RETENTION", "DROP_AFTER", relation, policy.DropAfter)
+
+				if err != nil {
+					return fmt.Errorf("failed to set retention policy of %s: %w", policy.Relation, err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// replacePolicy removes the compression or retention policy of relation, then
+// adds one running after the given duration unless it is 0
+func replacePolicy(ctx context.Context, db querier, kind string, parameter string, relation string, after time.Duration) error {
+	_, err := db.Exec(ctx, `SELECT REMOVE_`+kind+`_POLICY($1::REGCLASS, IF_EXISTS => TRUE)`, relation)
+
+	if err != nil || after == 0 {
+		return err
+	}
+
+	_, err = db.Exec(ctx, `SELECT ADD_`+kind+`_POLICY($1::REGCLASS, `+parameter+` => MAKE_INTERVAL(SECS => $2))`, relation, after.Seconds())
+	return err
+}
+
+func (r retention) List(ctx context.Context) ([]store.RetentionStatus, error) {
+	// Policies and chunks of a continuous aggregate belong to its
+	// materialization hypertable
+	rows, err := r.s.db.Query(ctx, `
+		WITH "relation" AS (
+			SELECT
+				"hypertable_schema" AS "schema",
+				"hypertable_name" AS "name",
+				"hypertable_name" AS "relation",
+				"compression_enabled"
+			FROM "timescaledb_information"."hypertables"
+			WHERE "hypertable_schema" = 'public'
+			UNION ALL
+			SELECT
+				"materialization_hypertable_schema",
+				"materialization_hypertable_name",
+				"view_name",
+				"compression_enabled"
+			FROM "timescaledb_information"."continuous_aggregates"
+			WHERE "view_schema" = 'public'
+		)
+		SELECT
+			r."relation",
+			(
+				SELECT EXTRACT(EPOCH FROM ("config"->>'drop_after')::INTERVAL)::DOUBLE PRECISION
+				FROM "timescaledb_information"."jobs" j
+				WHERE j."proc_name" = 'policy_retention' AND j."hypertable_schema" = r."schema" AND j."hypertable_name" = r."name"
+			),
+			(
+				SELECT EXTRACT(EPOCH FROM ("config"->>'compress_after')::INTERVAL)::DOUBLE PRECISION
+				FROM "timescaledb_information"."jobs" j
+				WHERE j."proc_name" = 'policy_compression' AND j."hypertable_schema" = r."schema" AND j."hypertable_name" = r."name"
+			),
+			r."compression_enabled",
+			COUNT(c."chunk_name"),
+			COUNT(c."chunk_name") FILTER (WHERE c."is_compressed"),
+			MIN(c."range_start")
+		FROM "relation" r
+		LEFT JOIN "timescaledb_information"."chunks" c ON c."hypertable_schema" = r."schema" AND c."hypertable_name" = r."name"
+		GROUP BY r."schema", r."name", r."relation", r."compression_enabled"
+		ORDER BY r."relation"`,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention policies: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.RetentionStatus{}
+
+	for rows.Next() {
+		status := store.RetentionStatus{}
+		var dropAfter, compressAfter *float64
+
+		err := rows.Scan(&status.Relation, &dropAfter, &compressAfter, &status.CompressionEnabled, &status.Chunks, &status.CompressedChunks, &status.Oldest)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query retention policies: %w", err)
+		}
+
+		status.DropAfter = seconds(dropAfter)
+		status.CompressAfter = seconds(compressAfter)
+		result = append(result, status)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query retention policies: %w", err)
+	}
+
+	return result, nil
+}
+
+// seconds converts an optional number of seconds, 0 if nil
+func seconds(value *float64) time.Duration {
+	if value == nil {
+		return 0
+	}
+
+	return time.Duration(*value * float64(time.Second))
+}
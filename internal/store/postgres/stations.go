@@ -0,0 +1,356 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type stations struct {
+	db querier
+}
+
+func (r stations) FindOrCreate(ctx context.Context, externalID string) (*store.KnownStation, error) {
+	known := store.KnownStation{}
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT "id", "last_status_reported", "name" IS NOT NULL, "capacity" FROM "public"."station" WHERE "external_id" = $1`,
+		externalID,
+	).Scan(&known.ID, &known.LastStatusReported, &known.Listed, &known.Capacity)
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to query station: %w", err)
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = r.db.QueryRow(
+			ctx,
+			`INSERT INTO "public"."station" ("external_id") VALUES ($1) RETURNING "id"`,
+			externalID,
+		).Scan(&known.ID)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert station: %w", err)
+		}
+	}
+
+	return &known, nil
+}
+
+func (r stations) Upsert(ctx context.Context, station store.StationInformation, at time.Time) error {
+	var id int64
+	err := r.db.QueryRow(
+		ctx,
+		`INSERT INTO "public"."station" ("external_id") VALUES ($1)
+		ON CONFLICT ("external_id") DO UPDATE SET "external_id" = excluded."external_id"
+		RETURNING "id"`,
+		station.ExternalID,
+	).Scan(&id)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert station: %w", err)
+	}
+
+	versions, err := r.History(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	unchanged := false
+
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+
+		if at.Before(latest.ValidFrom) || (latest.ValidTo != nil && at.Before(*latest.ValidTo)) {
+			return nil
+		}
+
+		unchanged = latest.ValidTo == nil && latest.Describes(station)
+	}
+
+	location := fmt.Sprintf("POINT(%f %f)", station.Lon, station.Lat)
+	metadata := station.Metadata
+	rentalMethods := metadata.RentalMethods
+
+	if rentalMethods == nil {
+		rentalMethods = []string{}
+	}
+
+	_, err = r.db.Exec(
+		ctx,
+		`UPDATE "public"."station" SET
+			"name" = $2,
+			"location" = $3,
+			"capacity" = $4,
+			"short_name" = $5,
+			"address" = $6,
+			"cross_street" = $7,
+			"region_id" = $8,
+			"post_code" = $9,
+			"rental_methods" = $10,
+			"has_kiosk" = $11,
+			"electric_bike_surcharge_waiver" = $12,
+			"is_charging" = $13
+		WHERE "id" = $1`,
+		id,
+		station.Name,
+		location,
+		station.Capacity,
+		station.ShortName,
+		metadata.Address,
+		metadata.CrossStreet,
+		metadata.RegionID,
+		metadata.PostCode,
+		rentalMethods,
+		metadata.HasKiosk,
+		metadata.ElectricBikeSurchargeWaiver,
+		metadata.IsCharging,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert station: %w", err)
+	}
+
+	if unchanged {
+		return nil
+	}
+
+	_, err = r.db.Exec(
+		ctx,
+		`UPDATE "public"."station_history" SET "valid_to" = $2 WHERE "station_id" = $1 AND "valid_to" IS NULL`,
+		id,
+		at,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to end station version: %w", err)
+	}
+
+	// A version replaced as soon as it started is overwritten
+	_, err = r.db.Exec(
+		ctx,
+		`INSERT INTO "public"."station_history" (
+			"station_id",
+			"valid_from",
+			"name",
+			"short_name",
+			"location",
+			"capacity",
+			"electric_bike_surcharge_waiver"
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT ("station_id", "valid_from") DO UPDATE SET
+			"valid_to" = NULL,
+			"name" = excluded."name",
+			"short_name" = excluded."short_name",
+			"location" = excluded."location",
+			"capacity" = excluded."capacity",
+			"electric_bike_surcharge_waiver" = excluded."electric_bike_surcharge_waiver"`,
+		id,
+		at,
+		station.Name,
+		station.ShortName,
+		location,
+		station.Capacity,
+		metadata.ElectricBikeSurchargeWaiver,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert station version: %w", err)
+	}
+
+	return nil
+}
+
+func (r stations) Decommission(ctx context.Context, listed []string, at time.Time) (int64, error) {
+	tag, err := r.db.Exec(
+		ctx,
+		`UPDATE "public"."station_history" SET "valid_to" = $2
+		FROM "public"."station"
+		WHERE
+			"station_history"."station_id" = "station"."id"
+			AND "station_history"."valid_to" IS NULL
+			AND "station_history"."valid_from" <= $2
+			AND NOT ("station"."external_id" = ANY($1))`,
+		listed,
+		at,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to decommission stations: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (r stations) History(ctx context.Context, id int64) ([]store.StationVersion, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			"valid_from",
+			"valid_to",
+			"name",
+			"short_name",
+			ST_X("location"),
+			ST_Y("location"),
+			"capacity",
+			"electric_bike_surcharge_waiver"
+		FROM "public"."station_history"
+		WHERE "station_id" = $1
+		ORDER BY "valid_from"`,
+		id,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station history: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.StationVersion{}
+
+	for rows.Next() {
+		version := store.StationVersion{}
+		err := rows.Scan(&version.ValidFrom, &version.ValidTo, &version.Name, &version.ShortName, &version.Lon, &version.Lat, &version.Capacity, &version.ElectricBikeSurchargeWaiver)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query station history: %w", err)
+		}
+
+		result = append(result, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query station history: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r stations) SetLastReported(ctx context.Context, id int64, reported time.Time) error {
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE "public"."station" SET "last_status_reported" = $2 WHERE "id" = $1`,
+		id,
+		reported,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update station: %w", err)
+	}
+
+	return nil
+}
+
+func (r stations) List(ctx context.Context, filter store.StationFilter) ([]store.StationSummary, error) {
+	rows, err := r.db.Query(ctx, `
+		WITH "station_status" AS (
+			SELECT
+				"station_id" AS "id",
+				MAX("time") > $1 AS "active"
+			FROM "public"."live_station_availability"
+			GROUP BY "station_id"
+		)
+		SELECT
+			"id",
+			"external_id",
+			"name",
+			"short_name",
+			ST_X("location"),
+			ST_Y("location"),
+			COALESCE("active", false),
+			EXISTS (SELECT 1 FROM "public"."station_history" h WHERE h."station_id" = "id")
+				AND NOT EXISTS (SELECT 1 FROM "public"."station_history" h WHERE h."station_id" = "id" AND h."valid_to" IS NULL),
+			`+metadataColumns+`
+		FROM "public"."station"
+		LEFT JOIN "station_status" USING ("id")
+		WHERE
+			"location" IS NOT NULL
+			AND ($2 = '' OR EXISTS (SELECT 1 FROM UNNEST("rental_methods") m WHERE UPPER(m) = UPPER($2)))
+			AND ($3::BOOLEAN IS NULL OR "has_kiosk" = $3)`,
+		filter.ActiveSince,
+		filter.RentalMethod,
+		filter.HasKiosk,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stations: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.StationSummary{}
+
+	for rows.Next() {
+		station := store.StationSummary{}
+		fields := append(
+			[]any{&station.ID, &station.ExternalID, &station.Name, &station.ShortName, &station.Lon, &station.Lat, &station.Active, &station.Decommissioned},
+			metadataFields(&station.Metadata)...,
+		)
+
+		if err := rows.Scan(fields...); err != nil {
+			return nil, fmt.Errorf("failed to query stations: %w", err)
+		}
+
+		result = append(result, station)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query stations: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r stations) Get(ctx context.Context, id int64) (*store.StationInformation, error) {
+	station := store.StationInformation{}
+	var name *string
+	var lon, lat *float64
+	fields := append(
+		[]any{&station.ExternalID, &name, &station.ShortName, &lon, &lat, &station.Capacity},
+		metadataFields(&station.Metadata)...,
+	)
+
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT "external_id", "name", "short_name", ST_X("location"), ST_Y("location"), "capacity", `+metadataColumns+`
+		FROM "public"."station"
+		WHERE "id" = $1`,
+		id,
+	).Scan(fields...)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station: %w", err)
+	}
+
+	if name != nil && lon != nil && lat != nil {
+		station.Name, station.Lon, station.Lat = *name, *lon, *lat
+	}
+
+	return &station, nil
+}
+
+// metadataColumns are the columns of store.StationMetadata, scanned by
+// metadataFields
+const metadataColumns = `"address", "cross_street", "region_id", "post_code", "rental_methods", "has_kiosk", "electric_bike_surcharge_waiver", "is_charging"`
+
+func metadataFields(metadata *store.StationMetadata) []any {
+	return []any{
+		&metadata.Address,
+		&metadata.CrossStreet,
+		&metadata.RegionID,
+		&metadata.PostCode,
+		&metadata.RentalMethods,
+		&metadata.HasKiosk,
+		&metadata.ElectricBikeSurchargeWaiver,
+		&metadata.IsCharging,
+	}
+}
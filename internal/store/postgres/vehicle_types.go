@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type vehicleTypes struct {
+	db querier
+}
+
+func (r vehicleTypes) FindOrCreate(ctx context.Context, externalID string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(
+		ctx,
+		`INSERT INTO "public"."vehicle_type" ("external_id") VALUES ($1)
+		ON CONFLICT ("external_id") DO UPDATE SET "external_id" = excluded."external_id"
+		RETURNING "id"`,
+		externalID,
+	).Scan(&id)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to getsert vehicle type: %w", err)
+	}
+
+	return id, nil
+}
+
+func (r vehicleTypes) Upsert(ctx context.Context, vehicleType store.VehicleType) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO "public"."vehicle_type" (
+			"external_id",
+			"form_factor",
+			"propulsion_type",
+			"name",
+			"max_range_meters"
+		) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT ("external_id") DO UPDATE SET
+			"form_factor" = excluded."form_factor",
+			"propulsion_type" = excluded."propulsion_type",
+			"name" = excluded."name",
+			"max_range_meters" = excluded."max_range_meters"`,
+		vehicleType.ExternalID,
+		vehicleType.FormFactor,
+		vehicleType.PropulsionType,
+		vehicleType.Name,
+		vehicleType.MaxRangeMeters,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert vehicle type: %w", err)
+	}
+
+	return nil
+}
+
+func (r vehicleTypes) List(ctx context.Context) ([]store.VehicleType, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			"id",
+			"external_id",
+			"form_factor",
+			"propulsion_type",
+			"name",
+			"max_range_meters"
+		FROM "public"."vehicle_type"
+		ORDER BY "id"`,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vehicle types: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.VehicleType{}
+
+	for rows.Next() {
+		vehicleType := store.VehicleType{}
+		err := rows.Scan(
+			&vehicleType.ID,
+			&vehicleType.ExternalID,
+			&vehicleType.FormFactor,
+			&vehicleType.PropulsionType,
+			&vehicleType.Name,
+			&vehicleType.MaxRangeMeters,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query vehicle types: %w", err)
+		}
+
+		result = append(result, vehicleType)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query vehicle types: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,182 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type alerts struct {
+	q querier
+}
+
+// jsonArray encodes a list, nil as an empty array
+func jsonArray[T any](values []T) (string, error) {
+	if values == nil {
+		values = []T{}
+	}
+
+	encoded, err := json.Marshal(values)
+	return string(encoded), err
+}
+
+func (r alerts) Upsert(ctx context.Context, alert store.Alert) error {
+	times, err := jsonArray(alert.Times)
+
+	if err != nil {
+		return err
+	}
+
+	stationIDs, err := jsonArray(alert.StationIDs)
+
+	if err != nil {
+		return err
+	}
+
+	regionIDs, err := jsonArray(alert.RegionIDs)
+
+	if err != nil {
+		return err
+	}
+
+	var lastUpdated *int64
+
+	if alert.LastUpdated != nil {
+		seconds := alert.LastUpdated.Unix()
+		lastUpdated = &seconds
+	}
+
+	_, err = r.q.ExecContext(
+		ctx,
+		`INSERT INTO "system_alert" (
+			"external_id",
+			"type",
+			"times",
+			"station_ids",
+			"region_ids",
+			"url",
+			"summary",
+			"description",
+			"last_updated"
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT ("external_id") DO UPDATE SET
+			"type" = excluded."type",
+			"times" = excluded."times",
+			"station_ids" = excluded."station_ids",
+			"region_ids" = excluded."region_ids",
+			"url" = excluded."url",
+			"summary" = excluded."summary",
+			"description" = excluded."description",
+			"last_updated" = excluded."last_updated",
+			"withdrawn" = NULL`,
+		alert.ExternalID,
+		alert.Type,
+		times,
+		stationIDs,
+		regionIDs,
+		alert.URL,
+		alert.Summary,
+		alert.Description,
+		lastUpdated,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert alert: %w", err)
+	}
+
+	return nil
+}
+
+func (r alerts) Withdraw(ctx context.Context, listed []string, at time.Time) (int64, error) {
+	ids, err := jsonArray(listed)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.q.ExecContext(
+		ctx,
+		`UPDATE "system_alert" SET "withdrawn" = ?
+		WHERE "withdrawn" IS NULL AND "external_id" NOT IN (SELECT "value" FROM JSON_EACH(?))`,
+		at.Unix(),
+		ids,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to withdraw alerts: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (r alerts) List(ctx context.Context, since time.Time) ([]store.Alert, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			"external_id",
+			"type",
+			"times",
+			"station_ids",
+			"region_ids",
+			"url",
+			"summary",
+			"description",
+			"last_updated",
+			"withdrawn"
+		FROM "system_alert"
+		WHERE "withdrawn" IS NULL OR "withdrawn" > ?
+		ORDER BY "external_id"`,
+		since.Unix(),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.Alert{}
+
+	for rows.Next() {
+		alert := store.Alert{}
+		var times, stationIDs, regionIDs string
+		var url, description sql.NullString
+		var lastUpdated, withdrawn sql.NullInt64
+
+		err := rows.Scan(&alert.ExternalID, &alert.Type, &times, &stationIDs, &regionIDs, &url, &alert.Summary, &description, &lastUpdated, &withdrawn)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query alerts: %w", err)
+		}
+
+		decode := []struct {
+			column string
+			value  any
+		}{
+			{times, &alert.Times},
+			{stationIDs, &alert.StationIDs},
+			{regionIDs, &alert.RegionIDs},
+		}
+
+		for _, d := range decode {
+			if err := json.Unmarshal([]byte(d.column), d.value); err != nil {
+				return nil, fmt.Errorf("failed to decode alert %s: %w", alert.ExternalID, err)
+			}
+		}
+
+		alert.URL = nullableString(url)
+		alert.Description = nullableString(description)
+		alert.LastUpdated = nullableTime(lastUpdated)
+		alert.Withdrawn = nullableTime(withdrawn)
+		result = append(result, alert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,503 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type availability struct {
+	q         querier
+	retention time.Duration
+	lastPrune *atomic.Int64
+}
+
+func (r availability) Insert(ctx context.Context, a store.Availability) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO "live_station_availability" (
+			"time",
+			"station_id",
+			"bikes_available",
+			"bikes_disabled",
+			"ebikes_available",
+			"ebikes_disabled",
+			"docks_available",
+			"docks_disabled"
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Time.Unix(),
+		a.StationID,
+		a.BikesAvailable,
+		a.BikesDisabled,
+		a.EbikesAvailable,
+		a.EbikesDisabled,
+		a.DocksAvailable,
+		a.DocksDisabled,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert station availability: %w", err)
+	}
+
+	ebikeSamples := 0
+
+	if a.EbikesAvailable != nil {
+		ebikeSamples = 1
+	}
+
+	_, err = r.q.ExecContext(
+		ctx,
+		`INSERT INTO "historical_station_availability" (
+			"time_bucket",
+			"station_id",
+			"samples",
+			"bikes_available",
+			"ebikes_samples",
+			"ebikes_available",
+			"docks_available"
+		) VALUES (?, ?, 1, ?, ?, COALESCE(?, 0), ?)
+		ON CONFLICT ("station_id", "time_bucket") DO UPDATE SET
+			"samples" = "samples" + 1,
+			"bikes_available" = "bikes_available" + excluded."bikes_available",
+			"ebikes_samples" = "ebikes_samples" + excluded."ebikes_samples",
+			"ebikes_available" = "ebikes_available" + excluded."ebikes_available",
+			"docks_available" = "docks_available" + excluded."docks_available"`,
+		a.Time.Truncate(bucketWidth).Unix(),
+		a.StationID,
+		a.BikesAvailable,
+		ebikeSamples,
+		a.EbikesAvailable,
+		a.DocksAvailable,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to aggregate station availability: %w", err)
+	}
+
+	for _, vehicles := range a.Vehicles {
+		_, err = r.q.ExecContext(
+			ctx,
+			`INSERT INTO "live_station_vehicle_availability" ("time", "station_id", "vehicle_type_id", "available") VALUES (?, ?, ?, ?)`,
+			a.Time.Unix(),
+			a.StationID,
+			vehicles.VehicleTypeID,
+			vehicles.Available,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to insert station vehicle availability: %w", err)
+		}
+
+		_, err = r.q.ExecContext(
+			ctx,
+			`INSERT INTO "historical_station_vehicle_availability" (
+				"time_bucket",
+				"station_id",
+				"vehicle_type_id",
+				"samples",
+				"available"
+			) VALUES (?, ?, ?, 1, ?)
+			ON CONFLICT ("station_id", "time_bucket", "vehicle_type_id") DO UPDATE SET
+				"samples" = "samples" + 1,
+				"available" = "available" + excluded."available"`,
+			a.Time.Truncate(bucketWidth).Unix(),
+			a.StationID,
+			vehicles.VehicleTypeID,
+			vehicles.Available,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to aggregate station vehicle availability: %w", err)
+		}
+	}
+
+	return r.prune(ctx, a.Time)
+}
+
+// prune drops raw availability past retention, which the aggregate keeps
+func (r availability) prune(ctx context.Context, now time.Time) error {
+	last := r.lastPrune.Load()
+
+	if r.retention == 0 || now.Unix()-last < int64(pruneInterval.Seconds()) || !r.lastPrune.CompareAndSwap(last, now.Unix()) {
+		return nil
+	}
+
+	for _, table := range []string{"live_station_availability", "live_station_vehicle_availability"} {
+		_, err := r.q.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %q WHERE "time" < ?`, table), now.Add(-r.retention).Unix())
+
+		if err != nil {
+			return fmt.Errorf("failed to prune %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+func (r availability) Latest(ctx context.Context, stationID int64) (*store.Availability, error) {
+	a := store.Availability{StationID: stationID}
+	var at int64
+	var bikesDisabled, ebikesAvailable, ebikesDisabled, docksDisabled sql.NullInt64
+
+	err := r.q.QueryRowContext(ctx, `
+		SELECT
+			"time",
+			"bikes_available",
+			"bikes_disabled",
+			"ebikes_available",
+			"ebikes_disabled",
+			"docks_available",
+			"docks_disabled"
+		FROM "live_station_availability"
+		WHERE "station_id" = ?
+		ORDER BY "time" DESC
+		LIMIT 1`,
+		stationID,
+	).Scan(&at, &a.BikesAvailable, &bikesDisabled, &ebikesAvailable, &ebikesDisabled, &a.DocksAvailable, &docksDisabled)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query current station availability: %w", err)
+	}
+
+	a.Time = time.Unix(at, 0)
+	a.BikesDisabled = nullableInt(bikesDisabled)
+	a.EbikesAvailable = nullableInt(ebikesAvailable)
+	a.EbikesDisabled = nullableInt(ebikesDisabled)
+	a.DocksDisabled = nullableInt(docksDisabled)
+
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT "vehicle_type_id", "available"
+		FROM "live_station_vehicle_availability"
+		WHERE "station_id" = ? AND "time" = ?
+		ORDER BY "vehicle_type_id"`,
+		stationID,
+		at,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query current station vehicle availability: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		vehicles := store.VehicleAvailability{}
+
+		if err := rows.Scan(&vehicles.VehicleTypeID, &vehicles.Available); err != nil {
+			return nil, fmt.Errorf("failed to query current station vehicle availability: %w", err)
+		}
+
+		a.Vehicles = append(a.Vehicles, vehicles)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query current station vehicle availability: %w", err)
+	}
+
+	return &a, nil
+}
+
+// History averages the 5 minute averages within each bucket, like the
+// TimescaleDB query does
+func (r availability) History(ctx context.Context, stationID int64, from time.Time, to time.Time, bucket time.Duration) ([]store.AverageAvailability, error) {
+	width := int64(bucket.Seconds())
+
+	if width < 1 {
+		return nil, fmt.Errorf("invalid bucket width %s", bucket)
+	}
+
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			"time_bucket" / ? * ? AS "bucket",
+			AVG(CAST("bikes_available" AS REAL) / "samples"),
+			COALESCE(AVG(CASE WHEN "ebikes_samples" > 0 THEN CAST("ebikes_available" AS REAL) / "ebikes_samples" END), 0)
+		FROM "historical_station_availability"
+		WHERE
+			"station_id" = ?
+			AND "time_bucket" BETWEEN ? AND ?
+		GROUP BY "bucket"
+		ORDER BY "bucket"`,
+		width,
+		width,
+		stationID,
+		from.Unix(),
+		to.Unix(),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station history: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.AverageAvailability{}
+
+	for rows.Next() {
+		var at int64
+		average := store.AverageAvailability{}
+
+		if err := rows.Scan(&at, &average.BikesAvailable, &average.EbikesAvailable); err != nil {
+			return nil, fmt.Errorf("failed to query station history: %w", err)
+		}
+
+		average.Time = time.Unix(at, 0)
+		result = append(result, average)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query station history: %w", err)
+	}
+
+	return result, r.vehicleHistory(ctx, result, stationID, from, to, width)
+}
+
+// vehicleHistory adds the availability of each vehicle type to the buckets
+// of History
+func (r availability) vehicleHistory(ctx context.Context, buckets []store.AverageAvailability, stationID int64, from time.Time, to time.Time, width int64) error {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			"time_bucket" / ? * ? AS "bucket",
+			"vehicle_type_id",
+			AVG(CAST("available" AS REAL) / "samples")
+		FROM "historical_station_vehicle_availability"
+		WHERE
+			"station_id" = ?
+			AND "time_bucket" BETWEEN ? AND ?
+		GROUP BY "bucket", "vehicle_type_id"
+		ORDER BY "bucket", "vehicle_type_id"`,
+		width,
+		width,
+		stationID,
+		from.Unix(),
+		to.Unix(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to query station vehicle history: %w", err)
+	}
+
+	defer rows.Close()
+
+	byTime := map[int64]*store.AverageAvailability{}
+
+	for i := range buckets {
+		byTime[buckets[i].Time.Unix()] = &buckets[i]
+	}
+
+	for rows.Next() {
+		var at int64
+		vehicles := store.AverageVehicleAvailability{}
+
+		if err := rows.Scan(&at, &vehicles.VehicleTypeID, &vehicles.Available); err != nil {
+			return fmt.Errorf("failed to query station vehicle history: %w", err)
+		}
+
+		if average, ok := byTime[at]; ok {
+			average.Vehicles = append(average.Vehicles, vehicles)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query station vehicle history: %w", err)
+	}
+
+	return nil
+}
+
+func (r availability) AggregateLag(ctx context.Context) (time.Time, time.Duration, error) {
+	var latest, live sql.NullInt64
+
+	err := r.q.QueryRowContext(ctx, `
+		SELECT
+			(SELECT MAX("time_bucket") FROM "historical_station_availability"),
+			(SELECT MAX("time") FROM "live_station_availability")`,
+	).Scan(&latest, &live)
+
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("failed to read aggregate state: %w", err)
+	}
+
+	if !latest.Valid || !live.Valid {
+		return time.Time{}, 0, store.ErrNotFound
+	}
+
+	return time.Unix(latest.Int64, 0), time.Duration(live.Int64-latest.Int64) * time.Second, nil
+}
+
+// Per-station aggregate over the requested window, keyed by
+// store.HeatmapMetrics, see the postgres store
+var heatmapMetrics = map[string]string{
+	"bikes":      `AVG("bikes_available")`,
+	"ebikes":     `AVG("ebikes_available")`,
+	"docks":      `AVG("docks_available")`,
+	"departures": `SUM(MAX("previous_vehicles" - "vehicles", 0))`,
+}
+
+// Heatmap only supports square cells, summed in Go without PostGIS
+func (r availability) Heatmap(ctx context.Context, query store.HeatmapQuery) ([]store.HeatmapCell, error) {
+	if query.Shape != "square" {
+		return nil, store.ErrUnsupported
+	}
+
+	metricExpression, ok := heatmapMetrics[query.Metric]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown heatmap metric %q", query.Metric)
+	}
+
+	var minLat, maxLat sql.NullFloat64
+	err := r.q.QueryRowContext(ctx, `SELECT MIN("lat"), MAX("lat") FROM "station" WHERE "lon" IS NOT NULL AND "lat" IS NOT NULL`).Scan(&minLat, &maxLat)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query heatmap: %w", err)
+	}
+
+	if !minLat.Valid {
+		return []store.HeatmapCell{}, nil
+	}
+
+	// The metric expression comes from the whitelist above, never from user
+	// input directly
+	rows, err := r.q.QueryContext(ctx, fmt.Sprintf(`
+		WITH "average" AS (
+			SELECT
+				"station_id",
+				"time_bucket",
+				CAST("bikes_available" AS REAL) / "samples" AS "bikes_available",
+				CASE WHEN "ebikes_samples" > 0 THEN CAST("ebikes_available" AS REAL) / "ebikes_samples" END AS "ebikes_available",
+				CAST("docks_available" AS REAL) / "samples" AS "docks_available"
+			FROM "historical_station_availability"
+			WHERE "time_bucket" BETWEEN ? AND ?
+		), "bucket" AS (
+			SELECT
+				*,
+				"bikes_available" + COALESCE("ebikes_available", 0) AS "vehicles",
+				CASE WHEN LAG("time_bucket") OVER "w" = "time_bucket" - 300
+					THEN LAG("bikes_available" + COALESCE("ebikes_available", 0)) OVER "w"
+				END AS "previous_vehicles"
+			FROM "average"
+			WINDOW "w" AS (PARTITION BY "station_id" ORDER BY "time_bucket")
+		)
+		SELECT s."lon", s."lat", COALESCE(%s, 0)
+		FROM "bucket" b
+		JOIN "station" s ON s."id" = b."station_id"
+		WHERE s."lon" IS NOT NULL AND s."lat" IS NOT NULL
+		GROUP BY b."station_id"`,
+		metricExpression,
+	),
+		query.From.Unix(),
+		query.To.Unix(),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query heatmap: %w", err)
+	}
+
+	defer rows.Close()
+
+	points := []store.HeatmapPoint{}
+
+	for rows.Next() {
+		point := store.HeatmapPoint{}
+
+		if err := rows.Scan(&point.Lon, &point.Lat, &point.Value); err != nil {
+			return nil, fmt.Errorf("failed to query heatmap: %w", err)
+		}
+
+		points = append(points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query heatmap: %w", err)
+	}
+
+	return store.SquareGrid(points, query.CellSize, (minLat.Float64+maxLat.Float64)/2), nil
+}
+
+// retentionPolicies reports the retention of the embedded database, whose
+// aggregate is kept forever
+type retentionPolicies struct {
+	retention time.Duration
+}
+
+func (retentionPolicies) Apply(ctx context.Context, policies []store.RetentionPolicy) error {
+	return store.ErrUnsupported
+}
+
+func (r retentionPolicies) List(ctx context.Context) ([]store.RetentionStatus, error) {
+	return []store.RetentionStatus{
+		{RetentionPolicy: store.RetentionPolicy{Relation: "historical_station_availability"}},
+		{RetentionPolicy: store.RetentionPolicy{Relation: "historical_station_vehicle_availability"}},
+		{RetentionPolicy: store.RetentionPolicy{Relation: "live_station_availability", DropAfter: r.retention}},
+		{RetentionPolicy: store.RetentionPolicy{Relation: "live_station_vehicle_availability", DropAfter: r.retention}},
+	}, nil
+}
+
+func (r availability) EbikeDepletion(ctx context.Context, from time.Time, to time.Time) ([]store.EbikeDepletion, error) {
+	// Only consecutive buckets are compared, see the postgres store. 12
+	// buckets of 5 minutes make an hour.
+	rows, err := r.q.QueryContext(ctx, `
+		WITH "average" AS (
+			SELECT
+				"station_id",
+				"time_bucket",
+				CAST("ebikes_available" AS REAL) / "ebikes_samples" AS "ebikes_available"
+			FROM "historical_station_availability"
+			WHERE "time_bucket" BETWEEN ? AND ? AND "ebikes_samples" > 0
+		), "bucket" AS (
+			SELECT
+				*,
+				CASE WHEN LAG("time_bucket") OVER "w" = "time_bucket" - 300
+					THEN LAG("ebikes_available") OVER "w"
+				END AS "previous"
+			FROM "average"
+			WINDOW "w" AS (PARTITION BY "station_id" ORDER BY "time_bucket")
+		)
+		SELECT
+			h."electric_bike_surcharge_waiver",
+			COUNT(DISTINCT b."station_id"),
+			AVG(b."ebikes_available"),
+			COALESCE(SUM(MAX(b."previous" - b."ebikes_available", 0)) * 12 / NULLIF(COUNT(b."previous"), 0), 0)
+		FROM "bucket" b
+		JOIN "station_history" h ON
+			h."station_id" = b."station_id"
+			AND h."valid_from" <= b."time_bucket"
+			AND (h."valid_to" IS NULL OR b."time_bucket" < h."valid_to")
+		GROUP BY h."electric_bike_surcharge_waiver"
+		ORDER BY h."electric_bike_surcharge_waiver"`,
+		from.Unix(),
+		to.Unix(),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ebike depletion: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.EbikeDepletion{}
+
+	for rows.Next() {
+		depletion := store.EbikeDepletion{}
+
+		if err := rows.Scan(&depletion.Waiver, &depletion.Stations, &depletion.EbikesAvailable, &depletion.Departures); err != nil {
+			return nil, fmt.Errorf("failed to query ebike depletion: %w", err)
+		}
+
+		result = append(result, depletion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query ebike depletion: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type geofencingZones struct {
+	q querier
+}
+
+func (r geofencingZones) Replace(ctx context.Context, zones []store.GeofencingZone) error {
+	_, err := r.q.ExecContext(ctx, `DELETE FROM "geofencing_zone"`)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete geofencing zones: %w", err)
+	}
+
+	for position, zone := range zones {
+		rules, err := jsonArray(zone.Rules)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = r.q.ExecContext(
+			ctx,
+			`INSERT INTO "geofencing_zone" ("position", "name", "start", "end", "rules", "geometry")
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			position,
+			zone.Name,
+			unixSeconds(zone.Start),
+			unixSeconds(zone.End),
+			rules,
+			string(zone.Geometry),
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to insert geofencing zone: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r geofencingZones) List(ctx context.Context) ([]store.GeofencingZone, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT "name", "start", "end", "rules", "geometry"
+		FROM "geofencing_zone"
+		ORDER BY "position"`,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query geofencing zones: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.GeofencingZone{}
+
+	for rows.Next() {
+		zone := store.GeofencingZone{}
+		var name sql.NullString
+		var start, end sql.NullInt64
+		var rules, geometry string
+
+		if err := rows.Scan(&name, &start, &end, &rules, &geometry); err != nil {
+			return nil, fmt.Errorf("failed to query geofencing zones: %w", err)
+		}
+
+		zone.Name = nullableString(name)
+		zone.Start = nullableTime(start)
+		zone.End = nullableTime(end)
+		zone.Geometry = json.RawMessage(geometry)
+
+		if err := json.Unmarshal([]byte(rules), &zone.Rules); err != nil {
+			return nil, fmt.Errorf("failed to decode rules of geofencing zone: %w", err)
+		}
+
+		result = append(result, zone)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query geofencing zones: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r geofencingZones) At(ctx context.Context, lon float64, lat float64) ([]store.GeofencingZone, error) {
+	zones, err := r.List(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := []store.GeofencingZone{}
+
+	for _, zone := range zones {
+		inside, err := store.Contains(zone.Geometry, lon, lat)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if inside {
+			result = append(result, zone)
+		}
+	}
+
+	return result, nil
+}
+
+func (r geofencingZones) Tile(ctx context.Context, z int, x int, y int) ([]byte, error) {
+	return nil, store.ErrUnsupported
+}
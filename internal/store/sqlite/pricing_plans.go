@@ -0,0 +1,219 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+const pricingPlanColumns = `
+	"plan_id",
+	"valid_from",
+	"valid_to",
+	"name",
+	"currency",
+	"price",
+	"is_taxable",
+	"description",
+	"url",
+	"per_km_pricing",
+	"per_min_pricing"`
+
+type pricingPlans struct {
+	q querier
+}
+
+// scanner is implemented by both rows and a single row
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPricingPlan reads the pricingPlanColumns of row
+func scanPricingPlan(row scanner) (store.PricingPlan, error) {
+	plan := store.PricingPlan{}
+	var validFrom int64
+	var validTo sql.NullInt64
+	var url sql.NullString
+	var perKm, perMin string
+
+	err := row.Scan(
+		&plan.PlanID,
+		&validFrom,
+		&validTo,
+		&plan.Name,
+		&plan.Currency,
+		&plan.Price,
+		&plan.IsTaxable,
+		&plan.Description,
+		&url,
+		&perKm,
+		&perMin,
+	)
+
+	if err != nil {
+		return plan, err
+	}
+
+	plan.ValidFrom = time.Unix(validFrom, 0)
+	plan.ValidTo = nullableTime(validTo)
+	plan.URL = nullableString(url)
+
+	if err := json.Unmarshal([]byte(perKm), &plan.PerKmPricing); err != nil {
+		return plan, fmt.Errorf("failed to decode per_km_pricing of plan %s: %w", plan.PlanID, err)
+	}
+
+	if err := json.Unmarshal([]byte(perMin), &plan.PerMinPricing); err != nil {
+		return plan, fmt.Errorf("failed to decode per_min_pricing of plan %s: %w", plan.PlanID, err)
+	}
+
+	return plan, nil
+}
+
+func (r pricingPlans) Upsert(ctx context.Context, plan store.PricingPlan, at time.Time) error {
+	latest, err := scanPricingPlan(r.q.QueryRowContext(
+		ctx,
+		`SELECT `+pricingPlanColumns+`
+		FROM "pricing_plan"
+		WHERE "plan_id" = ?
+		ORDER BY "valid_from" DESC
+		LIMIT 1`,
+		plan.PlanID,
+	))
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to query pricing plan: %w", err)
+	}
+
+	if err == nil {
+		if at.Before(latest.ValidFrom) || (latest.ValidTo != nil && at.Before(*latest.ValidTo)) {
+			return nil
+		}
+
+		if latest.ValidTo == nil && latest.Describes(plan) {
+			return nil
+		}
+	}
+
+	perKm, err := jsonArray(plan.PerKmPricing)
+
+	if err != nil {
+		return err
+	}
+
+	perMin, err := jsonArray(plan.PerMinPricing)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.ExecContext(ctx, `UPDATE "pricing_plan" SET "valid_to" = ? WHERE "plan_id" = ? AND "valid_to" IS NULL`, at.Unix(), plan.PlanID)
+
+	if err != nil {
+		return fmt.Errorf("failed to end pricing plan version: %w", err)
+	}
+
+	// A version replaced as soon as it started is overwritten
+	_, err = r.q.ExecContext(
+		ctx,
+		`INSERT INTO "pricing_plan" (
+			"plan_id",
+			"valid_from",
+			"name",
+			"currency",
+			"price",
+			"is_taxable",
+			"description",
+			"url",
+			"per_km_pricing",
+			"per_min_pricing"
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT ("plan_id", "valid_from") DO UPDATE SET
+			"valid_to" = NULL,
+			"name" = excluded."name",
+			"currency" = excluded."currency",
+			"price" = excluded."price",
+			"is_taxable" = excluded."is_taxable",
+			"description" = excluded."description",
+			"url" = excluded."url",
+			"per_km_pricing" = excluded."per_km_pricing",
+			"per_min_pricing" = excluded."per_min_pricing"`,
+		plan.PlanID,
+		at.Unix(),
+		plan.Name,
+		plan.Currency,
+		plan.Price,
+		plan.IsTaxable,
+		plan.Description,
+		plan.URL,
+		perKm,
+		perMin,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert pricing plan version: %w", err)
+	}
+
+	return nil
+}
+
+func (r pricingPlans) Withdraw(ctx context.Context, listed []string, at time.Time) (int64, error) {
+	ids, err := jsonArray(listed)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.q.ExecContext(
+		ctx,
+		`UPDATE "pricing_plan" SET "valid_to" = ?1
+		WHERE "valid_to" IS NULL AND "valid_from" <= ?1 AND "plan_id" NOT IN (SELECT "value" FROM JSON_EACH(?2))`,
+		at.Unix(),
+		ids,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to withdraw pricing plans: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (r pricingPlans) List(ctx context.Context, from time.Time, to time.Time) ([]store.PricingPlan, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+pricingPlanColumns+`
+		FROM "pricing_plan"
+		WHERE "valid_from" <= ? AND ("valid_to" IS NULL OR "valid_to" > ?)
+		ORDER BY "plan_id", "valid_from"`,
+		to.Unix(),
+		from.Unix(),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pricing plans: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.PricingPlan{}
+
+	for rows.Next() {
+		plan, err := scanPricingPlan(rows)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pricing plans: %w", err)
+		}
+
+		result = append(result, plan)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query pricing plans: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type quality struct {
+	q querier
+}
+
+func (r quality) Record(ctx context.Context, issue store.QualityIssue) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO "data_quality_issue" (
+			"time",
+			"feed",
+			"snapshot_updated",
+			"station_external_id",
+			"rule",
+			"message",
+			"quarantined",
+			"record"
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		issue.Time.Unix(),
+		issue.Feed,
+		issue.SnapshotUpdated.Unix(),
+		issue.StationExternalID,
+		issue.Rule,
+		issue.Message,
+		issue.Quarantined,
+		string(issue.Record),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to record data quality issue: %w", err)
+	}
+
+	return nil
+}
+
+func (r quality) List(ctx context.Context, filter store.QualityFilter) ([]store.QualityIssue, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			"time",
+			"feed",
+			"snapshot_updated",
+			"station_external_id",
+			"rule",
+			"message",
+			"quarantined",
+			"record"
+		FROM "data_quality_issue"
+		WHERE
+			"time" >= ?1
+			AND (?2 = '' OR "station_external_id" = ?2)
+			AND (?3 = '' OR "rule" = ?3)
+		ORDER BY "time" DESC, "id" DESC
+		LIMIT ?4`,
+		filter.Since.Unix(),
+		filter.StationID,
+		filter.Rule,
+		filter.Limit,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query data quality issues: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.QualityIssue{}
+
+	for rows.Next() {
+		issue := store.QualityIssue{}
+		var at, updated int64
+		var record string
+
+		err := rows.Scan(&at, &issue.Feed, &updated, &issue.StationExternalID, &issue.Rule, &issue.Message, &issue.Quarantined, &record)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query data quality issues: %w", err)
+		}
+
+		issue.Time = time.Unix(at, 0)
+		issue.SnapshotUpdated = time.Unix(updated, 0)
+		issue.Record = []byte(record)
+		result = append(result, issue)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query data quality issues: %w", err)
+	}
+
+	return result, nil
+}
+
+type syncState struct {
+	q querier
+}
+
+func (r syncState) Record(ctx context.Context, feed string, lastUpdated time.Time, syncedAt time.Time) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO "sync_state" ("feed", "last_success", "last_updated")
+		VALUES (?, ?, ?)
+		ON CONFLICT ("feed") DO UPDATE SET
+			"last_success" = excluded."last_success",
+			"last_updated" = excluded."last_updated"`,
+		feed,
+		syncedAt.Unix(),
+		lastUpdated.Unix(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to record sync state: %w", err)
+	}
+
+	return nil
+}
+
+func (r syncState) Get(ctx context.Context, feed string) (*store.SyncState, error) {
+	state := store.SyncState{Feed: feed}
+	var lastSuccess int64
+	var lastUpdated sql.NullInt64
+
+	err := r.q.QueryRowContext(
+		ctx,
+		`SELECT "last_success", "last_updated" FROM "sync_state" WHERE "feed" = ?`,
+		feed,
+	).Scan(&lastSuccess, &lastUpdated)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	state.LastSuccess = time.Unix(lastSuccess, 0)
+	state.LastUpdated = nullableTime(lastUpdated)
+	return &state, nil
+}
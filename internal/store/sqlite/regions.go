@@ -0,0 +1,150 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type regions struct {
+	q querier
+}
+
+func (r regions) Upsert(ctx context.Context, externalID string, name string) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO "region" ("external_id", "name") VALUES (?, ?)
+		ON CONFLICT ("external_id") DO UPDATE SET "name" = excluded."name"`,
+		externalID,
+		name,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert region: %w", err)
+	}
+
+	return nil
+}
+
+func (r regions) Create(ctx context.Context, name string, geometry []byte) (int64, error) {
+	return 0, store.ErrUnsupported
+}
+
+func (r regions) Delete(ctx context.Context, id int64) error {
+	// Every region comes from system_regions
+	return store.ErrNotFound
+}
+
+func (r regions) List(ctx context.Context) ([]store.Region, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			"id",
+			"external_id",
+			"name",
+			(
+				SELECT COUNT(*) FROM "station" s
+				WHERE s."region_id" = "region"."external_id"
+					AND EXISTS (SELECT 1 FROM "station_history" h WHERE h."station_id" = s."id" AND h."valid_to" IS NULL)
+			)
+		FROM "region"
+		ORDER BY "id"`,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query regions: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.Region{}
+
+	for rows.Next() {
+		region := store.Region{ExternalID: new(string)}
+
+		if err := rows.Scan(&region.ID, region.ExternalID, &region.Name, &region.Stations); err != nil {
+			return nil, fmt.Errorf("failed to query regions: %w", err)
+		}
+
+		result = append(result, region)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query regions: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r regions) Availability(ctx context.Context, id int64, from time.Time, to time.Time, bucket time.Duration) ([]store.RegionAvailability, error) {
+	width := int64(bucket.Seconds())
+
+	if width < 1 {
+		return nil, fmt.Errorf("invalid bucket width %s", bucket)
+	}
+
+	var externalID string
+	err := r.q.QueryRowContext(ctx, `SELECT "external_id" FROM "region" WHERE "id" = ?`, id).Scan(&externalID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query region: %w", err)
+	}
+
+	// Each station's availability is averaged within the bucket, then summed
+	rows, err := r.q.QueryContext(ctx, `
+		WITH "station_bucket" AS (
+			SELECT
+				a."time_bucket" / ?1 * ?1 AS "bucket",
+				AVG(CAST(a."bikes_available" AS REAL) / a."samples") AS "bikes_available",
+				COALESCE(AVG(CASE WHEN a."ebikes_samples" > 0 THEN CAST(a."ebikes_available" AS REAL) / a."ebikes_samples" END), 0) AS "ebikes_available",
+				AVG(CAST(a."docks_available" AS REAL) / a."samples") AS "docks_available"
+			FROM "historical_station_availability" a
+			JOIN "station" s ON s."id" = a."station_id"
+			WHERE
+				s."region_id" = ?2
+				AND a."time_bucket" BETWEEN ?3 AND ?4
+			GROUP BY "bucket", a."station_id"
+		)
+		SELECT "bucket", COUNT(*), SUM("bikes_available"), SUM("ebikes_available"), SUM("docks_available")
+		FROM "station_bucket"
+		GROUP BY "bucket"
+		ORDER BY "bucket"`,
+		width,
+		externalID,
+		from.Unix(),
+		to.Unix(),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query region availability: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.RegionAvailability{}
+
+	for rows.Next() {
+		var at int64
+		a := store.RegionAvailability{}
+
+		if err := rows.Scan(&at, &a.Stations, &a.BikesAvailable, &a.EbikesAvailable, &a.DocksAvailable); err != nil {
+			return nil, fmt.Errorf("failed to query region availability: %w", err)
+		}
+
+		a.Time = time.Unix(at, 0)
+		result = append(result, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query region availability: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,167 @@
+-- Times are unix seconds. historical_station_availability keeps running sums
+-- per 5 minute bucket, updated as availability is inserted, in place of
+-- TimescaleDB's continuous aggregate.
+
+CREATE TABLE "station" (
+    "id" INTEGER PRIMARY KEY,
+    "external_id" TEXT NOT NULL UNIQUE,
+    "name" TEXT,
+    "short_name" TEXT,
+    "lon" REAL,
+    "lat" REAL,
+    "capacity" INTEGER,
+    "last_status_reported" INTEGER,
+    "address" TEXT,
+    "cross_street" TEXT,
+    "region_id" TEXT,
+    "post_code" TEXT,
+    "rental_methods" TEXT NOT NULL DEFAULT '[]', -- JSON array
+    "has_kiosk" INTEGER,
+    "electric_bike_surcharge_waiver" INTEGER NOT NULL DEFAULT 0,
+    "is_charging" INTEGER NOT NULL DEFAULT 0
+);
+
+-- Versions of each station's description, see schema/007_StationHistory.sql
+CREATE TABLE "station_history" (
+    "station_id" INTEGER NOT NULL REFERENCES "station" ("id"),
+    "valid_from" INTEGER NOT NULL,
+    "valid_to" INTEGER,
+    "name" TEXT NOT NULL,
+    "short_name" TEXT,
+    "lon" REAL NOT NULL,
+    "lat" REAL NOT NULL,
+    "capacity" INTEGER,
+    "electric_bike_surcharge_waiver" INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY ("station_id", "valid_from")
+);
+
+CREATE UNIQUE INDEX "station_history_current" ON "station_history" ("station_id") WHERE "valid_to" IS NULL;
+
+-- Regions of the system_regions feed. Custom regions need PostGIS.
+CREATE TABLE "region" (
+    "id" INTEGER PRIMARY KEY,
+    "external_id" TEXT NOT NULL UNIQUE,
+    "name" TEXT NOT NULL
+);
+
+-- Alerts of the system_alerts feed, see schema/010_SystemAlert.sql. Lists are
+-- JSON arrays.
+CREATE TABLE "system_alert" (
+    "id" INTEGER PRIMARY KEY,
+    "external_id" TEXT NOT NULL UNIQUE,
+    "type" TEXT NOT NULL,
+    "times" TEXT NOT NULL DEFAULT '[]',
+    "station_ids" TEXT NOT NULL DEFAULT '[]',
+    "region_ids" TEXT NOT NULL DEFAULT '[]',
+    "url" TEXT,
+    "summary" TEXT NOT NULL,
+    "description" TEXT,
+    "last_updated" INTEGER,
+    "withdrawn" INTEGER
+);
+
+-- Versions of the plans of the system_pricing_plans feed, see
+-- schema/012_PricingPlan.sql. Pricing segments are JSON arrays.
+CREATE TABLE "pricing_plan" (
+    "plan_id" TEXT NOT NULL,
+    "valid_from" INTEGER NOT NULL,
+    "valid_to" INTEGER,
+    "name" TEXT NOT NULL,
+    "currency" TEXT NOT NULL,
+    "price" REAL NOT NULL,
+    "is_taxable" INTEGER NOT NULL,
+    "description" TEXT NOT NULL,
+    "url" TEXT,
+    "per_km_pricing" TEXT NOT NULL DEFAULT '[]',
+    "per_min_pricing" TEXT NOT NULL DEFAULT '[]',
+    PRIMARY KEY ("plan_id", "valid_from")
+);
+
+CREATE UNIQUE INDEX "pricing_plan_current" ON "pricing_plan" ("plan_id") WHERE "valid_to" IS NULL;
+
+-- Zones of the geofencing_zones feed, see schema/013_GeofencingZone.sql.
+-- Without PostGIS, geometries are GeoJSON MultiPolygons and points are
+-- located by scanning every zone.
+CREATE TABLE "geofencing_zone" (
+    "position" INTEGER PRIMARY KEY,
+    "name" TEXT,
+    "start" INTEGER,
+    "end" INTEGER,
+    "rules" TEXT NOT NULL DEFAULT '[]',
+    "geometry" TEXT NOT NULL
+);
+
+CREATE TABLE "live_station_availability" (
+    "time" INTEGER NOT NULL,
+    "station_id" INTEGER NOT NULL REFERENCES "station" ("id"),
+    "bikes_available" INTEGER NOT NULL,
+    "bikes_disabled" INTEGER,
+    "ebikes_available" INTEGER,
+    "ebikes_disabled" INTEGER,
+    "docks_available" INTEGER NOT NULL,
+    "docks_disabled" INTEGER
+);
+
+CREATE INDEX "live_station_availability_station_time" ON "live_station_availability" ("station_id", "time" DESC);
+CREATE INDEX "live_station_availability_time" ON "live_station_availability" ("time");
+
+CREATE TABLE "historical_station_availability" (
+    "time_bucket" INTEGER NOT NULL,
+    "station_id" INTEGER NOT NULL REFERENCES "station" ("id"),
+    "samples" INTEGER NOT NULL,
+    "bikes_available" INTEGER NOT NULL,
+    "ebikes_samples" INTEGER NOT NULL,
+    "ebikes_available" INTEGER NOT NULL,
+    "docks_available" INTEGER NOT NULL,
+    PRIMARY KEY ("station_id", "time_bucket")
+);
+
+CREATE INDEX "historical_station_availability_time_bucket" ON "historical_station_availability" ("time_bucket");
+
+CREATE TABLE "vehicle_type" (
+    "id" INTEGER PRIMARY KEY,
+    "external_id" TEXT NOT NULL UNIQUE,
+    "form_factor" TEXT,
+    "propulsion_type" TEXT,
+    "name" TEXT,
+    "max_range_meters" REAL
+);
+
+CREATE TABLE "live_station_vehicle_availability" (
+    "time" INTEGER NOT NULL,
+    "station_id" INTEGER NOT NULL REFERENCES "station" ("id"),
+    "vehicle_type_id" INTEGER NOT NULL REFERENCES "vehicle_type" ("id"),
+    "available" INTEGER NOT NULL
+);
+
+CREATE INDEX "live_station_vehicle_availability_station_time" ON "live_station_vehicle_availability" ("station_id", "time" DESC);
+CREATE INDEX "live_station_vehicle_availability_time" ON "live_station_vehicle_availability" ("time");
+
+CREATE TABLE "historical_station_vehicle_availability" (
+    "time_bucket" INTEGER NOT NULL,
+    "station_id" INTEGER NOT NULL REFERENCES "station" ("id"),
+    "vehicle_type_id" INTEGER NOT NULL REFERENCES "vehicle_type" ("id"),
+    "samples" INTEGER NOT NULL,
+    "available" INTEGER NOT NULL,
+    PRIMARY KEY ("station_id", "time_bucket", "vehicle_type_id")
+);
+
+CREATE TABLE "data_quality_issue" (
+    "id" INTEGER PRIMARY KEY,
+    "time" INTEGER NOT NULL,
+    "feed" TEXT NOT NULL,
+    "snapshot_updated" INTEGER NOT NULL,
+    "station_external_id" TEXT NOT NULL,
+    "rule" TEXT NOT NULL,
+    "message" TEXT NOT NULL,
+    "quarantined" INTEGER NOT NULL,
+    "record" TEXT NOT NULL
+);
+
+CREATE INDEX "data_quality_issue_time" ON "data_quality_issue" ("time" DESC);
+
+CREATE TABLE "sync_state" (
+    "feed" TEXT PRIMARY KEY,
+    "last_success" INTEGER NOT NULL,
+    "last_updated" INTEGER
+);
@@ -0,0 +1,289 @@
+// Package sqlite implements store.Store in a single SQLite file, for small
+// systems which do not warrant running TimescaleDB. Without PostGIS, the
+// heatmap only has square cells.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the pure Go "sqlite" driver
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+const (
+	// bucketWidth matches historical_station_availability in TimescaleDB
+	bucketWidth = 5 * time.Minute
+
+	// Raw availability past its retention is pruned at most this often
+	pruneInterval = time.Hour
+)
+
+// schema holds the steps creating the tables, applied once each and in
+// order of their number, which the database records as its user_version.
+// Never edit a released step: add a new one.
+//
+//go:embed schema/*.sql
+var schema embed.FS
+
+// querier is implemented by both the database and transactions
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Store struct {
+	db        *sql.DB
+	q         querier
+	inTx      bool
+	retention time.Duration // of raw availability, forever if 0
+	lastPrune *atomic.Int64 // unix seconds
+}
+
+// Open opens or creates the database at path and creates its tables. Raw
+// availability is pruned once older than retention, like
+// retention.live_station_availability.drop_after on PostgreSQL, and kept
+// forever if it is 0.
+func Open(ctx context.Context, path string, retention time.Duration) (*Store, error) {
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "busy_timeout(5000)")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite only allows one writer: serialize everything rather than deal
+	// with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	err = migrate(ctx, db)
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db, q: db, retention: retention, lastPrune: &atomic.Int64{}}, nil
+}
+
+// steps returns the statements of each schema step, in order
+func steps() ([]string, error) {
+	paths, err := fs.Glob(schema, "schema/*.sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Glob sorts paths, which are numbered
+	result := []string{}
+
+	for i, name := range paths {
+		if !strings.HasPrefix(name, fmt.Sprintf("schema/%03d_", i+1)) {
+			return nil, fmt.Errorf("schema step %s is out of sequence", name)
+		}
+
+		content, err := fs.ReadFile(schema, name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, string(content))
+	}
+
+	return result, nil
+}
+
+// Version is the version of the schema once every step is applied
+func Version() (int32, error) {
+	statements, err := steps()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	return int32(len(statements)), nil
+}
+
+// migrate applies the steps the database is missing, each in its own
+// transaction. A database created by a newer binary is refused.
+func migrate(ctx context.Context, db *sql.DB) error {
+	statements, err := steps()
+
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	var current int32
+
+	if err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if current > int32(len(statements)) {
+		return fmt.Errorf("database schema is at version %d, newer than the %d migrations of this binary: upgrade hixi", current, len(statements))
+	}
+
+	for version := current + 1; version <= int32(len(statements)); version++ {
+		tx, err := db.BeginTx(ctx, nil)
+
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, statements[version-1])
+
+		if err == nil {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, version))
+		}
+
+		if err == nil {
+			err = tx.Commit()
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply schema version %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Stations() store.StationRepository {
+	return stations{s.q}
+}
+
+func (s *Store) Availability() store.AvailabilityRepository {
+	return availability{s.q, s.retention, s.lastPrune}
+}
+
+func (s *Store) Quality() store.QualityRepository {
+	return quality{s.q}
+}
+
+func (s *Store) SyncState() store.SyncStateRepository {
+	return syncState{s.q}
+}
+
+func (s *Store) Retention() store.RetentionRepository {
+	return retentionPolicies{s.retention}
+}
+
+func (s *Store) Regions() store.RegionRepository {
+	return regions{s.q}
+}
+
+func (s *Store) Alerts() store.AlertRepository {
+	return alerts{s.q}
+}
+
+func (s *Store) VehicleTypes() store.VehicleTypeRepository {
+	return vehicleTypes{s.q}
+}
+
+func (s *Store) PricingPlans() store.PricingPlanRepository {
+	return pricingPlans{s.q}
+}
+
+func (s *Store) GeofencingZones() store.GeofencingZoneRepository {
+	return geofencingZones{s.q}
+}
+
+func (s *Store) InTx(ctx context.Context, fn func(tx store.Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	err = fn(&Store{db: s.db, q: tx, inTx: true, retention: s.retention, lastPrune: s.lastPrune})
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// SchemaVersion returns the user_version of the database, the number of
+// schema steps applied
+func (s *Store) SchemaVersion(ctx context.Context) (int32, error) {
+	var version int32
+	err := s.q.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// nullableTime converts a nullable unix time column
+func nullableTime(seconds sql.NullInt64) *time.Time {
+	if !seconds.Valid {
+		return nil
+	}
+
+	t := time.Unix(seconds.Int64, 0)
+	return &t
+}
+
+// unixSeconds is the inverse of nullableTime
+func unixSeconds(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+
+	seconds := t.Unix()
+	return &seconds
+}
+
+func nullableInt(value sql.NullInt64) *int64 {
+	if !value.Valid {
+		return nil
+	}
+
+	return &value.Int64
+}
+
+func nullableString(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+
+	return &value.String
+}
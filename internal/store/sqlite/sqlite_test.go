@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/storetest"
+)
+
+// retention is the default retention.live_station_availability.drop_after
+const retention = 24 * time.Hour
+
+func open(t *testing.T) *Store {
+	t.Helper()
+
+	st, err := Open(context.Background(), filepath.Join(t.TempDir(), "hixi.db"), retention)
+
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) (store.Store, func(ctx context.Context) error) {
+		return open(t), nil
+	})
+}
+
+func TestPrune(t *testing.T) {
+	st := open(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	station, _ := st.Stations().FindOrCreate(ctx, "1")
+
+	for _, at := range []time.Time{now.Add(-2 * retention), now} {
+		if err := st.Availability().Insert(ctx, store.Availability{Time: at, StationID: station.ID, BikesAvailable: 1}); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	var rows int
+
+	if err := st.db.QueryRow(`SELECT COUNT(*) FROM "live_station_availability"`).Scan(&rows); err != nil || rows != 1 {
+		t.Errorf("%d live rows, %v, want expired row pruned", rows, err)
+	}
+
+	history, err := st.Availability().History(ctx, station.ID, now.Add(-3*retention), now, time.Hour)
+
+	if err != nil || len(history) != 2 {
+		t.Errorf("History() = %+v, %v, want pruned row still aggregated", history, err)
+	}
+
+	if _, err := st.Availability().Heatmap(ctx, store.HeatmapQuery{Shape: "hex", Metric: "bikes"}); !errors.Is(err, store.ErrUnsupported) {
+		t.Errorf("Heatmap(hex) error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hixi.db")
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		st, err := Open(ctx, path, retention)
+
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		if _, err := st.Stations().FindOrCreate(ctx, "1"); err != nil {
+			t.Fatalf("FindOrCreate() error = %v", err)
+		}
+
+		st.Close()
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hixi.db")
+	ctx := context.Background()
+	st, err := Open(ctx, path, retention)
+
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	known, err := Version()
+
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+
+	if version, err := st.SchemaVersion(ctx); err != nil || version != known || version == 0 {
+		t.Errorf("SchemaVersion() = %d, %v, want %d", version, err, known)
+	}
+
+	// As if a newer binary had added a step
+	if _, err := st.db.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, known+1)); err != nil {
+		t.Fatal(err)
+	}
+
+	st.Close()
+
+	if st, err := Open(ctx, path, retention); err == nil {
+		st.Close()
+		t.Error("Open() of a newer schema succeeded, want error")
+	}
+}
@@ -0,0 +1,392 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type stations struct {
+	q querier
+}
+
+func (r stations) FindOrCreate(ctx context.Context, externalID string) (*store.KnownStation, error) {
+	known := store.KnownStation{}
+	var reported, capacity sql.NullInt64
+
+	err := r.q.QueryRowContext(
+		ctx,
+		`SELECT "id", "last_status_reported", "name" IS NOT NULL, "capacity" FROM "station" WHERE "external_id" = ?`,
+		externalID,
+	).Scan(&known.ID, &reported, &known.Listed, &capacity)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		err = r.q.QueryRowContext(
+			ctx,
+			`INSERT INTO "station" ("external_id") VALUES (?) RETURNING "id"`,
+			externalID,
+		).Scan(&known.ID)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert station: %w", err)
+		}
+
+		return &known, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station: %w", err)
+	}
+
+	known.LastStatusReported = nullableTime(reported)
+	known.Capacity = nullableInt(capacity)
+	return &known, nil
+}
+
+func (r stations) Upsert(ctx context.Context, station store.StationInformation, at time.Time) error {
+	var id int64
+	err := r.q.QueryRowContext(
+		ctx,
+		`INSERT INTO "station" ("external_id") VALUES (?)
+		ON CONFLICT ("external_id") DO UPDATE SET "external_id" = excluded."external_id"
+		RETURNING "id"`,
+		station.ExternalID,
+	).Scan(&id)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert station: %w", err)
+	}
+
+	versions, err := r.History(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	unchanged := false
+
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+
+		if at.Before(latest.ValidFrom) || (latest.ValidTo != nil && at.Before(*latest.ValidTo)) {
+			return nil
+		}
+
+		unchanged = latest.ValidTo == nil && latest.Describes(station)
+	}
+
+	metadata := station.Metadata
+	rentalMethods := metadata.RentalMethods
+
+	if rentalMethods == nil {
+		rentalMethods = []string{}
+	}
+
+	methods, err := json.Marshal(rentalMethods)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.ExecContext(
+		ctx,
+		`UPDATE "station" SET
+			"name" = ?,
+			"lon" = ?,
+			"lat" = ?,
+			"capacity" = ?,
+			"short_name" = ?,
+			"address" = ?,
+			"cross_street" = ?,
+			"region_id" = ?,
+			"post_code" = ?,
+			"rental_methods" = ?,
+			"has_kiosk" = ?,
+			"electric_bike_surcharge_waiver" = ?,
+			"is_charging" = ?
+		WHERE "id" = ?`,
+		station.Name,
+		station.Lon,
+		station.Lat,
+		station.Capacity,
+		station.ShortName,
+		metadata.Address,
+		metadata.CrossStreet,
+		metadata.RegionID,
+		metadata.PostCode,
+		string(methods),
+		metadata.HasKiosk,
+		metadata.ElectricBikeSurchargeWaiver,
+		metadata.IsCharging,
+		id,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert station: %w", err)
+	}
+
+	if unchanged {
+		return nil
+	}
+
+	_, err = r.q.ExecContext(ctx, `UPDATE "station_history" SET "valid_to" = ? WHERE "station_id" = ? AND "valid_to" IS NULL`, at.Unix(), id)
+
+	if err != nil {
+		return fmt.Errorf("failed to end station version: %w", err)
+	}
+
+	// A version replaced as soon as it started is overwritten
+	_, err = r.q.ExecContext(
+		ctx,
+		`INSERT INTO "station_history" ("station_id", "valid_from", "name", "short_name", "lon", "lat", "capacity", "electric_bike_surcharge_waiver")
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT ("station_id", "valid_from") DO UPDATE SET
+			"valid_to" = NULL,
+			"name" = excluded."name",
+			"short_name" = excluded."short_name",
+			"lon" = excluded."lon",
+			"lat" = excluded."lat",
+			"capacity" = excluded."capacity",
+			"electric_bike_surcharge_waiver" = excluded."electric_bike_surcharge_waiver"`,
+		id,
+		at.Unix(),
+		station.Name,
+		station.ShortName,
+		station.Lon,
+		station.Lat,
+		station.Capacity,
+		station.Metadata.ElectricBikeSurchargeWaiver,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert station version: %w", err)
+	}
+
+	return nil
+}
+
+func (r stations) Decommission(ctx context.Context, listed []string, at time.Time) (int64, error) {
+	ids, err := json.Marshal(listed)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.q.ExecContext(
+		ctx,
+		`UPDATE "station_history" SET "valid_to" = ?1
+		WHERE
+			"valid_to" IS NULL
+			AND "valid_from" <= ?1
+			AND "station_id" IN (
+				SELECT "id" FROM "station"
+				WHERE "external_id" NOT IN (SELECT "value" FROM JSON_EACH(?2))
+			)`,
+		at.Unix(),
+		string(ids),
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to decommission stations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (r stations) History(ctx context.Context, id int64) ([]store.StationVersion, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT "valid_from", "valid_to", "name", "short_name", "lon", "lat", "capacity", "electric_bike_surcharge_waiver"
+		FROM "station_history"
+		WHERE "station_id" = ?
+		ORDER BY "valid_from"`,
+		id,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station history: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.StationVersion{}
+
+	for rows.Next() {
+		version := store.StationVersion{}
+		var validFrom int64
+		var validTo, capacity sql.NullInt64
+		var shortName sql.NullString
+
+		err := rows.Scan(&validFrom, &validTo, &version.Name, &shortName, &version.Lon, &version.Lat, &capacity, &version.ElectricBikeSurchargeWaiver)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query station history: %w", err)
+		}
+
+		version.ValidFrom = time.Unix(validFrom, 0)
+		version.ValidTo = nullableTime(validTo)
+		version.Capacity = nullableInt(capacity)
+
+		version.ShortName = nullableString(shortName)
+		result = append(result, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query station history: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r stations) SetLastReported(ctx context.Context, id int64, reported time.Time) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE "station" SET "last_status_reported" = ? WHERE "id" = ?`, reported.Unix(), id)
+
+	if err != nil {
+		return fmt.Errorf("failed to update station: %w", err)
+	}
+
+	return nil
+}
+
+func (r stations) List(ctx context.Context, filter store.StationFilter) ([]store.StationSummary, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			"id",
+			"external_id",
+			"name",
+			"short_name",
+			"lon",
+			"lat",
+			EXISTS (
+				SELECT 1 FROM "live_station_availability"
+				WHERE "station_id" = "station"."id" AND "time" > ?1
+			),
+			EXISTS (SELECT 1 FROM "station_history" WHERE "station_id" = "station"."id")
+				AND NOT EXISTS (SELECT 1 FROM "station_history" WHERE "station_id" = "station"."id" AND "valid_to" IS NULL),
+			`+metadataColumns+`
+		FROM "station"
+		WHERE
+			"lon" IS NOT NULL AND "lat" IS NOT NULL
+			AND (?2 = '' OR EXISTS (SELECT 1 FROM JSON_EACH("rental_methods") WHERE UPPER("value") = UPPER(?2)))
+			AND (?3 IS NULL OR "has_kiosk" = ?3)
+		ORDER BY "id"`,
+		filter.ActiveSince.Unix(),
+		filter.RentalMethod,
+		filter.HasKiosk,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stations: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.StationSummary{}
+
+	for rows.Next() {
+		station := store.StationSummary{}
+		var name, shortName sql.NullString
+		scanned := metadataScanner{}
+		fields := append(
+			[]any{&station.ID, &station.ExternalID, &name, &shortName, &station.Lon, &station.Lat, &station.Active, &station.Decommissioned},
+			scanned.fields()...,
+		)
+
+		if err := rows.Scan(fields...); err != nil {
+			return nil, fmt.Errorf("failed to query stations: %w", err)
+		}
+
+		station.Name = name.String
+		station.ShortName = nullableString(shortName)
+
+		if station.Metadata, err = scanned.metadata(); err != nil {
+			return nil, fmt.Errorf("failed to query stations: %w", err)
+		}
+
+		result = append(result, station)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query stations: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r stations) Get(ctx context.Context, id int64) (*store.StationInformation, error) {
+	station := store.StationInformation{}
+	var name, shortName sql.NullString
+	var lon, lat sql.NullFloat64
+	var capacity sql.NullInt64
+	scanned := metadataScanner{}
+	fields := append(
+		[]any{&station.ExternalID, &name, &shortName, &lon, &lat, &capacity},
+		scanned.fields()...,
+	)
+
+	err := r.q.QueryRowContext(
+		ctx,
+		`SELECT "external_id", "name", "short_name", "lon", "lat", "capacity", `+metadataColumns+`
+		FROM "station"
+		WHERE "id" = ?`,
+		id,
+	).Scan(fields...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station: %w", err)
+	}
+
+	if name.Valid && lon.Valid && lat.Valid {
+		station.Name, station.Lon, station.Lat = name.String, lon.Float64, lat.Float64
+	}
+
+	station.ShortName = nullableString(shortName)
+	station.Capacity = nullableInt(capacity)
+
+	if station.Metadata, err = scanned.metadata(); err != nil {
+		return nil, fmt.Errorf("failed to query station: %w", err)
+	}
+
+	return &station, nil
+}
+
+// metadataColumns are the columns of store.StationMetadata, scanned by
+// metadataScanner
+const metadataColumns = `"address", "cross_street", "region_id", "post_code", "rental_methods", "has_kiosk", "electric_bike_surcharge_waiver", "is_charging"`
+
+type metadataScanner struct {
+	address, crossStreet, regionID, postCode sql.NullString
+	rentalMethods                            string
+	hasKiosk                                 sql.NullBool
+	surchargeWaiver, isCharging              bool
+}
+
+func (s *metadataScanner) fields() []any {
+	return []any{&s.address, &s.crossStreet, &s.regionID, &s.postCode, &s.rentalMethods, &s.hasKiosk, &s.surchargeWaiver, &s.isCharging}
+}
+
+func (s *metadataScanner) metadata() (store.StationMetadata, error) {
+	metadata := store.StationMetadata{
+		Address:                     nullableString(s.address),
+		CrossStreet:                 nullableString(s.crossStreet),
+		RegionID:                    nullableString(s.regionID),
+		PostCode:                    nullableString(s.postCode),
+		ElectricBikeSurchargeWaiver: s.surchargeWaiver,
+		IsCharging:                  s.isCharging,
+	}
+
+	if s.hasKiosk.Valid {
+		metadata.HasKiosk = &s.hasKiosk.Bool
+	}
+
+	err := json.Unmarshal([]byte(s.rentalMethods), &metadata.RentalMethods)
+	return metadata, err
+}
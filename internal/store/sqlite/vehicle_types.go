@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+type vehicleTypes struct {
+	q querier
+}
+
+func (r vehicleTypes) FindOrCreate(ctx context.Context, externalID string) (int64, error) {
+	var id int64
+	err := r.q.QueryRowContext(
+		ctx,
+		`INSERT INTO "vehicle_type" ("external_id") VALUES (?)
+		ON CONFLICT ("external_id") DO UPDATE SET "external_id" = excluded."external_id"
+		RETURNING "id"`,
+		externalID,
+	).Scan(&id)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to getsert vehicle type: %w", err)
+	}
+
+	return id, nil
+}
+
+func (r vehicleTypes) Upsert(ctx context.Context, vehicleType store.VehicleType) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO "vehicle_type" (
+			"external_id",
+			"form_factor",
+			"propulsion_type",
+			"name",
+			"max_range_meters"
+		) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT ("external_id") DO UPDATE SET
+			"form_factor" = excluded."form_factor",
+			"propulsion_type" = excluded."propulsion_type",
+			"name" = excluded."name",
+			"max_range_meters" = excluded."max_range_meters"`,
+		vehicleType.ExternalID,
+		vehicleType.FormFactor,
+		vehicleType.PropulsionType,
+		vehicleType.Name,
+		vehicleType.MaxRangeMeters,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert vehicle type: %w", err)
+	}
+
+	return nil
+}
+
+func (r vehicleTypes) List(ctx context.Context) ([]store.VehicleType, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			"id",
+			"external_id",
+			"form_factor",
+			"propulsion_type",
+			"name",
+			"max_range_meters"
+		FROM "vehicle_type"
+		ORDER BY "id"`,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vehicle types: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := []store.VehicleType{}
+
+	for rows.Next() {
+		vehicleType := store.VehicleType{}
+		var formFactor, propulsionType, name sql.NullString
+		var maxRange sql.NullFloat64
+
+		err := rows.Scan(&vehicleType.ID, &vehicleType.ExternalID, &formFactor, &propulsionType, &name, &maxRange)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query vehicle types: %w", err)
+		}
+
+		vehicleType.FormFactor = nullableString(formFactor)
+		vehicleType.PropulsionType = nullableString(propulsionType)
+		vehicleType.Name = nullableString(name)
+
+		if maxRange.Valid {
+			vehicleType.MaxRangeMeters = &maxRange.Float64
+		}
+
+		result = append(result, vehicleType)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query vehicle types: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,789 @@
+// Package store defines the storage used by the sync loops and the API, so
+// that they do not depend on a particular database. The postgres package
+// implements it on TimescaleDB/PostGIS, the sqlite package on an embedded
+// SQLite database for single-process deployments, and the memory package in
+// memory for tests.
+package store
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrUnsupported = errors.New("not supported by this store")
+)
+
+// Store gives access to every repository
+type Store interface {
+	Stations() StationRepository
+	Availability() AvailabilityRepository
+	Quality() QualityRepository
+	SyncState() SyncStateRepository
+	Retention() RetentionRepository
+	Regions() RegionRepository
+	Alerts() AlertRepository
+	VehicleTypes() VehicleTypeRepository
+	PricingPlans() PricingPlanRepository
+	GeofencingZones() GeofencingZoneRepository
+
+	// InTx runs fn against a Store bound to a single transaction, which is
+	// committed if fn succeeds and rolled back otherwise
+	InTx(ctx context.Context, fn func(tx Store) error) error
+
+	Ping(ctx context.Context) error
+
+	// SchemaVersion is the version of the applied schema, ErrUnsupported if
+	// the store has no schema migrations
+	SchemaVersion(ctx context.Context) (int32, error)
+}
+
+// KnownStation is what we know of a station before storing its status
+type KnownStation struct {
+	ID                 int64
+	LastStatusReported *time.Time // nil until a status has been stored
+	Listed             bool       // present in station_information
+	Capacity           *int64     // as of the last station_information sync
+}
+
+// StationInformation is the description of a station, from the
+// station_information feed
+type StationInformation struct {
+	ExternalID string // GBFS station_id
+	Name       string
+	ShortName  *string
+	Lon        float64
+	Lat        float64
+	Capacity   *int64
+	Metadata   StationMetadata
+}
+
+// StationMetadata is the rest of a station's description. Only its latest
+// value is kept: changes do not start a new version, except for the ebike
+// surcharge waiver, which is versioned to compare stations with and without
+// it over time.
+type StationMetadata struct {
+	Address                     *string
+	CrossStreet                 *string
+	RegionID                    *string
+	PostCode                    *string
+	RentalMethods               []string // GBFS rental methods, e.g. KEY or CREDITCARD
+	HasKiosk                    *bool
+	ElectricBikeSurchargeWaiver bool
+	IsCharging                  bool
+}
+
+// Offers reports whether method is one of the rental methods, compared
+// case-insensitively
+func (m StationMetadata) Offers(method string) bool {
+	for _, offered := range m.RentalMethods {
+		if strings.EqualFold(offered, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type StationSummary struct {
+	ID             int64
+	ExternalID     string // GBFS station_id
+	Name           string
+	ShortName      *string
+	Lon            float64
+	Lat            float64
+	Active         bool
+	Decommissioned bool // missing from station_information since its last version
+	Metadata       StationMetadata
+}
+
+// StationFilter selects the stations returned by List. Stations are active if
+// they reported their status since ActiveSince.
+type StationFilter struct {
+	ActiveSince  time.Time
+	RentalMethod string // any if empty
+	HasKiosk     *bool  // any if nil
+}
+
+// Matches reports whether metadata passes the rental method and kiosk
+// filters
+func (f StationFilter) Matches(metadata StationMetadata) bool {
+	if f.RentalMethod != "" && !metadata.Offers(f.RentalMethod) {
+		return false
+	}
+
+	return f.HasKiosk == nil || (metadata.HasKiosk != nil && *metadata.HasKiosk == *f.HasKiosk)
+}
+
+// StationVersion is the description of a station while it was valid. A
+// station whose latest version has ended was decommissioned.
+type StationVersion struct {
+	ValidFrom time.Time
+	ValidTo   *time.Time // nil for the current version
+	Name      string
+	ShortName *string
+	Lon       float64
+	Lat       float64
+	Capacity  *int64
+
+	ElectricBikeSurchargeWaiver bool
+}
+
+// Describes reports whether station matches this version, locations being
+// stored with 6 decimals
+func (v StationVersion) Describes(station StationInformation) bool {
+	return v.Name == station.Name &&
+		equalPtr(v.ShortName, station.ShortName) &&
+		math.Abs(v.Lon-station.Lon) < 1e-6 &&
+		math.Abs(v.Lat-station.Lat) < 1e-6 &&
+		equalPtr(v.Capacity, station.Capacity) &&
+		v.ElectricBikeSurchargeWaiver == station.Metadata.ElectricBikeSurchargeWaiver
+}
+
+// CapacityAt returns the capacity valid at t according to versions, oldest
+// first, or nil if unknown
+func CapacityAt(versions []StationVersion, t time.Time) *int64 {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !t.Before(versions[i].ValidFrom) {
+			return versions[i].Capacity
+		}
+	}
+
+	return nil
+}
+
+func equalPtr[T comparable](a *T, b *T) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+type StationRepository interface {
+	// FindOrCreate returns the station with the given GBFS station_id,
+	// creating it if it was never seen
+	FindOrCreate(ctx context.Context, externalID string) (*KnownStation, error)
+
+	// Upsert stores the description of a station as of at. A description
+	// which differs from the latest version of the station starts a new
+	// version, which also recommissions a decommissioned station. The
+	// metadata is overwritten either way. Descriptions older than the latest
+	// version are ignored.
+	Upsert(ctx context.Context, station StationInformation, at time.Time) error
+
+	// Decommission ends, as of at, the current version of the stations
+	// missing from listed (GBFS station_ids), returning how many were
+	// decommissioned
+	Decommission(ctx context.Context, listed []string, at time.Time) (int64, error)
+
+	// History returns the versions of a station, oldest first
+	History(ctx context.Context, id int64) ([]StationVersion, error)
+
+	// SetLastReported records the last_reported time of the latest status
+	// stored for a station
+	SetLastReported(ctx context.Context, id int64, reported time.Time) error
+
+	// List returns the stations with a known location matching filter
+	List(ctx context.Context, filter StationFilter) ([]StationSummary, error)
+
+	// Get returns the current description of a station. Name and location
+	// are zero until it is listed in station_information.
+	Get(ctx context.Context, id int64) (*StationInformation, error)
+}
+
+// Availability is the status of a station at one point in time. Bikes do not
+// include ebikes.
+type Availability struct {
+	Time            time.Time
+	StationID       int64
+	BikesAvailable  int64
+	BikesDisabled   *int64
+	EbikesAvailable *int64
+	EbikesDisabled  *int64
+	DocksAvailable  int64
+	DocksDisabled   *int64
+	Vehicles        []VehicleAvailability // by vehicle type id, empty unless the operator publishes vehicle_types_available
+}
+
+// VehicleAvailability counts the vehicles of one type available at a station
+type VehicleAvailability struct {
+	VehicleTypeID int64
+	Available     int64
+}
+
+// AverageAvailability is the availability of a station averaged within a
+// time bucket
+type AverageAvailability struct {
+	Time            time.Time
+	BikesAvailable  float64
+	EbikesAvailable float64
+	Vehicles        []AverageVehicleAvailability // by vehicle type id
+}
+
+// AverageVehicleAvailability is the number of vehicles of one type averaged
+// over the statuses of a time bucket which counted them
+type AverageVehicleAvailability struct {
+	VehicleTypeID int64
+	Available     float64
+}
+
+// Heatmap shapes and metrics supported by HeatmapQuery
+var (
+	HeatmapShapes  = []string{"hex", "square"}
+	HeatmapMetrics = []string{"bikes", "ebikes", "docks", "departures"}
+)
+
+type HeatmapQuery struct {
+	Shape    string
+	Metric   string
+	CellSize float64 // meters, at the center of the stations
+	From     time.Time
+	To       time.Time
+}
+
+// HeatmapCell is a grid cell containing at least one station
+type HeatmapCell struct {
+	Geometry json.RawMessage // GeoJSON polygon
+	Value    float64         // sum of the metric over the stations in the cell
+	Stations int64
+}
+
+type AvailabilityRepository interface {
+	Insert(ctx context.Context, availability Availability) error
+
+	// Latest returns the most recent availability of a station
+	Latest(ctx context.Context, stationID int64) (*Availability, error)
+
+	// History returns the availability of a station averaged over buckets of
+	// the given width, oldest first
+	History(ctx context.Context, stationID int64, from time.Time, to time.Time, bucket time.Duration) ([]AverageAvailability, error)
+
+	// AggregateLag returns the start of the latest aggregated bucket, and how
+	// far it lags behind the latest availability
+	AggregateLag(ctx context.Context) (time.Time, time.Duration, error)
+
+	Heatmap(ctx context.Context, query HeatmapQuery) ([]HeatmapCell, error)
+
+	// EbikeDepletion compares the stations with and without the ebike
+	// surcharge waiver between from and to, each station being counted in
+	// the group of the version valid at the time. Groups without ebike
+	// counts are omitted.
+	EbikeDepletion(ctx context.Context, from time.Time, to time.Time) ([]EbikeDepletion, error)
+}
+
+// EbikeDepletion is how fast ebikes leave a group of stations. Departures are
+// inferred from drops in the number of ebikes between two consecutive 5
+// minute buckets, like the departures heatmap.
+type EbikeDepletion struct {
+	Waiver          bool
+	Stations        int64
+	EbikesAvailable float64 // average per station
+	Departures      float64 // per station and hour
+}
+
+// QualityIssue is a feed record which broke a validation rule
+type QualityIssue struct {
+	Time              time.Time
+	Feed              string
+	SnapshotUpdated   time.Time
+	StationExternalID string
+	Rule              string
+	Message           string
+	Quarantined       bool
+	Record            []byte // JSON
+}
+
+type QualityFilter struct {
+	Since     time.Time
+	StationID string // GBFS station_id, any if empty
+	Rule      string // any if empty
+	Limit     int
+}
+
+type QualityRepository interface {
+	Record(ctx context.Context, issue QualityIssue) error
+
+	// List returns matching issues, most recent first
+	List(ctx context.Context, filter QualityFilter) ([]QualityIssue, error)
+}
+
+type SyncState struct {
+	Feed        string
+	LastSuccess time.Time
+	LastUpdated *time.Time
+}
+
+type SyncStateRepository interface {
+	// Record marks feed as successfully synced
+	Record(ctx context.Context, feed string, lastUpdated time.Time, syncedAt time.Time) error
+
+	Get(ctx context.Context, feed string) (*SyncState, error)
+}
+
+// RetentionPolicy sets how long a relation (hypertable or continuous
+// aggregate) keeps its data, and after how long its chunks are compressed. A
+// zero duration keeps data forever, or never compresses it.
+type RetentionPolicy struct {
+	Relation      string
+	DropAfter     time.Duration
+	CompressAfter time.Duration
+}
+
+// RetentionStatus is the policy in effect on a relation, and the state of
+// its chunks
+type RetentionStatus struct {
+	RetentionPolicy
+	CompressionEnabled bool
+	Chunks             int64
+	CompressedChunks   int64
+	Oldest             *time.Time // start of the oldest chunk, nil without data
+}
+
+type RetentionRepository interface {
+	// Apply sets the policies of each relation, leaving those already in
+	// effect untouched
+	Apply(ctx context.Context, policies []RetentionPolicy) error
+
+	// List returns the policies in effect, by relation name
+	List(ctx context.Context) ([]RetentionStatus, error)
+}
+
+// Region groups stations, either a region of the system_regions feed, which
+// its stations reference by region_id, or a custom polygon containing them
+type Region struct {
+	ID         int64
+	ExternalID *string // GBFS region_id, nil for custom regions
+	Name       string
+	Stations   int64 // stations in service within the region
+}
+
+// RegionAvailability sums the average availability of a region's stations
+// within a time bucket
+type RegionAvailability struct {
+	Time            time.Time
+	Stations        int64 // stations with data in the bucket
+	BikesAvailable  float64
+	EbikesAvailable float64
+	DocksAvailable  float64
+}
+
+type RegionRepository interface {
+	// Upsert stores a region of the system_regions feed, by GBFS region_id
+	Upsert(ctx context.Context, externalID string, name string) error
+
+	// Create stores a custom region bounded by a GeoJSON Polygon or
+	// MultiPolygon geometry, returning its id. ErrUnsupported without PostGIS.
+	Create(ctx context.Context, name string, geometry []byte) (int64, error)
+
+	// Delete removes a custom region, ErrNotFound if there is no custom
+	// region with this id
+	Delete(ctx context.Context, id int64) error
+
+	// List returns every region, ordered by id
+	List(ctx context.Context) ([]Region, error)
+
+	// Availability returns the availability of the stations currently within
+	// a region, in buckets of the given width. ErrNotFound for unknown
+	// regions.
+	Availability(ctx context.Context, id int64, from time.Time, to time.Time, bucket time.Duration) ([]RegionAvailability, error)
+}
+
+// Alert is an announcement of the system_alerts feed, such as a planned
+// station closure
+type Alert struct {
+	ExternalID  string // GBFS alert_id
+	Type        string // SYSTEM_CLOSURE, STATION_CLOSURE, STATION_MOVE or OTHER
+	Times       []AlertWindow
+	StationIDs  []string // GBFS station_ids, empty unless the alert is limited to them
+	RegionIDs   []string // GBFS region_ids, empty unless the alert is limited to them
+	URL         *string
+	Summary     string
+	Description *string
+	LastUpdated *time.Time
+	Withdrawn   *time.Time // when the alert left system_alerts, nil while published
+}
+
+// AlertWindow is a period an alert is in effect, open-ended if End is nil.
+// Stores keep it as JSON, in unix seconds.
+type AlertWindow struct {
+	Start time.Time
+	End   *time.Time
+}
+
+type alertWindowJSON struct {
+	Start int64  `json:"start"`
+	End   *int64 `json:"end"`
+}
+
+func (w AlertWindow) MarshalJSON() ([]byte, error) {
+	encoded := alertWindowJSON{Start: w.Start.Unix()}
+
+	if w.End != nil {
+		end := w.End.Unix()
+		encoded.End = &end
+	}
+
+	return json.Marshal(encoded)
+}
+
+func (w *AlertWindow) UnmarshalJSON(data []byte) error {
+	decoded := alertWindowJSON{}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*w = AlertWindow{Start: time.Unix(decoded.Start, 0)}
+
+	if decoded.End != nil {
+		end := time.Unix(*decoded.End, 0)
+		w.End = &end
+	}
+
+	return nil
+}
+
+// ActiveAt reports whether the alert is published and in effect at t. An
+// alert without times is in effect as long as it is published.
+func (a Alert) ActiveAt(t time.Time) bool {
+	if a.Withdrawn != nil && !t.Before(*a.Withdrawn) {
+		return false
+	}
+
+	if len(a.Times) == 0 {
+		return true
+	}
+
+	for _, window := range a.Times {
+		if !t.Before(window.Start) && (window.End == nil || t.Before(*window.End)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Affects reports whether the alert concerns a station, given its GBFS
+// station_id and region_id. Alerts limited to neither stations nor regions
+// concern the whole system.
+func (a Alert) Affects(stationID string, regionID *string) bool {
+	if len(a.StationIDs) == 0 && len(a.RegionIDs) == 0 {
+		return true
+	}
+
+	return slices.Contains(a.StationIDs, stationID) || (regionID != nil && slices.Contains(a.RegionIDs, *regionID))
+}
+
+type AlertRepository interface {
+	// Upsert stores an alert published in system_alerts, republishing it if
+	// it was withdrawn
+	Upsert(ctx context.Context, alert Alert) error
+
+	// Withdraw marks the published alerts missing from listed (GBFS
+	// alert_ids) as withdrawn at at, returning how many were withdrawn
+	Withdraw(ctx context.Context, listed []string, at time.Time) (int64, error)
+
+	// List returns the alerts still published at since, by alert_id
+	List(ctx context.Context, since time.Time) ([]Alert, error)
+}
+
+// VehicleType is a kind of vehicle of the vehicle_types feed, which
+// vehicle_types_available counts at each station
+type VehicleType struct {
+	ID             int64
+	ExternalID     string  // GBFS vehicle_type_id
+	FormFactor     *string // bicycle, cargo_bicycle, car, moped, scooter or other, nil until listed in vehicle_types
+	PropulsionType *string // human, electric_assist, electric or combustion, nil until listed in vehicle_types
+	Name           *string
+	MaxRangeMeters *float64
+}
+
+type VehicleTypeRepository interface {
+	// FindOrCreate returns the id of the vehicle type with the given GBFS
+	// vehicle_type_id, creating it if it was never seen
+	FindOrCreate(ctx context.Context, externalID string) (int64, error)
+
+	// Upsert stores the description of a vehicle type listed in
+	// vehicle_types
+	Upsert(ctx context.Context, vehicleType VehicleType) error
+
+	// List returns every vehicle type, ordered by id
+	List(ctx context.Context) ([]VehicleType, error)
+}
+
+// PricingPlan is a version of a plan of the system_pricing_plans feed, valid
+// from ValidFrom until ValidTo (excluded). A plan whose latest version has
+// ended was withdrawn from the feed.
+type PricingPlan struct {
+	PlanID        string // GBFS plan_id
+	ValidFrom     time.Time
+	ValidTo       *time.Time // nil for the current version
+	Name          string
+	Currency      string
+	Price         float64
+	IsTaxable     bool
+	Description   string
+	URL           *string
+	PerKmPricing  []PricingSegment
+	PerMinPricing []PricingSegment
+}
+
+// PricingSegment charges Rate every Interval (km or minutes) from Start until
+// End, or the end of the trip if nil. Stores keep it as JSON.
+type PricingSegment struct {
+	Start    int64   `json:"start"`
+	Rate     float64 `json:"rate"`
+	Interval int64   `json:"interval"`
+	End      *int64  `json:"end"`
+}
+
+// Describes reports whether plan matches this version, ignoring validity
+func (p PricingPlan) Describes(plan PricingPlan) bool {
+	return p.PlanID == plan.PlanID &&
+		p.Name == plan.Name &&
+		p.Currency == plan.Currency &&
+		p.Price == plan.Price &&
+		p.IsTaxable == plan.IsTaxable &&
+		p.Description == plan.Description &&
+		equalPtr(p.URL, plan.URL) &&
+		slices.EqualFunc(p.PerKmPricing, plan.PerKmPricing, PricingSegment.equal) &&
+		slices.EqualFunc(p.PerMinPricing, plan.PerMinPricing, PricingSegment.equal)
+}
+
+func (s PricingSegment) equal(other PricingSegment) bool {
+	return s.Start == other.Start && s.Rate == other.Rate && s.Interval == other.Interval && equalPtr(s.End, other.End)
+}
+
+type PricingPlanRepository interface {
+	// Upsert stores a plan listed in system_pricing_plans as of at. A plan
+	// which differs from its current version starts a new version. Plans
+	// older than the latest version are ignored.
+	Upsert(ctx context.Context, plan PricingPlan, at time.Time) error
+
+	// Withdraw ends, as of at, the current version of the plans missing from
+	// listed (GBFS plan_ids), returning how many were withdrawn
+	Withdraw(ctx context.Context, listed []string, at time.Time) (int64, error)
+
+	// List returns the versions valid at some point between from and to, by
+	// plan_id then valid_from
+	List(ctx context.Context, from time.Time, to time.Time) ([]PricingPlan, error)
+}
+
+// GeofencingZone is an area of the geofencing_zones feed restricting where
+// vehicles may ride or park. Where zones overlap, the first one listed in the
+// feed takes precedence.
+type GeofencingZone struct {
+	Name     *string
+	Start    *time.Time      // in effect from, always if nil
+	End      *time.Time      // in effect until, always if nil
+	Geometry json.RawMessage // GeoJSON MultiPolygon
+	Rules    []GeofencingRule
+}
+
+// GeofencingRule restricts the vehicles of the listed types, or every vehicle
+// if none is listed. Stores keep it as JSON.
+type GeofencingRule struct {
+	VehicleTypeIDs     []string `json:"vehicle_type_ids"` // GBFS vehicle_type_ids
+	RideAllowed        bool     `json:"ride_allowed"`
+	RideThroughAllowed bool     `json:"ride_through_allowed"`
+	MaximumSpeedKph    *int64   `json:"maximum_speed_kph"`
+	StationParking     *bool    `json:"station_parking"`
+}
+
+// ActiveAt reports whether the zone is in effect at t
+func (z GeofencingZone) ActiveAt(t time.Time) bool {
+	return (z.Start == nil || !t.Before(*z.Start)) && (z.End == nil || t.Before(*z.End))
+}
+
+// RuleFor returns the first rule of the zone applying to a vehicle type, nil
+// if none does. An empty vehicleTypeID only matches rules applying to every
+// vehicle.
+func (z GeofencingZone) RuleFor(vehicleTypeID string) *GeofencingRule {
+	for i, rule := range z.Rules {
+		if len(rule.VehicleTypeIDs) == 0 || (vehicleTypeID != "" && slices.Contains(rule.VehicleTypeIDs, vehicleTypeID)) {
+			return &z.Rules[i]
+		}
+	}
+
+	return nil
+}
+
+type GeofencingZoneRepository interface {
+	// Replace swaps every zone for those of the latest geofencing_zones, in
+	// the order of the feed. Geometries must be MultiPolygons.
+	Replace(ctx context.Context, zones []GeofencingZone) error
+
+	// List returns every zone, by precedence
+	List(ctx context.Context) ([]GeofencingZone, error)
+
+	// At returns the zones containing a point, by precedence
+	At(ctx context.Context, lon float64, lat float64) ([]GeofencingZone, error)
+
+	// Tile renders the zones intersecting a web mercator tile as a Mapbox
+	// Vector Tile, in the "geofencing_zones" layer. ErrUnsupported without
+	// PostGIS.
+	Tile(ctx context.Context, z int, x int, y int) ([]byte, error)
+}
+
+type multiPolygon struct {
+	Type        string           `json:"type"`
+	Coordinates [][][][2]float64 `json:"coordinates"`
+}
+
+// MultiPolygon validates a GeoJSON Polygon or MultiPolygon geometry,
+// returning it as a MultiPolygon
+func MultiPolygon(geometry []byte) (json.RawMessage, error) {
+	decoded := struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}{}
+
+	if err := json.Unmarshal(geometry, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode geometry: %w", err)
+	}
+
+	result := multiPolygon{Type: "MultiPolygon"}
+
+	switch decoded.Type {
+	case "Polygon":
+		polygon := [][][2]float64{}
+
+		if err := json.Unmarshal(decoded.Coordinates, &polygon); err != nil {
+			return nil, fmt.Errorf("failed to decode polygon: %w", err)
+		}
+
+		result.Coordinates = [][][][2]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(decoded.Coordinates, &result.Coordinates); err != nil {
+			return nil, fmt.Errorf("failed to decode multipolygon: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported geometry %q: expected Polygon or MultiPolygon", decoded.Type)
+	}
+
+	for _, polygon := range result.Coordinates {
+		for _, ring := range polygon {
+			if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+				return nil, errors.New("invalid geometry: rings must be closed and have at least 4 positions")
+			}
+		}
+	}
+
+	return json.Marshal(result)
+}
+
+// Contains reports whether a GeoJSON MultiPolygon contains a point, for
+// stores without PostGIS. Points on an edge may fall either way.
+func Contains(geometry json.RawMessage, lon float64, lat float64) (bool, error) {
+	decoded := multiPolygon{}
+
+	if err := json.Unmarshal(geometry, &decoded); err != nil {
+		return false, fmt.Errorf("failed to decode geometry: %w", err)
+	}
+
+	for _, polygon := range decoded.Coordinates {
+		// The first ring is the exterior, the others are holes
+		inside := false
+
+		for i, ring := range polygon {
+			if ringContains(ring, lon, lat) != (i == 0) {
+				inside = false
+				break
+			}
+
+			inside = true
+		}
+
+		if inside {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ringContains casts a ray from the point, which is within the ring if it
+// crosses an odd number of edges
+func ringContains(ring [][2]float64, lon float64, lat float64) bool {
+	inside := false
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+
+		if (a[1] > lat) != (b[1] > lat) && lon < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// earthRadius is the radius of the web mercator sphere, in meters
+const earthRadius = 6378137.0
+
+// HeatmapPoint is the value of the heatmap metric at a station
+type HeatmapPoint struct {
+	Lon, Lat float64
+	Value    float64
+}
+
+// SquareGrid sums points onto square cells like PostGIS's ST_SquareGrid, for
+// stores without PostGIS. Cells are aligned on web mercator, which stretches
+// distances by 1/cos(latitude): they measure cellSize meters at centerLat. A
+// point on the edge between cells is counted in the one with the lowest
+// indices.
+func SquareGrid(points []HeatmapPoint, cellSize float64, centerLat float64) []HeatmapCell {
+	size := cellSize / math.Cos(centerLat*math.Pi/180)
+
+	type index struct{ i, j int64 }
+	sums := map[index]*HeatmapCell{}
+	indices := []index{}
+
+	for _, point := range points {
+		x := earthRadius * point.Lon * math.Pi / 180
+		y := earthRadius * math.Log(math.Tan(math.Pi/4+point.Lat*math.Pi/360))
+		key := index{int64(math.Ceil(x/size)) - 1, int64(math.Ceil(y/size)) - 1}
+
+		if sums[key] == nil {
+			sums[key] = &HeatmapCell{}
+			indices = append(indices, key)
+		}
+
+		sums[key].Value += point.Value
+		sums[key].Stations++
+	}
+
+	slices.SortFunc(indices, func(a, b index) int {
+		return cmp.Or(cmp.Compare(a.j, b.j), cmp.Compare(a.i, b.i))
+	})
+
+	lon := func(x float64) float64 { return x / earthRadius * 180 / math.Pi }
+	lat := func(y float64) float64 { return (2*math.Atan(math.Exp(y/earthRadius)) - math.Pi/2) * 180 / math.Pi }
+	cells := []HeatmapCell{}
+
+	for _, key := range indices {
+		x0, y0 := float64(key.i)*size, float64(key.j)*size
+		x1, y1 := x0+size, y0+size
+
+		polygon, _ := json.Marshal(struct {
+			Type        string         `json:"type"`
+			Coordinates [][][2]float64 `json:"coordinates"`
+		}{
+			Type: "Polygon",
+			Coordinates: [][][2]float64{{
+				{lon(x0), lat(y0)}, {lon(x1), lat(y0)}, {lon(x1), lat(y1)}, {lon(x0), lat(y1)}, {lon(x0), lat(y0)},
+			}},
+		})
+
+		cell := sums[key]
+		cell.Geometry = polygon
+		cells = append(cells, *cell)
+	}
+
+	return cells
+}
@@ -0,0 +1,1091 @@
+// Package storetest checks that store.Store implementations behave alike
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+// Factory returns an empty store, along with a function materializing the
+// historical aggregate for stores which do not maintain it as rows are
+// inserted (nil otherwise)
+type Factory func(t *testing.T) (store.Store, func(ctx context.Context) error)
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+// Run runs every conformance test against the stores built by factory
+func Run(t *testing.T, factory Factory) {
+	tests := map[string]func(t *testing.T, factory Factory){
+		"Stations":        testStations,
+		"Availability":    testAvailability,
+		"Quality":         testQuality,
+		"SyncState":       testSyncState,
+		"InTx":            testInTx,
+		"StationHistory":  testStationHistory,
+		"StationMetadata": testStationMetadata,
+		"Regions":         testRegions,
+		"Alerts":          testAlerts,
+		"VehicleTypes":    testVehicleTypes,
+		"PricingPlans":    testPricingPlans,
+		"EbikeDepletion":  testEbikeDepletion,
+		"Heatmap":         testHeatmap,
+		"GeofencingZones": testGeofencingZones,
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) { test(t, factory) })
+	}
+}
+
+func testStations(t *testing.T, factory Factory) {
+	st, _ := factory(t)
+	ctx := context.Background()
+
+	unlisted, err := st.Stations().FindOrCreate(ctx, "1")
+
+	if err != nil {
+		t.Fatalf("FindOrCreate() error = %v", err)
+	}
+
+	if unlisted.Listed || unlisted.LastStatusReported != nil || unlisted.Capacity != nil {
+		t.Errorf("FindOrCreate() = %+v, want a new unlisted station", unlisted)
+	}
+
+	err = st.Stations().Upsert(ctx, store.StationInformation{ExternalID: "1", Name: "Station 1", ShortName: ptr("6001"), Lon: -73.57, Lat: 45.5, Capacity: ptr[int64](20)}, time.Now())
+
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	reported := time.Unix(1717243200, 0)
+
+	if err := st.Stations().SetLastReported(ctx, unlisted.ID, reported); err != nil {
+		t.Fatalf("SetLastReported() error = %v", err)
+	}
+
+	known, err := st.Stations().FindOrCreate(ctx, "1")
+
+	if err != nil || known.ID != unlisted.ID || !known.Listed || known.Capacity == nil || *known.Capacity != 20 || known.LastStatusReported == nil || !known.LastStatusReported.Equal(reported) {
+		t.Errorf("FindOrCreate() = %+v, %v, want station %d listed with capacity 20, reported at %s", known, err, unlisted.ID, reported)
+	}
+
+	second, err := st.Stations().FindOrCreate(ctx, "2")
+
+	if err != nil {
+		t.Fatalf("FindOrCreate() error = %v", err)
+	}
+
+	stations, err := st.Stations().List(ctx, store.StationFilter{ActiveSince: time.Now().Add(-time.Hour)})
+
+	if err != nil || len(stations) != 1 || stations[0].ExternalID != "1" || stations[0].Name != "Station 1" || stations[0].Lon != -73.57 || stations[0].Lat != 45.5 || stations[0].Active {
+		t.Errorf("List() = %+v, %v, want only the inactive listed station", stations, err)
+	}
+
+	if station, err := st.Stations().Get(ctx, known.ID); err != nil || station.Name != "Station 1" || station.Capacity == nil || *station.Capacity != 20 {
+		t.Errorf("Get() = %+v, %v, want Station 1 with capacity 20", station, err)
+	}
+
+	if station, err := st.Stations().Get(ctx, second.ID); err != nil || station.ExternalID != "2" || station.Name != "" {
+		t.Errorf("Get() of unlisted station = %+v, %v, want station 2 without description", station, err)
+	}
+
+	if _, err := st.Stations().Get(ctx, known.ID+1000); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get() of unknown station error = %v, want ErrNotFound", err)
+	}
+}
+
+func testStationMetadata(t *testing.T, factory Factory) {
+	st, _ := factory(t)
+	ctx := context.Background()
+	start := time.Unix(1717243200, 0)
+
+	upsert := func(id string, at time.Time, metadata store.StationMetadata) {
+		t.Helper()
+		err := st.Stations().Upsert(ctx, store.StationInformation{ExternalID: id, Name: "Station " + id, Lon: -73.57, Lat: 45.5, Metadata: metadata}, at)
+
+		if err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+	}
+
+	kiosk := store.StationMetadata{
+		Address:                     ptr("Rue Sainte-Catherine / Rue Peel"),
+		RegionID:                    ptr("1"),
+		RentalMethods:               []string{"KEY", "CREDITCARD"},
+		HasKiosk:                    ptr(true),
+		ElectricBikeSurchargeWaiver: true,
+	}
+
+	upsert("1", start, kiosk)
+	upsert("2", start, store.StationMetadata{RentalMethods: []string{"KEY"}, HasKiosk: ptr(false)})
+	upsert("3", start, store.StationMetadata{})
+
+	// Metadata is overwritten without starting a new version
+	kiosk.CrossStreet = ptr("Rue Peel")
+	kiosk.IsCharging = true
+	upsert("1", start.Add(time.Hour), kiosk)
+
+	stations, err := st.Stations().List(ctx, store.StationFilter{})
+
+	if err != nil || len(stations) != 3 {
+		t.Fatalf("List() = %+v, %v, want 3 stations", stations, err)
+	}
+
+	got := map[string]store.StationMetadata{}
+
+	for _, station := range stations {
+		got[station.Name] = station.Metadata
+	}
+
+	if m := got["Station 1"]; m.Address == nil || *m.Address != *kiosk.Address || m.CrossStreet == nil || *m.CrossStreet != "Rue Peel" || m.RegionID == nil || *m.RegionID != "1" || m.PostCode != nil || len(m.RentalMethods) != 2 || m.HasKiosk == nil || !*m.HasKiosk || !m.ElectricBikeSurchargeWaiver || !m.IsCharging {
+		t.Errorf("List() metadata of station 1 = %+v, want %+v", m, kiosk)
+	}
+
+	if m := got["Station 3"]; m.HasKiosk != nil || len(m.RentalMethods) != 0 {
+		t.Errorf("List() metadata of station 3 = %+v, want none", m)
+	}
+
+	id := stations[0].ID
+
+	for _, station := range stations {
+		if station.Name == "Station 1" {
+			id = station.ID
+		}
+	}
+
+	if versions, err := st.Stations().History(ctx, id); err != nil || len(versions) != 1 {
+		t.Errorf("History() = %+v, %v, want a single version", versions, err)
+	}
+
+	if station, err := st.Stations().Get(ctx, id); err != nil || station.Metadata.CrossStreet == nil || !station.Metadata.Offers("creditcard") {
+		t.Errorf("Get() = %+v, %v, want the latest metadata", station, err)
+	}
+
+	filters := []struct {
+		filter store.StationFilter
+		want   []string
+	}{
+		{filter: store.StationFilter{RentalMethod: "key"}, want: []string{"Station 1", "Station 2"}},
+		{filter: store.StationFilter{RentalMethod: "CREDITCARD"}, want: []string{"Station 1"}},
+		{filter: store.StationFilter{RentalMethod: "PHONE"}, want: []string{}},
+		{filter: store.StationFilter{HasKiosk: ptr(true)}, want: []string{"Station 1"}},
+		{filter: store.StationFilter{HasKiosk: ptr(false)}, want: []string{"Station 2"}},
+		{filter: store.StationFilter{RentalMethod: "KEY", HasKiosk: ptr(false)}, want: []string{"Station 2"}},
+	}
+
+	for _, tt := range filters {
+		stations, err := st.Stations().List(ctx, tt.filter)
+		names := []string{}
+
+		for _, station := range stations {
+			names = append(names, station.Name)
+		}
+
+		slices.Sort(names)
+
+		if err != nil || !slices.Equal(names, tt.want) {
+			t.Errorf("List(%+v) = %v, %v, want %v", tt.filter, names, err, tt.want)
+		}
+	}
+
+	// Unlike the rest of the metadata, the surcharge waiver is versioned
+	kiosk.ElectricBikeSurchargeWaiver = false
+	upsert("1", start.Add(2*time.Hour), kiosk)
+
+	versions, err := st.Stations().History(ctx, id)
+
+	if err != nil || len(versions) != 2 || !versions[0].ElectricBikeSurchargeWaiver || versions[1].ElectricBikeSurchargeWaiver || !versions[1].ValidFrom.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("History() = %+v, %v, want the waiver lifted as of %s", versions, err, start.Add(2*time.Hour))
+	}
+}
+
+func testStationHistory(t *testing.T, factory Factory) {
+	st, _ := factory(t)
+	ctx := context.Background()
+	start := time.Unix(1717243200, 0)
+	day := 24 * time.Hour
+
+	upsert := func(id string, at time.Time, lon float64, capacity int64) {
+		t.Helper()
+		err := st.Stations().Upsert(ctx, store.StationInformation{ExternalID: id, Name: "Station " + id, Lon: lon, Lat: 45.5, Capacity: &capacity}, at)
+
+		if err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+	}
+
+	upsert("1", start, -73.57, 20)
+	upsert("1", start.Add(day), -73.57, 20)    // unchanged
+	upsert("1", start.Add(2*day), -73.572, 25) // moved and grown
+	upsert("1", start.Add(day), -73.5, 10)     // older than the latest version
+	upsert("2", start, -73.6, 15)
+
+	station, _ := st.Stations().FindOrCreate(ctx, "1")
+	versions, err := st.Stations().History(ctx, station.ID)
+
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+
+	if len(versions) != 2 || !versions[0].ValidFrom.Equal(start) || versions[0].ValidTo == nil || !versions[0].ValidTo.Equal(start.Add(2*day)) || *versions[0].Capacity != 20 || versions[1].ValidTo != nil || *versions[1].Capacity != 25 || math.Abs(versions[1].Lon+73.572) > 1e-6 {
+		t.Fatalf("History() = %+v, want 20 docks then 25 docks from %s", versions, start.Add(2*day))
+	}
+
+	for _, tt := range []struct {
+		at   time.Time
+		want *int64
+	}{
+		{start.Add(-time.Hour), nil},
+		{start.Add(day), ptr[int64](20)},
+		{start.Add(3 * day), ptr[int64](25)},
+	} {
+		if got := store.CapacityAt(versions, tt.at); (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("CapacityAt(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	decommissioned, err := st.Stations().Decommission(ctx, []string{"1"}, start.Add(3*day))
+
+	if err != nil || decommissioned != 1 {
+		t.Fatalf("Decommission() = %d, %v, want 1", decommissioned, err)
+	}
+
+	stations, _ := st.Stations().List(ctx, store.StationFilter{})
+	decommissionedNames := []string{}
+
+	for _, station := range stations {
+		if station.Decommissioned {
+			decommissionedNames = append(decommissionedNames, station.Name)
+		}
+	}
+
+	if len(stations) != 2 || len(decommissionedNames) != 1 || decommissionedNames[0] != "Station 2" {
+		t.Errorf("List() = %+v, want station 2 decommissioned", stations)
+	}
+
+	// Listed again, the station is recommissioned
+	other, _ := st.Stations().FindOrCreate(ctx, "2")
+	upsert("2", start.Add(4*day), -73.6, 15)
+	versions, _ = st.Stations().History(ctx, other.ID)
+
+	if len(versions) != 2 || versions[0].ValidTo == nil || !versions[0].ValidTo.Equal(start.Add(3*day)) || !versions[1].ValidFrom.Equal(start.Add(4*day)) || versions[1].ValidTo != nil {
+		t.Errorf("History() = %+v, want a gap while decommissioned", versions)
+	}
+}
+
+func testAvailability(t *testing.T, factory Factory) {
+	st, materialize := factory(t)
+	ctx := context.Background()
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	station, err := st.Stations().FindOrCreate(ctx, "1")
+
+	if err != nil {
+		t.Fatalf("FindOrCreate() error = %v", err)
+	}
+
+	if _, err := st.Availability().Latest(ctx, station.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Latest() error = %v, want ErrNotFound", err)
+	}
+
+	if _, _, err := st.Availability().AggregateLag(ctx); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("AggregateLag() error = %v, want ErrNotFound", err)
+	}
+
+	rows := []store.Availability{
+		{Time: start, BikesAvailable: 2, EbikesAvailable: ptr[int64](1), DocksAvailable: 17},
+		{Time: start.Add(5 * time.Minute), BikesAvailable: 4, DocksAvailable: 16},
+		{Time: start.Add(17 * time.Minute), BikesAvailable: 9, EbikesAvailable: ptr[int64](3), BikesDisabled: ptr[int64](1), DocksAvailable: 7},
+	}
+
+	for _, row := range rows {
+		row.StationID = station.ID
+
+		if err := st.Availability().Insert(ctx, row); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	latest, err := st.Availability().Latest(ctx, station.ID)
+
+	if err != nil || !latest.Time.Equal(rows[2].Time) || latest.BikesAvailable != 9 || latest.EbikesAvailable == nil || *latest.EbikesAvailable != 3 || latest.BikesDisabled == nil || *latest.BikesDisabled != 1 || latest.DocksDisabled != nil {
+		t.Errorf("Latest() = %+v, %v, want the last row", latest, err)
+	}
+
+	if materialize != nil {
+		if err := materialize(ctx); err != nil {
+			t.Fatalf("materialize() error = %v", err)
+		}
+	}
+
+	history, err := st.Availability().History(ctx, station.ID, start, start.Add(time.Hour), 15*time.Minute)
+
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+
+	if len(history) != 2 || !history[0].Time.Equal(start) || history[0].BikesAvailable != 3 || history[0].EbikesAvailable != 1 || history[1].BikesAvailable != 9 {
+		t.Errorf("History() = %+v, want buckets averaging 3 then 9 bikes", history)
+	}
+
+	bucket, lag, err := st.Availability().AggregateLag(ctx)
+
+	if err != nil || !bucket.Equal(start.Add(15*time.Minute)) || lag != 2*time.Minute {
+		t.Errorf("AggregateLag() = %s, %s, %v, want %s, 2m", bucket, lag, err, start.Add(15*time.Minute))
+	}
+
+	stations, err := st.Stations().List(ctx, store.StationFilter{ActiveSince: start.Add(10 * time.Minute)})
+
+	if err != nil || len(stations) != 0 {
+		t.Errorf("List() = %+v, %v, want unlisted station hidden", stations, err)
+	}
+}
+
+func testQuality(t *testing.T, factory Factory) {
+	st, _ := factory(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	issues := []store.QualityIssue{
+		{Time: now.Add(-48 * time.Hour), Feed: "station_status", SnapshotUpdated: now, StationExternalID: "1", Rule: "over_capacity", Record: []byte(`{}`)},
+		{Time: now.Add(-time.Hour), Feed: "station_status", SnapshotUpdated: now, StationExternalID: "1", Rule: "negative_count", Quarantined: true, Message: "num_bikes_available is -1", Record: []byte(`{"station_id":"1"}`)},
+		{Time: now, Feed: "station_status", SnapshotUpdated: now, StationExternalID: "2", Rule: "unknown_station", Record: []byte(`{}`)},
+	}
+
+	for _, issue := range issues {
+		if err := st.Quality().Record(ctx, issue); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter store.QualityFilter
+		want   []string
+	}{
+		{"recent", store.QualityFilter{Since: now.Add(-24 * time.Hour), Limit: 10}, []string{"unknown_station", "negative_count"}},
+		{"by station", store.QualityFilter{Since: now.Add(-72 * time.Hour), StationID: "1", Limit: 10}, []string{"negative_count", "over_capacity"}},
+		{"by rule", store.QualityFilter{Since: now.Add(-72 * time.Hour), Rule: "over_capacity", Limit: 10}, []string{"over_capacity"}},
+		{"limited", store.QualityFilter{Since: now.Add(-72 * time.Hour), Limit: 1}, []string{"unknown_station"}},
+	}
+
+	for _, tt := range tests {
+		got, err := st.Quality().List(ctx, tt.filter)
+
+		if err != nil {
+			t.Fatalf("%s: List() error = %v", tt.name, err)
+		}
+
+		rules := []string{}
+
+		for _, issue := range got {
+			rules = append(rules, issue.Rule)
+		}
+
+		if len(rules) != len(tt.want) {
+			t.Errorf("%s: List() = %v, want %v", tt.name, rules, tt.want)
+			continue
+		}
+
+		for i := range rules {
+			if rules[i] != tt.want[i] {
+				t.Errorf("%s: List() = %v, want %v", tt.name, rules, tt.want)
+				break
+			}
+		}
+	}
+
+	got, _ := st.Quality().List(ctx, store.QualityFilter{Since: now.Add(-24 * time.Hour), Rule: "negative_count", Limit: 10})
+
+	// JSONB does not preserve whitespace
+	if len(got) != 1 || !got[0].Quarantined || got[0].Message != issues[1].Message || !got[0].Time.Equal(issues[1].Time) || !got[0].SnapshotUpdated.Equal(now) || strings.ReplaceAll(string(got[0].Record), " ", "") != string(issues[1].Record) {
+		t.Errorf("List() = %+v, want the issue as recorded", got)
+	}
+}
+
+func testSyncState(t *testing.T, factory Factory) {
+	st, _ := factory(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	if _, err := st.SyncState().Get(ctx, "station_status"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+
+	for _, at := range []time.Time{now.Add(-time.Minute), now} {
+		if err := st.SyncState().Record(ctx, "station_status", at.Add(-10*time.Second), at); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	state, err := st.SyncState().Get(ctx, "station_status")
+
+	if err != nil || !state.LastSuccess.Equal(now) || state.LastUpdated == nil || !state.LastUpdated.Equal(now.Add(-10*time.Second)) {
+		t.Errorf("Get() = %+v, %v, want the latest sync", state, err)
+	}
+}
+
+func testInTx(t *testing.T, factory Factory) {
+	st, _ := factory(t)
+	ctx := context.Background()
+	failure := errors.New("failure")
+
+	err := st.InTx(ctx, func(tx store.Store) error {
+		err := tx.Stations().Upsert(ctx, store.StationInformation{ExternalID: "1", Name: "Station 1"}, time.Now())
+
+		if err != nil {
+			return err
+		}
+
+		return failure
+	})
+
+	if !errors.Is(err, failure) {
+		t.Fatalf("InTx() error = %v, want %v", err, failure)
+	}
+
+	if stations, _ := st.Stations().List(ctx, store.StationFilter{}); len(stations) != 0 {
+		t.Errorf("List() = %+v, want rolled back", stations)
+	}
+
+	err = st.InTx(ctx, func(tx store.Store) error {
+		return tx.InTx(ctx, func(tx store.Store) error {
+			return tx.Stations().Upsert(ctx, store.StationInformation{ExternalID: "1", Name: "Station 1"}, time.Now())
+		})
+	})
+
+	if err != nil {
+		t.Fatalf("InTx() error = %v", err)
+	}
+
+	if stations, _ := st.Stations().List(ctx, store.StationFilter{}); len(stations) != 1 {
+		t.Errorf("List() = %+v, want committed", stations)
+	}
+}
+
+func testRegions(t *testing.T, factory Factory) {
+	st, materialize := factory(t)
+	ctx := context.Background()
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	for _, region := range [][2]string{{"1", "Ville-Marie"}, {"2", "Plateau"}, {"1", "Centre-ville"}} {
+		if err := st.Regions().Upsert(ctx, region[0], region[1]); err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+	}
+
+	stations := map[string]int64{}
+
+	for _, station := range []struct {
+		id     string
+		region string
+		lon    float64
+	}{
+		{"a", "1", -73.57},
+		{"b", "1", -73.60},
+		{"c", "2", -73.55},
+		{"d", "1", -73.57},
+	} {
+		err := st.Stations().Upsert(ctx, store.StationInformation{ExternalID: station.id, Name: station.id, Lon: station.lon, Lat: 45.5, Metadata: store.StationMetadata{RegionID: &station.region}}, start)
+
+		if err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+
+		known, _ := st.Stations().FindOrCreate(ctx, station.id)
+		stations[station.id] = known.ID
+	}
+
+	if _, err := st.Stations().Decommission(ctx, []string{"a", "b", "c"}, start); err != nil {
+		t.Fatalf("Decommission() error = %v", err)
+	}
+
+	rows := []store.Availability{
+		{Time: start, StationID: stations["a"], BikesAvailable: 2, EbikesAvailable: ptr[int64](1), DocksAvailable: 10},
+		{Time: start.Add(5 * time.Minute), StationID: stations["a"], BikesAvailable: 4, DocksAvailable: 12},
+		{Time: start.Add(time.Minute), StationID: stations["b"], BikesAvailable: 6, EbikesAvailable: ptr[int64](0), DocksAvailable: 4},
+		{Time: start.Add(20 * time.Minute), StationID: stations["b"], BikesAvailable: 1, DocksAvailable: 9},
+		{Time: start, StationID: stations["c"], BikesAvailable: 5, DocksAvailable: 5},
+	}
+
+	for _, row := range rows {
+		if err := st.Availability().Insert(ctx, row); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	if materialize != nil {
+		if err := materialize(ctx); err != nil {
+			t.Fatalf("materialize() error = %v", err)
+		}
+	}
+
+	regions, err := st.Regions().List(ctx)
+
+	if err != nil || len(regions) != 2 {
+		t.Fatalf("List() = %+v, %v, want 2 regions", regions, err)
+	}
+
+	centre := regions[0]
+
+	if centre.ExternalID == nil || *centre.ExternalID != "1" || centre.Name != "Centre-ville" || centre.Stations != 2 {
+		t.Errorf("List()[0] = %+v, want region 1 renamed, with 2 stations in service", centre)
+	}
+
+	availability, err := st.Regions().Availability(ctx, centre.ID, start, start.Add(time.Hour), 15*time.Minute)
+
+	if err != nil || len(availability) != 2 {
+		t.Fatalf("Availability() = %+v, %v, want 2 buckets", availability, err)
+	}
+
+	if a := availability[0]; !a.Time.Equal(start) || a.Stations != 2 || a.BikesAvailable != 9 || a.EbikesAvailable != 1 || a.DocksAvailable != 15 {
+		t.Errorf("Availability()[0] = %+v, want 2 stations summing 9 bikes, 1 ebike and 15 docks", a)
+	}
+
+	if a := availability[1]; !a.Time.Equal(start.Add(15*time.Minute)) || a.Stations != 1 || a.BikesAvailable != 1 {
+		t.Errorf("Availability()[1] = %+v, want station b alone", a)
+	}
+
+	if _, err := st.Regions().Availability(ctx, centre.ID+1000, start, start.Add(time.Hour), 15*time.Minute); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Availability() of unknown region error = %v, want ErrNotFound", err)
+	}
+
+	if err := st.Regions().Delete(ctx, centre.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete() of a system region error = %v, want ErrNotFound", err)
+	}
+
+	// Contains stations a, d and c
+	polygon := []byte(`{"type": "Polygon", "coordinates": [[[-73.58, 45.49], [-73.54, 45.49], [-73.54, 45.51], [-73.58, 45.51], [-73.58, 45.49]]]}`)
+	id, err := st.Regions().Create(ctx, "Custom", polygon)
+
+	if errors.Is(err, store.ErrUnsupported) {
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	regions, _ = st.Regions().List(ctx)
+
+	if len(regions) != 3 || regions[2].ID != id || regions[2].ExternalID != nil || regions[2].Stations != 2 {
+		t.Errorf("List() = %+v, want custom region %d with 2 stations in service", regions, id)
+	}
+
+	availability, err = st.Regions().Availability(ctx, id, start, start.Add(time.Hour), time.Hour)
+
+	if err != nil || len(availability) != 1 || availability[0].Stations != 2 || availability[0].BikesAvailable != 8 {
+		t.Errorf("Availability() = %+v, %v, want stations a and c summing 8 bikes", availability, err)
+	}
+
+	if err := st.Regions().Delete(ctx, id); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+
+	if err := st.Regions().Delete(ctx, id); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete() of a deleted region error = %v, want ErrNotFound", err)
+	}
+}
+
+func testAlerts(t *testing.T, factory Factory) {
+	st, _ := factory(t)
+	ctx := context.Background()
+	start := time.Unix(1717243200, 0)
+	end := start.Add(4 * time.Hour)
+
+	closure := store.Alert{
+		ExternalID:  "closure",
+		Type:        "STATION_CLOSURE",
+		Times:       []store.AlertWindow{{Start: start, End: &end}, {Start: end.Add(24 * time.Hour)}},
+		StationIDs:  []string{"1", "2"},
+		URL:         ptr("https://example.com/closure"),
+		Summary:     "Closed for maintenance",
+		Description: ptr("Stations 1 and 2 are closed for maintenance"),
+		LastUpdated: &start,
+	}
+	outage := store.Alert{ExternalID: "outage", Type: "SYSTEM_CLOSURE", Summary: "Outage"}
+
+	for _, alert := range []store.Alert{closure, outage} {
+		if err := st.Alerts().Upsert(ctx, alert); err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+	}
+
+	alerts, err := st.Alerts().List(ctx, start)
+
+	if err != nil || len(alerts) != 2 {
+		t.Fatalf("List() = %+v, %v, want 2 alerts", alerts, err)
+	}
+
+	got := alerts[0]
+
+	if got.ExternalID != "closure" || got.Type != closure.Type || got.Summary != closure.Summary ||
+		got.URL == nil || *got.URL != *closure.URL || got.Description == nil || *got.Description != *closure.Description ||
+		got.LastUpdated == nil || !got.LastUpdated.Equal(start) || got.Withdrawn != nil ||
+		!slices.Equal(got.StationIDs, closure.StationIDs) || len(got.RegionIDs) != 0 {
+		t.Errorf("List()[0] = %+v, want %+v", got, closure)
+	}
+
+	if len(got.Times) != 2 || !got.Times[0].Start.Equal(start) || got.Times[0].End == nil || !got.Times[0].End.Equal(end) ||
+		!got.Times[1].Start.Equal(end.Add(24*time.Hour)) || got.Times[1].End != nil {
+		t.Errorf("List()[0].Times = %+v, want %+v", got.Times, closure.Times)
+	}
+
+	if alerts[1].ExternalID != "outage" || alerts[1].URL != nil || alerts[1].LastUpdated != nil || len(alerts[1].Times) != 0 {
+		t.Errorf("List()[1] = %+v, want %+v", alerts[1], outage)
+	}
+
+	withdrawn := start.Add(time.Hour)
+	count, err := st.Alerts().Withdraw(ctx, []string{"outage"}, withdrawn)
+
+	if err != nil || count != 1 {
+		t.Fatalf("Withdraw() = %d, %v, want 1", count, err)
+	}
+
+	count, err = st.Alerts().Withdraw(ctx, []string{"outage"}, withdrawn.Add(time.Hour))
+
+	if err != nil || count != 0 {
+		t.Errorf("Withdraw() of withdrawn alerts = %d, %v, want 0", count, err)
+	}
+
+	alerts, err = st.Alerts().List(ctx, start)
+
+	if err != nil || len(alerts) != 2 || alerts[0].Withdrawn == nil || !alerts[0].Withdrawn.Equal(withdrawn) {
+		t.Errorf("List() before the withdrawal = %+v, %v, want closure withdrawn at %v", alerts, err, withdrawn)
+	}
+
+	alerts, err = st.Alerts().List(ctx, withdrawn)
+
+	if err != nil || len(alerts) != 1 || alerts[0].ExternalID != "outage" {
+		t.Errorf("List() after the withdrawal = %+v, %v, want only outage", alerts, err)
+	}
+
+	closure.Summary = "Closed until further notice"
+
+	if err := st.Alerts().Upsert(ctx, closure); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	alerts, err = st.Alerts().List(ctx, withdrawn)
+
+	if err != nil || len(alerts) != 2 || alerts[0].Withdrawn != nil || alerts[0].Summary != closure.Summary {
+		t.Errorf("List() after republishing = %+v, %v, want closure published again", alerts, err)
+	}
+}
+
+func testVehicleTypes(t *testing.T, factory Factory) {
+	st, materialize := factory(t)
+	ctx := context.Background()
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	scooter, err := st.VehicleTypes().FindOrCreate(ctx, "scooter")
+
+	if err != nil {
+		t.Fatalf("FindOrCreate() error = %v", err)
+	}
+
+	if again, err := st.VehicleTypes().FindOrCreate(ctx, "scooter"); err != nil || again != scooter {
+		t.Errorf("FindOrCreate() of a known vehicle type = %d, %v, want %d", again, err, scooter)
+	}
+
+	cargo, err := st.VehicleTypes().FindOrCreate(ctx, "cargo")
+
+	if err != nil {
+		t.Fatalf("FindOrCreate() error = %v", err)
+	}
+
+	err = st.VehicleTypes().Upsert(ctx, store.VehicleType{ExternalID: "scooter", FormFactor: ptr("scooter"), PropulsionType: ptr("electric"), Name: ptr("Trottinette"), MaxRangeMeters: ptr(25000.0)})
+
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	vehicleTypes, err := st.VehicleTypes().List(ctx)
+
+	if err != nil || len(vehicleTypes) != 2 {
+		t.Fatalf("List() = %+v, %v, want 2 vehicle types", vehicleTypes, err)
+	}
+
+	if got := vehicleTypes[0]; got.ID != scooter || got.ExternalID != "scooter" || got.FormFactor == nil || *got.FormFactor != "scooter" ||
+		got.PropulsionType == nil || *got.PropulsionType != "electric" || got.Name == nil || *got.Name != "Trottinette" ||
+		got.MaxRangeMeters == nil || *got.MaxRangeMeters != 25000 {
+		t.Errorf("List()[0] = %+v, want the described scooter", got)
+	}
+
+	if got := vehicleTypes[1]; got.ID != cargo || got.FormFactor != nil || got.PropulsionType != nil || got.Name != nil {
+		t.Errorf("List()[1] = %+v, want an undescribed vehicle type", got)
+	}
+
+	station, err := st.Stations().FindOrCreate(ctx, "1")
+
+	if err != nil {
+		t.Fatalf("FindOrCreate() error = %v", err)
+	}
+
+	rows := []store.Availability{
+		{Time: start, BikesAvailable: 3, DocksAvailable: 7, Vehicles: []store.VehicleAvailability{{VehicleTypeID: scooter, Available: 2}, {VehicleTypeID: cargo, Available: 1}}},
+		{Time: start.Add(5 * time.Minute), BikesAvailable: 4, DocksAvailable: 6, Vehicles: []store.VehicleAvailability{{VehicleTypeID: scooter, Available: 4}}},
+		{Time: start.Add(17 * time.Minute), BikesAvailable: 6, DocksAvailable: 4, Vehicles: []store.VehicleAvailability{{VehicleTypeID: cargo, Available: 0}, {VehicleTypeID: scooter, Available: 6}}},
+	}
+
+	for _, row := range rows {
+		row.StationID = station.ID
+
+		if err := st.Availability().Insert(ctx, row); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	latest, err := st.Availability().Latest(ctx, station.ID)
+
+	if err != nil || !slices.Equal(latest.Vehicles, []store.VehicleAvailability{{VehicleTypeID: scooter, Available: 6}, {VehicleTypeID: cargo, Available: 0}}) {
+		t.Errorf("Latest() = %+v, %v, want 6 scooters and no cargo bike", latest, err)
+	}
+
+	if materialize != nil {
+		if err := materialize(ctx); err != nil {
+			t.Fatalf("materialize() error = %v", err)
+		}
+	}
+
+	history, err := st.Availability().History(ctx, station.ID, start, start.Add(time.Hour), 15*time.Minute)
+
+	if err != nil || len(history) != 2 {
+		t.Fatalf("History() = %+v, %v, want 2 buckets", history, err)
+	}
+
+	// Vehicle types are averaged over the statuses counting them
+	want := [][]store.AverageVehicleAvailability{
+		{{VehicleTypeID: scooter, Available: 3}, {VehicleTypeID: cargo, Available: 1}},
+		{{VehicleTypeID: scooter, Available: 6}, {VehicleTypeID: cargo, Available: 0}},
+	}
+
+	for i, bucket := range history {
+		if !slices.Equal(bucket.Vehicles, want[i]) {
+			t.Errorf("History()[%d].Vehicles = %+v, want %+v", i, bucket.Vehicles, want[i])
+		}
+	}
+}
+
+func testPricingPlans(t *testing.T, factory Factory) {
+	st, _ := factory(t)
+	ctx := context.Background()
+	start := time.Unix(1717243200, 0)
+	day := 24 * time.Hour
+
+	single := store.PricingPlan{
+		PlanID:        "single",
+		Name:          "Single trip",
+		Currency:      "CAD",
+		Price:         1.25,
+		IsTaxable:     true,
+		Description:   "Unlock fee plus per minute",
+		URL:           ptr("https://example.com/single"),
+		PerMinPricing: []store.PricingSegment{{Start: 0, Rate: 0.15, Interval: 1, End: ptr[int64](45)}, {Start: 45, Rate: 0.30, Interval: 1}},
+	}
+	member := store.PricingPlan{PlanID: "member", Name: "Member", Currency: "CAD", Description: "45 minutes included"}
+
+	upsert := func(plan store.PricingPlan, at time.Time) {
+		t.Helper()
+
+		if err := st.PricingPlans().Upsert(ctx, plan, at); err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+	}
+
+	upsert(single, start)
+	upsert(member, start)
+	upsert(single, start.Add(day)) // unchanged
+
+	raised := single
+	raised.PerMinPricing = []store.PricingSegment{{Start: 0, Rate: 0.20, Interval: 1}}
+	upsert(raised, start.Add(2*day))
+	upsert(single, start.Add(day)) // older than the latest version
+
+	count, err := st.PricingPlans().Withdraw(ctx, []string{"single"}, start.Add(3*day))
+
+	if err != nil || count != 1 {
+		t.Fatalf("Withdraw() = %d, %v, want 1", count, err)
+	}
+
+	plans, err := st.PricingPlans().List(ctx, start, start.Add(4*day))
+
+	if err != nil || len(plans) != 3 {
+		t.Fatalf("List() = %+v, %v, want 3 versions", plans, err)
+	}
+
+	if got := plans[0]; got.PlanID != "member" || got.URL != nil || len(got.PerMinPricing) != 0 || got.ValidTo == nil || !got.ValidTo.Equal(start.Add(3*day)) {
+		t.Errorf("List()[0] = %+v, want member withdrawn as of %s", got, start.Add(3*day))
+	}
+
+	if got := plans[1]; !got.Describes(single) || !got.ValidFrom.Equal(start) || got.ValidTo == nil || !got.ValidTo.Equal(start.Add(2*day)) {
+		t.Errorf("List()[1] = %+v, want %+v until %s", got, single, start.Add(2*day))
+	}
+
+	if got := plans[2]; !got.Describes(raised) || !got.ValidFrom.Equal(start.Add(2*day)) || got.ValidTo != nil {
+		t.Errorf("List()[2] = %+v, want %+v", got, raised)
+	}
+
+	for _, tt := range []struct {
+		from, to time.Time
+		want     int
+	}{
+		{start.Add(-day), start.Add(-time.Hour), 0},
+		{start.Add(day), start.Add(day), 2},
+		{start.Add(5 * day), start.Add(5 * day), 1},
+	} {
+		if plans, err := st.PricingPlans().List(ctx, tt.from, tt.to); err != nil || len(plans) != tt.want {
+			t.Errorf("List(%s, %s) = %+v, %v, want %d versions", tt.from, tt.to, plans, err, tt.want)
+		}
+	}
+}
+
+func testEbikeDepletion(t *testing.T, factory Factory) {
+	st, materialize := factory(t)
+	ctx := context.Background()
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	ebikes := map[string][]*int64{
+		"waiver":    {ptr[int64](6), ptr[int64](4), nil, ptr[int64](4)},
+		"no-waiver": {ptr[int64](3), ptr[int64](3), ptr[int64](5), ptr[int64](5)},
+	}
+
+	for id, counts := range ebikes {
+		information := store.StationInformation{ExternalID: id, Name: id, Lon: -73.57, Lat: 45.5}
+		information.Metadata.ElectricBikeSurchargeWaiver = id == "waiver"
+
+		if err := st.Stations().Upsert(ctx, information, start.Add(-time.Hour)); err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+
+		station, _ := st.Stations().FindOrCreate(ctx, id)
+
+		for i, count := range counts {
+			row := store.Availability{Time: start.Add(time.Duration(i) * 5 * time.Minute), StationID: station.ID, EbikesAvailable: count}
+
+			if err := st.Availability().Insert(ctx, row); err != nil {
+				t.Fatalf("Insert() error = %v", err)
+			}
+		}
+	}
+
+	if materialize != nil {
+		if err := materialize(ctx); err != nil {
+			t.Fatalf("materialize() error = %v", err)
+		}
+	}
+
+	depletion, err := st.Availability().EbikeDepletion(ctx, start, start.Add(15*time.Minute))
+
+	if err != nil || len(depletion) != 2 {
+		t.Fatalf("EbikeDepletion() = %+v, %v, want 2 groups", depletion, err)
+	}
+
+	// 2 ebikes taken over the only consecutive buckets of the waiver station,
+	// the buckets on either side of its gap are not compared
+	want := []store.EbikeDepletion{
+		{Waiver: false, Stations: 1, EbikesAvailable: 4, Departures: 0},
+		{Waiver: true, Stations: 1, EbikesAvailable: 14.0 / 3, Departures: 24},
+	}
+
+	for i, got := range depletion {
+		if got.Waiver != want[i].Waiver || got.Stations != want[i].Stations || math.Abs(got.EbikesAvailable-want[i].EbikesAvailable) > 1e-6 || math.Abs(got.Departures-want[i].Departures) > 1e-6 {
+			t.Errorf("EbikeDepletion()[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	if depletion, err := st.Availability().EbikeDepletion(ctx, start.Add(-2*time.Hour), start.Add(-time.Hour)); err != nil || len(depletion) != 0 {
+		t.Errorf("EbikeDepletion() before any row = %+v, %v, want no group", depletion, err)
+	}
+}
+
+func testHeatmap(t *testing.T, factory Factory) {
+	st, materialize := factory(t)
+	ctx := context.Background()
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	// Station 3 stops syncing for a bucket, during which 6 bikes leave
+	stations := []struct {
+		id       string
+		lon, lat float64
+		bikes    map[time.Duration]int64
+	}{
+		{"1", -73.57, 45.5, map[time.Duration]int64{0: 4, 5 * time.Minute: 4, 10 * time.Minute: 4}},
+		{"2", -73.5695, 45.5003, map[time.Duration]int64{0: 2, 5 * time.Minute: 2, 10 * time.Minute: 2}},
+		{"3", -73.54, 45.52, map[time.Duration]int64{0: 10, 5 * time.Minute: 8, 15 * time.Minute: 2}},
+	}
+
+	for _, s := range stations {
+		if err := st.Stations().Upsert(ctx, store.StationInformation{ExternalID: s.id, Name: s.id, Lon: s.lon, Lat: s.lat}, start.Add(-time.Hour)); err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+
+		station, _ := st.Stations().FindOrCreate(ctx, s.id)
+
+		for offset, bikes := range s.bikes {
+			if err := st.Availability().Insert(ctx, store.Availability{Time: start.Add(offset), StationID: station.ID, BikesAvailable: bikes}); err != nil {
+				t.Fatalf("Insert() error = %v", err)
+			}
+		}
+	}
+
+	if materialize != nil {
+		if err := materialize(ctx); err != nil {
+			t.Fatalf("materialize() error = %v", err)
+		}
+	}
+
+	query := store.HeatmapQuery{Shape: "square", Metric: "bikes", CellSize: 500, From: start, To: start.Add(15 * time.Minute)}
+
+	if _, err := st.Availability().Heatmap(ctx, query); errors.Is(err, store.ErrUnsupported) {
+		t.Skip("heatmap not supported")
+	}
+
+	tests := []struct {
+		shape, metric string
+		want          float64
+	}{
+		{"square", "bikes", 4 + 2 + 20.0/3},
+		{"hex", "bikes", 4 + 2 + 20.0/3},
+		{"square", "departures", 2},
+		{"hex", "departures", 2},
+	}
+
+	for _, tt := range tests {
+		query.Shape, query.Metric = tt.shape, tt.metric
+		cells, err := st.Availability().Heatmap(ctx, query)
+
+		// Stores without PostGIS only compute square cells
+		if errors.Is(err, store.ErrUnsupported) {
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Heatmap(%s, %s) error = %v", tt.shape, tt.metric, err)
+		}
+
+		// Each station is counted in exactly one cell
+		value, count := 0.0, int64(0)
+
+		for _, cell := range cells {
+			value += cell.Value
+			count += cell.Stations
+		}
+
+		if count != 3 || math.Abs(value-tt.want) > 1e-6 {
+			t.Errorf("Heatmap(%s, %s) = %d stations, %f, want 3 stations, %f", tt.shape, tt.metric, count, value, tt.want)
+		}
+	}
+
+	query.Shape, query.Metric = "square", "bikes"
+	cells, _ := st.Availability().Heatmap(ctx, query)
+
+	for _, cell := range cells {
+		polygon := struct {
+			Coordinates [][][2]float64 `json:"coordinates"`
+		}{}
+
+		if err := json.Unmarshal(cell.Geometry, &polygon); err != nil || len(polygon.Coordinates) != 1 {
+			t.Fatalf("Heatmap() geometry = %s, %v, want a polygon", cell.Geometry, err)
+		}
+
+		minLon, maxLon, lat := math.Inf(1), math.Inf(-1), polygon.Coordinates[0][0][1]
+
+		for _, point := range polygon.Coordinates[0] {
+			minLon, maxLon = min(minLon, point[0]), max(maxLon, point[0])
+		}
+
+		// A degree of longitude spans 111319.49m at the equator
+		if width := (maxLon - minLon) * 111319.49 * math.Cos(lat*math.Pi/180); math.Abs(width-500) > 5 {
+			t.Errorf("Heatmap() cell width = %fm, want 500m", width)
+		}
+	}
+}
+
+func testGeofencingZones(t *testing.T, factory Factory) {
+	st, _ := factory(t)
+	ctx := context.Background()
+	start := time.Unix(1717243200, 0)
+
+	// A park with a pond, within a slow zone
+	park := store.GeofencingZone{
+		Name:     ptr("Parc La Fontaine"),
+		Start:    &start,
+		Geometry: []byte(`{"type": "MultiPolygon", "coordinates": [[[[-73.575, 45.52], [-73.565, 45.52], [-73.565, 45.53], [-73.575, 45.53], [-73.575, 45.52]], [[-73.572, 45.523], [-73.568, 45.523], [-73.568, 45.527], [-73.572, 45.527], [-73.572, 45.523]]]]}`),
+		Rules:    []store.GeofencingRule{{VehicleTypeIDs: []string{"ebike"}, RideThroughAllowed: true, StationParking: ptr(true)}},
+	}
+	slow := store.GeofencingZone{
+		Geometry: []byte(`{"type": "MultiPolygon", "coordinates": [[[[-73.6, 45.5], [-73.55, 45.5], [-73.55, 45.55], [-73.6, 45.55], [-73.6, 45.5]]]]}`),
+		Rules:    []store.GeofencingRule{{RideAllowed: true, RideThroughAllowed: true, MaximumSpeedKph: ptr[int64](15)}},
+	}
+
+	if err := st.GeofencingZones().Replace(ctx, []store.GeofencingZone{{Geometry: slow.Geometry}}); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	if err := st.GeofencingZones().Replace(ctx, []store.GeofencingZone{park, slow}); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	zones, err := st.GeofencingZones().List(ctx)
+
+	if err != nil || len(zones) != 2 {
+		t.Fatalf("List() = %+v, %v, want 2 zones", zones, err)
+	}
+
+	if got := zones[0]; got.Name == nil || *got.Name != "Parc La Fontaine" || got.Start == nil || !got.Start.Equal(start) || got.End != nil ||
+		len(got.Rules) != 1 || !slices.Equal(got.Rules[0].VehicleTypeIDs, []string{"ebike"}) || got.Rules[0].RideAllowed || got.Rules[0].StationParking == nil || !*got.Rules[0].StationParking {
+		t.Errorf("List()[0] = %+v, want %+v", got, park)
+	}
+
+	if got := zones[1]; got.Name != nil || got.Start != nil || len(got.Rules) != 1 || got.Rules[0].MaximumSpeedKph == nil || *got.Rules[0].MaximumSpeedKph != 15 || got.Rules[0].StationParking != nil {
+		t.Errorf("List()[1] = %+v, want %+v", got, slow)
+	}
+
+	if contains, err := store.Contains(zones[0].Geometry, -73.574, 45.521); err != nil || !contains {
+		t.Errorf("Contains() of the stored park = %t, %v, want true", contains, err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		lon, lat float64
+		want     int
+	}{
+		{"park", -73.574, 45.521, 2},
+		{"pond", -73.57, 45.525, 1},
+		{"slow zone", -73.58, 45.51, 1},
+		{"outside", -73.5, 45.5, 0},
+	} {
+		zones, err := st.GeofencingZones().At(ctx, tt.lon, tt.lat)
+
+		if err != nil || len(zones) != tt.want {
+			t.Errorf("At() in the %s = %+v, %v, want %d zones", tt.name, zones, err, tt.want)
+		}
+
+		if tt.want == 2 && (zones[0].Name == nil || zones[1].Name != nil) {
+			t.Errorf("At() in the %s = %+v, want the park first", tt.name, zones)
+		}
+	}
+
+	if _, err := st.GeofencingZones().Tile(ctx, 12, 1211, 1463); err != nil && !errors.Is(err, store.ErrUnsupported) {
+		t.Errorf("Tile() error = %v", err)
+	}
+
+	if err := st.GeofencingZones().Replace(ctx, nil); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	if zones, err := st.GeofencingZones().List(ctx); err != nil || len(zones) != 0 {
+		t.Errorf("List() = %+v, %v, want no zone", zones, err)
+	}
+}
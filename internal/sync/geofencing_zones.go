@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/pkg/gbfs"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+const geofencingZonesFeed = "geofencing_zones"
+
+func unixTime(seconds *int64) *time.Time {
+	if seconds == nil {
+		return nil
+	}
+
+	t := time.Unix(*seconds, 0)
+	return &t
+}
+
+func FetchGeofencingZonesOnce(ctx context.Context, st store.Store, fetcher Fetcher, url string, timeout time.Duration) (int64, error) {
+	return syncFeed(ctx, st, fetcher, geofencingZonesFeed, url, timeout, func(ctx context.Context, tx store.Store, geofencingZones *gbfs.GBFSDocument[v1_0.GeofencingZonesData]) error {
+		zones := []store.GeofencingZone{}
+
+		for i, feature := range geofencingZones.Data.GeofencingZones.Features {
+			geometry, err := store.MultiPolygon(feature.Geometry)
+
+			// A zone we cannot place is left out rather than failing the
+			// whole document
+			if err != nil {
+				slog.Warn("skipping invalid geofencing zone", "index", i, "error", err)
+				continue
+			}
+
+			zone := store.GeofencingZone{
+				Name:     feature.Properties.Name,
+				Start:    unixTime(feature.Properties.Start),
+				End:      unixTime(feature.Properties.End),
+				Geometry: geometry,
+			}
+
+			for _, rule := range feature.Properties.Rules {
+				stored := store.GeofencingRule{
+					VehicleTypeIDs:     rule.VehicleTypeIDs,
+					RideAllowed:        bool(rule.RideAllowed),
+					RideThroughAllowed: bool(rule.RideThroughAllowed),
+					MaximumSpeedKph:    rule.MaximumSpeedKph,
+				}
+
+				if rule.StationParking != nil {
+					stationParking := bool(*rule.StationParking)
+					stored.StationParking = &stationParking
+				}
+
+				zone.Rules = append(zone.Rules, stored)
+			}
+
+			zones = append(zones, zone)
+		}
+
+		return tx.GeofencingZones().Replace(ctx, zones)
+	})
+}
+
+func FetchGeofencingZonesLoop(ctx context.Context, st store.Store, fetcher Fetcher, feedUrl string, feed config.Feed) error {
+	return runFeedLoop(ctx, geofencingZonesFeed, feed, false, func(ctx context.Context) (int64, error) {
+		return FetchGeofencingZonesOnce(ctx, st, fetcher, feedUrl, feed.Timeout)
+	})
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/gbfstest"
+	"github.com/ngc7293/hixi/internal/store/memory"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+func TestFetchGeofencingZonesOnce(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+	now := time.Now().Truncate(time.Second)
+	end := now.Add(time.Hour).Unix()
+	speed := int64(10)
+
+	// Operators sometimes publish plain Polygons
+	zones := v1_0.GeofencingZones{Type: "FeatureCollection", Features: []v1_0.GeofencingZone{
+		{
+			Type:     "Feature",
+			Geometry: json.RawMessage(`{"type": "Polygon", "coordinates": [[[-73.58, 45.5], [-73.56, 45.5], [-73.56, 45.52], [-73.58, 45.52], [-73.58, 45.5]]]}`),
+			Properties: v1_0.GeofencingZoneProperties{
+				End:   &end,
+				Rules: []v1_0.GeofencingRule{{VehicleTypeIDs: []string{"ebike"}, RideThroughAllowed: true, MaximumSpeedKph: &speed}},
+			},
+		},
+	}}
+
+	operator.Push("geofencing_zones", now, 60, v1_0.GeofencingZonesData{GeofencingZones: zones})
+
+	fetchOnce(t, st, operator, "geofencing_zones", FetchGeofencingZonesOnce)
+
+	stored, err := st.GeofencingZones().At(ctx, -73.57, 45.51)
+
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("At() = %+v, %v, want the zone", stored, err)
+	}
+
+	if zone := stored[0]; zone.End == nil || zone.End.Unix() != end || zone.Start != nil || len(zone.Rules) != 1 || zone.Rules[0].RideAllowed || !zone.Rules[0].RideThroughAllowed || zone.RuleFor("ebike") == nil || zone.RuleFor("bike") != nil {
+		t.Errorf("At()[0] = %+v, want a zone restricting ebikes until %d", zone, end)
+	}
+
+	if geometry := string(stored[0].Geometry); geometry[:22] != `{"type":"MultiPolygon"` {
+		t.Errorf("At()[0].Geometry = %s, want a MultiPolygon", geometry)
+	}
+
+	assertSyncedAt(t, st, "geofencing_zones", now)
+
+	// Invalid zones are skipped, the rest of the document is stored
+	invalid := zones.Features[0]
+	invalid.Geometry = json.RawMessage(`{"type": "Point", "coordinates": [-73.57, 45.51]}`)
+	zones.Features = append([]v1_0.GeofencingZone{invalid}, zones.Features[0])
+	zones.Features[1].Properties.End = nil
+	malformed := gbfstest.NewOperator(t)
+	malformed.Push("geofencing_zones", now.Add(time.Minute), 60, v1_0.GeofencingZonesData{GeofencingZones: zones})
+
+	fetchOnce(t, st, malformed, "geofencing_zones", FetchGeofencingZonesOnce)
+
+	if stored, err := st.GeofencingZones().List(ctx); err != nil || len(stored) != 1 || stored[0].End != nil {
+		t.Errorf("List() = %+v, %v, want only the valid zone of the new document", stored, err)
+	}
+
+	assertSyncedAt(t, st, "geofencing_zones", now.Add(time.Minute))
+}
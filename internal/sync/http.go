@@ -1,34 +1,233 @@
 package sync
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"time"
 
+	"github.com/ngc7293/hixi/internal/archive"
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/metrics"
+	"github.com/ngc7293/hixi/internal/store"
 	"github.com/ngc7293/hixi/pkg/gbfs"
 )
 
-func FetchDocument[DataType any](url string) (*gbfs.GBFSDocument[DataType], error) {
+var errUnexpectedStatus = errors.New("unexpected status")
+
+// Fetcher retrieves the raw content of GBFS documents, along with the time
+// at which they were fetched. Syncing uses that time as its clock, so that
+// replaying archived documents is deterministic.
+type Fetcher interface {
+	Fetch(ctx context.Context, feed string, url string, timeout time.Duration) ([]byte, time.Time, error)
+}
+
+// HTTPFetcher fetches documents from the operator, keeping a copy of each in
+// Archive if set
+type HTTPFetcher struct {
+	Archive archive.Store
+}
+
+func (f HTTPFetcher) Fetch(ctx context.Context, feed string, url string, timeout time.Duration) ([]byte, time.Time, error) {
 	slog.Info("fetch document", "method", "get", "url", url)
-	resp, err := http.Get(url)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch document: %w", err)
+		return nil, time.Time{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to fetch document: %w", err)
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch document: %w", err)
+		return nil, time.Time{}, fmt.Errorf("failed to fetch document: %w %s", errUnexpectedStatus, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to fetch document: %w", err)
+	}
+
+	fetchedAt := time.Now()
+
+	// The archive is a debugging aid: failing to write to it must not stop
+	// the sync
+	if f.Archive != nil {
+		snapshot := archive.Snapshot{Feed: feed, URL: url, FetchedAt: fetchedAt, Body: body}
+
+		if err := f.Archive.Put(context.WithoutCancel(ctx), &snapshot); err != nil {
+			slog.Error("failed to archive document", "feed", feed, "url", url, "error", err)
+		}
+	}
+
+	return body, fetchedAt, nil
+}
+
+// ArchivedFetcher serves a single archived document, for replays
+type ArchivedFetcher struct {
+	Body      []byte
+	FetchedAt time.Time
+}
+
+func (f ArchivedFetcher) Fetch(ctx context.Context, feed string, url string, timeout time.Duration) ([]byte, time.Time, error) {
+	return f.Body, f.FetchedAt, nil
+}
+
+// fetchFeed fetches and decodes a GBFS document through fetcher
+func fetchFeed[DataType any](ctx context.Context, fetcher Fetcher, feed string, url string, timeout time.Duration) (*gbfs.GBFSDocument[DataType], time.Time, error) {
+	body, fetchedAt, err := fetcher.Fetch(ctx, feed, url, timeout)
+
+	if err != nil {
+		return nil, time.Time{}, err
 	}
 
 	document := gbfs.GBFSDocument[DataType]{}
-	err = json.NewDecoder(resp.Body).Decode(&document)
+	err = json.Unmarshal(body, &document)
+
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode document: %w", err)
+	}
+
+	return &document, fetchedAt, nil
+}
+
+// syncFeed fetches a GBFS document and stores it through apply, recording
+// the sync state of the feed in the same transaction. It returns the ttl of
+// the document.
+func syncFeed[DataType any](ctx context.Context, st store.Store, fetcher Fetcher, feed string, url string, timeout time.Duration, apply func(ctx context.Context, tx store.Store, document *gbfs.GBFSDocument[DataType]) error) (int64, error) {
+	document, now, err := fetchFeed[DataType](ctx, fetcher, feed, url, timeout)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode document: %w", err)
+		return 0, err
+	}
+
+	metrics.FeedUpdated(feed, document.LastUpdated)
+
+	// Once the document is fetched, let the transaction complete even if we
+	// are asked to shut down
+	ctx = context.WithoutCancel(ctx)
+
+	err = st.InTx(ctx, func(tx store.Store) error {
+		if err := apply(ctx, tx, document); err != nil {
+			return err
+		}
+
+		return tx.SyncState().Record(ctx, feed, time.Unix(document.LastUpdated, 0), now)
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return document.TTL, nil
+}
+
+// FetchDocument fetches and decodes a GBFS document, without archiving it
+func FetchDocument[DataType any](ctx context.Context, url string, timeout time.Duration) (*gbfs.GBFSDocument[DataType], error) {
+	document, _, err := fetchFeed[DataType](ctx, HTTPFetcher{}, "", url, timeout)
+	return document, err
+}
+
+// nextFetchDelay returns how long to wait before polling a feed again: the
+// configured interval if any, otherwise the ttl advertised by the feed. Feeds
+// advertising a ttl of 0, as near-static feeds often do, are polled every
+// defaultFeedTTL seconds rather than in a tight loop.
+func nextFetchDelay(feed config.Feed, ttl int64) time.Duration {
+	if feed.Interval > 0 {
+		return feed.Interval
+	}
+
+	if ttl <= 0 {
+		ttl = defaultFeedTTL
+	}
+
+	return time.Duration(ttl) * time.Second
+}
+
+// sleepContext waits for d, returning false if ctx is cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
+}
+
+// defaultFeedTTL is the ttl assumed for a feed which advertises none, or
+// failed before advertising one
+const defaultFeedTTL = 60
+
+// runFeedLoop polls a feed through once until ctx is cancelled, waiting for
+// the interval of feed or the ttl returned by once between fetches. A failure
+// of a required feed stops the loop. Failures of optional feeds are logged and
+// retried after the last known ttl, since a broken optional feed must not stop
+// syncing the others.
+func runFeedLoop(ctx context.Context, name string, feed config.Feed, required bool, once func(ctx context.Context) (int64, error)) error {
+	ttl := int64(defaultFeedTTL)
+
+	for {
+		start := time.Now()
+		next, err := once(ctx)
+
+		// A cancelled fetch is not an error when shutting down
+		if ctx.Err() != nil {
+			return nil
+		}
 
-	return &document, nil
+		if err != nil {
+			metrics.FetchErrors.WithLabelValues(name, fetchErrorType(err)).Inc()
+
+			if required {
+				return fmt.Errorf("failed to sync %s: %w", name, err)
+			}
+
+			slog.Error("failed to sync optional feed, retrying", "feed", name, "error", err)
+		} else {
+			metrics.SyncDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+			ttl = next
+		}
+
+		if !sleepContext(ctx, nextFetchDelay(feed, ttl)) {
+			return nil
+		}
+	}
+}
+
+// fetchErrorType classifies a sync error for the fetch error metric
+func fetchErrorType(err error) string {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var netError net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, errUnexpectedStatus):
+		return "status"
+	case errors.As(err, &syntaxError), errors.As(err, &typeError), errors.Is(err, io.ErrUnexpectedEOF):
+		return "decode"
+	case errors.As(err, &netError):
+		return "network"
+	default:
+		return "database"
+	}
 }
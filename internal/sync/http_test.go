@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/archive"
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+func TestHTTPFetcherArchives(t *testing.T) {
+	const document = `{"last_updated": 1700000000, "ttl": 10, "data": {"stations": [{"station_id": "42", "num_bikes_available": 3}]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(document))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	store, err := archive.NewDiskStore(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetched, fetchedAt, err := fetchFeed[v1_0.StationStatusData](ctx, HTTPFetcher{Archive: store}, stationStatusFeed, server.URL, time.Second)
+
+	if err != nil {
+		t.Fatalf("fetchFeed() error = %v", err)
+	}
+
+	snapshots, err := store.List(ctx, stationStatusFeed, fetchedAt.Add(-time.Minute), fetchedAt.Add(time.Minute))
+
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("List() = %v, %v, want one snapshot", snapshots, err)
+	}
+
+	if !snapshots[0].FetchedAt.Equal(fetchedAt) || snapshots[0].URL != server.URL {
+		t.Errorf("snapshot = %+v, want fetched at %s from %s", snapshots[0], fetchedAt, server.URL)
+	}
+
+	body, err := store.Load(ctx, snapshots[0].Hash)
+
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Replaying the archived body yields the same document and clock
+	replayed, replayedAt, err := fetchFeed[v1_0.StationStatusData](ctx, ArchivedFetcher{Body: body, FetchedAt: snapshots[0].FetchedAt}, stationStatusFeed, server.URL, 0)
+
+	if err != nil {
+		t.Fatalf("fetchFeed() error = %v", err)
+	}
+
+	if !replayedAt.Equal(fetchedAt) || replayed.LastUpdated != fetched.LastUpdated || replayed.Data.Stations[0].NumBikesAvailable != 3 {
+		t.Errorf("replayed document = %+v at %s, want %+v at %s", replayed, replayedAt, fetched, fetchedAt)
+	}
+}
+
+func TestRunFeedLoopRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	once := func(ctx context.Context) (int64, error) {
+		calls++
+
+		if calls == 3 {
+			cancel()
+		}
+
+		if calls == 1 {
+			return 0, errors.New("invalid document")
+		}
+
+		return 60, nil
+	}
+
+	if err := runFeedLoop(ctx, "system_alerts", config.Feed{Interval: time.Millisecond}, false, once); err != nil {
+		t.Errorf("runFeedLoop() error = %v, want failures retried", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("runFeedLoop() called once %d times, want 3", calls)
+	}
+}
+
+func TestRunFeedLoopStopsOnRequiredFailure(t *testing.T) {
+	calls := 0
+	once := func(ctx context.Context) (int64, error) {
+		calls++
+		return 0, errors.New("invalid document")
+	}
+
+	if err := runFeedLoop(context.Background(), "station_status", config.Feed{Interval: time.Millisecond}, true, once); err == nil {
+		t.Error("runFeedLoop() error = nil, want the failure of a required feed")
+	}
+
+	if calls != 1 {
+		t.Errorf("runFeedLoop() called once %d times, want 1", calls)
+	}
+}
+
+func TestNextFetchDelay(t *testing.T) {
+	tests := []struct {
+		name string
+		feed config.Feed
+		ttl  int64
+		want time.Duration
+	}{
+		{name: "interval", feed: config.Feed{Interval: time.Minute}, ttl: 10, want: time.Minute},
+		{name: "ttl", ttl: 10, want: 10 * time.Second},
+		{name: "no ttl", ttl: 0, want: defaultFeedTTL * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := nextFetchDelay(tt.feed, tt.ttl); got != tt.want {
+			t.Errorf("nextFetchDelay(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/dbtest"
+	"github.com/ngc7293/hixi/internal/gbfstest"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/store/postgres"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+// stationsData is the data of a station_status or station_information
+// document
+type stationsData[Record any] struct {
+	Stations []Record `json:"stations"`
+}
+
+// stationInformation is the data of a BIXI station_information document
+func stationInformation(stations ...string) stationsData[bixiStationInformation] {
+	data := stationsData[bixiStationInformation]{}
+
+	for i, id := range stations {
+		data.Stations = append(data.Stations, bixiStationInformation{StationInformationStation: v1_0.StationInformationStation{
+			StationID: id,
+			Name:      "Station " + id,
+			Lat:       45.5 + float64(i)*0.001,
+			Lon:       -73.57,
+			Capacity:  ptr[int64](20),
+		}})
+	}
+
+	return data
+}
+
+// status is a BIXI station_status record, whose bikes include the ebikes
+func status(id string, reported time.Time, bikes int64, ebikes int64, docks int64) bixiStationStatus {
+	return bixiStationStatus{
+		StationStatus: v1_0.StationStatus{
+			StationID:         id,
+			NumBikesAvailable: bikes,
+			NumDocksAvailable: docks,
+			LastReported:      reported.Unix(),
+		},
+		NumEbikesAvailable: ptr(ebikes),
+	}
+}
+
+// statuses is the data of a BIXI station_status document
+func statuses(stations ...bixiStationStatus) stationsData[bixiStationStatus] {
+	return stationsData[bixiStationStatus]{Stations: stations}
+}
+
+// fetchOnce syncs feed from operator through once, failing the test on error
+func fetchOnce(t *testing.T, st store.Store, operator *gbfstest.Operator, feed string, once func(context.Context, store.Store, Fetcher, string, time.Duration) (int64, error)) {
+	t.Helper()
+
+	if _, err := once(context.Background(), st, HTTPFetcher{}, operator.FeedURL(feed), time.Second); err != nil {
+		t.Fatalf("syncing %s: %v", feed, err)
+	}
+}
+
+// stationInformationOnce syncs station_information with the normalizer of
+// system, for fetchOnce
+func stationInformationOnce(system string) func(context.Context, store.Store, Fetcher, string, time.Duration) (int64, error) {
+	normalizer, _ := NormalizerFor(system)
+
+	return func(ctx context.Context, st store.Store, fetcher Fetcher, url string, timeout time.Duration) (int64, error) {
+		return FetchStationInformationOnce(ctx, st, fetcher, url, timeout, normalizer)
+	}
+}
+
+// assertSyncedAt checks the last_updated recorded for feed
+func assertSyncedAt(t *testing.T, st store.Store, feed string, want time.Time) {
+	t.Helper()
+
+	if state, err := st.SyncState().Get(context.Background(), feed); err != nil || state.LastUpdated == nil || !state.LastUpdated.Equal(want) {
+		t.Errorf("SyncState().Get(%s) = %+v, %v, want updated at %s", feed, state, err, want)
+	}
+}
+
+func TestFetchStationStatusOnceIntegration(t *testing.T) {
+	pool := dbtest.New(t)
+	st := postgres.New(pool)
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+
+	rules := config.Default().Validation
+	rules.NegativeCount = config.ValidationQuarantine
+	normalizer, _ := NormalizerFor("bixi")
+	now := time.Now().Truncate(time.Second)
+
+	rowCount := func(query string) int {
+		t.Helper()
+		var n int
+
+		if err := pool.QueryRow(ctx, query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+
+		return n
+	}
+
+	operator.Push("station_information", now, 3600, stationInformation("1", "2"))
+	operator.Push("station_status", now, 10, statuses(
+		status("1", now.Add(-time.Minute), 5, 2, 13),
+		status("2", now.Add(-time.Minute), 1, 3, 16), // 1 bike counted with 3 ebikes: negative once normalized
+	))
+	operator.Push("station_status", now.Add(10*time.Second), 10, statuses(
+		status("1", now.Add(-time.Minute), 5, 2, 13), // unchanged report
+		status("2", now, 4, 3, 13),
+		status("3", now, 1, 0, 5), // not in station_information
+	))
+
+	if _, err := FetchStationInformationOnce(ctx, st, HTTPFetcher{}, operator.FeedURL("station_information"), time.Second, normalizer); err != nil {
+		t.Fatalf("FetchStationInformationOnce() error = %v", err)
+	}
+
+	ttl, err := FetchStationStatusOnce(ctx, st, HTTPFetcher{}, operator.FeedURL("station_status"), time.Second, normalizer, rules)
+
+	if err != nil {
+		t.Fatalf("FetchStationStatusOnce() error = %v", err)
+	}
+
+	if ttl != 10 {
+		t.Errorf("ttl = %d, want 10", ttl)
+	}
+
+	if n := rowCount(`SELECT COUNT(*) FROM "public"."live_station_availability"`); n != 1 {
+		t.Errorf("first snapshot stored %d rows, want 1 (station 2 quarantined)", n)
+	}
+
+	var bikes int64
+	err = pool.QueryRow(ctx, `SELECT "bikes_available" FROM "public"."live_station_availability" JOIN "public"."station" ON "station"."id" = "station_id" WHERE "external_id" = '1'`).Scan(&bikes)
+
+	if err != nil || bikes != 3 {
+		t.Errorf("bikes_available = %d, %v, want ebikes subtracted: 3", bikes, err)
+	}
+
+	if _, err := FetchStationStatusOnce(ctx, st, HTTPFetcher{}, operator.FeedURL("station_status"), time.Second, normalizer, rules); err != nil {
+		t.Fatalf("FetchStationStatusOnce() error = %v", err)
+	}
+
+	if n := rowCount(`SELECT COUNT(*) FROM "public"."live_station_availability"`); n != 3 {
+		t.Errorf("second snapshot brought rows to %d, want 3 (station 1 unchanged)", n)
+	}
+
+	if n := rowCount(`SELECT COUNT(*) FROM "public"."data_quality_issue" WHERE "rule" = 'negative_count' AND "quarantined"`); n != 1 {
+		t.Errorf("%d quarantined negative_count issues, want 1", n)
+	}
+
+	if n := rowCount(`SELECT COUNT(*) FROM "public"."data_quality_issue" WHERE "rule" = 'unknown_station' AND "station_external_id" = '3'`); n != 1 {
+		t.Errorf("%d unknown_station issues for station 3, want 1", n)
+	}
+
+	if n := rowCount(`SELECT COUNT(*) FROM "public"."sync_state"`); n != 2 {
+		t.Errorf("sync_state has %d feeds, want 2", n)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+	operator.Push("station_status", time.Now(), 10, v1_0.StationStatusData{})
+	operator.Fail("station_status", http.StatusServiceUnavailable)
+
+	_, _, err := fetchFeed[v1_0.StationStatusData](ctx, HTTPFetcher{}, stationStatusFeed, operator.FeedURL("station_status"), time.Second)
+
+	if got := fetchErrorType(err); got != "status" {
+		t.Errorf("fetchErrorType(%v) = %s, want status", err, got)
+	}
+
+	operator.PushRaw("station_information", `{"last_updated": "yesterday"}`)
+	_, _, err = fetchFeed[v1_0.StationInformationData](ctx, HTTPFetcher{}, stationInformationFeed, operator.FeedURL("station_information"), time.Second)
+
+	if got := fetchErrorType(err); got != "decode" {
+		t.Errorf("fetchErrorType(%v) = %s, want decode", err, got)
+	}
+
+	operator.Delay("station_status", time.Second)
+	_, _, err = fetchFeed[v1_0.StationStatusData](ctx, HTTPFetcher{}, stationStatusFeed, operator.FeedURL("station_status"), 50*time.Millisecond)
+
+	if got := fetchErrorType(err); got != "timeout" {
+		t.Errorf("fetchErrorType(%v) = %s, want timeout", err, got)
+	}
+
+	if n := operator.Requests("station_status"); n != 2 {
+		t.Errorf("station_status requested %d times, want 2", n)
+	}
+}
@@ -0,0 +1,11 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/ngc7293/hixi/internal/dbtest"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
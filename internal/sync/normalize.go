@@ -0,0 +1,188 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+// StationStatus is a normalized station_status record: the bike counts only
+// include classic bikes, ebikes are counted separately when the operator
+// reports them
+type StationStatus struct {
+	v1_0.StationStatus
+
+	EbikesAvailable *int64 `json:"ebikes_available,omitempty"`
+	EbikesDisabled  *int64 `json:"ebikes_disabled,omitempty"`
+}
+
+// StationInformation is a normalized station_information record, with the
+// station metadata some operators publish outside of GBFS
+type StationInformation struct {
+	v1_0.StationInformationStation
+
+	HasKiosk                    *bool
+	ElectricBikeSurchargeWaiver bool
+	IsCharging                  bool
+}
+
+// Normalizer decodes the stations of a feed, correcting operator-specific
+// quirks and reading the non-standard fields of the operator
+type Normalizer interface {
+	// StationStatus decodes the data of a station_status document
+	StationStatus(data json.RawMessage) ([]StationStatus, error)
+
+	// StationInformation decodes the data of a station_information document
+	StationInformation(data json.RawMessage) ([]StationInformation, error)
+}
+
+// normalizers are selected per system with gbfs.system
+var normalizers = map[string]Normalizer{
+	"bixi":    bixiNormalizer{},
+	"generic": genericNormalizer{},
+}
+
+// NormalizerFor returns the normalizer registered for system
+func NormalizerFor(system string) (Normalizer, error) {
+	normalizer, ok := normalizers[system]
+
+	if !ok {
+		names := []string{}
+
+		for name := range normalizers {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+		return nil, fmt.Errorf("unknown system %q, expected one of %s", system, strings.Join(names, ", "))
+	}
+
+	return normalizer, nil
+}
+
+// decodeStations decodes the stations listed by the data of a document
+func decodeStations[Record any](data json.RawMessage) ([]Record, error) {
+	document := struct {
+		Stations []Record `json:"stations"`
+	}{}
+
+	err := json.Unmarshal(data, &document)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode stations: %w", err)
+	}
+
+	return document.Stations, nil
+}
+
+// genericNormalizer follows GBFS 1.x and 2.x alike, which have no separate
+// ebike counts: num_bikes_available counts vehicles of every type and is
+// stored as-is. GBFS 2.1+ feeds break it down by vehicle type instead.
+type genericNormalizer struct{}
+
+func (genericNormalizer) StationStatus(data json.RawMessage) ([]StationStatus, error) {
+	records, err := decodeStations[v1_0.StationStatus](data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make([]StationStatus, 0, len(records))
+
+	for _, record := range records {
+		stations = append(stations, StationStatus{StationStatus: record})
+	}
+
+	return stations, nil
+}
+
+func (genericNormalizer) StationInformation(data json.RawMessage) ([]StationInformation, error) {
+	records, err := decodeStations[v1_0.StationInformationStation](data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make([]StationInformation, 0, len(records))
+
+	for _, record := range records {
+		stations = append(stations, StationInformation{StationInformationStation: record})
+	}
+
+	return stations, nil
+}
+
+// bixiStationStatus is a station_status record of BIXI Montréal, which
+// publishes non-standard ebike counts
+type bixiStationStatus struct {
+	v1_0.StationStatus
+
+	NumEbikesAvailable *int64 `json:"num_ebikes_available"`
+	NumEbikesDisabled  *int64 `json:"num_ebikes_disabled"`
+}
+
+// bixiStationInformation is a station_information record of BIXI Montréal
+type bixiStationInformation struct {
+	v1_0.StationInformationStation
+
+	HasKiosk                    *bool `json:"has_kiosk"`
+	ElectricBikeSurchargeWaiver bool  `json:"electric_bike_surcharge_waiver"`
+	IsCharging                  bool  `json:"is_charging"`
+}
+
+// bixiNormalizer handles BIXI Montréal, which publishes GBFS 1.0 but, like
+// GBFS 2.x, includes its non-standard ebike counts in the bike counts
+type bixiNormalizer struct{}
+
+func (bixiNormalizer) StationStatus(data json.RawMessage) ([]StationStatus, error) {
+	records, err := decodeStations[bixiStationStatus](data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make([]StationStatus, 0, len(records))
+
+	for _, record := range records {
+		station := StationStatus{
+			StationStatus:   record.StationStatus,
+			EbikesAvailable: record.NumEbikesAvailable,
+			EbikesDisabled:  record.NumEbikesDisabled,
+		}
+
+		station.NumBikesAvailable -= coalesce(record.NumEbikesAvailable, 0)
+
+		if record.NumBikesDisabled != nil {
+			disabled := *record.NumBikesDisabled - coalesce(record.NumEbikesDisabled, 0)
+			station.NumBikesDisabled = &disabled
+		}
+
+		stations = append(stations, station)
+	}
+
+	return stations, nil
+}
+
+func (bixiNormalizer) StationInformation(data json.RawMessage) ([]StationInformation, error) {
+	records, err := decodeStations[bixiStationInformation](data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make([]StationInformation, 0, len(records))
+
+	for _, record := range records {
+		stations = append(stations, StationInformation{
+			StationInformationStation:   record.StationInformationStation,
+			HasKiosk:                    record.HasKiosk,
+			ElectricBikeSurchargeWaiver: record.ElectricBikeSurchargeWaiver,
+			IsCharging:                  record.IsCharging,
+		})
+	}
+
+	return stations, nil
+}
@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+func TestNormalizers(t *testing.T) {
+	tests := []struct {
+		name   string
+		system string
+		data   string
+		want   StationStatus
+	}{
+		{
+			name:   "bixi subtracts ebikes",
+			system: "bixi",
+			data:   `{"num_bikes_available": 7, "num_bikes_disabled": 2, "num_ebikes_available": 3, "num_ebikes_disabled": 1}`,
+			want:   StationStatus{StationStatus: v1_0.StationStatus{NumBikesAvailable: 4, NumBikesDisabled: ptr[int64](1)}, EbikesAvailable: ptr[int64](3), EbikesDisabled: ptr[int64](1)},
+		},
+		{
+			name:   "bixi without ebikes",
+			system: "bixi",
+			data:   `{"num_bikes_available": 7}`,
+			want:   StationStatus{StationStatus: v1_0.StationStatus{NumBikesAvailable: 7}},
+		},
+		{
+			name:   "generic ignores non-standard ebikes",
+			system: "generic",
+			data:   `{"num_bikes_available": 7, "num_bikes_disabled": 2, "num_ebikes_available": 3, "num_ebikes_disabled": 1}`,
+			want:   StationStatus{StationStatus: v1_0.StationStatus{NumBikesAvailable: 7, NumBikesDisabled: ptr[int64](2)}},
+		},
+		{
+			name:   "generic reads GBFS 2.x booleans",
+			system: "generic",
+			data:   `{"num_bikes_available": 7, "num_docks_available": 5, "is_installed": true, "is_renting": false}`,
+			want:   StationStatus{StationStatus: v1_0.StationStatus{NumBikesAvailable: 7, NumDocksAvailable: 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalizer, err := NormalizerFor(tt.system)
+
+			if err != nil {
+				t.Fatalf("NormalizerFor() error = %v", err)
+			}
+
+			stations, err := normalizer.StationStatus(json.RawMessage(`{"stations": [` + tt.data + `]}`))
+
+			if err != nil || len(stations) != 1 {
+				t.Fatalf("StationStatus() = %+v, %v, want one station", stations, err)
+			}
+
+			station := stations[0]
+
+			if station.NumBikesAvailable != tt.want.NumBikesAvailable || station.NumDocksAvailable != tt.want.NumDocksAvailable {
+				t.Errorf("bikes, docks = %d, %d, want %d, %d", station.NumBikesAvailable, station.NumDocksAvailable, tt.want.NumBikesAvailable, tt.want.NumDocksAvailable)
+			}
+
+			for _, count := range []struct {
+				name      string
+				got, want *int64
+			}{
+				{"num_bikes_disabled", station.NumBikesDisabled, tt.want.NumBikesDisabled},
+				{"ebikes_available", station.EbikesAvailable, tt.want.EbikesAvailable},
+				{"ebikes_disabled", station.EbikesDisabled, tt.want.EbikesDisabled},
+			} {
+				if (count.got == nil) != (count.want == nil) || (count.got != nil && *count.got != *count.want) {
+					t.Errorf("%s = %v, want %v", count.name, count.got, count.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizersStationInformation(t *testing.T) {
+	data := json.RawMessage(`{"stations": [{"station_id": "1", "name": "Metcalfe", "has_kiosk": true, "electric_bike_surcharge_waiver": true, "is_charging": true}]}`)
+
+	tests := []struct {
+		system string
+		want   bool
+	}{
+		{system: "bixi", want: true},
+		{system: "generic", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.system, func(t *testing.T) {
+			normalizer, _ := NormalizerFor(tt.system)
+			stations, err := normalizer.StationInformation(data)
+
+			if err != nil || len(stations) != 1 || stations[0].StationID != "1" || stations[0].Name != "Metcalfe" {
+				t.Fatalf("StationInformation() = %+v, %v, want station 1", stations, err)
+			}
+
+			station := stations[0]
+
+			if (station.HasKiosk != nil) != tt.want || station.ElectricBikeSurchargeWaiver != tt.want || station.IsCharging != tt.want {
+				t.Errorf("StationInformation() = %+v, want BIXI metadata %t", station, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizerForUnknownSystem(t *testing.T) {
+	if _, err := NormalizerFor("velib"); err == nil {
+		t.Error("NormalizerFor() error = nil, want unknown system error")
+	}
+}
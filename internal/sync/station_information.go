@@ -2,76 +2,96 @@ package sync
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"log/slog"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/metrics"
+	"github.com/ngc7293/hixi/internal/store"
 )
 
-func FetchStationInformationOnce(pool *pgxpool.Pool, url string) (int64, error) {
-	stationInformation, err := FetchDocument[v1_0.StationInformationData](url)
+const stationInformationFeed = "station_information"
+
+func FetchStationInformationOnce(ctx context.Context, st store.Store, fetcher Fetcher, url string, timeout time.Duration, normalizer Normalizer) (int64, error) {
+	stationInformation, now, err := fetchFeed[json.RawMessage](ctx, fetcher, stationInformationFeed, url, timeout)
 
 	if err != nil {
 		return 0, err
 	}
 
-	tx, err := pool.Begin(context.Background())
+	stations, err := normalizer.StationInformation(stationInformation.Data)
 
 	if err != nil {
-		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, err
 	}
 
-	defer tx.Rollback(context.Background())
-
-	for _, station := range stationInformation.Data.Stations {
-		_, err = tx.Exec(
-			context.Background(),
-			`
-			INSERT INTO "public"."station" (
-				"external_id",
-				"name",
-				"location",
-                "capacity"
-			) VALUES (
-				$1,
-				$2,
-			 	$3,
-				$4
-			) ON CONFLICT ("external_id") DO UPDATE SET
-				"external_id" = excluded."external_id",
-				"name" =  excluded."name",
-				"location" =  excluded."location",
-				"capacity" =  excluded."capacity"
-			`,
-			station.StationID,
-			station.Name,
-			fmt.Sprintf("POINT(%f %f)", station.Lon, station.Lat),
-			station.Capacity,
-		)
-
-		if err != nil {
-			return 0, fmt.Errorf("failed to insert station availability: %w", err)
+	metrics.FeedUpdated(stationInformationFeed, stationInformation.LastUpdated)
+
+	// Once the document is fetched, let the transaction complete even if we
+	// are asked to shut down
+	ctx = context.WithoutCancel(ctx)
+
+	// Changes are dated by the feed, so that replayed documents rebuild the
+	// same station history
+	updated := time.Unix(stationInformation.LastUpdated, 0)
+	listed := []string{}
+
+	err = st.InTx(ctx, func(tx store.Store) error {
+		for _, station := range stations {
+			listed = append(listed, station.StationID)
+			err := tx.Stations().Upsert(ctx, store.StationInformation{
+				ExternalID: station.StationID,
+				Name:       station.Name,
+				ShortName:  station.ShortName,
+				Lon:        station.Lon,
+				Lat:        station.Lat,
+				Capacity:   station.Capacity,
+				Metadata: store.StationMetadata{
+					Address:                     station.Address,
+					CrossStreet:                 station.CrossStreet,
+					RegionID:                    station.RegionID,
+					PostCode:                    station.PostCode,
+					RentalMethods:               station.RentalMethods,
+					HasKiosk:                    station.HasKiosk,
+					ElectricBikeSurchargeWaiver: station.ElectricBikeSurchargeWaiver,
+					IsCharging:                  station.IsCharging,
+				},
+			}, updated)
+
+			if err != nil {
+				return err
+			}
 		}
-	}
 
-	err = tx.Commit(context.Background())
+		// An empty feed is more likely an operator outage than every station
+		// being removed at once
+		if len(listed) > 0 {
+			decommissioned, err := tx.Stations().Decommission(ctx, listed, updated)
+
+			if err != nil {
+				return err
+			}
+
+			if decommissioned > 0 {
+				slog.Info("decommissioned stations missing from station_information", "count", decommissioned)
+			}
+		}
+
+		return tx.SyncState().Record(ctx, stationInformationFeed, updated, now)
+	})
 
 	if err != nil {
-		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, err
 	}
 
+	metrics.RecordSnapshot(stationInformationFeed, len(stations), 0, len(stations))
+
 	return stationInformation.TTL, nil
 }
 
-func FetchStationInformationLoop(pool *pgxpool.Pool, feedUrl string) error {
-	for {
-		ttl, err := FetchStationInformationOnce(pool, feedUrl)
-
-		if err != nil {
-			return fmt.Errorf("failed to sync station information: %w", err)
-		}
-
-		time.Sleep(time.Duration(ttl) * time.Second)
-	}
+func FetchStationInformationLoop(ctx context.Context, st store.Store, fetcher Fetcher, feedUrl string, feed config.Feed, normalizer Normalizer) error {
+	return runFeedLoop(ctx, stationInformationFeed, feed, true, func(ctx context.Context) (int64, error) {
+		return FetchStationInformationOnce(ctx, st, fetcher, feedUrl, feed.Timeout, normalizer)
+	})
 }
@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/gbfstest"
+	"github.com/ngc7293/hixi/internal/store/memory"
+)
+
+func TestFetchStationInformationOnce(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	moved := stationInformation("1", "2")
+	moved.Stations[0].Lat += 0.002
+	moved.Stations[0].Address = ptr("Metcalfe / du Square-Dorchester")
+	moved.Stations[0].RentalMethods = []string{"KEY", "CREDITCARD"}
+	moved.Stations[0].HasKiosk = ptr(true)
+	removed := stationsData[bixiStationInformation]{Stations: moved.Stations[:1]}
+
+	operator.Push("station_information", start, 60, stationInformation("1", "2"))
+	operator.Push("station_information", start.Add(time.Minute), 60, moved)
+	operator.Push("station_information", start.Add(2*time.Minute), 60, removed)
+	operator.Push("station_information", start.Add(3*time.Minute), 60, stationsData[bixiStationInformation]{}) // outage
+
+	for range 4 {
+		fetchOnce(t, st, operator, "station_information", stationInformationOnce("bixi"))
+	}
+
+	one, _ := st.Stations().FindOrCreate(ctx, "1")
+	two, _ := st.Stations().FindOrCreate(ctx, "2")
+
+	if versions, _ := st.Stations().History(ctx, one.ID); len(versions) != 2 || versions[1].ValidTo != nil || !versions[1].ValidFrom.Equal(start.Add(time.Minute)) {
+		t.Errorf("History(1) = %+v, want moved at %s", versions, start.Add(time.Minute))
+	}
+
+	if versions, _ := st.Stations().History(ctx, two.ID); len(versions) != 1 || versions[0].ValidTo == nil || !versions[0].ValidTo.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("History(2) = %+v, want decommissioned at %s", versions, start.Add(2*time.Minute))
+	}
+
+	if station, _ := st.Stations().Get(ctx, one.ID); station.Metadata.Address == nil || !station.Metadata.Offers("CREDITCARD") || station.Metadata.HasKiosk == nil || !*station.Metadata.HasKiosk {
+		t.Errorf("Get(1) = %+v, want the metadata of the moved station", station)
+	}
+}
@@ -2,17 +2,24 @@ package sync
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/metrics"
+	"github.com/ngc7293/hixi/internal/store"
 
 	"github.com/ngc7293/hixi/pkg/gbfs"
-	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
 )
 
+const stationStatusFeed = "station_status"
+
+// ErrFeedNotFound is returned when the GBFS discovery document does not list
+// a feed, which operators may omit for optional feeds
+var ErrFeedNotFound = errors.New("feed not found in GBFS discovery document")
+
 func coalesce[T any](pointer *T, def T) T {
 	if pointer != nil {
 		return *pointer
@@ -48,125 +55,119 @@ func FindFeedURLWithLanguage(discovery gbfs.GBFSDiscoveryData, feedName string,
 		}
 	}
 
-	return "", "", fmt.Errorf("no station status URL found in GBFS discovery document")
+	return "", "", fmt.Errorf("%w: %s", ErrFeedNotFound, feedName)
 }
 
-func fetchStationLastReportedOrInsert(tx pgx.Tx, stationID string) (*time.Time, error) {
-	var lastReported time.Time
-	err := tx.QueryRow(
-		context.Background(),
-		`SELECT "last_status_reported" FROM "public"."station" WHERE "external_id" = $1`,
-		stationID,
-	).Scan(&lastReported)
+func FetchStationStatusOnce(ctx context.Context, st store.Store, fetcher Fetcher, url string, timeout time.Duration, normalizer Normalizer, rules config.Validation) (int64, error) {
+	stationStatus, now, err := fetchFeed[json.RawMessage](ctx, fetcher, stationStatusFeed, url, timeout)
 
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		return nil, err
+	if err != nil {
+		return 0, err
 	}
 
-	if errors.Is(err, pgx.ErrNoRows) {
-		lastReported = time.Now()
-
-		_, err = tx.Exec(
-			context.Background(),
-			`INSERT INTO "public"."station" ("external_id", "last_status_reported") VALUES ($1, $2)`,
-			stationID,
-			lastReported,
-		)
+	stations, err := normalizer.StationStatus(stationStatus.Data)
 
-		if err != nil {
-			return nil, err
-		}
+	if err != nil {
+		return 0, err
 	}
 
-	return &lastReported, nil
-}
+	metrics.FeedUpdated(stationStatusFeed, stationStatus.LastUpdated)
 
-func FetchStationStatusOnce(pool *pgxpool.Pool, url string) (int64, error) {
-	stationStatus, err := FetchDocument[v1_0.StationStatusData](url)
+	// Once the document is fetched, let the transaction complete even if we
+	// are asked to shut down
+	ctx = context.WithoutCancel(ctx)
 
-	if err != nil {
-		return 0, err
-	}
+	skipped, inserted := 0, 0
 
-	tx, err := pool.Begin(context.Background())
+	err = st.InTx(ctx, func(tx store.Store) error {
+		// Vehicle type ids, by GBFS vehicle_type_id
+		vehicleTypes := map[string]int64{}
 
-	if err != nil {
-		return 0, fmt.Errorf("failed to begin transaction: %w", err)
-	}
+		for _, station := range stations {
+			known, err := tx.Stations().FindOrCreate(ctx, station.StationID)
 
-	defer tx.Rollback(context.Background())
+			if err != nil {
+				return fmt.Errorf("failed to getsert station: %w", err)
+			}
 
-	for _, station := range stationStatus.Data.Stations {
-		lastReported, err := fetchStationLastReportedOrInsert(tx, station.StationID)
+			reported := time.Unix(station.LastReported, 0)
 
-		if err != nil {
-			return 0, fmt.Errorf("failed to getsert station: %w", err)
-		}
+			if known.LastStatusReported != nil && !reported.After(*known.LastStatusReported) {
+				skipped++
+				continue
+			}
 
-		if lastReported != nil && !time.Unix(station.LastReported, 0).After(*lastReported) {
-			continue
-		}
+			issues := validateStationStatus(rules, station, *known, now)
 
-		// Fix up data
-		station.NumBikesAvailable = station.NumBikesAvailable - coalesce(station.NumEbikesAvailable, 0)
+			if len(issues) > 0 {
+				err = recordQualityIssues(ctx, tx, stationStatusFeed, stationStatus.LastUpdated, station.StationID, station, issues, now)
 
-		if station.NumBikesDisabled != nil {
-			*station.NumBikesDisabled = *station.NumBikesDisabled - coalesce(station.NumEbikesDisabled, 0)
-		}
+				if err != nil {
+					return err
+				}
+
+				if quarantined(issues) {
+					skipped++
+					continue
+				}
+			}
 
-		_, err = tx.Exec(
-			context.Background(),
-			`INSERT INTO "public"."live_station_availability" (
-					"time",
-					"station_id",
-					"bikes_available",
-					"bikes_disabled",
-					"ebikes_available",
-					"ebikes_disabled",
-					"docks_available",
-					"docks_disabled"
-				) VALUES (
-					$1,
-					(SELECT "id" FROM "public"."station" WHERE "external_id" = $2),
-					$3,
-					$4,
-					$5,
-					$6,
-					$7,
-					$8
-			)`,
-			time.Now(),
-			station.StationID,
-			station.NumBikesAvailable,
-			station.NumBikesDisabled,
-			station.NumEbikesAvailable,
-			station.NumEbikesDisabled,
-			station.NumDocksAvailable,
-			station.NumDocksDisabled,
-		)
-
-		if err != nil {
-			return 0, fmt.Errorf("failed to insert station availability: %w", err)
+			availability := store.Availability{
+				Time:            now,
+				StationID:       known.ID,
+				BikesAvailable:  station.NumBikesAvailable,
+				BikesDisabled:   station.NumBikesDisabled,
+				EbikesAvailable: station.EbikesAvailable,
+				EbikesDisabled:  station.EbikesDisabled,
+				DocksAvailable:  station.NumDocksAvailable,
+				DocksDisabled:   station.NumDocksDisabled,
+			}
+
+			for _, vehicles := range station.VehicleTypesAvailable {
+				id, ok := vehicleTypes[vehicles.VehicleTypeID]
+
+				if !ok {
+					id, err = tx.VehicleTypes().FindOrCreate(ctx, vehicles.VehicleTypeID)
+
+					if err != nil {
+						return err
+					}
+
+					vehicleTypes[vehicles.VehicleTypeID] = id
+				}
+
+				availability.Vehicles = append(availability.Vehicles, store.VehicleAvailability{VehicleTypeID: id, Available: vehicles.Count})
+			}
+
+			err = tx.Availability().Insert(ctx, availability)
+
+			if err != nil {
+				return err
+			}
+
+			err = tx.Stations().SetLastReported(ctx, known.ID, reported)
+
+			if err != nil {
+				return err
+			}
+
+			inserted++
 		}
-	}
 
-	err = tx.Commit(context.Background())
+		return tx.SyncState().Record(ctx, stationStatusFeed, time.Unix(stationStatus.LastUpdated, 0), now)
+	})
 
 	if err != nil {
-		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, err
 	}
 
+	metrics.RecordSnapshot(stationStatusFeed, len(stations), skipped, inserted)
+
 	return stationStatus.TTL, nil
 }
 
-func FetchStationStatusLoop(pool *pgxpool.Pool, feedUrl string) error {
-	for {
-		ttl, err := FetchStationStatusOnce(pool, feedUrl)
-
-		if err != nil {
-			return fmt.Errorf("failed to sync station status: %w", err)
-		}
-
-		time.Sleep(time.Duration(ttl) * time.Second)
-	}
+func FetchStationStatusLoop(ctx context.Context, st store.Store, fetcher Fetcher, feedUrl string, feed config.Feed, normalizer Normalizer, rules config.Validation) error {
+	return runFeedLoop(ctx, stationStatusFeed, feed, true, func(ctx context.Context) (int64, error) {
+		return FetchStationStatusOnce(ctx, st, fetcher, feedUrl, feed.Timeout, normalizer, rules)
+	})
 }
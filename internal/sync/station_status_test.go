@@ -1,8 +1,15 @@
 package sync
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/gbfstest"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/store/memory"
 	"github.com/ngc7293/hixi/pkg/gbfs"
 )
 
@@ -74,3 +81,98 @@ func TestGetStationStatusURL(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchStationStatusOnce(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+
+	rules := config.Default().Validation
+	rules.NegativeCount = config.ValidationQuarantine
+	normalizer, _ := NormalizerFor("bixi")
+	now := time.Now().Truncate(time.Second)
+
+	operator.Push("station_information", now, 3600, stationInformation("1", "2"))
+	operator.Push("station_status", now, 10, statuses(
+		status("1", now.Add(-time.Minute), 5, 2, 13),
+		status("2", now.Add(-time.Minute), 1, 3, 16), // quarantined once normalized
+	))
+	operator.Push("station_status", now.Add(10*time.Second), 10, statuses(
+		status("1", now.Add(-time.Minute), 5, 2, 13), // unchanged report
+		status("2", now, 4, 3, 13),
+	))
+
+	if _, err := FetchStationInformationOnce(ctx, st, HTTPFetcher{}, operator.FeedURL("station_information"), time.Second, normalizer); err != nil {
+		t.Fatalf("FetchStationInformationOnce() error = %v", err)
+	}
+
+	one, _ := st.Stations().FindOrCreate(ctx, "1")
+	two, _ := st.Stations().FindOrCreate(ctx, "2")
+
+	if _, err := FetchStationStatusOnce(ctx, st, HTTPFetcher{}, operator.FeedURL("station_status"), time.Second, normalizer, rules); err != nil {
+		t.Fatalf("FetchStationStatusOnce() error = %v", err)
+	}
+
+	latest, err := st.Availability().Latest(ctx, one.ID)
+
+	if err != nil || latest.BikesAvailable != 3 {
+		t.Errorf("Latest(1) = %+v, %v, want ebikes subtracted: 3 bikes", latest, err)
+	}
+
+	if _, err := st.Availability().Latest(ctx, two.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Latest(2) error = %v, want quarantined", err)
+	}
+
+	if _, err := FetchStationStatusOnce(ctx, st, HTTPFetcher{}, operator.FeedURL("station_status"), time.Second, normalizer, rules); err != nil {
+		t.Fatalf("FetchStationStatusOnce() error = %v", err)
+	}
+
+	if again, _ := st.Availability().Latest(ctx, one.ID); again == nil || !again.Time.Equal(latest.Time) {
+		t.Errorf("Latest(1) = %+v, want unchanged report skipped", again)
+	}
+
+	if latest, _ := st.Availability().Latest(ctx, two.ID); latest == nil || latest.BikesAvailable != 1 {
+		t.Errorf("Latest(2) = %+v, want 1 bike", latest)
+	}
+
+	issues, _ := st.Quality().List(ctx, store.QualityFilter{Rule: "negative_count"})
+
+	if len(issues) != 1 || !issues[0].Quarantined || issues[0].StationExternalID != "2" {
+		t.Errorf("negative_count issues = %+v, want station 2 quarantined", issues)
+	}
+
+	state, err := st.SyncState().Get(ctx, stationStatusFeed)
+
+	if err != nil || state.LastUpdated == nil || !state.LastUpdated.Equal(now.Add(10*time.Second)) {
+		t.Errorf("sync state = %+v, %v, want last_updated of the second snapshot", state, err)
+	}
+}
+
+func TestFindFeedURLWithLanguage(t *testing.T) {
+	discovery := gbfs.GBFSDiscoveryData{
+		"en": {Feeds: []gbfs.GBFSFeed{{Name: "station_status", URL: "https://example.com/en/station_status.json"}}},
+		"fr": {Feeds: []gbfs.GBFSFeed{
+			{Name: "station_status", URL: "https://example.com/fr/station_status.json"},
+			{Name: "system_regions", URL: "https://example.com/fr/system_regions.json"},
+		}},
+	}
+
+	tests := []struct {
+		feed     string
+		wantURL  string
+		wantLang string
+		wantErr  error
+	}{
+		{feed: "station_status", wantURL: "https://example.com/en/station_status.json", wantLang: "en"},
+		{feed: "system_regions", wantURL: "https://example.com/fr/system_regions.json", wantLang: "fr"},
+		{feed: "system_alerts", wantErr: ErrFeedNotFound},
+	}
+
+	for _, tt := range tests {
+		url, lang, err := FindFeedURLWithLanguage(discovery, tt.feed, "en")
+
+		if url != tt.wantURL || lang != tt.wantLang || !errors.Is(err, tt.wantErr) {
+			t.Errorf("FindFeedURLWithLanguage(%s) = %q, %q, %v, want %q, %q, %v", tt.feed, url, lang, err, tt.wantURL, tt.wantLang, tt.wantErr)
+		}
+	}
+}
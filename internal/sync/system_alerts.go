@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/pkg/gbfs"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+const systemAlertsFeed = "system_alerts"
+
+func FetchSystemAlertsOnce(ctx context.Context, st store.Store, fetcher Fetcher, url string, timeout time.Duration) (int64, error) {
+	return syncFeed(ctx, st, fetcher, systemAlertsFeed, url, timeout, func(ctx context.Context, tx store.Store, systemAlerts *gbfs.GBFSDocument[v1_0.SystemAlertsData]) error {
+		updated := time.Unix(systemAlerts.LastUpdated, 0)
+		listed := []string{}
+
+		for _, alert := range systemAlerts.Data.Alerts {
+			listed = append(listed, alert.AlertID)
+			stored := store.Alert{
+				ExternalID:  alert.AlertID,
+				Type:        alert.Type,
+				StationIDs:  alert.StationIDs,
+				RegionIDs:   alert.RegionIDs,
+				URL:         alert.URL,
+				Summary:     alert.Summary,
+				Description: alert.Description,
+			}
+
+			for _, window := range alert.Times {
+				decoded := store.AlertWindow{Start: time.Unix(window.Start, 0)}
+
+				if window.End != nil {
+					end := time.Unix(*window.End, 0)
+					decoded.End = &end
+				}
+
+				stored.Times = append(stored.Times, decoded)
+			}
+
+			if alert.LastUpdated != nil {
+				lastUpdated := time.Unix(*alert.LastUpdated, 0)
+				stored.LastUpdated = &lastUpdated
+			}
+
+			if err := tx.Alerts().Upsert(ctx, stored); err != nil {
+				return err
+			}
+		}
+
+		// Unlike stations, an empty feed is the usual state of system_alerts
+		withdrawn, err := tx.Alerts().Withdraw(ctx, listed, updated)
+
+		if err != nil {
+			return err
+		}
+
+		if withdrawn > 0 {
+			slog.Info("withdrew alerts missing from system_alerts", "count", withdrawn)
+		}
+
+		return nil
+	})
+}
+
+func FetchSystemAlertsLoop(ctx context.Context, st store.Store, fetcher Fetcher, feedUrl string, feed config.Feed) error {
+	return runFeedLoop(ctx, systemAlertsFeed, feed, false, func(ctx context.Context) (int64, error) {
+		return FetchSystemAlertsOnce(ctx, st, fetcher, feedUrl, feed.Timeout)
+	})
+}
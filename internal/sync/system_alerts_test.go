@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/gbfstest"
+	"github.com/ngc7293/hixi/internal/store/memory"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+func TestFetchSystemAlertsOnce(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	end := start.Add(2 * time.Hour).Unix()
+
+	closure := v1_0.SystemAlert{
+		AlertID:    "1",
+		Type:       v1_0.AlertStationClosure,
+		Times:      []v1_0.SystemAlertTime{{Start: start.Unix(), End: &end}},
+		StationIDs: []string{"42"},
+		Summary:    "Station closed for roadwork",
+	}
+	outage := v1_0.SystemAlert{AlertID: "2", Type: v1_0.AlertSystemClosure, Summary: "Payment outage"}
+
+	operator.Push("system_alerts", start, 60, v1_0.SystemAlertsData{Alerts: []v1_0.SystemAlert{closure, outage}})
+	operator.Push("system_alerts", start.Add(time.Minute), 60, v1_0.SystemAlertsData{Alerts: []v1_0.SystemAlert{closure}})
+	operator.Push("system_alerts", start.Add(2*time.Minute), 60, v1_0.SystemAlertsData{Alerts: []v1_0.SystemAlert{}})
+
+	for range 2 {
+		fetchOnce(t, st, operator, "system_alerts", FetchSystemAlertsOnce)
+	}
+
+	alerts, err := st.Alerts().List(ctx, start.Add(time.Minute))
+
+	if err != nil || len(alerts) != 1 || alerts[0].ExternalID != "1" || !slices.Equal(alerts[0].StationIDs, closure.StationIDs) {
+		t.Fatalf("List() = %+v, %v, want only the station closure", alerts, err)
+	}
+
+	if len(alerts[0].Times) != 1 || !alerts[0].Times[0].Start.Equal(start) || alerts[0].Times[0].End == nil || alerts[0].Times[0].End.Unix() != end {
+		t.Errorf("List()[0].Times = %+v, want one window from %s", alerts[0].Times, start)
+	}
+
+	if !alerts[0].ActiveAt(start.Add(time.Minute)) || !alerts[0].Affects("42", nil) || alerts[0].Affects("43", nil) {
+		t.Errorf("List()[0] = %+v, want active for station 42 only", alerts[0])
+	}
+
+	// An empty feed withdraws every alert
+	fetchOnce(t, st, operator, "system_alerts", FetchSystemAlertsOnce)
+
+	if alerts, err := st.Alerts().List(ctx, start.Add(2*time.Minute)); err != nil || len(alerts) != 0 {
+		t.Errorf("List() = %+v, %v, want no published alert", alerts, err)
+	}
+
+	assertSyncedAt(t, st, "system_alerts", start.Add(2*time.Minute))
+}
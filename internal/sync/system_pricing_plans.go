@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/pkg/gbfs"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+const systemPricingPlansFeed = "system_pricing_plans"
+
+func pricingSegments(segments []v1_0.PricingSegment) []store.PricingSegment {
+	var result []store.PricingSegment
+
+	for _, segment := range segments {
+		result = append(result, store.PricingSegment{
+			Start:    segment.Start,
+			Rate:     segment.Rate,
+			Interval: segment.Interval,
+			End:      segment.End,
+		})
+	}
+
+	return result
+}
+
+func FetchSystemPricingPlansOnce(ctx context.Context, st store.Store, fetcher Fetcher, url string, timeout time.Duration) (int64, error) {
+	return syncFeed(ctx, st, fetcher, systemPricingPlansFeed, url, timeout, func(ctx context.Context, tx store.Store, systemPricingPlans *gbfs.GBFSDocument[v1_0.SystemPricingPlansData]) error {
+		updated := time.Unix(systemPricingPlans.LastUpdated, 0)
+		listed := []string{}
+
+		for _, plan := range systemPricingPlans.Data.Plans {
+			listed = append(listed, plan.PlanID)
+
+			err := tx.PricingPlans().Upsert(ctx, store.PricingPlan{
+				PlanID:        plan.PlanID,
+				Name:          plan.Name,
+				Currency:      plan.Currency,
+				Price:         plan.Price,
+				IsTaxable:     bool(plan.IsTaxable),
+				Description:   plan.Description,
+				URL:           plan.URL,
+				PerKmPricing:  pricingSegments(plan.PerKmPricing),
+				PerMinPricing: pricingSegments(plan.PerMinPricing),
+			}, updated)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		withdrawn, err := tx.PricingPlans().Withdraw(ctx, listed, updated)
+
+		if err != nil {
+			return err
+		}
+
+		if withdrawn > 0 {
+			slog.Info("withdrew plans missing from system_pricing_plans", "count", withdrawn)
+		}
+
+		return nil
+	})
+}
+
+func FetchSystemPricingPlansLoop(ctx context.Context, st store.Store, fetcher Fetcher, feedUrl string, feed config.Feed) error {
+	return runFeedLoop(ctx, systemPricingPlansFeed, feed, false, func(ctx context.Context) (int64, error) {
+		return FetchSystemPricingPlansOnce(ctx, st, fetcher, feedUrl, feed.Timeout)
+	})
+}
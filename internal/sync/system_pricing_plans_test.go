@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/gbfstest"
+	"github.com/ngc7293/hixi/internal/store/memory"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+func TestFetchSystemPricingPlansOnce(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	// GBFS 1.x publishes is_taxable as 0 or 1
+	operator.PushRaw("system_pricing_plans", fmt.Sprintf(`{"last_updated": %d, "ttl": 60, "data": {"plans": [
+		{"plan_id": "single", "name": "Single trip", "currency": "CAD", "price": 1.25, "is_taxable": 1, "description": "Per minute",
+		 "per_min_pricing": [{"start": 0, "rate": 0.15, "interval": 1, "end": 45}, {"start": 45, "rate": 0.3, "interval": 1}]},
+		{"plan_id": "day", "name": "Day pass", "currency": "CAD", "price": 7, "is_taxable": 0, "description": "24 hours"}
+	]}}`, start.Unix()))
+	operator.Push("system_pricing_plans", start.Add(time.Minute), 60, v1_0.SystemPricingPlansData{Plans: []v1_0.PricingPlan{
+		{PlanID: "single", Name: "Single trip", Currency: "CAD", Price: 1.5, IsTaxable: true, Description: "Per minute"},
+	}})
+
+	for range 2 {
+		fetchOnce(t, st, operator, "system_pricing_plans", FetchSystemPricingPlansOnce)
+	}
+
+	plans, err := st.PricingPlans().List(ctx, start, start)
+
+	if err != nil || len(plans) != 2 {
+		t.Fatalf("List() = %+v, %v, want 2 plans", plans, err)
+	}
+
+	if day := plans[0]; day.PlanID != "day" || day.IsTaxable || day.ValidTo == nil || !day.ValidTo.Equal(start.Add(time.Minute)) {
+		t.Errorf("List()[0] = %+v, want the day pass withdrawn as of %s", day, start.Add(time.Minute))
+	}
+
+	if single := plans[1]; !single.IsTaxable || len(single.PerMinPricing) != 2 || single.PerMinPricing[0].End == nil || *single.PerMinPricing[0].End != 45 || single.PerMinPricing[1].End != nil {
+		t.Errorf("List()[1] = %+v, want two per minute segments", single)
+	}
+
+	current, err := st.PricingPlans().List(ctx, start.Add(time.Minute), start.Add(time.Minute))
+
+	if err != nil || len(current) != 1 || current[0].Price != 1.5 || len(current[0].PerMinPricing) != 0 {
+		t.Errorf("List() = %+v, %v, want the repriced single trip", current, err)
+	}
+
+	assertSyncedAt(t, st, "system_pricing_plans", start.Add(time.Minute))
+
+	malformed := gbfstest.NewOperator(t)
+	malformed.PushRaw("system_pricing_plans", `{"last_updated": 0, "ttl": 60, "data": {"plans": [{"plan_id": "bad", "is_taxable": "yes"}]}}`)
+
+	if _, err := FetchSystemPricingPlansOnce(ctx, st, HTTPFetcher{}, malformed.FeedURL("system_pricing_plans"), time.Second); err == nil {
+		t.Error("FetchSystemPricingPlansOnce() with is_taxable \"yes\" succeeded, want error")
+	}
+}
@@ -0,0 +1,32 @@
+package sync
+
+import (
+	"context"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/pkg/gbfs"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+const systemRegionsFeed = "system_regions"
+
+func FetchSystemRegionsOnce(ctx context.Context, st store.Store, fetcher Fetcher, url string, timeout time.Duration) (int64, error) {
+	// Regions missing from the feed are kept, along with their history
+	return syncFeed(ctx, st, fetcher, systemRegionsFeed, url, timeout, func(ctx context.Context, tx store.Store, systemRegions *gbfs.GBFSDocument[v1_0.SystemRegionsData]) error {
+		for _, region := range systemRegions.Data.Regions {
+			if err := tx.Regions().Upsert(ctx, region.RegionID, region.Name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func FetchSystemRegionsLoop(ctx context.Context, st store.Store, fetcher Fetcher, feedUrl string, feed config.Feed) error {
+	return runFeedLoop(ctx, systemRegionsFeed, feed, false, func(ctx context.Context) (int64, error) {
+		return FetchSystemRegionsOnce(ctx, st, fetcher, feedUrl, feed.Timeout)
+	})
+}
@@ -0,0 +1,33 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/gbfstest"
+	"github.com/ngc7293/hixi/internal/store/memory"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+func TestFetchSystemRegionsOnce(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	operator.Push("system_regions", start, 60, v1_0.SystemRegionsData{Regions: []v1_0.SystemRegion{{RegionID: "1", Name: "Ville-Marie"}, {RegionID: "2", Name: "Plateau"}}})
+	operator.Push("system_regions", start.Add(time.Minute), 60, v1_0.SystemRegionsData{Regions: []v1_0.SystemRegion{{RegionID: "1", Name: "Centre-ville"}}})
+
+	for range 2 {
+		fetchOnce(t, st, operator, "system_regions", FetchSystemRegionsOnce)
+	}
+
+	regions, err := st.Regions().List(ctx)
+
+	if err != nil || len(regions) != 2 || regions[0].Name != "Centre-ville" || regions[1].Name != "Plateau" {
+		t.Errorf("List() = %+v, %v, want region 1 renamed and region 2 kept", regions, err)
+	}
+
+	assertSyncedAt(t, st, "system_regions", start.Add(time.Minute))
+}
@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/metrics"
+	"github.com/ngc7293/hixi/internal/store"
+)
+
+// How far in the future last_reported may be before it is considered bogus,
+// to allow for clock skew between us and the operator
+const maxReportSkew = 5 * time.Minute
+
+type qualityIssue struct {
+	rule       string
+	message    string
+	quarantine bool
+}
+
+// validateStationStatus checks one normalized station_status record
+// against the configured rules
+func validateStationStatus(rules config.Validation, station StationStatus, known store.KnownStation, now time.Time) []qualityIssue {
+	issues := []qualityIssue{}
+
+	add := func(rule string, action string, format string, args ...any) {
+		if action == config.ValidationOff || action == "" {
+			return
+		}
+
+		issues = append(issues, qualityIssue{
+			rule:       rule,
+			message:    fmt.Sprintf(format, args...),
+			quarantine: action == config.ValidationQuarantine,
+		})
+	}
+
+	counts := []struct {
+		name  string
+		value *int64
+	}{
+		{"num_bikes_available", &station.NumBikesAvailable},
+		{"num_bikes_disabled", station.NumBikesDisabled},
+		{"ebikes_available", station.EbikesAvailable},
+		{"ebikes_disabled", station.EbikesDisabled},
+		{"num_docks_available", &station.NumDocksAvailable},
+		{"num_docks_disabled", station.NumDocksDisabled},
+	}
+
+	total := int64(0)
+
+	for _, count := range counts {
+		if count.value == nil {
+			continue
+		}
+
+		if *count.value < 0 {
+			add("negative_count", rules.NegativeCount, "%s is %d", count.name, *count.value)
+		}
+
+		total += *count.value
+	}
+
+	if known.Capacity != nil && total > *known.Capacity {
+		add("over_capacity", rules.OverCapacity, "bikes and docks add up to %d, capacity is %d", total, *known.Capacity)
+	}
+
+	if !known.Listed {
+		add("unknown_station", rules.UnknownStation, "station is not listed in station_information")
+	}
+
+	if reported := time.Unix(station.LastReported, 0); reported.After(now.Add(maxReportSkew)) {
+		add("future_report", rules.FutureReport, "last_reported is %s in the future", reported.Sub(now).Round(time.Second))
+	}
+
+	return issues
+}
+
+// quarantined reports whether any of issues requires dropping the record
+func quarantined(issues []qualityIssue) bool {
+	for _, issue := range issues {
+		if issue.quarantine {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordQualityIssues stores the issues found in one record of a feed
+// snapshot
+func recordQualityIssues(ctx context.Context, st store.Store, feed string, lastUpdated int64, stationID string, record any, issues []qualityIssue, at time.Time) error {
+	content, err := json.Marshal(record)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	dropped := quarantined(issues)
+
+	for _, issue := range issues {
+		err = st.Quality().Record(ctx, store.QualityIssue{
+			Time:              at,
+			Feed:              feed,
+			SnapshotUpdated:   time.Unix(lastUpdated, 0),
+			StationExternalID: stationID,
+			Rule:              issue.rule,
+			Message:           issue.message,
+			Quarantined:       dropped,
+			Record:            content,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		metrics.DataQualityIssues.WithLabelValues(feed, issue.rule).Inc()
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func TestValidateStationStatus(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	record := func() config.Validation {
+		return config.Validation{
+			NegativeCount:  config.ValidationRecord,
+			OverCapacity:   config.ValidationRecord,
+			UnknownStation: config.ValidationRecord,
+			FutureReport:   config.ValidationRecord,
+		}
+	}
+
+	station := func() StationStatus {
+		return StationStatus{
+			StationStatus: v1_0.StationStatus{
+				StationID:         "42",
+				NumBikesAvailable: 3,
+				NumDocksAvailable: 10,
+				LastReported:      now.Unix(),
+			},
+			EbikesAvailable: ptr[int64](2),
+		}
+	}
+
+	known := store.KnownStation{Listed: true, Capacity: ptr[int64](15)}
+
+	tests := []struct {
+		name           string
+		rules          func(rules *config.Validation)
+		station        func(station *StationStatus)
+		known          store.KnownStation
+		wantRules      []string
+		wantQuarantine bool
+	}{
+		{name: "valid", known: known},
+		{name: "at capacity", station: func(s *StationStatus) { s.NumDocksAvailable = 10 }, known: known},
+		{
+			name:      "negative bikes after ebike subtraction",
+			station:   func(s *StationStatus) { s.NumBikesAvailable = -1 },
+			known:     known,
+			wantRules: []string{"negative_count"},
+		},
+		{
+			name:      "over capacity",
+			station:   func(s *StationStatus) { s.NumDocksDisabled = ptr[int64](1) },
+			known:     known,
+			wantRules: []string{"over_capacity"},
+		},
+		{name: "unknown capacity", known: store.KnownStation{Listed: true}},
+		{
+			name:      "unknown station",
+			known:     store.KnownStation{},
+			wantRules: []string{"unknown_station"},
+		},
+		{
+			name:      "far future report",
+			station:   func(s *StationStatus) { s.LastReported = now.Add(time.Hour).Unix() },
+			known:     known,
+			wantRules: []string{"future_report"},
+		},
+		{
+			name:    "slight clock skew",
+			station: func(s *StationStatus) { s.LastReported = now.Add(time.Minute).Unix() },
+			known:   known,
+		},
+		{
+			name:           "quarantined",
+			rules:          func(r *config.Validation) { r.NegativeCount = config.ValidationQuarantine },
+			station:        func(s *StationStatus) { s.NumDocksAvailable = -2 },
+			known:          store.KnownStation{},
+			wantRules:      []string{"negative_count", "unknown_station"},
+			wantQuarantine: true,
+		},
+		{
+			name:    "rule off",
+			rules:   func(r *config.Validation) { r.UnknownStation = config.ValidationOff },
+			known:   store.KnownStation{},
+			station: func(s *StationStatus) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := record()
+			s := station()
+
+			if tt.rules != nil {
+				tt.rules(&rules)
+			}
+
+			if tt.station != nil {
+				tt.station(&s)
+			}
+
+			issues := validateStationStatus(rules, s, tt.known, now)
+
+			if len(issues) != len(tt.wantRules) {
+				t.Fatalf("validateStationStatus() = %+v, want rules %v", issues, tt.wantRules)
+			}
+
+			for i, issue := range issues {
+				if issue.rule != tt.wantRules[i] {
+					t.Errorf("issue %d rule = %s, want %s", i, issue.rule, tt.wantRules[i])
+				}
+			}
+
+			if quarantined(issues) != tt.wantQuarantine {
+				t.Errorf("quarantined() = %v, want %v", quarantined(issues), tt.wantQuarantine)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package sync
+
+import (
+	"context"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/pkg/gbfs"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+const vehicleTypesFeed = "vehicle_types"
+
+func FetchVehicleTypesOnce(ctx context.Context, st store.Store, fetcher Fetcher, url string, timeout time.Duration) (int64, error) {
+	// Vehicle types missing from the feed are kept, their availability
+	// history still references them
+	return syncFeed(ctx, st, fetcher, vehicleTypesFeed, url, timeout, func(ctx context.Context, tx store.Store, vehicleTypes *gbfs.GBFSDocument[v1_0.VehicleTypesData]) error {
+		for _, vehicleType := range vehicleTypes.Data.VehicleTypes {
+			err := tx.VehicleTypes().Upsert(ctx, store.VehicleType{
+				ExternalID:     vehicleType.VehicleTypeID,
+				FormFactor:     &vehicleType.FormFactor,
+				PropulsionType: &vehicleType.PropulsionType,
+				Name:           vehicleType.Name,
+				MaxRangeMeters: vehicleType.MaxRangeMeters,
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func FetchVehicleTypesLoop(ctx context.Context, st store.Store, fetcher Fetcher, feedUrl string, feed config.Feed) error {
+	return runFeedLoop(ctx, vehicleTypesFeed, feed, false, func(ctx context.Context) (int64, error) {
+		return FetchVehicleTypesOnce(ctx, st, fetcher, feedUrl, feed.Timeout)
+	})
+}
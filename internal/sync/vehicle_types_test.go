@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ngc7293/hixi/internal/config"
+	"github.com/ngc7293/hixi/internal/gbfstest"
+	"github.com/ngc7293/hixi/internal/store"
+	"github.com/ngc7293/hixi/internal/store/memory"
+	"github.com/ngc7293/hixi/pkg/gbfs/v1_0"
+)
+
+func TestFetchVehicleTypesOnce(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+	operator := gbfstest.NewOperator(t)
+	normalizer, _ := NormalizerFor("generic")
+	now := time.Now().Truncate(time.Second)
+
+	operator.Push("station_information", now, 3600, stationInformation("1"))
+	operator.Push("vehicle_types", now, 3600, v1_0.VehicleTypesData{VehicleTypes: []v1_0.VehicleType{
+		{VehicleTypeID: "bike", FormFactor: v1_0.FormFactorBicycle, PropulsionType: v1_0.PropulsionHuman},
+		{VehicleTypeID: "cargo", FormFactor: v1_0.FormFactorCargoBicycle, PropulsionType: v1_0.PropulsionElectricAssist, Name: ptr("Cargo"), MaxRangeMeters: ptr(40000.0)},
+	}})
+
+	counted := status("1", now, 5, 0, 10)
+	counted.VehicleTypesAvailable = []v1_0.VehicleTypeAvailability{{VehicleTypeID: "bike", Count: 3}, {VehicleTypeID: "cargo", Count: 1}, {VehicleTypeID: "scooter", Count: 1}}
+	operator.Push("station_status", now, 10, statuses(counted))
+
+	fetchOnce(t, st, operator, "station_information", stationInformationOnce("generic"))
+
+	fetchOnce(t, st, operator, "vehicle_types", FetchVehicleTypesOnce)
+
+	// scooter is counted before vehicle_types lists it
+	if _, err := FetchStationStatusOnce(ctx, st, HTTPFetcher{}, operator.FeedURL("station_status"), time.Second, normalizer, config.Default().Validation); err != nil {
+		t.Fatalf("FetchStationStatusOnce() error = %v", err)
+	}
+
+	vehicleTypes, err := st.VehicleTypes().List(ctx)
+
+	if err != nil || len(vehicleTypes) != 3 {
+		t.Fatalf("List() = %+v, %v, want 3 vehicle types", vehicleTypes, err)
+	}
+
+	if cargo := vehicleTypes[1]; cargo.ExternalID != "cargo" || cargo.FormFactor == nil || *cargo.FormFactor != v1_0.FormFactorCargoBicycle ||
+		cargo.PropulsionType == nil || *cargo.PropulsionType != v1_0.PropulsionElectricAssist || cargo.Name == nil || *cargo.Name != "Cargo" {
+		t.Errorf("List()[1] = %+v, want the described cargo bike", cargo)
+	}
+
+	if scooter := vehicleTypes[2]; scooter.ExternalID != "scooter" || scooter.FormFactor != nil {
+		t.Errorf("List()[2] = %+v, want an undescribed scooter", scooter)
+	}
+
+	station, _ := st.Stations().FindOrCreate(ctx, "1")
+	latest, err := st.Availability().Latest(ctx, station.ID)
+	want := []store.VehicleAvailability{
+		{VehicleTypeID: vehicleTypes[0].ID, Available: 3},
+		{VehicleTypeID: vehicleTypes[1].ID, Available: 1},
+		{VehicleTypeID: vehicleTypes[2].ID, Available: 1},
+	}
+
+	if err != nil || !slices.Equal(latest.Vehicles, want) {
+		t.Errorf("Latest() = %+v, %v, want vehicles %+v", latest, err, want)
+	}
+
+	assertSyncedAt(t, st, "vehicle_types", now)
+}
@@ -0,0 +1,396 @@
+package tilecache
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Tile is a cached map tile, as returned by the upstream tile server
+type Tile struct {
+	ContentType string
+	Data        []byte
+	FetchedAt   time.Time
+}
+
+// Fetcher retrieves a tile from upstream on a cache miss
+type Fetcher func(ctx context.Context, key string) (*Tile, error)
+
+type entry struct {
+	key  string
+	size int64
+	tile *Tile // only set for entries of the in-memory hot set
+}
+
+// Cache is a two-level tile cache: a small in-memory hot set in front of a
+// size-bounded directory on disk, both evicted in least-recently-used order.
+//
+// Concurrent misses for the same key are coalesced into a single upstream
+// fetch. Tiles older than maxAge are served as is while they are refreshed in
+// the background (stale-while-revalidate).
+type Cache struct {
+	dir          string
+	maxDiskBytes int64
+	maxHot       int
+	maxAge       time.Duration
+
+	mu        sync.Mutex
+	hot       *list.List
+	hotIndex  map[string]*list.Element
+	disk      *list.List
+	diskIndex map[string]*list.Element
+	diskBytes int64
+
+	group singleflight.Group
+}
+
+// New creates a tile cache. An empty dir disables the disk layer; existing
+// tiles in dir are indexed so the cache survives restarts.
+func New(dir string, maxDiskBytes int64, maxHot int, maxAge time.Duration) (*Cache, error) {
+	cache := &Cache{
+		dir:          dir,
+		maxDiskBytes: maxDiskBytes,
+		maxHot:       maxHot,
+		maxAge:       maxAge,
+		hot:          list.New(),
+		hotIndex:     make(map[string]*list.Element),
+		disk:         list.New(),
+		diskIndex:    make(map[string]*list.Element),
+	}
+
+	if dir == "" {
+		return cache, nil
+	}
+
+	err := os.MkdirAll(dir, 0o755)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tile cache directory: %w", err)
+	}
+
+	err = cache.loadIndex()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to index tile cache directory: %w", err)
+	}
+
+	return cache, nil
+}
+
+func (c *Cache) loadIndex() error {
+	files, err := os.ReadDir(c.dir)
+
+	if err != nil {
+		return err
+	}
+
+	type diskFile struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+
+	found := []diskFile{}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".tile" {
+			continue
+		}
+
+		info, err := file.Info()
+
+		if err != nil {
+			return err
+		}
+
+		found = append(found, diskFile{name: file.Name(), size: info.Size(), modTime: info.ModTime()})
+	}
+
+	// Most recently written tiles go to the front of the LRU list
+	sort.Slice(found, func(i, j int) bool { return found[i].modTime.After(found[j].modTime) })
+
+	for _, file := range found {
+		hash := file.name[:len(file.name)-len(".tile")]
+		c.diskIndex[hash] = c.disk.PushBack(&entry{key: hash, size: file.size})
+		c.diskBytes += file.size
+	}
+
+	c.evictDisk()
+	return nil
+}
+
+// Get returns the tile for key, fetching it from upstream if it is missing. A
+// stale tile is returned immediately and refreshed in the background.
+func (c *Cache) Get(ctx context.Context, key string, fetch Fetcher) (*Tile, error) {
+	cached := c.lookup(key)
+
+	if cached == nil {
+		return c.fetch(ctx, key, fetch)
+	}
+
+	if time.Since(cached.FetchedAt) >= c.maxAge {
+		go func() {
+			_, err := c.fetch(ctx, key, fetch)
+
+			if err != nil {
+				slog.Warn("failed to refresh stale map tile", "key", key, "age", time.Since(cached.FetchedAt), "error", err)
+			}
+		}()
+	}
+
+	return cached, nil
+}
+
+// fetch retrieves key from upstream and stores it, coalescing concurrent
+// fetches of the same key.
+func (c *Cache) fetch(ctx context.Context, key string, fetch Fetcher) (*Tile, error) {
+	result, err, _ := c.group.Do(key, func() (any, error) {
+		// The tile may have been stored by a fetch that completed between
+		// our lookup and joining the group
+		if tile := c.lookup(key); tile != nil && time.Since(tile.FetchedAt) < c.maxAge {
+			return tile, nil
+		}
+
+		// Other requests may be waiting on this fetch, so it must not be
+		// cancelled when the client that triggered it goes away.
+		tile, err := fetch(context.WithoutCancel(ctx), key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.store(key, tile)
+		return tile, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*Tile), nil
+}
+
+func (c *Cache) lookup(key string) *Tile {
+	c.mu.Lock()
+
+	if element, ok := c.hotIndex[key]; ok {
+		c.hot.MoveToFront(element)
+		tile := element.Value.(*entry).tile
+		c.mu.Unlock()
+		return tile
+	}
+
+	hash := hashKey(key)
+	element, ok := c.diskIndex[hash]
+
+	if ok {
+		c.disk.MoveToFront(element)
+	}
+
+	c.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	tile, err := c.readTile(hash)
+
+	if err != nil {
+		slog.Warn("failed to read cached map tile", "key", key, "error", err)
+		c.removeDisk(hash)
+		return nil
+	}
+
+	c.promote(key, tile)
+	return tile
+}
+
+func (c *Cache) store(key string, tile *Tile) {
+	c.promote(key, tile)
+
+	if c.dir == "" {
+		return
+	}
+
+	hash := hashKey(key)
+	size, err := c.writeTile(hash, tile)
+
+	if err != nil {
+		slog.Warn("failed to write cached map tile", "key", key, "error", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.diskIndex[hash]; ok {
+		c.diskBytes -= element.Value.(*entry).size
+		element.Value.(*entry).size = size
+		c.disk.MoveToFront(element)
+	} else {
+		c.diskIndex[hash] = c.disk.PushFront(&entry{key: hash, size: size})
+	}
+
+	c.diskBytes += size
+	c.evictDisk()
+}
+
+// promote inserts tile into the in-memory hot set, evicting the least
+// recently used tiles beyond maxHot
+func (c *Cache) promote(key string, tile *Tile) {
+	if c.maxHot <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.hotIndex[key]; ok {
+		element.Value.(*entry).tile = tile
+		c.hot.MoveToFront(element)
+		return
+	}
+
+	c.hotIndex[key] = c.hot.PushFront(&entry{key: key, size: int64(len(tile.Data)), tile: tile})
+
+	for c.hot.Len() > c.maxHot {
+		oldest := c.hot.Back()
+		c.hot.Remove(oldest)
+		delete(c.hotIndex, oldest.Value.(*entry).key)
+	}
+}
+
+// evictDisk removes the least recently used tiles from disk until the cache
+// fits within maxDiskBytes. Must be called with c.mu held.
+func (c *Cache) evictDisk() {
+	for c.diskBytes > c.maxDiskBytes && c.disk.Len() > 0 {
+		oldest := c.disk.Back()
+		e := oldest.Value.(*entry)
+
+		c.disk.Remove(oldest)
+		delete(c.diskIndex, e.key)
+		c.diskBytes -= e.size
+
+		err := os.Remove(c.path(e.key))
+
+		if err != nil && !os.IsNotExist(err) {
+			slog.Warn("failed to evict cached map tile", "file", c.path(e.key), "error", err)
+		}
+	}
+}
+
+func (c *Cache) removeDisk(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.diskIndex[hash]; ok {
+		c.diskBytes -= element.Value.(*entry).size
+		c.disk.Remove(element)
+		delete(c.diskIndex, hash)
+	}
+
+	_ = os.Remove(c.path(hash))
+}
+
+func (c *Cache) path(hash string) string {
+	return filepath.Join(c.dir, hash+".tile")
+}
+
+// Tiles are stored on disk as the content type on the first line followed by
+// the raw tile data. The file's modification time records when the tile was
+// fetched from upstream.
+func (c *Cache) writeTile(hash string, tile *Tile) (int64, error) {
+	temp, err := os.CreateTemp(c.dir, hash+".*.tmp")
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer os.Remove(temp.Name())
+
+	writer := bufio.NewWriter(temp)
+	_, err = fmt.Fprintf(writer, "%s\n", tile.ContentType)
+
+	if err == nil {
+		_, err = writer.Write(tile.Data)
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.Chtimes(temp.Name(), tile.FetchedAt, tile.FetchedAt)
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.Rename(temp.Name(), c.path(hash))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(tile.ContentType) + 1 + len(tile.Data)), nil
+}
+
+func (c *Cache) readTile(hash string) (*Tile, error) {
+	file, err := os.Open(c.path(hash))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	contentType, err := reader.ReadString('\n')
+
+	if err != nil {
+		return nil, fmt.Errorf("malformed tile header: %w", err)
+	}
+
+	data, err := io.ReadAll(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tile{
+		ContentType: strings.TrimSuffix(contentType, "\n"),
+		Data:        data,
+		FetchedAt:   info.ModTime(),
+	}, nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
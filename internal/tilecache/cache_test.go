@@ -0,0 +1,219 @@
+package tilecache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func staticFetcher(calls *atomic.Int64, data string) Fetcher {
+	return func(ctx context.Context, key string) (*Tile, error) {
+		calls.Add(1)
+		return &Tile{ContentType: "image/png", Data: []byte(data), FetchedAt: time.Now()}, nil
+	}
+}
+
+func TestCacheHitAfterMiss(t *testing.T) {
+	tests := []struct {
+		name   string
+		dir    bool
+		maxHot int
+	}{
+		{name: "memory only", dir: false, maxHot: 16},
+		{name: "disk only", dir: true, maxHot: 0},
+		{name: "memory and disk", dir: true, maxHot: 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := ""
+
+			if tt.dir {
+				dir = t.TempDir()
+			}
+
+			cache, err := New(dir, 1024*1024, tt.maxHot, time.Hour)
+
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			var calls atomic.Int64
+
+			for range 3 {
+				tile, err := cache.Get(context.Background(), "1/2/3", staticFetcher(&calls, "tile"))
+
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+
+				if string(tile.Data) != "tile" || tile.ContentType != "image/png" {
+					t.Errorf("Get() = %q (%s), want %q (image/png)", tile.Data, tile.ContentType, "tile")
+				}
+			}
+
+			if calls.Load() != 1 {
+				t.Errorf("upstream fetched %d times, want 1", calls.Load())
+			}
+		})
+	}
+}
+
+func TestCacheSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	var calls atomic.Int64
+
+	cache, _ := New(dir, 1024*1024, 0, time.Hour)
+	_, _ = cache.Get(context.Background(), "1/2/3", staticFetcher(&calls, "tile"))
+
+	cache, err := New(dir, 1024*1024, 0, time.Hour)
+
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	_, _ = cache.Get(context.Background(), "1/2/3", staticFetcher(&calls, "tile"))
+
+	if calls.Load() != 1 {
+		t.Errorf("upstream fetched %d times, want 1", calls.Load())
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	var calls atomic.Int64
+
+	// Each tile takes len("image/png\n") + 4 = 14 bytes on disk, so only two fit
+	cache, _ := New(t.TempDir(), 28, 0, time.Hour)
+
+	_, _ = cache.Get(context.Background(), "a", staticFetcher(&calls, "aaaa"))
+	_, _ = cache.Get(context.Background(), "b", staticFetcher(&calls, "bbbb"))
+	_, _ = cache.Get(context.Background(), "a", staticFetcher(&calls, "aaaa"))
+	_, _ = cache.Get(context.Background(), "c", staticFetcher(&calls, "cccc"))
+
+	if calls.Load() != 3 {
+		t.Fatalf("upstream fetched %d times, want 3", calls.Load())
+	}
+
+	// "b" was least recently used and must have been evicted, "a" must remain
+	_, _ = cache.Get(context.Background(), "a", staticFetcher(&calls, "aaaa"))
+
+	if calls.Load() != 3 {
+		t.Errorf("recently used tile was evicted")
+	}
+
+	_, _ = cache.Get(context.Background(), "b", staticFetcher(&calls, "bbbb"))
+
+	if calls.Load() != 4 {
+		t.Errorf("least recently used tile was not evicted")
+	}
+}
+
+func TestCacheServesStaleOnUpstreamError(t *testing.T) {
+	var calls atomic.Int64
+	cache, _ := New("", 0, 16, time.Nanosecond)
+
+	_, _ = cache.Get(context.Background(), "1/2/3", staticFetcher(&calls, "tile"))
+	time.Sleep(time.Millisecond)
+
+	tile, err := cache.Get(context.Background(), "1/2/3", func(ctx context.Context, key string) (*Tile, error) {
+		return nil, errors.New("upstream down")
+	})
+
+	if err != nil {
+		t.Fatalf("Get() error = %v, want stale tile", err)
+	}
+
+	if string(tile.Data) != "tile" {
+		t.Errorf("Get() = %q, want %q", tile.Data, "tile")
+	}
+
+	_, err = cache.Get(context.Background(), "4/5/6", func(ctx context.Context, key string) (*Tile, error) {
+		return nil, errors.New("upstream down")
+	})
+
+	if err == nil {
+		t.Errorf("Get() error = nil, want upstream error for uncached tile")
+	}
+}
+
+func TestCacheCoalescesConcurrentMisses(t *testing.T) {
+	var calls atomic.Int64
+	cache, _ := New("", 0, 16, time.Hour)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, key string) (*Tile, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+
+		<-release
+		return &Tile{Data: []byte("tile"), FetchedAt: time.Now()}, nil
+	}
+
+	var wg sync.WaitGroup
+	get := func() {
+		defer wg.Done()
+		_, _ = cache.Get(context.Background(), "1/2/3", fetch)
+	}
+
+	wg.Add(1)
+	go get()
+	<-started
+
+	// The upstream fetch is in flight, every other miss must share it
+	for range 9 {
+		wg.Add(1)
+		go get()
+	}
+
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("upstream fetched %d times, want 1", calls.Load())
+	}
+}
+
+func TestCacheRefreshesStaleInBackground(t *testing.T) {
+	cache, _ := New("", 0, 16, time.Hour)
+
+	_, _ = cache.Get(context.Background(), "1/2/3", func(ctx context.Context, key string) (*Tile, error) {
+		return &Tile{Data: []byte("old"), FetchedAt: time.Now().Add(-2 * time.Hour)}, nil
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	refresh := func(ctx context.Context, key string) (*Tile, error) {
+		close(started)
+		<-release
+		return &Tile{Data: []byte("new"), FetchedAt: time.Now()}, nil
+	}
+
+	// The refresh is blocked on release, so the stale tile must be returned
+	// without waiting for it
+	tile, err := cache.Get(context.Background(), "1/2/3", refresh)
+
+	if err != nil || string(tile.Data) != "old" {
+		t.Fatalf("Get() = %v, %v, want stale tile", tile, err)
+	}
+
+	<-started
+	close(release)
+
+	// Joins the background refresh, or finds the tile it stored
+	tile, err = cache.fetch(context.Background(), "1/2/3", refresh)
+
+	if err != nil || string(tile.Data) != "new" {
+		t.Fatalf("fetch() = %v, %v, want refreshed tile", tile, err)
+	}
+
+	tile, _ = cache.Get(context.Background(), "1/2/3", refresh)
+
+	if string(tile.Data) != "new" {
+		t.Errorf("Get() = %q, want refreshed tile %q", tile.Data, "new")
+	}
+}
@@ -0,0 +1,22 @@
+// Package ui embeds the web UI, built into dist by `npm run build`
+package ui
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+// FS returns the embedded UI, and whether it was built before compiling
+func FS() (fs.FS, bool) {
+	sub, err := fs.Sub(dist, "dist")
+
+	if err != nil {
+		return nil, false
+	}
+
+	_, err = fs.Stat(sub, "index.html")
+	return sub, err == nil
+}
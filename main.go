@@ -1,9 +1,11 @@
 package main
 
 import (
+	"os"
+
 	"github.com/ngc7293/hixi/internal"
 )
 
 func main() {
-	internal.Run()
+	os.Exit(internal.Run(os.Args[1:]))
 }
@@ -1,5 +1,7 @@
 package v1
 
+import "encoding/json"
+
 // ListStationResponse
 // The API response format for the /stations/ endpoint.  The response is a
 // GeoJSON FeatureCollection, where each Feature is a station (Point)
@@ -15,9 +17,32 @@ type StationFeature struct {
 }
 
 type StationFeatureProperties struct {
-	ID     int64  `json:"id"`
-	Name   string `json:"name"`
-	Active bool   `json:"active"`
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	Active         bool   `json:"active"`
+	Decommissioned bool   `json:"decommissioned"` // missing from the operator's station_information
+	StationMetadata
+	Alerts []StationAlert `json:"alerts"` // in effect now
+}
+
+// StationAlert summarizes an alert concerning a station, detailed by /alerts
+type StationAlert struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Summary string `json:"summary"`
+}
+
+// StationMetadata is the part of station_information which is not versioned
+type StationMetadata struct {
+	ShortName                   *string  `json:"short_name"`
+	Address                     *string  `json:"address"`
+	CrossStreet                 *string  `json:"cross_street"`
+	RegionID                    *string  `json:"region_id"`
+	PostCode                    *string  `json:"post_code"`
+	RentalMethods               []string `json:"rental_methods"`
+	HasKiosk                    *bool    `json:"has_kiosk"`
+	ElectricBikeSurchargeWaiver bool     `json:"electric_bike_surcharge_waiver"`
+	IsCharging                  bool     `json:"is_charging"` // the station charges ebikes
 }
 
 type GeoJSONPoint struct {
@@ -26,13 +51,274 @@ type GeoJSONPoint struct {
 }
 
 type GetStationResponse struct {
-	HistoricalAvailability []Availability `json:"historical"`
-	CurrentAvailability    Availability   `json:"current"`
-	Capacity               *int64         `json:"capacity"`
+	Name string `json:"name"`
+	StationMetadata
+	HistoricalAvailability []Availability   `json:"historical"`
+	CurrentAvailability    Availability     `json:"current"`
+	Capacity               *int64           `json:"capacity"`       // current capacity
+	Decommissioned         *int64           `json:"decommissioned"` // when the station was decommissioned, null if in service
+	Versions               []StationVersion `json:"versions"`       // oldest first
+	Alerts                 []Alert          `json:"alerts"`         // in effect now
 }
 
 type Availability struct {
+	Time            int64              `json:"t"`
+	BikesAvailable  float64            `json:"b"` // Availability is averaged within the time bucket, so fractional values are possible
+	EbikesAvailable float64            `json:"eb"`
+	Capacity        *int64             `json:"c"`           // Capacity of the station at the time
+	Vehicles        map[string]float64 `json:"v,omitempty"` // by GBFS vehicle_type_id, for systems publishing vehicle_types_available
+}
+
+// StationVersion is the description of a station while it was valid, from
+// its renames, moves and capacity changes
+type StationVersion struct {
+	ValidFrom   int64      `json:"from"`
+	ValidTo     *int64     `json:"to"` // null for the current version
+	Name        string     `json:"name"`
+	ShortName   *string    `json:"short_name"`
+	Coordinates [2]float64 `json:"coordinates"` // lon, lat
+	Capacity    *int64     `json:"capacity"`
+
+	ElectricBikeSurchargeWaiver bool `json:"electric_bike_surcharge_waiver"`
+}
+
+// HeatmapResponse
+// The API response format for the /heatmap endpoint. The response is a GeoJSON
+// FeatureCollection, where each Feature is a grid cell (Polygon) containing at
+// least one station.
+type HeatmapResponse struct {
+	Type     string           `json:"type"` // always "FeatureCollection"
+	Features []HeatmapFeature `json:"features"`
+}
+
+type HeatmapFeature struct {
+	Type       string                   `json:"type"` // always "Feature"
+	Properties HeatmapFeatureProperties `json:"properties"`
+	Geometry   GeoJSONPolygon           `json:"geometry"`
+}
+
+type HeatmapFeatureProperties struct {
+	Value    float64 `json:"value"`    // Sum of the requested metric over all stations in the cell
+	Stations int64   `json:"stations"` // Number of stations within the cell
+}
+
+type GeoJSONPolygon struct {
+	Type        string         `json:"type"` // always "Polygon"
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+type GeoJSONMultiPolygon struct {
+	Type        string           `json:"type"` // always "MultiPolygon"
+	Coordinates [][][][2]float64 `json:"coordinates"`
+}
+
+// HealthResponse
+// The API response format for the /ready endpoint and the detailed /health
+// endpoint (/health?detail=true)
+type HealthResponse struct {
+	Status string        `json:"status"` // "ok" or "fail"
+	Checks []HealthCheck `json:"checks"`
+}
+
+type HealthCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+	Time    *int64 `json:"t,omitempty"` // Time of the checked event (last sync, latest aggregate bucket), if any
+}
+
+// ListDataQualityIssuesResponse
+// The API response format for the /quality/issues endpoint, most recent first
+type ListDataQualityIssuesResponse struct {
+	Issues []DataQualityIssue `json:"issues"`
+}
+
+type DataQualityIssue struct {
+	Time            int64           `json:"t"`
+	Feed            string          `json:"feed"`
+	SnapshotUpdated int64           `json:"snapshot_updated"` // last_updated of the feed snapshot the record came from
+	StationID       string          `json:"station_id"`       // GBFS station_id
+	Rule            string          `json:"rule"`
+	Message         string          `json:"message"`
+	Quarantined     bool            `json:"quarantined"` // whether the record was dropped rather than stored
+	Record          json.RawMessage `json:"record,omitempty"`
+}
+
+// RetentionResponse
+// The API response format for the /admin/retention endpoint: the retention
+// and compression policies in effect on each hypertable or continuous
+// aggregate
+type RetentionResponse struct {
+	Relations []RetentionStatus `json:"relations"`
+}
+
+type RetentionStatus struct {
+	Relation           string `json:"relation"`
+	DropAfter          *int64 `json:"drop_after"`     // seconds, null if data is kept forever
+	CompressAfter      *int64 `json:"compress_after"` // seconds, null if never compressed
+	CompressionEnabled bool   `json:"compression_enabled"`
+	Chunks             int64  `json:"chunks"`
+	CompressedChunks   int64  `json:"compressed_chunks"`
+	Oldest             *int64 `json:"oldest"` // start of the oldest chunk, null without data
+}
+
+// ListRegionsResponse
+// The API response format for the /regions endpoint
+type ListRegionsResponse struct {
+	Regions []Region `json:"regions"`
+}
+
+type Region struct {
+	ID       int64   `json:"id"`
+	RegionID *string `json:"region_id"` // from system_regions, null for custom regions
+	Name     string  `json:"name"`
+	Custom   bool    `json:"custom"`   // uploaded polygon, containing the stations located within it
+	Stations int64   `json:"stations"` // stations in service within the region
+}
+
+// RegionAvailabilityResponse
+// The API response format for the /regions/{regionId}/availability endpoint
+type RegionAvailabilityResponse struct {
+	Region       Region               `json:"region"`
+	Availability []RegionAvailability `json:"availability"`
+}
+
+// RegionAvailability sums the average availability of each station within a
+// time bucket
+type RegionAvailability struct {
 	Time            int64   `json:"t"`
-	BikesAvailable  float64 `json:"b"` // Availability is averaged within the time bucket, so fractional values are possible
+	Stations        int64   `json:"n"` // stations with data in the bucket
+	BikesAvailable  float64 `json:"b"`
 	EbikesAvailable float64 `json:"eb"`
+	DocksAvailable  float64 `json:"d"`
+}
+
+// ListAlertsResponse
+// The API response format for the /alerts endpoint
+type ListAlertsResponse struct {
+	Alerts []Alert `json:"alerts"`
+}
+
+// Alert is an announcement of the operator's system_alerts feed
+type Alert struct {
+	ID          string        `json:"id"`   // GBFS alert_id
+	Type        string        `json:"type"` // SYSTEM_CLOSURE, STATION_CLOSURE, STATION_MOVE or OTHER
+	Times       []AlertWindow `json:"times"`
+	StationIDs  []string      `json:"station_ids"` // GBFS station_ids, empty unless limited to them
+	RegionIDs   []string      `json:"region_ids"`  // GBFS region_ids, empty unless limited to them
+	URL         *string       `json:"url"`
+	Summary     string        `json:"summary"`
+	Description *string       `json:"description"`
+	LastUpdated *int64        `json:"last_updated"`
+	Withdrawn   *int64        `json:"withdrawn"` // when the alert left the feed, null while published
+	Active      bool          `json:"active"`    // in effect now
+}
+
+type AlertWindow struct {
+	Start int64  `json:"start"`
+	End   *int64 `json:"end"` // null if open-ended
+}
+
+// ListVehicleTypesResponse
+// The API response format for the /vehicle_types endpoint
+type ListVehicleTypesResponse struct {
+	VehicleTypes []VehicleType `json:"vehicle_types"`
+}
+
+// VehicleType describes the vehicles counted under its id by Availability
+type VehicleType struct {
+	ID             string   `json:"id"`              // GBFS vehicle_type_id
+	FormFactor     *string  `json:"form_factor"`     // bicycle, cargo_bicycle, car, moped, scooter or other, null until listed in vehicle_types
+	PropulsionType *string  `json:"propulsion_type"` // human, electric_assist, electric or combustion, null until listed in vehicle_types
+	Name           *string  `json:"name"`
+	MaxRangeMeters *float64 `json:"max_range_meters"`
+}
+
+// ListPricingPlansResponse
+// The API response format for the /pricing_plans endpoint
+type ListPricingPlansResponse struct {
+	PricingPlans []PricingPlan `json:"pricing_plans"`
+}
+
+// PricingPlan is a version of a plan of the operator's system_pricing_plans
+// feed
+type PricingPlan struct {
+	ID            string           `json:"id"` // GBFS plan_id
+	ValidFrom     int64            `json:"from"`
+	ValidTo       *int64           `json:"to"` // null for the current version
+	Name          string           `json:"name"`
+	Currency      string           `json:"currency"`
+	Price         float64          `json:"price"`
+	IsTaxable     bool             `json:"is_taxable"`
+	Description   string           `json:"description"`
+	URL           *string          `json:"url"`
+	PerKmPricing  []PricingSegment `json:"per_km_pricing"`
+	PerMinPricing []PricingSegment `json:"per_min_pricing"`
+}
+
+// PricingSegment charges rate every interval (km or minutes) from start
+type PricingSegment struct {
+	Start    int64   `json:"start"`
+	Rate     float64 `json:"rate"`
+	Interval int64   `json:"interval"`
+	End      *int64  `json:"end"` // null until the end of the trip
+}
+
+// EbikeDepletionResponse
+// The API response format for the /ebike_depletion endpoint, comparing
+// stations waiving the ebike surcharge with the others
+type EbikeDepletionResponse struct {
+	From         int64          `json:"from"`
+	To           int64          `json:"to"`
+	Waiver       EbikeDepletion `json:"waiver"`
+	NoWaiver     EbikeDepletion `json:"no_waiver"`
+	PricingPlans []PricingPlan  `json:"pricing_plans"` // versions in effect between from and to
+}
+
+type EbikeDepletion struct {
+	Stations        int64   `json:"stations"`
+	EbikesAvailable float64 `json:"ebikes_available"` // average per station
+	Departures      float64 `json:"departures"`       // ebikes taken per station and hour
+}
+
+// GeofencingZonesResponse
+// The API response format for the /geofencing_zones endpoint. The response is
+// a GeoJSON FeatureCollection of the zones of the operator's geofencing_zones
+// feed, by precedence: where zones overlap, the first one applies.
+type GeofencingZonesResponse struct {
+	Type     string                  `json:"type"` // always "FeatureCollection"
+	Features []GeofencingZoneFeature `json:"features"`
+}
+
+type GeofencingZoneFeature struct {
+	Type       string              `json:"type"` // always "Feature"
+	Properties GeofencingZone      `json:"properties"`
+	Geometry   GeoJSONMultiPolygon `json:"geometry"`
+}
+
+type GeofencingZone struct {
+	Name   *string          `json:"name"`
+	Start  *int64           `json:"start"`  // null if in effect from the start
+	End    *int64           `json:"end"`    // null if open-ended
+	Active bool             `json:"active"` // in effect now
+	Rules  []GeofencingRule `json:"rules"`
+}
+
+// GeofencingRule restricts the listed vehicle types, or every vehicle if none
+// is listed. The first rule of a zone matching a vehicle applies.
+type GeofencingRule struct {
+	VehicleTypeIDs     []string `json:"vehicle_type_ids"` // GBFS vehicle_type_ids
+	RideAllowed        bool     `json:"ride_allowed"`
+	RideThroughAllowed bool     `json:"ride_through_allowed"`
+	MaximumSpeedKph    *int64   `json:"maximum_speed_kph"`
+	StationParking     *bool    `json:"station_parking"` // must park at a station, null if unspecified
+}
+
+// GeofencingRulesResponse
+// The API response format for the /geofencing_zones/rules endpoint
+type GeofencingRulesResponse struct {
+	Coordinates   [2]float64       `json:"coordinates"` // lon, lat
+	VehicleTypeID *string          `json:"vehicle_type_id"`
+	Zones         []GeofencingZone `json:"zones"` // zones containing the point in effect, by precedence
+	Rule          *GeofencingRule  `json:"rule"`  // rule of the first zone applying to the vehicle type, null if unrestricted
 }
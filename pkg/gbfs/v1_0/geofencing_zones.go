@@ -0,0 +1,40 @@
+package v1_0
+
+import "encoding/json"
+
+// geofencing_zones was introduced by GBFS 2.1. Zones are GeoJSON features;
+// where they overlap, the zone listed first takes precedence.
+
+type GeofencingZonesData struct {
+	GeofencingZones GeofencingZones `json:"geofencing_zones"`
+}
+
+type GeofencingZones struct {
+	Type     string           `json:"type"` // FeatureCollection
+	Features []GeofencingZone `json:"features"`
+}
+
+type GeofencingZone struct {
+	Type       string                   `json:"type"`     // Feature
+	Geometry   json.RawMessage          `json:"geometry"` // MultiPolygon
+	Properties GeofencingZoneProperties `json:"properties"`
+}
+
+type GeofencingZoneProperties struct {
+	Name  *string          `json:"name"`
+	Start *int64           `json:"start"` // in effect from, always if nil
+	End   *int64           `json:"end"`   // in effect until, always if nil
+	Rules []GeofencingRule `json:"rules"`
+}
+
+// GeofencingRule restricts the vehicles of the listed types, or every
+// vehicle if none is listed. The first rule matching a vehicle applies.
+type GeofencingRule struct {
+	VehicleTypeIDs     []string `json:"vehicle_type_id"`
+	RideAllowed        Bool     `json:"ride_allowed"`
+	RideThroughAllowed Bool     `json:"ride_through_allowed"`
+	MaximumSpeedKph    *int64   `json:"maximum_speed_kph"`
+
+	// GBFS 2.3+
+	StationParking *Bool `json:"station_parking"`
+}
@@ -16,11 +16,4 @@ type StationInformationStation struct {
 	PostCode      *string  `json:"post_code"`
 	RentalMethods []string `json:"rental_methods"`
 	Capacity      *int64   `json:"capacity"`
-
-	// Non-standard fields (Bixi)
-	ExternalID                  *string `json:"external_id"`
-	EightdHasKeyDispenser       *bool   `json:"eightd_has_key_dispenser"`
-	HasKiosk                    *bool   `json:"has_kiosk"`
-	ElectricBikeSurchargeWaiver bool    `json:"electric_bike_surcharge_waiver"`
-	IsCharging                  bool    `json:"is_charging"`
 }
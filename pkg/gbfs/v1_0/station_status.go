@@ -10,12 +10,11 @@ type StationStatus struct {
 	NumBikesDisabled  *int64 `json:"num_bikes_disabled"`
 	NumDocksDisabled  *int64 `json:"num_docks_disabled"`
 	NumDocksAvailable int64  `json:"num_docks_available"`
-	IsInstalled       int64  `json:"is_installed"`
-	IsRenting         int64  `json:"is_renting"`
-	IsReturning       int64  `json:"is_returning"`
+	IsInstalled       Bool   `json:"is_installed"`
+	IsRenting         Bool   `json:"is_renting"`
+	IsReturning       Bool   `json:"is_returning"`
 	LastReported      int64  `json:"last_reported"`
 
-	// Non-standard fields (Bixi)
-	NumEbikesAvailable *int64 `json:"num_ebikes_available"`
-	NumEbikesDisabled  *int64 `json:"num_ebikes_disabled"`
+	// GBFS 2.1+
+	VehicleTypesAvailable []VehicleTypeAvailability `json:"vehicle_types_available"`
 }
@@ -0,0 +1,30 @@
+package v1_0
+
+// Alert types
+const (
+	AlertSystemClosure  = "SYSTEM_CLOSURE"
+	AlertStationClosure = "STATION_CLOSURE"
+	AlertStationMove    = "STATION_MOVE"
+	AlertOther          = "OTHER"
+)
+
+type SystemAlertsData struct {
+	Alerts []SystemAlert `json:"alerts"`
+}
+
+type SystemAlert struct {
+	AlertID     string            `json:"alert_id"`
+	Type        string            `json:"type"`
+	Times       []SystemAlertTime `json:"times"`
+	StationIDs  []string          `json:"station_ids"`
+	RegionIDs   []string          `json:"region_ids"`
+	URL         *string           `json:"url"`
+	Summary     string            `json:"summary"`
+	Description *string           `json:"description"`
+	LastUpdated *int64            `json:"last_updated"`
+}
+
+type SystemAlertTime struct {
+	Start int64  `json:"start"`
+	End   *int64 `json:"end"`
+}
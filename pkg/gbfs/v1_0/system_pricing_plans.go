@@ -0,0 +1,47 @@
+package v1_0
+
+import "fmt"
+
+type SystemPricingPlansData struct {
+	Plans []PricingPlan `json:"plans"`
+}
+
+type PricingPlan struct {
+	PlanID      string  `json:"plan_id"`
+	URL         *string `json:"url"`
+	Name        string  `json:"name"`
+	Currency    string  `json:"currency"`
+	Price       float64 `json:"price"`
+	IsTaxable   Bool    `json:"is_taxable"`
+	Description string  `json:"description"`
+
+	// GBFS 2.0+
+	PerKmPricing  []PricingSegment `json:"per_km_pricing"`
+	PerMinPricing []PricingSegment `json:"per_min_pricing"`
+}
+
+// PricingSegment charges rate every interval (km or minutes) from start
+// until end, or the end of the trip if nil
+type PricingSegment struct {
+	Start    int64   `json:"start"`
+	Rate     float64 `json:"rate"`
+	Interval int64   `json:"interval"`
+	End      *int64  `json:"end"`
+}
+
+// Bool is a GBFS boolean, published as 0 or 1 by GBFS 1.x and as true or
+// false since
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", "1":
+		*b = true
+	case "false", "0":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+package v1_0
+
+type SystemRegionsData struct {
+	Regions []SystemRegion `json:"regions"`
+}
+
+type SystemRegion struct {
+	RegionID string `json:"region_id"`
+	Name     string `json:"name"`
+}
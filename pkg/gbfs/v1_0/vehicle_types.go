@@ -0,0 +1,40 @@
+package v1_0
+
+// vehicle_types was introduced by GBFS 2.1, but only adds optional fields to
+// the other feeds, which v1.x feeds may publish as well
+
+// Vehicle form factors
+const (
+	FormFactorBicycle      = "bicycle"
+	FormFactorCargoBicycle = "cargo_bicycle"
+	FormFactorCar          = "car"
+	FormFactorMoped        = "moped"
+	FormFactorScooter      = "scooter"
+	FormFactorOther        = "other"
+)
+
+// Vehicle propulsion types
+const (
+	PropulsionHuman          = "human"
+	PropulsionElectricAssist = "electric_assist"
+	PropulsionElectric       = "electric"
+	PropulsionCombustion     = "combustion"
+)
+
+type VehicleTypesData struct {
+	VehicleTypes []VehicleType `json:"vehicle_types"`
+}
+
+type VehicleType struct {
+	VehicleTypeID  string   `json:"vehicle_type_id"`
+	FormFactor     string   `json:"form_factor"`
+	PropulsionType string   `json:"propulsion_type"`
+	MaxRangeMeters *float64 `json:"max_range_meters"`
+	Name           *string  `json:"name"`
+}
+
+// VehicleTypeAvailability counts the vehicles of one type at a station
+type VehicleTypeAvailability struct {
+	VehicleTypeID string `json:"vehicle_type_id"`
+	Count         int64  `json:"count"`
+}
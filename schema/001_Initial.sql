@@ -56,13 +56,13 @@ SELECT ADD_RETENTION_POLICY(
 
 ---- create above / drop below ----
 
-DROP INDEX "idx_station_external_id";
-DROP TABLE "public"."station";
-
-SELECT REMOVE_RETENTION_POLICY('public.live_station_availability'::REGCLASS);
-SELECT REMOVE_CONTINUOUS_AGGREGATE_POLICY('public.historical_station_availability'::REGCLASS);
+-- Dropping the aggregate and hypertable also removes their policies, which
+-- `hixi retention apply` may have changed
 DROP MATERIALIZED VIEW "public"."historical_station_availability";
 DROP TABLE "public"."live_station_availability";
 
-DROP EXTENSION postgis;
-DROP EXTENSION timescaledb CASCADE;
\ No newline at end of file
+DROP INDEX "public"."idx_station_external_id_uniq";
+DROP TABLE "public"."station";
+
+-- The extensions are left installed: they may predate this migration, which
+-- only creates them if missing
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE "public"."sync_state"
+(
+    "feed"         TEXT PRIMARY KEY,
+    "last_success" TIMESTAMP WITH TIME ZONE NOT NULL,
+    "last_updated" TIMESTAMP WITH TIME ZONE
+);
+
+---- create above / drop below ----
+
+DROP TABLE "public"."sync_state";
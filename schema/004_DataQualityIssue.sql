@@ -0,0 +1,19 @@
+CREATE TABLE "public"."data_quality_issue"
+(
+    "id"                  BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    "time"                TIMESTAMP WITH TIME ZONE NOT NULL,
+    "feed"                TEXT                     NOT NULL,
+    "snapshot_updated"    TIMESTAMP WITH TIME ZONE NOT NULL,
+    "station_external_id" TEXT                     NOT NULL,
+    "rule"                TEXT                     NOT NULL,
+    "message"             TEXT                     NOT NULL,
+    "quarantined"         BOOLEAN                  NOT NULL,
+    "record"              JSONB
+);
+
+CREATE INDEX "idx_data_quality_issue_time" ON "public"."data_quality_issue" ("time" DESC);
+CREATE INDEX "idx_data_quality_issue_station" ON "public"."data_quality_issue" ("station_external_id", "time" DESC);
+
+---- create above / drop below ----
+
+DROP TABLE "public"."data_quality_issue";
@@ -0,0 +1,21 @@
+CREATE TABLE "public"."raw_document"
+(
+    "hash"    TEXT PRIMARY KEY,
+    "content" BYTEA NOT NULL
+);
+
+CREATE TABLE "public"."raw_snapshot"
+(
+    "feed"       TEXT                     NOT NULL,
+    "fetched_at" TIMESTAMP WITH TIME ZONE NOT NULL,
+    "url"        TEXT                     NOT NULL,
+    "hash"       TEXT                     NOT NULL,
+
+    PRIMARY KEY ("feed", "fetched_at"),
+    FOREIGN KEY ("hash") REFERENCES "public"."raw_document" ("hash")
+);
+
+---- create above / drop below ----
+
+DROP TABLE "public"."raw_snapshot";
+DROP TABLE "public"."raw_document";
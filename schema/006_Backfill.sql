@@ -0,0 +1,30 @@
+ALTER TABLE "public"."station" ADD COLUMN "short_name" TEXT NULL;
+
+-- Departures and arrivals per station, in 5 minute buckets
+CREATE TABLE "public"."station_flow"
+(
+    "time"       TIMESTAMP WITH TIME ZONE NOT NULL,
+    "station_id" BIGINT                   NOT NULL,
+    "departures" INTEGER                  NOT NULL DEFAULT 0,
+    "arrivals"   INTEGER                  NOT NULL DEFAULT 0,
+
+    PRIMARY KEY ("station_id", "time"),
+    FOREIGN KEY ("station_id") REFERENCES "public"."station" ("id") ON DELETE CASCADE
+);
+SELECT CREATE_HYPERTABLE('public.station_flow'::REGCLASS, 'time');
+
+-- Files imported by `hixi backfill`, by content hash, so imports can be re-run
+CREATE TABLE "public"."backfill_file"
+(
+    "hash"        TEXT PRIMARY KEY,
+    "kind"        TEXT                     NOT NULL,
+    "name"        TEXT                     NOT NULL,
+    "rows"        BIGINT                   NOT NULL,
+    "imported_at" TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+---- create above / drop below ----
+
+DROP TABLE "public"."backfill_file";
+DROP TABLE "public"."station_flow";
+ALTER TABLE "public"."station" DROP COLUMN "short_name";
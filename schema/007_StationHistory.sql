@@ -0,0 +1,29 @@
+-- Every version of each station's description, valid from "valid_from" until
+-- "valid_to" (excluded). A station whose latest version has ended is
+-- decommissioned.
+CREATE TABLE "public"."station_history"
+(
+    "station_id" BIGINT                   NOT NULL,
+    "valid_from" TIMESTAMP WITH TIME ZONE NOT NULL,
+    "valid_to"   TIMESTAMP WITH TIME ZONE,
+    "name"       TEXT                     NOT NULL,
+    "short_name" TEXT,
+    "location"   GEOMETRY(POINT, 4326)    NOT NULL,
+    "capacity"   INTEGER,
+
+    PRIMARY KEY ("station_id", "valid_from"),
+    FOREIGN KEY ("station_id") REFERENCES "public"."station" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX "idx_station_history_current" ON "public"."station_history" ("station_id") WHERE "valid_to" IS NULL;
+
+-- When the current descriptions became valid is unknown, so they are valid
+-- since the epoch
+INSERT INTO "public"."station_history" ("station_id", "valid_from", "name", "short_name", "location", "capacity")
+SELECT "id", TO_TIMESTAMP(0), "name", "short_name", "location", "capacity"
+FROM "public"."station"
+WHERE "name" IS NOT NULL AND "location" IS NOT NULL;
+
+---- create above / drop below ----
+
+DROP TABLE "public"."station_history";
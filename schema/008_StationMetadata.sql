@@ -0,0 +1,23 @@
+-- The rest of station_information, as of the latest sync. Unlike the name,
+-- location and capacity, it is not versioned in station_history.
+ALTER TABLE "public"."station"
+    ADD COLUMN "address"                        TEXT,
+    ADD COLUMN "cross_street"                   TEXT,
+    ADD COLUMN "region_id"                      TEXT,
+    ADD COLUMN "post_code"                      TEXT,
+    ADD COLUMN "rental_methods"                 TEXT[]  NOT NULL DEFAULT '{}',
+    ADD COLUMN "has_kiosk"                      BOOLEAN,
+    ADD COLUMN "electric_bike_surcharge_waiver" BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN "is_charging"                    BOOLEAN NOT NULL DEFAULT FALSE;
+
+---- create above / drop below ----
+
+ALTER TABLE "public"."station"
+    DROP COLUMN "address",
+    DROP COLUMN "cross_street",
+    DROP COLUMN "region_id",
+    DROP COLUMN "post_code",
+    DROP COLUMN "rental_methods",
+    DROP COLUMN "has_kiosk",
+    DROP COLUMN "electric_bike_surcharge_waiver",
+    DROP COLUMN "is_charging";
@@ -0,0 +1,20 @@
+-- Regions of the system_regions feed, which stations reference by region_id,
+-- and custom regions, which contain the stations located within their
+-- geometry
+CREATE TABLE "public"."region"
+(
+    "id"          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    "external_id" TEXT UNIQUE,
+    "name"        TEXT                       NOT NULL,
+    "geometry"    GEOMETRY(MULTIPOLYGON, 4326),
+
+    CHECK (("external_id" IS NULL) <> ("geometry" IS NULL))
+);
+
+CREATE INDEX "idx_region_geometry" ON "public"."region" USING GIST ("geometry");
+CREATE INDEX "idx_station_region_id" ON "public"."station" ("region_id");
+
+---- create above / drop below ----
+
+DROP INDEX "public"."idx_station_region_id";
+DROP TABLE "public"."region";
@@ -0,0 +1,21 @@
+-- Alerts of the system_alerts feed. "times" holds the windows the alert is in
+-- effect, as [{"start": <unix>, "end": <unix or null>}]. Alerts which leave
+-- the feed are kept, withdrawn.
+CREATE TABLE "public"."system_alert"
+(
+    "id"           BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    "external_id"  TEXT                     NOT NULL UNIQUE,
+    "type"         TEXT                     NOT NULL,
+    "times"        JSONB                    NOT NULL DEFAULT '[]',
+    "station_ids"  TEXT[]                   NOT NULL DEFAULT '{}',
+    "region_ids"   TEXT[]                   NOT NULL DEFAULT '{}',
+    "url"          TEXT,
+    "summary"      TEXT                     NOT NULL,
+    "description"  TEXT,
+    "last_updated" TIMESTAMP WITH TIME ZONE,
+    "withdrawn"    TIMESTAMP WITH TIME ZONE
+);
+
+---- create above / drop below ----
+
+DROP TABLE "public"."system_alert";
@@ -0,0 +1,50 @@
+-- Vehicle types of the vehicle_types feed. Types counted in station_status
+-- before being listed have no description yet.
+CREATE TABLE "public"."vehicle_type"
+(
+    "id"               BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    "external_id"      TEXT NOT NULL UNIQUE,
+    "form_factor"      TEXT,
+    "propulsion_type"  TEXT,
+    "name"             TEXT,
+    "max_range_meters" DOUBLE PRECISION
+);
+
+-- Vehicles available per station and vehicle type, from
+-- vehicle_types_available, one row per type
+CREATE TABLE "public"."live_station_vehicle_availability"
+(
+    "time"            TIMESTAMP WITH TIME ZONE NOT NULL,
+    "station_id"      BIGINT                   NOT NULL,
+    "vehicle_type_id" BIGINT                   NOT NULL,
+    "available"       INTEGER                  NOT NULL,
+
+    FOREIGN KEY ("station_id") REFERENCES "public"."station" ("id") ON DELETE CASCADE,
+    FOREIGN KEY ("vehicle_type_id") REFERENCES "public"."vehicle_type" ("id") ON DELETE CASCADE
+);
+SELECT CREATE_HYPERTABLE('public.live_station_vehicle_availability'::REGCLASS, 'time');
+
+CREATE INDEX "idx_live_station_vehicle_availability_station" ON "public"."live_station_vehicle_availability" ("station_id", "time" DESC);
+
+CREATE MATERIALIZED VIEW "public"."historical_station_vehicle_availability"
+    WITH (timescaledb.continuous) AS
+SELECT TIME_BUCKET(INTERVAL '5 minutes', "time") AS time_bucket,
+   "station_id",
+   "vehicle_type_id",
+   AVG("available") AS available
+FROM "public"."live_station_vehicle_availability"
+GROUP BY "time_bucket", "station_id", "vehicle_type_id"
+WITH NO DATA;
+
+SELECT ADD_CONTINUOUS_AGGREGATE_POLICY(
+   CONTINUOUS_AGGREGATE => 'public.historical_station_vehicle_availability'::REGCLASS,
+   START_OFFSET => '90 minutes'::INTERVAL,
+   END_OFFSET => NULL,
+   SCHEDULE_INTERVAL => '5 minutes'::INTERVAL
+);
+
+---- create above / drop below ----
+
+DROP MATERIALIZED VIEW "public"."historical_station_vehicle_availability";
+DROP TABLE "public"."live_station_vehicle_availability";
+DROP TABLE "public"."vehicle_type";
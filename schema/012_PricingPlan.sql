@@ -0,0 +1,34 @@
+-- The ebike surcharge waiver is versioned with the rest of the station's
+-- description. Past versions take the current value, the best we know.
+ALTER TABLE "public"."station_history" ADD COLUMN "electric_bike_surcharge_waiver" BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE "public"."station_history" SET "electric_bike_surcharge_waiver" = "station"."electric_bike_surcharge_waiver"
+FROM "public"."station"
+WHERE "station"."id" = "station_history"."station_id";
+
+-- Every version of each plan of the system_pricing_plans feed, valid from
+-- "valid_from" until "valid_to" (excluded). A plan whose latest version has
+-- ended was withdrawn.
+CREATE TABLE "public"."pricing_plan"
+(
+    "plan_id"         TEXT                     NOT NULL,
+    "valid_from"      TIMESTAMP WITH TIME ZONE NOT NULL,
+    "valid_to"        TIMESTAMP WITH TIME ZONE,
+    "name"            TEXT                     NOT NULL,
+    "currency"        TEXT                     NOT NULL,
+    "price"           DOUBLE PRECISION         NOT NULL,
+    "is_taxable"      BOOLEAN                  NOT NULL,
+    "description"     TEXT                     NOT NULL,
+    "url"             TEXT,
+    "per_km_pricing"  JSONB                    NOT NULL DEFAULT '[]',
+    "per_min_pricing" JSONB                    NOT NULL DEFAULT '[]',
+
+    PRIMARY KEY ("plan_id", "valid_from")
+);
+
+CREATE UNIQUE INDEX "idx_pricing_plan_current" ON "public"."pricing_plan" ("plan_id") WHERE "valid_to" IS NULL;
+
+---- create above / drop below ----
+
+DROP TABLE "public"."pricing_plan";
+ALTER TABLE "public"."station_history" DROP COLUMN "electric_bike_surcharge_waiver";
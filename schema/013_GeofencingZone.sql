@@ -0,0 +1,20 @@
+-- Zones of the geofencing_zones feed, replaced on every sync. "position" is
+-- the order of the feed: where zones overlap, the lowest takes precedence.
+-- "rules" holds the feed's rules, as [{"vehicle_type_ids": [...],
+-- "ride_allowed": ..., "ride_through_allowed": ..., "maximum_speed_kph": ...,
+-- "station_parking": ...}].
+CREATE TABLE "public"."geofencing_zone"
+(
+    "position" INTEGER PRIMARY KEY,
+    "name"     TEXT,
+    "start"    TIMESTAMP WITH TIME ZONE,
+    "end"      TIMESTAMP WITH TIME ZONE,
+    "rules"    JSONB                        NOT NULL DEFAULT '[]',
+    "geometry" GEOMETRY(MULTIPOLYGON, 4326) NOT NULL
+);
+
+CREATE INDEX "idx_geofencing_zone_geometry" ON "public"."geofencing_zone" USING GIST ("geometry");
+
+---- create above / drop below ----
+
+DROP TABLE "public"."geofencing_zone";
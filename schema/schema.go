@@ -0,0 +1,25 @@
+// Package schema embeds the database migrations, applied in order by tern
+package schema
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/jackc/tern/v2/migrate"
+)
+
+//go:embed *.sql
+var Migrations embed.FS
+
+// Version is the version of the schema once every migration in migrations
+// is applied
+func Version(migrations fs.FS) (int32, error) {
+	paths, err := migrate.FindMigrations(migrations)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to find migrations: %w", err)
+	}
+
+	return int32(len(paths)), nil
+}
@@ -3,6 +3,11 @@ import { svelte } from '@sveltejs/vite-plugin-svelte'
 
 export default defineConfig({
   plugins: [svelte()],
+  build: {
+    // Embedded into the binary by internal/ui
+    outDir: 'internal/ui/dist',
+    emptyOutDir: true
+  },
   server: {
     proxy: {
       '/stations': 'http://localhost:8080'